@@ -0,0 +1,14 @@
+// +build !linux,!darwin,!freebsd,!dragonfly,!windows
+
+package filestore
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// available reports an error on platforms the filestore cannot read the free
+// disk space on, so space cannot be reserved there
+func (fs fileStore) available() (uint64, error) {
+	return 0, fmt.Errorf("error getting %s disk usage: not supported on %s", fs.base, runtime.GOOS)
+}
@@ -0,0 +1,18 @@
+// +build linux darwin freebsd dragonfly
+
+package filestore
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// available returns the number of bytes available to unprivileged users on
+// the disk holding the filestore
+func (fs fileStore) available() (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(fs.base, &st); err != nil {
+		return 0, fmt.Errorf("error getting %s disk usage: %s", fs.base, err.Error())
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
@@ -0,0 +1,25 @@
+package filestore
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// available returns the number of bytes available to the user running the
+// filestore, taking disk quotas into account, on the disk holding the
+// filestore
+func (fs fileStore) available() (uint64, error) {
+	dir, err := syscall.UTF16PtrFromString(fs.base)
+	if err != nil {
+		return 0, fmt.Errorf("error getting %s disk usage: %s", fs.base, err.Error())
+	}
+	var freeBytesAvailable uint64
+	ok, _, err := getDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(dir)), uintptr(unsafe.Pointer(&freeBytesAvailable)), 0, 0)
+	if ok == 0 {
+		return 0, fmt.Errorf("error getting %s disk usage: %s", fs.base, err.Error())
+	}
+	return freeBytesAvailable, nil
+}
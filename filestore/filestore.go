@@ -7,10 +7,14 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sync"
 )
 
 type fileStore struct {
 	base string
+
+	lk           *sync.Mutex
+	reservations map[string]uint64
 }
 
 // NewLocalFileStore creates a filestore mounted on a given local directory path
@@ -23,7 +27,11 @@ func NewLocalFileStore(basedirectory OsPath) (FileStore, error) {
 	if !info.IsDir() {
 		return nil, fmt.Errorf("%s is not a directory", base)
 	}
-	return &fileStore{string(base)}, nil
+	return &fileStore{
+		base:         string(base),
+		lk:           &sync.Mutex{},
+		reservations: make(map[string]uint64),
+	}, nil
 }
 
 func (fs fileStore) filename(p Path) string {
@@ -73,3 +81,70 @@ func (fs fileStore) CreateTemp() (File, error) {
 	filename := filepath.Base(f.Name())
 	return &fd{File: f, basepath: fs.base, filename: filename}, nil
 }
+
+func (fs fileStore) Reserve(key string, size uint64) error {
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+
+	if _, ok := fs.reservations[key]; ok {
+		return nil
+	}
+
+	available, err := fs.available()
+	if err != nil {
+		return err
+	}
+	reserved := fs.reserved()
+	if available < reserved+size {
+		return fmt.Errorf("%w: requested %d bytes, %d bytes available and %d bytes reserved", ErrNotEnoughSpace, size, available, reserved)
+	}
+
+	fs.reservations[key] = size
+	return nil
+}
+
+func (fs fileStore) Release(key string) {
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+
+	delete(fs.reservations, key)
+}
+
+func (fs fileStore) Usage() (Usage, error) {
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+
+	available, err := fs.available()
+	if err != nil {
+		return Usage{}, err
+	}
+
+	var used uint64
+	err = filepath.Walk(fs.base, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			used += uint64(info.Size())
+		}
+		return nil
+	})
+	if err != nil {
+		return Usage{}, fmt.Errorf("error measuring %s: %s", fs.base, err.Error())
+	}
+
+	reserved := fs.reserved()
+	var free uint64
+	if available > reserved {
+		free = available - reserved
+	}
+	return Usage{Reserved: reserved, Used: used, Free: free}, nil
+}
+
+func (fs fileStore) reserved() uint64 {
+	var reserved uint64
+	for _, size := range fs.reservations {
+		reserved += size
+	}
+	return reserved
+}
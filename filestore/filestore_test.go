@@ -2,6 +2,7 @@ package filestore
 
 import (
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -176,3 +177,31 @@ func Test_CopyFile(t *testing.T) {
 	err = store.Delete(newPath)
 	require.NoError(t, err)
 }
+
+func Test_ReserveAndRelease(t *testing.T) {
+	store, err := NewLocalFileStore(baseDir)
+	require.NoError(t, err)
+
+	usage, err := store.Usage()
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), usage.Reserved)
+	require.NotEqual(t, uint64(0), usage.Used)
+
+	err = store.Reserve("deal", 1024)
+	require.NoError(t, err)
+	// reserving the same key again does not reserve more space
+	err = store.Reserve("deal", 1024)
+	require.NoError(t, err)
+
+	reservedUsage, err := store.Usage()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1024), reservedUsage.Reserved)
+
+	err = store.Reserve("too big", 1<<62)
+	require.True(t, errors.Is(err, ErrNotEnoughSpace))
+
+	store.Release("deal")
+	usage, err = store.Usage()
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), usage.Reserved)
+}
@@ -96,6 +96,25 @@ func (_m *FileStore) Open(p filestore.Path) (filestore.File, error) {
 	return r0, r1
 }
 
+// Release provides a mock function with given fields: key
+func (_m *FileStore) Release(key string) {
+	_m.Called(key)
+}
+
+// Reserve provides a mock function with given fields: key, size
+func (_m *FileStore) Reserve(key string, size uint64) error {
+	ret := _m.Called(key, size)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, uint64) error); ok {
+		r0 = rf(key, size)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Store provides a mock function with given fields: p, f
 func (_m *FileStore) Store(p filestore.Path, f filestore.File) (filestore.Path, error) {
 	ret := _m.Called(p, f)
@@ -116,3 +135,24 @@ func (_m *FileStore) Store(p filestore.Path, f filestore.File) (filestore.Path,
 
 	return r0, r1
 }
+
+// Usage provides a mock function with given fields:
+func (_m *FileStore) Usage() (filestore.Usage, error) {
+	ret := _m.Called()
+
+	var r0 filestore.Usage
+	if rf, ok := ret.Get(0).(func() filestore.Usage); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(filestore.Usage)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -1,6 +1,9 @@
 package filestore
 
-import "io"
+import (
+	"errors"
+	"io"
+)
 
 // Path represents an abstract path to a file
 type Path string
@@ -33,4 +36,25 @@ type FileStore interface {
 	Delete(p Path) error
 
 	CreateTemp() (File, error)
+
+	// Reserve sets aside size bytes under the given key for a file that will
+	// be written later. Reserving the same key twice has no effect
+	Reserve(key string, size uint64) error
+	// Release frees the space set aside under the given key
+	Release(key string)
+	// Usage reports how space in the filestore is used
+	Usage() (Usage, error)
+}
+
+// ErrNotEnoughSpace is returned when a reservation cannot be satisfied
+var ErrNotEnoughSpace = errors.New("not enough space in filestore")
+
+// Usage describes how space in a filestore is used
+type Usage struct {
+	// Reserved is the space set aside for files that are expected to be written
+	Reserved uint64
+	// Used is the space taken up by files in the filestore
+	Used uint64
+	// Free is the space still available for new reservations
+	Free uint64
 }
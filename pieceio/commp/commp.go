@@ -0,0 +1,163 @@
+// Package commp computes piece commitments (CommP) in Go, so that they can be
+// generated without linking the filecoin-ffi proofs library
+package commp
+
+import (
+	"crypto/sha256"
+	"io"
+
+	commcid "github.com/filecoin-project/go-fil-commcid"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+)
+
+const (
+	// NodeSize is the size of a node in the piece merkle tree
+	NodeSize = 32
+
+	// fr32 padding spreads every 127 bytes of data over four 254 bit field
+	// elements, each stored in a 32 byte node
+	unpaddedChunkSize = 127
+	paddedChunkSize   = 128
+)
+
+// GeneratePieceCID computes the commitment of a piece of the given unpadded
+// size read from r. It has the same signature and produces the same output as
+// ffiwrapper.GeneratePieceCIDFromFile, which means the reader must provide
+// exactly pieceSize bytes, already padded with zeros to a valid piece size
+func GeneratePieceCID(rt abi.RegisteredProof, r io.Reader, pieceSize abi.UnpaddedPieceSize) (cid.Cid, error) {
+	if err := pieceSize.Validate(); err != nil {
+		return cid.Undef, xerrors.Errorf("invalid piece size: %w", err)
+	}
+	// like the FFI, only check that the proof is known: pieces are not
+	// required to fit in a sector of its size
+	if _, err := rt.SectorSize(); err != nil {
+		return cid.Undef, xerrors.Errorf("invalid registered proof: %w", err)
+	}
+
+	w := NewWriter()
+	n, err := io.CopyN(w, r, int64(pieceSize))
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("reading piece data (read %d of %d bytes): %w", n, pieceSize, err)
+	}
+	return w.Sum()
+}
+
+// Writer computes the commitment of the unpadded piece data written to it,
+// fr32 padding the data and building the merkle tree as the data streams in.
+// It only keeps one node for each level of the tree in memory
+type Writer struct {
+	chunk    [unpaddedChunkSize]byte
+	buffered int
+	written  uint64
+
+	// layers holds, for each level of the tree above the leaves, a left node
+	// still waiting for its sibling
+	layers []*[NodeSize]byte
+}
+
+// NewWriter returns a Writer for a new piece
+func NewWriter() *Writer {
+	return &Writer{}
+}
+
+// Write adds data to the piece
+func (w *Writer) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		copied := copy(w.chunk[w.buffered:], p)
+		w.buffered += copied
+		p = p[copied:]
+		if w.buffered == unpaddedChunkSize {
+			w.addChunk()
+			w.buffered = 0
+		}
+	}
+	w.written += uint64(n)
+	return n, nil
+}
+
+// Written returns the number of bytes written so far
+func (w *Writer) Written() uint64 {
+	return w.written
+}
+
+// Sum returns the piece commitment of the data written. The amount of data
+// written must be a valid unpadded piece size, so pieces need to be padded
+// with zeros before they are written
+func (w *Writer) Sum() (cid.Cid, error) {
+	if err := abi.UnpaddedPieceSize(w.written).Validate(); err != nil {
+		return cid.Undef, xerrors.Errorf("invalid piece size %d: %w", w.written, err)
+	}
+	// a valid piece has a power of two number of chunks, so the only node
+	// left is the root, at the top of the tree
+	root := w.layers[len(w.layers)-1]
+	return commcid.PieceCommitmentV1ToCID(root[:]), nil
+}
+
+// addChunk pads a full chunk of data and adds its two subtrees to the tree
+func (w *Writer) addChunk() {
+	var padded [paddedChunkSize]byte
+	Pad(w.chunk[:], padded[:])
+	w.addNode(0, hashNodes(padded[0:32], padded[32:64]))
+	w.addNode(0, hashNodes(padded[64:96], padded[96:128]))
+}
+
+// addNode adds a node at the given level, combining it with its left sibling
+// into their parent when there is one
+func (w *Writer) addNode(level int, node [NodeSize]byte) {
+	for {
+		if level == len(w.layers) {
+			w.layers = append(w.layers, nil)
+		}
+		left := w.layers[level]
+		if left == nil {
+			w.layers[level] = &node
+			return
+		}
+		w.layers[level] = nil
+		node = hashNodes(left[:], node[:])
+		level++
+	}
+}
+
+// hashNodes returns the parent of two nodes: the SHA256 of the nodes with the
+// two most significant bits cleared, so that it fits in a field element
+func hashNodes(left, right []byte) [NodeSize]byte {
+	var data [2 * NodeSize]byte
+	copy(data[:NodeSize], left)
+	copy(data[NodeSize:], right)
+	out := sha256.Sum256(data[:])
+	out[NodeSize-1] &= 0x3f
+	return out
+}
+
+// Pad fr32 pads 127 bytes of data into 128 bytes. Every 254 bits of input are
+// written to a 32 byte node, leaving the top two bits of each node zero
+func Pad(in, out []byte) {
+	_ = in[unpaddedChunkSize-1]
+	_ = out[paddedChunkSize-1]
+
+	// the first node takes input bits 0 to 253
+	copy(out[:31], in[:31])
+	out[31] = in[31] & 0x3f
+
+	// the second node starts at bit 6 of byte 31
+	for i := 32; i < 64; i++ {
+		out[i] = in[i-1]>>6 | in[i]<<2
+	}
+	out[63] &= 0x3f
+
+	// the third node starts at bit 4 of byte 63
+	for i := 64; i < 96; i++ {
+		out[i] = in[i-1]>>4 | in[i]<<4
+	}
+	out[95] &= 0x3f
+
+	// the last node starts at bit 2 of byte 95, and ends with the input
+	for i := 96; i < 127; i++ {
+		out[i] = in[i-1]>>2 | in[i]<<6
+	}
+	out[127] = in[126] >> 2
+}
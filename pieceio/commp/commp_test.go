@@ -0,0 +1,158 @@
+package commp_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"math/rand"
+	"testing"
+
+	commcid "github.com/filecoin-project/go-fil-commcid"
+	"github.com/filecoin-project/sector-storage/zerocomm"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/pieceio/commp"
+)
+
+// zero piece commitments in zerocomm were generated with filecoin-ffi, and
+// cover every piece size from 128 bytes up
+func zeroCommitment(size abi.PaddedPieceSize) []byte {
+	level := 0
+	for s := abi.PaddedPieceSize(128); s < size; s <<= 1 {
+		level++
+	}
+	return zerocomm.PieceComms[level][:]
+}
+
+func TestGeneratePieceCIDZeroPieces(t *testing.T) {
+	// computing larger pieces takes too long for a unit test; the tree above
+	// them is checked by TestZeroPieceTree
+	const maxComputed = abi.PaddedPieceSize(8 << 20)
+
+	testCases := map[string]abi.RegisteredProof{
+		"2KiB":   abi.RegisteredProof_StackedDRG2KiBSeal,
+		"8MiB":   abi.RegisteredProof_StackedDRG8MiBSeal,
+		"512MiB": abi.RegisteredProof_StackedDRG512MiBSeal,
+		"32GiB":  abi.RegisteredProof_StackedDRG32GiBSeal,
+	}
+	for name, rt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			sectorSize, err := rt.SectorSize()
+			require.NoError(t, err)
+
+			for size := abi.PaddedPieceSize(128); size <= abi.PaddedPieceSize(sectorSize) && size <= maxComputed; size <<= 1 {
+				unpadded := size.Unpadded()
+				commitment, err := commp.GeneratePieceCID(rt, bytes.NewReader(make([]byte, unpadded)), unpadded)
+				require.NoError(t, err)
+
+				expected := commcid.PieceCommitmentV1ToCID(zeroCommitment(size))
+				require.Equal(t, expected, commitment, "piece size %d", size)
+			}
+		})
+	}
+}
+
+func TestZeroPieceTree(t *testing.T) {
+	// a zero piece twice the size has two zero pieces as its children
+	node := zerocomm.PieceComms[0]
+	for level := 1; level < len(zerocomm.PieceComms); level++ {
+		var data [2 * commp.NodeSize]byte
+		copy(data[:commp.NodeSize], node[:])
+		copy(data[commp.NodeSize:], node[:])
+		node = sha256.Sum256(data[:])
+		node[commp.NodeSize-1] &= 0x3f
+		require.Equal(t, zerocomm.PieceComms[level], node, "level %d", level)
+	}
+}
+
+// referencePad fr32 pads a chunk one bit at a time, writing 254 bits of input
+// followed by two zero bits to each node
+func referencePad(in []byte) []byte {
+	out := make([]byte, 128)
+	inBit := 0
+	for outBit := 0; outBit < 128*8; outBit++ {
+		if outBit%256 >= 254 {
+			continue
+		}
+		if in[inBit/8]&(1<<(inBit%8)) != 0 {
+			out[outBit/8] |= 1 << (outBit % 8)
+		}
+		inBit++
+	}
+	return out
+}
+
+func TestPad(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		in := make([]byte, 127)
+		_, _ = rng.Read(in)
+		out := make([]byte, 128)
+		commp.Pad(in, out)
+		require.Equal(t, referencePad(in), out)
+	}
+
+	in := bytes.Repeat([]byte{0xff}, 127)
+	out := make([]byte, 128)
+	commp.Pad(in, out)
+	require.Equal(t, referencePad(in), out)
+}
+
+func TestWriter(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	data := make([]byte, 127*64)
+	_, _ = rng.Read(data)
+
+	whole := commp.NewWriter()
+	_, err := whole.Write(data)
+	require.NoError(t, err)
+	expected, err := whole.Sum()
+	require.NoError(t, err)
+
+	t.Run("result does not depend on write sizes", func(t *testing.T) {
+		w := commp.NewWriter()
+		for rest := data; len(rest) > 0; {
+			n := rng.Intn(300) + 1
+			if n > len(rest) {
+				n = len(rest)
+			}
+			_, err := w.Write(rest[:n])
+			require.NoError(t, err)
+			rest = rest[n:]
+		}
+		require.Equal(t, uint64(len(data)), w.Written())
+		commitment, err := w.Sum()
+		require.NoError(t, err)
+		require.Equal(t, expected, commitment)
+	})
+
+	t.Run("changing data changes the commitment", func(t *testing.T) {
+		changed := append([]byte(nil), data...)
+		changed[len(changed)-1] ^= 1
+		commitment, err := commp.GeneratePieceCID(abi.RegisteredProof_StackedDRG8MiBPoSt, bytes.NewReader(changed), abi.UnpaddedPieceSize(len(changed)))
+		require.NoError(t, err)
+		require.NotEqual(t, expected, commitment)
+	})
+
+	t.Run("sum requires a valid piece size", func(t *testing.T) {
+		w := commp.NewWriter()
+		_, err := w.Write(data[:200])
+		require.NoError(t, err)
+		_, err = w.Sum()
+		require.Error(t, err)
+	})
+}
+
+func TestGeneratePieceCIDErrors(t *testing.T) {
+	rt := abi.RegisteredProof_StackedDRG2KiBPoSt
+
+	_, err := commp.GeneratePieceCID(rt, bytes.NewReader(make([]byte, 200)), 200)
+	require.EqualError(t, err, "invalid piece size: unpadded piece size must be a power of 2 multiple of 127")
+
+	_, err = commp.GeneratePieceCID(abi.RegisteredProof(-1), bytes.NewReader(make([]byte, 254)), 254)
+	require.Error(t, err)
+
+	_, err = commp.GeneratePieceCID(rt, bytes.NewReader(make([]byte, 100)), 254)
+	require.EqualError(t, err, "reading piece data (read 100 of 254 bytes): "+io.EOF.Error())
+}
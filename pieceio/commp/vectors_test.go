@@ -0,0 +1,73 @@
+package commp_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	commcid "github.com/filecoin-project/go-fil-commcid"
+	"github.com/filecoin-project/sector-storage/zerocomm"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/pieceio/commp"
+)
+
+// zeroPieceCIDs are the published commitments of pieces of zeros, from the
+// smallest piece up to each sector size. They are the commitments of empty
+// sectors that lotus and rust-fil-proofs use, and are written out here
+// rather than computed so that they do not depend on any code under test
+var zeroPieceCIDs = map[abi.PaddedPieceSize]string{
+	128:       "bafk4chzag4y3xgnmncpwn3xvs47evfg2dchu3xfoladsj7dph7la37kiqmzq",
+	2 << 10:   "bafk4chza7r7jfauw4ulpvlpjq2zi7ewujjhsjojvjbjcgn3kpgicppay7azq",
+	8 << 20:   "bafk4chzamxzj4xmy2jdmhczyrt6anwy7nmbbga6fukeqac645azktq7miioa",
+	512 << 20: "bafk4chzahfla46ytve5qpisd7utsb75hzm7b2lsqlkzwfhtz6rrrgujm3ida",
+	32 << 30:  "bafk4chzaa57f7xrvyufjga5fkae6gsmkj27n7444ik3rbnzq3dwhvr5puy7a",
+	64 << 30:  "bafk4chza4zaaljv74n3xsu5yvvxpspypziietmqeczkpfjar65ycpgoozyba",
+}
+
+func TestGeneratePieceCIDPublishedZeroPieces(t *testing.T) {
+	// larger pieces take too long to compute in a unit test; the tree above
+	// them is checked by TestPublishedZeroPieceTree
+	testCases := map[abi.PaddedPieceSize]abi.RegisteredProof{
+		128:     abi.RegisteredProof_StackedDRG2KiBSeal,
+		2 << 10: abi.RegisteredProof_StackedDRG2KiBSeal,
+		8 << 20: abi.RegisteredProof_StackedDRG8MiBSeal,
+	}
+	for size, rt := range testCases {
+		unpadded := size.Unpadded()
+		commitment, err := commp.GeneratePieceCID(rt, bytes.NewReader(make([]byte, unpadded)), unpadded)
+		require.NoError(t, err)
+		require.Equal(t, zeroPieceCIDs[size], commitment.String(), "piece size %d", size)
+	}
+}
+
+func TestPublishedZeroPieceTree(t *testing.T) {
+	// each zero piece is the root of a tree of the smallest zero piece, so
+	// hashing up from it must reach every published commitment
+	smallest, err := cid.Decode(zeroPieceCIDs[128])
+	require.NoError(t, err)
+	commitment, err := commcid.CIDToPieceCommitmentV1(smallest)
+	require.NoError(t, err)
+
+	var node [commp.NodeSize]byte
+	copy(node[:], commitment)
+	for size := abi.PaddedPieceSize(256); size <= 64<<30; size <<= 1 {
+		var data [2 * commp.NodeSize]byte
+		copy(data[:commp.NodeSize], node[:])
+		copy(data[commp.NodeSize:], node[:])
+		node = sha256.Sum256(data[:])
+		node[commp.NodeSize-1] &= 0x3f
+
+		if expected, ok := zeroPieceCIDs[size]; ok {
+			require.Equal(t, expected, commcid.PieceCommitmentV1ToCID(node[:]).String(), "piece size %d", size)
+		}
+	}
+}
+
+func TestPublishedZeroPiecesMatchZerocomm(t *testing.T) {
+	for size, expected := range zeroPieceCIDs {
+		require.Equal(t, expected, zerocomm.ZeroPieceCommitment(size.Unpadded()).String(), "piece size %d", size)
+	}
+}
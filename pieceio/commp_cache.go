@@ -0,0 +1,97 @@
+package pieceio
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"golang.org/x/xerrors"
+)
+
+func init() {
+	cbor.RegisterCborType(CommPCacheEntry{})
+}
+
+// CommPCacheEntry is the commitment cached for a payload
+type CommPCacheEntry struct {
+	PieceCid  cid.Cid
+	PieceSize abi.UnpaddedPieceSize
+	// CarSize is the size of the CAR the commitment was computed over
+	CarSize uint64
+	// Blocks are the blocks in the CAR, so a cached commitment can be checked
+	// to still be readable without traversing the payload
+	Blocks []cid.Cid
+}
+
+// CommPCache remembers the piece commitments computed for payloads, keyed by
+// payload CID, selector and registered proof. As the CAR for a payload and
+// selector is determined entirely by the content addressed blocks it contains,
+// an entry stays correct for as long as the blocks can be read
+type CommPCache struct {
+	ds datastore.Datastore
+}
+
+// NewCommPCache returns a cache that stores commitments in the given
+// datastore. The datastore should not be shared with other data
+func NewCommPCache(ds datastore.Datastore) *CommPCache {
+	return &CommPCache{ds: ds}
+}
+
+// Get returns the cached commitment for the payload, if there is one
+func (c *CommPCache) Get(rt abi.RegisteredProof, payloadCid cid.Cid, selector ipld.Node) (CommPCacheEntry, bool, error) {
+	key, err := commPCacheKey(rt, payloadCid, selector)
+	if err != nil {
+		return CommPCacheEntry{}, false, err
+	}
+
+	data, err := c.ds.Get(key)
+	if err == datastore.ErrNotFound {
+		return CommPCacheEntry{}, false, nil
+	}
+	if err != nil {
+		return CommPCacheEntry{}, false, xerrors.Errorf("reading commP cache: %w", err)
+	}
+
+	var entry CommPCacheEntry
+	if err := cbor.DecodeInto(data, &entry); err != nil {
+		return CommPCacheEntry{}, false, xerrors.Errorf("decoding commP cache entry: %w", err)
+	}
+	return entry, true, nil
+}
+
+// Put records the commitment for the payload
+func (c *CommPCache) Put(rt abi.RegisteredProof, payloadCid cid.Cid, selector ipld.Node, entry CommPCacheEntry) error {
+	key, err := commPCacheKey(rt, payloadCid, selector)
+	if err != nil {
+		return err
+	}
+
+	data, err := cbor.DumpObject(entry)
+	if err != nil {
+		return xerrors.Errorf("encoding commP cache entry: %w", err)
+	}
+	return c.ds.Put(key, data)
+}
+
+// Invalidate removes the commitment for the payload
+func (c *CommPCache) Invalidate(rt abi.RegisteredProof, payloadCid cid.Cid, selector ipld.Node) error {
+	key, err := commPCacheKey(rt, payloadCid, selector)
+	if err != nil {
+		return err
+	}
+	return c.ds.Delete(key)
+}
+
+func commPCacheKey(rt abi.RegisteredProof, payloadCid cid.Cid, selector ipld.Node) (datastore.Key, error) {
+	var buf bytes.Buffer
+	if err := dagcbor.Encoder(selector, &buf); err != nil {
+		return datastore.Key{}, xerrors.Errorf("encoding selector: %w", err)
+	}
+	return datastore.NewKey(fmt.Sprintf("/%d/%s/%x", rt, payloadCid, sha256.Sum256(buf.Bytes()))), nil
+}
@@ -0,0 +1,152 @@
+package pieceio_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dag "github.com/ipfs/go-merkledag"
+	dstest "github.com/ipfs/go-merkledag/test"
+	basicnode "github.com/ipld/go-ipld-prime/node/basic"
+	"github.com/ipld/go-ipld-prime/traversal/selector"
+	"github.com/ipld/go-ipld-prime/traversal/selector/builder"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/filestore"
+	"github.com/filecoin-project/go-fil-markets/pieceio"
+	"github.com/filecoin-project/go-fil-markets/pieceio/cario"
+	pmocks "github.com/filecoin-project/go-fil-markets/pieceio/mocks"
+	"github.com/filecoin-project/go-fil-markets/shared_testutil"
+)
+
+func TestCommPCache(t *testing.T) {
+	rt := abi.RegisteredProof_StackedDRG2KiBPoSt
+	ssb := builder.NewSelectorSpecBuilder(basicnode.Style.Any)
+	allSelector := ssb.ExploreRecursive(selector.RecursionLimitNone(), ssb.ExploreAll(ssb.ExploreRecursiveEdge())).Node()
+	rootSelector := ssb.Matcher().Node()
+	cids := shared_testutil.GenerateCids(3)
+	payloadCid, pieceCid := cids[0], cids[1]
+
+	cache := pieceio.NewCommPCache(datastore.NewMapDatastore())
+
+	_, ok, err := cache.Get(rt, payloadCid, allSelector)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	entry := pieceio.CommPCacheEntry{
+		PieceCid:  pieceCid,
+		PieceSize: 1016,
+		CarSize:   900,
+		Blocks:    []cid.Cid{payloadCid},
+	}
+	require.NoError(t, cache.Put(rt, payloadCid, allSelector, entry))
+
+	cached, ok, err := cache.Get(rt, payloadCid, allSelector)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, entry, cached)
+
+	t.Run("entries are keyed by selector, proof and payload", func(t *testing.T) {
+		_, ok, err := cache.Get(rt, payloadCid, rootSelector)
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		_, ok, err = cache.Get(abi.RegisteredProof_StackedDRG8MiBPoSt, payloadCid, allSelector)
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		_, ok, err = cache.Get(rt, cids[2], allSelector)
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+
+	t.Run("invalidate removes the entry", func(t *testing.T) {
+		require.NoError(t, cache.Invalidate(rt, payloadCid, allSelector))
+		_, ok, err := cache.Get(rt, payloadCid, allSelector)
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+}
+
+func TestPieceIOCommPCache(t *testing.T) {
+	rt := abi.RegisteredProof_StackedDRG2KiBPoSt
+	ctx := context.Background()
+	ssb := builder.NewSelectorSpecBuilder(basicnode.Style.Any)
+	allSelector := ssb.ExploreRecursive(selector.RecursionLimitNone(), ssb.ExploreAll(ssb.ExploreRecursiveEdge())).Node()
+
+	sourceBserv := dstest.Bserv()
+	sourceBs := sourceBserv.Blockstore()
+	dserv := dag.NewDAGService(sourceBserv)
+	a := dag.NewRawNode([]byte("aaaa"))
+	nd := &dag.ProtoNode{}
+	_ = nd.AddNodeLink("cat", a)
+	require.NoError(t, dserv.Add(ctx, a))
+	require.NoError(t, dserv.Add(ctx, nd))
+
+	store, err := filestore.NewLocalFileStore(filestore.OsPath("./tempDir"))
+	require.NoError(t, err)
+
+	cache := pieceio.NewCommPCache(datastore.NewMapDatastore())
+	pio := pieceio.NewPieceIOWithStore(cario.NewCarIO(), store, sourceBs, pieceio.WithCommPCache(cache))
+
+	commitment, size, err := pio.GeneratePieceCommitment(rt, nd.Cid(), allSelector)
+	require.NoError(t, err)
+
+	t.Run("computed commitments are cached with their blocks", func(t *testing.T) {
+		entry, ok, err := cache.Get(rt, nd.Cid(), allSelector)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, commitment, entry.PieceCid)
+		require.Equal(t, size, entry.PieceSize)
+		require.Equal(t, []cid.Cid{nd.Cid(), a.Cid()}, entry.Blocks)
+
+		require.NoError(t, cache.Invalidate(rt, nd.Cid(), allSelector))
+		_, path, _, err := pio.GeneratePieceCommitmentToFile(rt, nd.Cid(), allSelector)
+		require.NoError(t, err)
+		require.NoError(t, store.Delete(path))
+
+		fileEntry, ok, err := cache.Get(rt, nd.Cid(), allSelector)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, entry, fileEntry)
+	})
+
+	t.Run("cached commitments are not recomputed", func(t *testing.T) {
+		entry, _, err := cache.Get(rt, nd.Cid(), allSelector)
+		require.NoError(t, err)
+		fakeEntry := entry
+		fakeEntry.PieceCid = shared_testutil.GenerateCids(1)[0]
+		require.NoError(t, cache.Put(rt, nd.Cid(), allSelector, fakeEntry))
+
+		// the CAR IO mock has no expectations, so the payload must not be
+		// traversed
+		noCarPio := pieceio.NewPieceIO(&pmocks.CarIO{}, sourceBs, pieceio.WithCommPCache(cache))
+		var progress []uint64
+		cached, _, err := noCarPio.GeneratePieceCommitmentWithProgress(ctx, rt, nd.Cid(), allSelector, func(processed uint64, total uint64) {
+			progress = append(progress, processed, total)
+		})
+		require.NoError(t, err)
+		require.Equal(t, fakeEntry.PieceCid, cached)
+		require.Equal(t, []uint64{entry.CarSize, entry.CarSize}, progress)
+
+		cached, path, _, err := pio.GeneratePieceCommitmentToFile(rt, nd.Cid(), allSelector)
+		require.NoError(t, err)
+		require.Equal(t, fakeEntry.PieceCid, cached)
+		require.NoError(t, store.Delete(path))
+
+		require.NoError(t, cache.Put(rt, nd.Cid(), allSelector, entry))
+	})
+
+	t.Run("missing blocks invalidate the cache", func(t *testing.T) {
+		require.NoError(t, sourceBs.DeleteBlock(a.Cid()))
+
+		_, _, err := pio.GeneratePieceCommitment(rt, nd.Cid(), allSelector)
+		require.Error(t, err)
+
+		_, ok, err := cache.Get(rt, nd.Cid(), allSelector)
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+}
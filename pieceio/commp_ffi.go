@@ -0,0 +1,18 @@
+// +build !nativecommp
+
+package pieceio
+
+import (
+	"io"
+
+	"github.com/filecoin-project/sector-storage/ffiwrapper"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+)
+
+// GeneratePieceCID computes a piece commitment with the filecoin-ffi proofs
+// library. Build with the nativecommp tag to use the Go implementation in
+// pieceio/commp instead, which drops the dependency on filecoin-ffi
+func GeneratePieceCID(rt abi.RegisteredProof, r io.Reader, pieceSize abi.UnpaddedPieceSize) (cid.Cid, error) {
+	return ffiwrapper.GeneratePieceCIDFromFile(rt, r, pieceSize)
+}
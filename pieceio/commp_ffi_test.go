@@ -0,0 +1,44 @@
+// +build !nativecommp
+
+package pieceio_test
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/filecoin-project/sector-storage/ffiwrapper"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/pieceio/commp"
+)
+
+func TestNativeCommPMatchesFFI(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	testCases := map[string]struct {
+		rt      abi.RegisteredProof
+		maxSize abi.PaddedPieceSize
+	}{
+		"2KiB":   {abi.RegisteredProof_StackedDRG2KiBSeal, 2 << 10},
+		"8MiB":   {abi.RegisteredProof_StackedDRG8MiBSeal, 1 << 20},
+		"512MiB": {abi.RegisteredProof_StackedDRG512MiBSeal, 1 << 20},
+		"32GiB":  {abi.RegisteredProof_StackedDRG32GiBSeal, 1 << 20},
+	}
+	for name, data := range testCases {
+		t.Run(name, func(t *testing.T) {
+			for size := abi.PaddedPieceSize(128); size <= data.maxSize; size <<= 1 {
+				unpadded := size.Unpadded()
+				piece := make([]byte, unpadded)
+				_, _ = rng.Read(piece)
+
+				expected, err := ffiwrapper.GeneratePieceCIDFromFile(data.rt, bytes.NewReader(piece), unpadded)
+				require.NoError(t, err)
+				commitment, err := commp.GeneratePieceCID(data.rt, bytes.NewReader(piece), unpadded)
+				require.NoError(t, err)
+				require.Equal(t, expected, commitment, "piece size %d", size)
+			}
+		})
+	}
+}
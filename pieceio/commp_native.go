@@ -0,0 +1,18 @@
+// +build nativecommp
+
+package pieceio
+
+import (
+	"io"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-fil-markets/pieceio/commp"
+)
+
+// GeneratePieceCID computes a piece commitment with the Go implementation in
+// pieceio/commp, as this binary was built with the nativecommp tag
+func GeneratePieceCID(rt abi.RegisteredProof, r io.Reader, pieceSize abi.UnpaddedPieceSize) (cid.Cid, error) {
+	return commp.GeneratePieceCID(rt, r, pieceSize)
+}
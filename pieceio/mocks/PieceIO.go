@@ -4,9 +4,11 @@ package mocks
 
 import abi "github.com/filecoin-project/specs-actors/actors/abi"
 import cid "github.com/ipfs/go-cid"
+import context "context"
 import io "io"
 import ipld "github.com/ipld/go-ipld-prime"
 import mock "github.com/stretchr/testify/mock"
+import pieceio "github.com/filecoin-project/go-fil-markets/pieceio"
 
 // PieceIO is an autogenerated mock type for the PieceIO type
 type PieceIO struct {
@@ -41,6 +43,34 @@ func (_m *PieceIO) GeneratePieceCommitment(rt abi.RegisteredProof, payloadCid ci
 	return r0, r1, r2
 }
 
+// GeneratePieceCommitmentWithProgress provides a mock function with given fields: ctx, rt, payloadCid, selector, progress
+func (_m *PieceIO) GeneratePieceCommitmentWithProgress(ctx context.Context, rt abi.RegisteredProof, payloadCid cid.Cid, selector ipld.Node, progress pieceio.ProgressFunc) (cid.Cid, abi.UnpaddedPieceSize, error) {
+	ret := _m.Called(ctx, rt, payloadCid, selector, progress)
+
+	var r0 cid.Cid
+	if rf, ok := ret.Get(0).(func(context.Context, abi.RegisteredProof, cid.Cid, ipld.Node, pieceio.ProgressFunc) cid.Cid); ok {
+		r0 = rf(ctx, rt, payloadCid, selector, progress)
+	} else {
+		r0 = ret.Get(0).(cid.Cid)
+	}
+
+	var r1 abi.UnpaddedPieceSize
+	if rf, ok := ret.Get(1).(func(context.Context, abi.RegisteredProof, cid.Cid, ipld.Node, pieceio.ProgressFunc) abi.UnpaddedPieceSize); ok {
+		r1 = rf(ctx, rt, payloadCid, selector, progress)
+	} else {
+		r1 = ret.Get(1).(abi.UnpaddedPieceSize)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, abi.RegisteredProof, cid.Cid, ipld.Node, pieceio.ProgressFunc) error); ok {
+		r2 = rf(ctx, rt, payloadCid, selector, progress)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // ReadPiece provides a mock function with given fields: r
 func (_m *PieceIO) ReadPiece(r io.Reader) (cid.Cid, error) {
 	ret := _m.Called(r)
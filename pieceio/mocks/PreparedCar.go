@@ -5,6 +5,8 @@ package mocks
 import (
 	io "io"
 
+	cid "github.com/ipfs/go-cid"
+
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -13,6 +15,22 @@ type PreparedCar struct {
 	mock.Mock
 }
 
+// Cids provides a mock function with given fields:
+func (_m *PreparedCar) Cids() []cid.Cid {
+	ret := _m.Called()
+
+	var r0 []cid.Cid
+	if rf, ok := ret.Get(0).(func() []cid.Cid); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]cid.Cid)
+		}
+	}
+
+	return r0
+}
+
 // Dump provides a mock function with given fields: w
 func (_m *PreparedCar) Dump(w io.Writer) error {
 	ret := _m.Called(w)
@@ -3,22 +3,25 @@ package pieceio
 import (
 	"context"
 	"io"
-	"os"
-	"sync"
 
 	"github.com/filecoin-project/go-padreader"
-	"github.com/filecoin-project/sector-storage/ffiwrapper"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/ipfs/go-cid"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	logging "github.com/ipfs/go-log/v2"
 	"github.com/ipld/go-car"
 	"github.com/ipld/go-ipld-prime"
+	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-fil-markets/filestore"
 )
 
+var log = logging.Logger("pieceio")
+
 type PreparedCar interface {
 	Size() uint64
+	// Cids returns the blocks in the CAR, in the order they are written
+	Cids() []cid.Cid
 	Dump(w io.Writer) error
 }
 
@@ -34,13 +37,43 @@ type CarIO interface {
 	LoadCar(bs WriteStore, r io.Reader) (cid.Cid, error)
 }
 
+// CommPFunc computes the commitment of a piece of the given unpadded size read
+// from r, which must already be padded to a valid piece size
+type CommPFunc func(rt abi.RegisteredProof, r io.Reader, pieceSize abi.UnpaddedPieceSize) (cid.Cid, error)
+
 type pieceIO struct {
-	carIO CarIO
-	bs    blockstore.Blockstore
+	carIO      CarIO
+	bs         blockstore.Blockstore
+	commPCache *CommPCache
+	commP      CommPFunc
+}
+
+// Option configures a PieceIO
+type Option func(*pieceIO)
+
+// WithCommPCache makes a PieceIO look up commitments in the given cache before
+// computing them, and record the commitments it computes
+func WithCommPCache(cache *CommPCache) Option {
+	return func(pio *pieceIO) {
+		pio.commPCache = cache
+	}
 }
 
-func NewPieceIO(carIO CarIO, bs blockstore.Blockstore) PieceIO {
-	return &pieceIO{carIO, bs}
+// WithCommPFunc sets the function a PieceIO computes piece commitments with,
+// e.g. commp.GeneratePieceCID to use the Go implementation in a binary that
+// is built with filecoin-ffi. It defaults to GeneratePieceCID
+func WithCommPFunc(commP CommPFunc) Option {
+	return func(pio *pieceIO) {
+		pio.commP = commP
+	}
+}
+
+func NewPieceIO(carIO CarIO, bs blockstore.Blockstore, options ...Option) PieceIO {
+	pio := &pieceIO{carIO: carIO, bs: bs, commP: GeneratePieceCID}
+	for _, option := range options {
+		option(pio)
+	}
+	return pio
 }
 
 type pieceIOWithStore struct {
@@ -48,47 +81,142 @@ type pieceIOWithStore struct {
 	store filestore.FileStore
 }
 
-func NewPieceIOWithStore(carIO CarIO, store filestore.FileStore, bs blockstore.Blockstore) PieceIOWithStore {
-	return &pieceIOWithStore{pieceIO{carIO, bs}, store}
+func NewPieceIOWithStore(carIO CarIO, store filestore.FileStore, bs blockstore.Blockstore, options ...Option) PieceIOWithStore {
+	pio := &pieceIOWithStore{pieceIO{carIO: carIO, bs: bs, commP: GeneratePieceCID}, store}
+	for _, option := range options {
+		option(&pio.pieceIO)
+	}
+	return pio
 }
 
-func (pio *pieceIO) GeneratePieceCommitment(rt abi.RegisteredProof, payloadCid cid.Cid, selector ipld.Node) (cid.Cid, abi.UnpaddedPieceSize, error) {
-	preparedCar, err := pio.carIO.PrepareCar(context.Background(), pio.bs, payloadCid, selector)
+// cachedCommP returns the cached commitment for the payload, if there is one
+// and every block it was computed from is still in the blockstore. An entry
+// whose blocks are missing is dropped
+func (pio *pieceIO) cachedCommP(rt abi.RegisteredProof, payloadCid cid.Cid, selector ipld.Node) (CommPCacheEntry, bool) {
+	if pio.commPCache == nil {
+		return CommPCacheEntry{}, false
+	}
+	entry, ok, err := pio.commPCache.Get(rt, payloadCid, selector)
 	if err != nil {
-		return cid.Undef, 0, err
+		log.Warnf("reading commP cache for payload %s: %s", payloadCid, err)
+		return CommPCacheEntry{}, false
+	}
+	if !ok || len(entry.Blocks) == 0 || !entry.Blocks[0].Equals(payloadCid) {
+		return CommPCacheEntry{}, false
+	}
+	for _, block := range entry.Blocks {
+		has, err := pio.bs.Has(block)
+		if err != nil || !has {
+			pio.invalidateCommP(rt, payloadCid, selector)
+			return CommPCacheEntry{}, false
+		}
+	}
+	return entry, true
+}
+
+func (pio *pieceIO) cacheCommP(rt abi.RegisteredProof, payloadCid cid.Cid, selector ipld.Node, entry CommPCacheEntry) {
+	if pio.commPCache == nil {
+		return
+	}
+	if err := pio.commPCache.Put(rt, payloadCid, selector, entry); err != nil {
+		log.Warnf("writing commP cache for payload %s: %s", payloadCid, err)
+	}
+}
+
+// invalidateCommP drops the cached commitment for a payload that can no longer
+// be read, e.g. because some of its blocks were removed from the blockstore
+func (pio *pieceIO) invalidateCommP(rt abi.RegisteredProof, payloadCid cid.Cid, selector ipld.Node) {
+	if pio.commPCache == nil {
+		return
 	}
-	pieceSize := uint64(preparedCar.Size())
-	r, w, err := os.Pipe()
+	if err := pio.commPCache.Invalidate(rt, payloadCid, selector); err != nil {
+		log.Warnf("invalidating commP cache for payload %s: %s", payloadCid, err)
+	}
+}
+
+func (pio *pieceIO) GeneratePieceCommitment(rt abi.RegisteredProof, payloadCid cid.Cid, selector ipld.Node) (cid.Cid, abi.UnpaddedPieceSize, error) {
+	return pio.GeneratePieceCommitmentWithProgress(context.Background(), rt, payloadCid, selector, nil)
+}
+
+func (pio *pieceIO) GeneratePieceCommitmentWithProgress(ctx context.Context, rt abi.RegisteredProof, payloadCid cid.Cid, selector ipld.Node, progress ProgressFunc) (cid.Cid, abi.UnpaddedPieceSize, error) {
+	if entry, ok := pio.cachedCommP(rt, payloadCid, selector); ok {
+		if progress != nil {
+			progress(entry.CarSize, entry.CarSize)
+		}
+		return entry.PieceCid, entry.PieceSize, nil
+	}
+
+	preparedCar, err := pio.carIO.PrepareCar(ctx, pio.bs, payloadCid, selector)
 	if err != nil {
 		return cid.Undef, 0, err
 	}
-	var stop sync.WaitGroup
-	stop.Add(1)
-	var werr error
+	pieceSize := preparedCar.Size()
+
+	// the pipe is synchronous, so bytes are only counted as processed once
+	// the commitment function has read them
+	r, w := io.Pipe()
+	done := make(chan error, 1)
 	go func() {
-		defer stop.Done()
-		werr = preparedCar.Dump(w)
-		err := w.Close()
-		if werr == nil && err != nil {
-			werr = err
-		}
+		err := preparedCar.Dump(&progressWriter{ctx: ctx, w: w, total: pieceSize, progress: progress})
+		_ = w.CloseWithError(err)
+		done <- err
 	}()
-	commitment, paddedSize, err := GeneratePieceCommitment(rt, r, pieceSize)
-	closeErr := r.Close()
+	commitment, paddedSize, err := generatePieceCommitment(pio.commP, rt, r, pieceSize)
+	// unblock the writer if the commitment function stopped reading early
+	_ = r.Close()
+	werr := <-done
 	if err != nil {
+		// the commitment function may not wrap the error it got reading the
+		// CAR, so a cancelled context is added to its error
+		if ctx.Err() != nil {
+			return cid.Undef, 0, xerrors.Errorf("%s: %w", err, ctx.Err())
+		}
 		return cid.Undef, 0, err
 	}
-	if closeErr != nil {
-		return cid.Undef, 0, closeErr
-	}
-	stop.Wait()
 	if werr != nil {
+		// the CAR writer does not wrap the errors it gets from the writer
+		if ctx.Err() != nil {
+			return cid.Undef, 0, ctx.Err()
+		}
 		return cid.Undef, 0, werr
 	}
+	pio.cacheCommP(rt, payloadCid, selector, CommPCacheEntry{
+		PieceCid:  commitment,
+		PieceSize: paddedSize,
+		CarSize:   pieceSize,
+		Blocks:    preparedCar.Cids(),
+	})
 	return commitment, paddedSize, nil
 }
 
+// progressWriter reports the bytes written through it, and fails writes once
+// its context is cancelled
+type progressWriter struct {
+	ctx       context.Context
+	w         io.Writer
+	processed uint64
+	total     uint64
+	progress  ProgressFunc
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	if err := pw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := pw.w.Write(p)
+	pw.processed += uint64(n)
+	if pw.progress != nil && n > 0 {
+		pw.progress(pw.processed, pw.total)
+	}
+	return n, err
+}
+
+// GeneratePieceCommitmentToFile always writes the CAR, as the file is the
+// piece the caller goes on to use. A cached commitment saves computing the
+// commitment over the file
 func (pio *pieceIOWithStore) GeneratePieceCommitmentToFile(rt abi.RegisteredProof, payloadCid cid.Cid, selector ipld.Node, userOnNewCarBlocks ...car.OnNewCarBlockFunc) (cid.Cid, filestore.Path, abi.UnpaddedPieceSize, error) {
+	entry, cached := pio.cachedCommP(rt, payloadCid, selector)
+
 	f, err := pio.store.CreateTemp()
 	if err != nil {
 		return cid.Undef, "", 0, err
@@ -97,29 +225,50 @@ func (pio *pieceIOWithStore) GeneratePieceCommitmentToFile(rt abi.RegisteredProo
 		f.Close()
 		_ = pio.store.Delete(f.Path())
 	}
-	err = pio.carIO.WriteCar(context.Background(), pio.bs, payloadCid, selector, f, userOnNewCarBlocks...)
+	var blocks []cid.Cid
+	onNewCarBlocks := append([]car.OnNewCarBlockFunc{func(block car.Block) error {
+		blocks = append(blocks, block.BlockCID)
+		return nil
+	}}, userOnNewCarBlocks...)
+	err = pio.carIO.WriteCar(context.Background(), pio.bs, payloadCid, selector, f, onNewCarBlocks...)
 	if err != nil {
 		cleanup()
 		return cid.Undef, "", 0, err
 	}
+	if cached {
+		_ = f.Close()
+		return entry.PieceCid, f.Path(), entry.PieceSize, nil
+	}
 	pieceSize := uint64(f.Size())
 	_, err = f.Seek(0, io.SeekStart)
 	if err != nil {
 		cleanup()
 		return cid.Undef, "", 0, err
 	}
-	commitment, paddedSize, err := GeneratePieceCommitment(rt, f, pieceSize)
+	commitment, paddedSize, err := generatePieceCommitment(pio.commP, rt, f, pieceSize)
 	if err != nil {
 		cleanup()
 		return cid.Undef, "", 0, err
 	}
 	_ = f.Close()
+	pio.cacheCommP(rt, payloadCid, selector, CommPCacheEntry{
+		PieceCid:  commitment,
+		PieceSize: paddedSize,
+		CarSize:   pieceSize,
+		Blocks:    blocks,
+	})
 	return commitment, f.Path(), paddedSize, nil
 }
 
+// GeneratePieceCommitment pads the data read from rd to a valid piece size and
+// computes its commitment with GeneratePieceCID
 func GeneratePieceCommitment(rt abi.RegisteredProof, rd io.Reader, pieceSize uint64) (cid.Cid, abi.UnpaddedPieceSize, error) {
+	return generatePieceCommitment(GeneratePieceCID, rt, rd, pieceSize)
+}
+
+func generatePieceCommitment(commP CommPFunc, rt abi.RegisteredProof, rd io.Reader, pieceSize uint64) (cid.Cid, abi.UnpaddedPieceSize, error) {
 	paddedReader, paddedSize := padreader.New(rd, pieceSize)
-	commitment, err := ffiwrapper.GeneratePieceCIDFromFile(rt, paddedReader, paddedSize)
+	commitment, err := commP(rt, paddedReader, paddedSize)
 	if err != nil {
 		return cid.Undef, 0, err
 	}
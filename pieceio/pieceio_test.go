@@ -7,7 +7,6 @@ import (
 	"io"
 	"testing"
 
-	"github.com/filecoin-project/sector-storage/ffiwrapper"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/ipfs/go-cid"
 	dag "github.com/ipfs/go-merkledag"
@@ -17,11 +16,13 @@ import (
 	"github.com/ipld/go-ipld-prime/traversal/selector/builder"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-fil-markets/filestore"
 	fsmocks "github.com/filecoin-project/go-fil-markets/filestore/mocks"
 	"github.com/filecoin-project/go-fil-markets/pieceio"
 	"github.com/filecoin-project/go-fil-markets/pieceio/cario"
+	"github.com/filecoin-project/go-fil-markets/pieceio/commp"
 	pmocks "github.com/filecoin-project/go-fil-markets/pieceio/mocks"
 )
 
@@ -175,7 +176,7 @@ func Test_StoreRestoreMemoryBuffer(t *testing.T) {
 	_, err = tmpFile.Read(buf)
 	require.NoError(t, err)
 	buffer := bytes.NewBuffer(buf)
-	secondCommitment, err := ffiwrapper.GeneratePieceCIDFromFile(abi.RegisteredProof_StackedDRG2KiBPoSt, buffer, paddedSize)
+	secondCommitment, err := pieceio.GeneratePieceCID(abi.RegisteredProof_StackedDRG2KiBPoSt, buffer, paddedSize)
 	require.NoError(t, err)
 	require.Equal(t, commitment, secondCommitment)
 }
@@ -235,6 +236,95 @@ func Test_PieceCommitmentEquivalenceMemoryFile(t *testing.T) {
 	require.NoError(t, merr)
 }
 
+func Test_CommPFunc(t *testing.T) {
+	store, err := filestore.NewLocalFileStore(filestore.OsPath("./tempDir"))
+	require.NoError(t, err)
+
+	sourceBserv := dstest.Bserv()
+	sourceBs := sourceBserv.Blockstore()
+	dserv := dag.NewDAGService(sourceBserv)
+	a := dag.NewRawNode([]byte("aaaa"))
+	nd := &dag.ProtoNode{}
+	_ = nd.AddNodeLink("cat", a)
+	require.NoError(t, dserv.Add(context.Background(), a))
+	require.NoError(t, dserv.Add(context.Background(), nd))
+
+	var calls int
+	commP := func(rt abi.RegisteredProof, r io.Reader, pieceSize abi.UnpaddedPieceSize) (cid.Cid, error) {
+		calls++
+		return commp.GeneratePieceCID(rt, r, pieceSize)
+	}
+	pio := pieceio.NewPieceIOWithStore(cario.NewCarIO(), store, sourceBs, pieceio.WithCommPFunc(commP))
+
+	ssb := builder.NewSelectorSpecBuilder(basicnode.Style.Any)
+	node := ssb.ExploreRecursive(selector.RecursionLimitNone(), ssb.ExploreAll(ssb.ExploreRecursiveEdge())).Node()
+
+	commitment, size, err := pio.GeneratePieceCommitment(abi.RegisteredProof_StackedDRG2KiBPoSt, nd.Cid(), node)
+	require.NoError(t, err)
+	fcommitment, tmpPath, fsize, err := pio.GeneratePieceCommitmentToFile(abi.RegisteredProof_StackedDRG2KiBPoSt, nd.Cid(), node)
+	require.NoError(t, err)
+	require.NoError(t, store.Delete(tmpPath))
+
+	require.Equal(t, 2, calls)
+	require.Equal(t, commitment, fcommitment)
+	require.Equal(t, size, fsize)
+}
+
+func Test_GeneratePieceCommitmentWithProgress(t *testing.T) {
+	ctx := context.Background()
+	sourceBserv := dstest.Bserv()
+	sourceBs := sourceBserv.Blockstore()
+	dserv := dag.NewDAGService(sourceBserv)
+	nd := &dag.ProtoNode{}
+	for i := 0; i < 10; i++ {
+		a := dag.NewRawNode(bytes.Repeat([]byte{byte(i)}, 100))
+		require.NoError(t, dserv.Add(ctx, a))
+		_ = nd.AddNodeLink(fmt.Sprintf("%d", i), a)
+	}
+	require.NoError(t, dserv.Add(ctx, nd))
+
+	pio := pieceio.NewPieceIO(cario.NewCarIO(), sourceBs)
+	ssb := builder.NewSelectorSpecBuilder(basicnode.Style.Any)
+	node := ssb.ExploreRecursive(selector.RecursionLimitNone(), ssb.ExploreAll(ssb.ExploreRecursiveEdge())).Node()
+	rt := abi.RegisteredProof_StackedDRG2KiBPoSt
+
+	t.Run("reports bytes processed", func(t *testing.T) {
+		var calls int
+		var processed, total uint64
+		commitment, size, err := pio.GeneratePieceCommitmentWithProgress(ctx, rt, nd.Cid(), node, func(p uint64, tot uint64) {
+			require.True(t, p > processed)
+			calls++
+			processed, total = p, tot
+		})
+		require.NoError(t, err)
+		require.True(t, calls > 1)
+		require.Equal(t, total, processed)
+
+		expectedCommitment, expectedSize, err := pio.GeneratePieceCommitment(rt, nd.Cid(), node)
+		require.NoError(t, err)
+		require.Equal(t, expectedCommitment, commitment)
+		require.Equal(t, expectedSize, size)
+	})
+
+	t.Run("stops when the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		_, _, err := pio.GeneratePieceCommitmentWithProgress(ctx, rt, nd.Cid(), node, func(uint64, uint64) {
+			cancel()
+		})
+		require.True(t, xerrors.Is(err, context.Canceled), "unexpected error: %v", err)
+	})
+
+	t.Run("returns the error of the commitment function", func(t *testing.T) {
+		// the CAR writer also fails, as the commitment function stops reading
+		failing := pieceio.NewPieceIO(cario.NewCarIO(), sourceBs, pieceio.WithCommPFunc(func(abi.RegisteredProof, io.Reader, abi.UnpaddedPieceSize) (cid.Cid, error) {
+			return cid.Undef, xerrors.New("commP failed")
+		}))
+		_, _, err := failing.GeneratePieceCommitmentWithProgress(ctx, rt, nd.Cid(), node, nil)
+		require.EqualError(t, err, "commP failed")
+	})
+}
+
 func Test_Failures(t *testing.T) {
 	sourceBserv := dstest.Bserv()
 	sourceBs := sourceBserv.Blockstore()
@@ -282,7 +372,7 @@ func Test_Failures(t *testing.T) {
 
 		ciomock := pmocks.CarIO{}
 		any := mock.Anything
-		ciomock.On("WriteCar", any, any, any, any, any).Return(fmt.Errorf("failed to write car"))
+		ciomock.On("WriteCar", any, any, any, any, any, any).Return(fmt.Errorf("failed to write car"))
 		pio := pieceio.NewPieceIOWithStore(&ciomock, store, sourceBs)
 		_, _, _, err = pio.GeneratePieceCommitmentToFile(abi.RegisteredProof_StackedDRG2KiBPoSt, nd3.Cid(), node)
 		require.Error(t, err)
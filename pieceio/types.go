@@ -1,6 +1,7 @@
 package pieceio
 
 import (
+	"context"
 	"io"
 
 	"github.com/filecoin-project/specs-actors/actors/abi"
@@ -20,9 +21,17 @@ type ReadStore interface {
 	Get(cid.Cid) (blocks.Block, error)
 }
 
+// ProgressFunc is called with the number of bytes of a CAR processed so far,
+// out of its total size
+type ProgressFunc func(processed uint64, total uint64)
+
 // PieceIO converts between payloads and pieces
 type PieceIO interface {
 	GeneratePieceCommitment(rt abi.RegisteredProof, payloadCid cid.Cid, selector ipld.Node) (cid.Cid, abi.UnpaddedPieceSize, error)
+	// GeneratePieceCommitmentWithProgress streams the CAR for a payload into
+	// the commitment function, calling progress as the CAR is processed. It
+	// stops with the context's error when the context is cancelled
+	GeneratePieceCommitmentWithProgress(ctx context.Context, rt abi.RegisteredProof, payloadCid cid.Cid, selector ipld.Node, progress ProgressFunc) (cid.Cid, abi.UnpaddedPieceSize, error)
 	ReadPiece(r io.Reader) (cid.Cid, error)
 }
 
@@ -3,6 +3,7 @@ package blockio
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 
 	"github.com/ipld/go-ipld-prime"
@@ -16,8 +17,14 @@ type BlockReader interface {
 	// ReadBlock reads data from a single block. Data is nil
 	// for intermediate nodes
 	ReadBlock(context.Context) (retrievalmarket.Block, bool, error)
+
+	// Close stops reading blocks. Later reads fail
+	Close(context.Context)
 }
 
+// ErrReaderClosed means a block was read from a closed reader
+var ErrReaderClosed = errors.New("block reader closed")
+
 // SelectorBlockReader reads an ipld data structure in individual blocks
 // allowing the next block to be read and then advancing no further
 type SelectorBlockReader struct {
@@ -25,17 +32,20 @@ type SelectorBlockReader struct {
 	selector  ipld.Node
 	loader    ipld.Loader
 	traverser *Traverser
+	closed    bool
 }
 
 // NewSelectorBlockReader returns a new Block reader starting at the given
 // root and using the given loader
 func NewSelectorBlockReader(root ipld.Link, sel ipld.Node, loader ipld.Loader) BlockReader {
-	return &SelectorBlockReader{root, sel, loader, nil}
+	return &SelectorBlockReader{root, sel, loader, nil, false}
 }
 
 // ReadBlock reads the next block in the IPLD traversal
 func (sr *SelectorBlockReader) ReadBlock(ctx context.Context) (retrievalmarket.Block, bool, error) {
-
+	if sr.closed {
+		return retrievalmarket.EmptyBlock, false, ErrReaderClosed
+	}
 	if sr.traverser == nil {
 		sr.traverser = NewTraverser(sr.root, sr.selector)
 		sr.traverser.Start(ctx)
@@ -59,3 +69,14 @@ func (sr *SelectorBlockReader) ReadBlock(ctx context.Context) (retrievalmarket.B
 	err = sr.traverser.Advance(ctx, &buf)
 	return block, sr.traverser.IsComplete(ctx), err
 }
+
+// Close stops the IPLD traversal, if it is still running
+func (sr *SelectorBlockReader) Close(ctx context.Context) {
+	if sr.closed {
+		return
+	}
+	sr.closed = true
+	if sr.traverser != nil {
+		sr.traverser.Error(ctx, ErrReaderClosed)
+	}
+}
@@ -33,6 +33,18 @@ func TestSelectorReader(t *testing.T) {
 		})
 	})
 
+	t.Run("fails to read once closed", func(t *testing.T) {
+		reader := blockio.NewSelectorBlockReader(testdata.RootNodeLnk, shared.AllSelector(), testdata.Loader)
+
+		for i := 0; i < 2; i++ {
+			_, done, err := reader.ReadBlock(ctx)
+			require.NoError(t, err)
+			require.False(t, done)
+		}
+		reader.Close(ctx)
+		_, _, err := reader.ReadBlock(ctx)
+		require.Equal(t, blockio.ErrReaderClosed, err)
+	})
 }
 
 func checkReadSequence(ctx context.Context, t *testing.T, reader blockio.BlockReader, expectedBlks []blocks.Block) {
@@ -88,7 +88,10 @@ func (c *client) FindProviders(payloadCID cid.Cid) []retrievalmarket.RetrievalPe
 	return peers
 }
 
-func (c *client) Query(_ context.Context, p retrievalmarket.RetrievalPeer, payloadCID cid.Cid, params retrievalmarket.QueryParams) (retrievalmarket.QueryResponse, error) {
+// Query asks a provider for information about a piece it is storing. When the
+// provider sends the ask its terms were quoted from, the ask's signature and
+// terms are verified
+func (c *client) Query(ctx context.Context, p retrievalmarket.RetrievalPeer, payloadCID cid.Cid, params retrievalmarket.QueryParams) (retrievalmarket.QueryResponse, error) {
 	s, err := c.network.NewQueryStream(p.ID)
 	if err != nil {
 		log.Warn(err)
@@ -105,12 +108,59 @@ func (c *client) Query(_ context.Context, p retrievalmarket.RetrievalPeer, paylo
 		return retrievalmarket.QueryResponseUndefined, err
 	}
 
-	return s.ReadQueryResponse()
+	resp, err := s.ReadQueryResponse()
+	if err != nil {
+		return retrievalmarket.QueryResponseUndefined, err
+	}
+
+	if resp.Ask != nil {
+		if err := c.verifyAsk(ctx, p, resp); err != nil {
+			return retrievalmarket.QueryResponseUndefined, xerrors.Errorf("verifying retrieval ask: %w", err)
+		}
+	}
+	return resp, nil
+}
+
+// verifyAsk checks that the terms in a query response were quoted from an ask
+// the miner signed
+func (c *client) verifyAsk(ctx context.Context, p retrievalmarket.RetrievalPeer, resp retrievalmarket.QueryResponse) error {
+	ask := resp.Ask.Ask
+	if ask == nil {
+		return xerrors.New("ask is empty")
+	}
+	if ask.Miner != p.Address {
+		return xerrors.Errorf("ask is for miner %s, not %s", ask.Miner, p.Address)
+	}
+
+	tok, _, err := c.node.GetChainHead(ctx)
+	if err != nil {
+		return err
+	}
+	valid, err := c.node.ValidateAskSignature(ctx, resp.Ask, tok)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return xerrors.New("invalid ask signature")
+	}
+
+	if !resp.MinPricePerByte.Equals(ask.PricePerByte) ||
+		resp.MaxPaymentInterval != ask.PaymentInterval ||
+		resp.MaxPaymentIntervalIncrease != ask.PaymentIntervalIncrease {
+		return xerrors.New("quoted terms do not match the ask")
+	}
+	if !resp.UnsealPrice.Nil() && !resp.UnsealPrice.IsZero() && !resp.UnsealPrice.Equals(ask.UnsealPrice) {
+		return xerrors.New("quoted unseal price does not match the ask")
+	}
+	return nil
 }
 
 // Retrieve begins the process of requesting the data referred to by payloadCID, after a deal is accepted
 func (c *client) Retrieve(ctx context.Context, payloadCID cid.Cid, params retrievalmarket.Params, totalFunds abi.TokenAmount, miner peer.ID, clientWallet address.Address, minerWallet address.Address) (retrievalmarket.DealID, error) {
 	var err error
+	if params.UnsealPrice.Nil() {
+		params.UnsealPrice = abi.NewTokenAmount(0)
+	}
 	next, err := c.storedCounter.Next()
 	if err != nil {
 		return 0, err
@@ -131,6 +181,7 @@ func (c *client) Retrieve(ctx context.Context, payloadCID cid.Cid, params retrie
 		BytesPaidFor:     0,
 		PaymentRequested: abi.NewTokenAmount(0),
 		FundsSpent:       abi.NewTokenAmount(0),
+		UnsealFundsPaid:  abi.NewTokenAmount(0),
 		Status:           retrievalmarket.DealStatusNew,
 		Sender:           miner,
 	}
@@ -205,20 +256,103 @@ func (c *client) SubscribeToEvents(subscriber retrievalmarket.ClientSubscriber)
 }
 
 // V1
-func (c *client) AddMoreFunds(retrievalmarket.DealID, abi.TokenAmount) error {
-	panic("not implemented")
+
+// AddMoreFunds tops up the payment channel of a deal that has run out of
+// funds, and resumes the deal by paying the provider's outstanding request
+func (c *client) AddMoreFunds(id retrievalmarket.DealID, amount abi.TokenAmount) error {
+	if amount.Nil() || amount.Sign() <= 0 {
+		return xerrors.Errorf("amount to add must be positive, got %s", amount)
+	}
+
+	var deal retrievalmarket.ClientDealState
+	if err := c.stateMachines.Get(id).Get(&deal); err != nil {
+		return xerrors.Errorf("getting deal %d: %w", id, err)
+	}
+	if deal.Status != retrievalmarket.DealStatusInsufficientFunds && deal.Status != retrievalmarket.DealStatusInsufficientFundsLastPayment {
+		return xerrors.Errorf("deal %d is not waiting for funds, status is %s", id, retrievalmarket.DealStatuses[deal.Status])
+	}
+
+	ctx := context.TODO()
+	tok, _, err := c.node.GetChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	_, msgCID, err := c.node.GetOrCreatePaymentChannel(ctx, deal.ClientWallet, deal.MinerWallet, amount, tok)
+	if err != nil {
+		return xerrors.Errorf("adding funds to payment channel: %w", err)
+	}
+	if err := c.node.WaitForPaymentChannelAddFunds(msgCID); err != nil {
+		return xerrors.Errorf("waiting for funds to be added to payment channel: %w", err)
+	}
+
+	if err := c.stateMachines.SendSync(ctx, id, retrievalmarket.ClientEventFundsAdded, amount); err != nil {
+		return xerrors.Errorf("funds were added to the payment channel, but not to deal %d: %w", id, err)
+	}
+	return nil
 }
 
-func (c *client) CancelDeal(retrievalmarket.DealID) error {
-	panic("not implemented")
+// CancelDeal stops a deal before it completes. The client pays the provider
+// for the data it has received, tells the provider the deal is cancelled and
+// closes the deal stream
+func (c *client) CancelDeal(id retrievalmarket.DealID) error {
+	var deal retrievalmarket.ClientDealState
+	if err := c.stateMachines.Get(id).Get(&deal); err != nil {
+		return xerrors.Errorf("getting deal %d: %w", id, err)
+	}
+	if retrievalmarket.IsTerminalStatus(deal.Status) ||
+		deal.Status == retrievalmarket.DealStatusErrored ||
+		deal.Status == retrievalmarket.DealStatusCancelling {
+		return xerrors.Errorf("deal %d cannot be cancelled, status is %s", id, retrievalmarket.DealStatuses[deal.Status])
+	}
+
+	return c.stateMachines.Send(id, retrievalmarket.ClientEventCancel)
 }
 
-func (c *client) RetrievalStatus(retrievalmarket.DealID) {
-	panic("not implemented")
+// RetrievalStatus returns the current state of a deal
+func (c *client) RetrievalStatus(id retrievalmarket.DealID) (retrievalmarket.ClientDealState, error) {
+	var deal retrievalmarket.ClientDealState
+	if err := c.stateMachines.Get(id).Get(&deal); err != nil {
+		return retrievalmarket.ClientDealState{}, xerrors.Errorf("getting deal %d: %w", id, err)
+	}
+	return deal, nil
 }
 
+// ListDeals returns all deals the client has made
 func (c *client) ListDeals() map[retrievalmarket.DealID]retrievalmarket.ClientDealState {
-	panic("not implemented")
+	deals, err := c.FilterDeals(retrievalmarket.DealFilter{})
+	if err != nil {
+		log.Errorf("listing retrieval deals: %s", err)
+	}
+	return deals
+}
+
+// FilterDeals returns the deals the client has made that match the filter
+func (c *client) FilterDeals(filter retrievalmarket.DealFilter) (map[retrievalmarket.DealID]retrievalmarket.ClientDealState, error) {
+	var deals []retrievalmarket.ClientDealState
+	if err := c.stateMachines.List(&deals); err != nil {
+		return nil, xerrors.Errorf("listing deals: %w", err)
+	}
+	out := make(map[retrievalmarket.DealID]retrievalmarket.ClientDealState)
+	for _, deal := range deals {
+		if filter.MatchesClientDeal(deal) {
+			out[deal.ID] = deal
+		}
+	}
+	return out, nil
+}
+
+// DealStatusCounts returns the number of deals the client has in each status
+func (c *client) DealStatusCounts() (map[retrievalmarket.DealStatus]int, error) {
+	var deals []retrievalmarket.ClientDealState
+	if err := c.stateMachines.List(&deals); err != nil {
+		return nil, xerrors.Errorf("listing deals: %w", err)
+	}
+	counts := make(map[retrievalmarket.DealStatus]int)
+	for _, deal := range deals {
+		counts[deal.Status]++
+	}
+	return counts, nil
 }
 
 func (c *client) Node() retrievalmarket.RetrievalClientNode {
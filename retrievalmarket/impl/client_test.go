@@ -143,6 +143,97 @@ func TestClient_Query(t *testing.T) {
 		assert.EqualError(t, err, "query response failed")
 		assert.Equal(t, retrievalmarket.QueryResponseUndefined, statusCode)
 	})
+
+	t.Run("verifying the ask", func(t *testing.T) {
+		validAsk := func() *retrievalmarket.SignedRetrievalAsk {
+			return &retrievalmarket.SignedRetrievalAsk{
+				Ask: &retrievalmarket.RetrievalAsk{
+					PricePerByte:            expectedQueryResponse.MinPricePerByte,
+					UnsealPrice:             abi.NewTokenAmount(0),
+					PaymentInterval:         expectedQueryResponse.MaxPaymentInterval,
+					PaymentIntervalIncrease: expectedQueryResponse.MaxPaymentIntervalIncrease,
+					Miner:                   rpeer.Address,
+				},
+				Signature: tut.MakeTestSignature(),
+			}
+		}
+		testCases := map[string]struct {
+			ask           func() *retrievalmarket.SignedRetrievalAsk
+			unsealPrice   abi.TokenAmount
+			validationErr error
+			expErr        string
+		}{
+			"valid ask": {
+				ask: validAsk,
+			},
+			"valid ask with an unseal price": {
+				ask: func() *retrievalmarket.SignedRetrievalAsk {
+					ask := validAsk()
+					ask.Ask.UnsealPrice = abi.NewTokenAmount(100)
+					return ask
+				},
+				unsealPrice: abi.NewTokenAmount(100),
+			},
+			"invalid signature": {
+				ask:           validAsk,
+				validationErr: errors.New("bad signature"),
+				expErr:        "verifying retrieval ask: bad signature",
+			},
+			"ask for another miner": {
+				ask: func() *retrievalmarket.SignedRetrievalAsk {
+					ask := validAsk()
+					ask.Ask.Miner = address.TestAddress
+					return ask
+				},
+				expErr: "verifying retrieval ask: ask is for miner " + address.TestAddress.String() + ", not " + rpeer.Address.String(),
+			},
+			"price does not match the ask": {
+				ask: func() *retrievalmarket.SignedRetrievalAsk {
+					ask := validAsk()
+					ask.Ask.PricePerByte = abi.NewTokenAmount(1)
+					return ask
+				},
+				expErr: "verifying retrieval ask: quoted terms do not match the ask",
+			},
+			"unseal price does not match the ask": {
+				ask:         validAsk,
+				unsealPrice: abi.NewTokenAmount(100),
+				expErr:      "verifying retrieval ask: quoted unseal price does not match the ask",
+			},
+		}
+		for name, tc := range testCases {
+			t.Run(name, func(t *testing.T) {
+				response := expectedQueryResponse
+				response.UnsealPrice = tc.unsealPrice
+				response.Ask = tc.ask()
+				var qsb tut.QueryStreamBuilder = func(p peer.ID) (rmnet.RetrievalQueryStream, error) {
+					return tut.NewTestRetrievalQueryStream(tut.TestQueryStreamParams{
+						RespReader: tut.StubbedQueryResponseReader(response),
+					}), nil
+				}
+				net := tut.NewTestRetrievalMarketNetwork(tut.TestNetworkParams{
+					QueryStreamBuilder: qsb,
+				})
+				c, err := retrievalimpl.NewClient(
+					net,
+					bs,
+					testnodes.NewTestRetrievalClientNode(testnodes.TestRetrievalClientNodeParams{AskValidationErr: tc.validationErr}),
+					&tut.TestPeerResolver{},
+					ds,
+					storedCounter)
+				require.NoError(t, err)
+
+				resp, err := c.Query(ctx, rpeer, pcid, retrievalmarket.QueryParams{})
+				if tc.expErr == "" {
+					require.NoError(t, err)
+					assert.Equal(t, response, resp)
+				} else {
+					assert.EqualError(t, err, tc.expErr)
+					assert.Equal(t, retrievalmarket.QueryResponseUndefined, resp)
+				}
+			})
+		}
+	})
 }
 
 func TestClient_FindProviders(t *testing.T) {
@@ -104,11 +104,20 @@ var ClientEvents = fsm.Events{
 			return nil
 		}),
 	fsm.Event(rm.ClientEventFundsExpended).
-		FromMany(rm.DealStatusFundsNeeded, rm.DealStatusFundsNeededLastPayment).To(rm.DealStatusFailed).
+		From(rm.DealStatusFundsNeeded).To(rm.DealStatusInsufficientFunds).
+		From(rm.DealStatusFundsNeededLastPayment).To(rm.DealStatusInsufficientFundsLastPayment).
 		Action(func(deal *rm.ClientDealState, expectedTotal string, actualTotal string) error {
 			deal.Message = fmt.Sprintf("not enough funds left: expected amt = %s, actual amt = %s", expectedTotal, actualTotal)
 			return nil
 		}),
+	fsm.Event(rm.ClientEventFundsAdded).
+		From(rm.DealStatusInsufficientFunds).To(rm.DealStatusFundsNeeded).
+		From(rm.DealStatusInsufficientFundsLastPayment).To(rm.DealStatusFundsNeededLastPayment).
+		Action(func(deal *rm.ClientDealState, amount abi.TokenAmount) error {
+			deal.TotalFunds = big.Add(deal.TotalFunds, amount)
+			deal.Message = ""
+			return nil
+		}),
 	fsm.Event(rm.ClientEventBadPaymentRequested).
 		FromMany(rm.DealStatusFundsNeeded, rm.DealStatusFundsNeededLastPayment).To(rm.DealStatusFailed).
 		Action(func(deal *rm.ClientDealState, message string) error {
@@ -116,7 +125,7 @@ var ClientEvents = fsm.Events{
 			return nil
 		}),
 	fsm.Event(rm.ClientEventCreateVoucherFailed).
-		FromMany(rm.DealStatusFundsNeeded, rm.DealStatusFundsNeededLastPayment).To(rm.DealStatusFailed).
+		FromMany(rm.DealStatusFundsNeeded, rm.DealStatusFundsNeededLastPayment, rm.DealStatusCancelling).To(rm.DealStatusFailed).
 		Action(func(deal *rm.ClientDealState, err error) error {
 			deal.Message = xerrors.Errorf("creating payment voucher: %w", err).Error()
 			return nil
@@ -145,6 +154,14 @@ var ClientEvents = fsm.Events{
 			deal.PaymentRequested = abi.NewTokenAmount(0)
 			return nil
 		}),
+	fsm.Event(rm.ClientEventUnsealPaymentSent).
+		From(rm.DealStatusFundsNeeded).To(rm.DealStatusOngoing).
+		Action(func(deal *rm.ClientDealState) error {
+			deal.FundsSpent = big.Add(deal.FundsSpent, deal.PaymentRequested)
+			deal.UnsealFundsPaid = big.Add(deal.UnsealFundsPaid, deal.PaymentRequested)
+			deal.PaymentRequested = abi.NewTokenAmount(0)
+			return nil
+		}),
 	fsm.Event(rm.ClientEventConsumeBlockFailed).
 		FromMany(rm.DealStatusPaymentChannelReady, rm.DealStatusOngoing).To(rm.DealStatusFailed).
 		Action(func(deal *rm.ClientDealState, err error) error {
@@ -180,6 +197,29 @@ var ClientEvents = fsm.Events{
 		From(rm.DealStatusPaymentChannelReady).To(rm.DealStatusOngoing).
 		From(rm.DealStatusOngoing).ToNoChange().
 		Action(recordProcessed),
+	fsm.Event(rm.ClientEventCancel).
+		FromMany(rm.DealStatusNew,
+			rm.DealStatusAccepted,
+			rm.DealStatusPaymentChannelCreating,
+			rm.DealStatusPaymentChannelAddingFunds,
+			rm.DealStatusPaymentChannelReady,
+			rm.DealStatusOngoing,
+			rm.DealStatusBlocksComplete,
+			rm.DealStatusFundsNeeded,
+			rm.DealStatusFundsNeededLastPayment,
+			rm.DealStatusFinalizing,
+			rm.DealStatusInsufficientFunds,
+			rm.DealStatusInsufficientFundsLastPayment).To(rm.DealStatusCancelling),
+	fsm.Event(rm.ClientEventCancelComplete).
+		From(rm.DealStatusCancelling).To(rm.DealStatusCancelled).
+		Action(func(deal *rm.ClientDealState, payment abi.TokenAmount) error {
+			if payment.GreaterThan(big.Zero()) {
+				deal.FundsSpent = big.Add(deal.FundsSpent, payment)
+				deal.BytesPaidFor += big.Div(payment, deal.PricePerByte).Uint64()
+			}
+			deal.PaymentRequested = abi.NewTokenAmount(0)
+			return nil
+		}),
 }
 
 // ClientStateEntryFuncs are the handlers for different states in a retrieval client
@@ -194,4 +234,5 @@ var ClientStateEntryFuncs = fsm.StateEntryFuncs{
 	rm.DealStatusFundsNeeded:               ProcessPaymentRequested,
 	rm.DealStatusFundsNeededLastPayment:    ProcessPaymentRequested,
 	rm.DealStatusFinalizing:                Finalize,
+	rm.DealStatusCancelling:                CancelDeal,
 }
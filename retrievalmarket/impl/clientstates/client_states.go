@@ -99,14 +99,24 @@ func ProcessPaymentRequested(ctx fsm.Context, environment ClientDealEnvironment,
 		return ctx.Trigger(rm.ClientEventFundsExpended, expectedTotal, actualTotal)
 	}
 
-	// check that totalReceived - bytesPaidFor >= currentInterval, or fail
-	if (deal.TotalReceived-deal.BytesPaidFor < deal.CurrentInterval) && deal.Status != rm.DealStatusFundsNeededLastPayment {
-		return ctx.Trigger(rm.ClientEventBadPaymentRequested, "not enough bytes received between payment request")
-	}
+	// the provider asks for the unseal price up front, before it sends any
+	// data, so check that paymentRequested is exactly what is left of it
+	unsealOwed := big.Sub(deal.UnsealPrice, deal.UnsealFundsPaid)
+	unsealing := unsealOwed.GreaterThan(big.Zero())
+	if unsealing {
+		if !deal.PaymentRequested.Equals(unsealOwed) {
+			return ctx.Trigger(rm.ClientEventBadPaymentRequested, "payment requested does not match the unseal price")
+		}
+	} else {
+		// check that totalReceived - bytesPaidFor >= currentInterval, or fail
+		if (deal.TotalReceived-deal.BytesPaidFor < deal.CurrentInterval) && deal.Status != rm.DealStatusFundsNeededLastPayment {
+			return ctx.Trigger(rm.ClientEventBadPaymentRequested, "not enough bytes received between payment request")
+		}
 
-	// check that paymentRequest <= (totalReceived - bytesPaidFor) * pricePerByte, or fail
-	if deal.PaymentRequested.GreaterThan(big.Mul(abi.NewTokenAmount(int64(deal.TotalReceived-deal.BytesPaidFor)), deal.PricePerByte)) {
-		return ctx.Trigger(rm.ClientEventBadPaymentRequested, "too much money requested for bytes sent")
+		// check that paymentRequest <= (totalReceived - bytesPaidFor) * pricePerByte, or fail
+		if deal.PaymentRequested.GreaterThan(big.Mul(abi.NewTokenAmount(int64(deal.TotalReceived-deal.BytesPaidFor)), deal.PricePerByte)) {
+			return ctx.Trigger(rm.ClientEventBadPaymentRequested, "too much money requested for bytes sent")
+		}
 	}
 
 	tok, _, err := environment.Node().GetChainHead(ctx.Context())
@@ -132,6 +142,9 @@ func ProcessPaymentRequested(ctx fsm.Context, environment ClientDealEnvironment,
 		return ctx.Trigger(rm.ClientEventWriteDealPaymentErrored, err)
 	}
 
+	if unsealing {
+		return ctx.Trigger(rm.ClientEventUnsealPaymentSent)
+	}
 	return ctx.Trigger(rm.ClientEventPaymentSent)
 }
 
@@ -199,3 +212,48 @@ func Finalize(ctx fsm.Context, environment ClientDealEnvironment, deal rm.Client
 
 	return ctx.Trigger(rm.ClientEventComplete, uint64(0))
 }
+
+// CancelDeal pays for the data received that hasn't been paid for yet, tells
+// the provider the deal is cancelled and closes the deal stream. A deal
+// cancelled before its payment channel is set up has no way to pay, so the
+// stream is just closed
+func CancelDeal(ctx fsm.Context, environment ClientDealEnvironment, deal rm.ClientDealState) error {
+	stream := environment.DealStream(deal.ID)
+	if deal.PaymentInfo == nil {
+		_ = stream.Close()
+		return ctx.Trigger(rm.ClientEventCancelComplete, big.Zero())
+	}
+
+	payment := rm.DealPayment{
+		ID:             deal.DealProposal.ID,
+		PaymentChannel: deal.PaymentInfo.PayCh,
+		Cancel:         true,
+	}
+
+	// pay for bytes received so far, as far as the deal's funds go
+	owed := big.Mul(abi.NewTokenAmount(int64(deal.TotalReceived-deal.BytesPaidFor)), deal.PricePerByte)
+	if big.Add(deal.FundsSpent, owed).GreaterThan(deal.TotalFunds) {
+		owed = big.Sub(deal.TotalFunds, deal.FundsSpent)
+	}
+	if owed.GreaterThan(big.Zero()) {
+		tok, _, err := environment.Node().GetChainHead(ctx.Context())
+		if err != nil {
+			return ctx.Trigger(rm.ClientEventCreateVoucherFailed, err)
+		}
+		voucher, err := environment.Node().CreatePaymentVoucher(ctx.Context(), deal.PaymentInfo.PayCh, big.Add(deal.FundsSpent, owed), deal.PaymentInfo.Lane, tok)
+		if err != nil {
+			return ctx.Trigger(rm.ClientEventCreateVoucherFailed, err)
+		}
+		payment.PaymentVoucher = voucher
+	} else {
+		owed = big.Zero()
+	}
+
+	err := stream.WriteDealPayment(payment)
+	if err != nil {
+		return ctx.Trigger(rm.ClientEventWriteDealPaymentErrored, err)
+	}
+	_ = stream.Close()
+
+	return ctx.Trigger(rm.ClientEventCancelComplete, owed)
+}
@@ -370,7 +370,19 @@ func TestProcessPaymentRequested(t *testing.T) {
 		}
 		runProcessPaymentRequested(t, dealStreamParams, nodeParams, dealState)
 		require.NotEmpty(t, dealState.Message)
-		require.Equal(t, dealState.Status, retrievalmarket.DealStatusFailed)
+		require.Equal(t, dealState.Status, retrievalmarket.DealStatusInsufficientFunds)
+	})
+
+	t.Run("not enough funds left for last payment", func(t *testing.T) {
+		dealState := makeDealState(retrievalmarket.DealStatusFundsNeededLastPayment)
+		dealState.FundsSpent = defaultTotalFunds
+		dealStreamParams := testnet.TestDealStreamParams{}
+		nodeParams := testnodes.TestRetrievalClientNodeParams{
+			Voucher: testVoucher,
+		}
+		runProcessPaymentRequested(t, dealStreamParams, nodeParams, dealState)
+		require.NotEmpty(t, dealState.Message)
+		require.Equal(t, dealState.Status, retrievalmarket.DealStatusInsufficientFundsLastPayment)
 	})
 
 	t.Run("not enough bytes since last payment", func(t *testing.T) {
@@ -415,6 +427,56 @@ func TestProcessPaymentRequested(t *testing.T) {
 		require.Equal(t, dealState.Status, retrievalmarket.DealStatusFailed)
 	})
 
+	t.Run("unseal payment", func(t *testing.T) {
+		dealState := makeDealState(retrievalmarket.DealStatusFundsNeeded)
+		dealState.UnsealPrice = defaultUnsealPrice
+		dealState.TotalReceived = 0
+		dealState.BytesPaidFor = 0
+		dealState.FundsSpent = big.Zero()
+		dealState.PaymentRequested = defaultUnsealPrice
+		dealStreamParams := testnet.TestDealStreamParams{}
+		nodeParams := testnodes.TestRetrievalClientNodeParams{
+			Voucher: testVoucher,
+		}
+		runProcessPaymentRequested(t, dealStreamParams, nodeParams, dealState)
+		require.Empty(t, dealState.Message)
+		require.Equal(t, dealState.PaymentRequested, abi.NewTokenAmount(0))
+		require.Equal(t, dealState.FundsSpent, defaultUnsealPrice)
+		require.Equal(t, dealState.UnsealFundsPaid, defaultUnsealPrice)
+		require.Equal(t, dealState.BytesPaidFor, uint64(0))
+		require.Equal(t, dealState.CurrentInterval, defaultCurrentInterval)
+		require.Equal(t, dealState.Status, retrievalmarket.DealStatusOngoing)
+	})
+
+	t.Run("more than the unseal price requested", func(t *testing.T) {
+		dealState := makeDealState(retrievalmarket.DealStatusFundsNeeded)
+		dealState.UnsealPrice = defaultUnsealPrice
+		dealState.TotalReceived = 0
+		dealState.BytesPaidFor = 0
+		dealState.PaymentRequested = big.Add(defaultUnsealPrice, abi.NewTokenAmount(1))
+		dealStreamParams := testnet.TestDealStreamParams{}
+		nodeParams := testnodes.TestRetrievalClientNodeParams{
+			Voucher: testVoucher,
+		}
+		runProcessPaymentRequested(t, dealStreamParams, nodeParams, dealState)
+		require.Equal(t, dealState.Message, "payment requested does not match the unseal price")
+		require.Equal(t, dealState.Status, retrievalmarket.DealStatusFailed)
+	})
+
+	t.Run("unseal price already paid", func(t *testing.T) {
+		dealState := makeDealState(retrievalmarket.DealStatusFundsNeeded)
+		dealState.UnsealPrice = defaultUnsealPrice
+		dealState.UnsealFundsPaid = defaultUnsealPrice
+		dealState.PaymentRequested = big.Add(defaultPaymentRequested, defaultUnsealPrice)
+		dealStreamParams := testnet.TestDealStreamParams{}
+		nodeParams := testnodes.TestRetrievalClientNodeParams{
+			Voucher: testVoucher,
+		}
+		runProcessPaymentRequested(t, dealStreamParams, nodeParams, dealState)
+		require.NotEmpty(t, dealState.Message)
+		require.Equal(t, dealState.Status, retrievalmarket.DealStatusFailed)
+	})
+
 	t.Run("too little payment requested works but records correctly", func(t *testing.T) {
 		dealState := makeDealState(retrievalmarket.DealStatusFundsNeeded)
 		smallerPaymentRequested := abi.NewTokenAmount(250000)
@@ -603,6 +665,170 @@ func TestProcessNextResponse(t *testing.T) {
 	})
 }
 
+func TestAddFunds(t *testing.T) {
+	ctx := context.Background()
+	eventMachine, err := fsm.NewEventProcessor(retrievalmarket.ClientDealState{}, "Status", clientstates.ClientEvents)
+	require.NoError(t, err)
+	addFunds := func(t *testing.T, dealState *retrievalmarket.ClientDealState, amount abi.TokenAmount) {
+		fsmCtx := fsmtest.NewTestContext(ctx, eventMachine)
+		err := fsmCtx.Trigger(retrievalmarket.ClientEventFundsAdded, amount)
+		require.NoError(t, err)
+		fsmCtx.ReplayEvents(t, dealState)
+	}
+	runProcessPaymentRequested := func(t *testing.T, dealState *retrievalmarket.ClientDealState) {
+		ds := testnet.NewTestRetrievalDealStream(testnet.TestDealStreamParams{})
+		node := testnodes.NewTestRetrievalClientNode(testnodes.TestRetrievalClientNodeParams{
+			Voucher: &paych.SignedVoucher{},
+		})
+		environment := &fakeEnvironment{node, ds, 0, nil}
+		fsmCtx := fsmtest.NewTestContext(ctx, eventMachine)
+		err := clientstates.ProcessPaymentRequested(fsmCtx, environment, *dealState)
+		require.NoError(t, err)
+		fsmCtx.ReplayEvents(t, dealState)
+	}
+
+	t.Run("resumes payment after funds are added", func(t *testing.T) {
+		dealState := makeDealState(retrievalmarket.DealStatusInsufficientFunds)
+		dealState.FundsSpent = defaultTotalFunds
+		dealState.Message = "not enough funds left"
+		addFunds(t, dealState, defaultPaymentRequested)
+		require.Equal(t, retrievalmarket.DealStatusFundsNeeded, dealState.Status)
+		require.Equal(t, big.Add(defaultTotalFunds, defaultPaymentRequested), dealState.TotalFunds)
+		require.Empty(t, dealState.Message)
+
+		runProcessPaymentRequested(t, dealState)
+		require.Empty(t, dealState.Message)
+		require.Equal(t, dealState.TotalFunds, dealState.FundsSpent)
+		require.Equal(t, abi.NewTokenAmount(0), dealState.PaymentRequested)
+		require.Equal(t, retrievalmarket.DealStatusOngoing, dealState.Status)
+	})
+
+	t.Run("resumes last payment after funds are added", func(t *testing.T) {
+		dealState := makeDealState(retrievalmarket.DealStatusInsufficientFundsLastPayment)
+		dealState.FundsSpent = defaultTotalFunds
+		addFunds(t, dealState, defaultPaymentRequested)
+		require.Equal(t, retrievalmarket.DealStatusFundsNeededLastPayment, dealState.Status)
+
+		runProcessPaymentRequested(t, dealState)
+		require.Empty(t, dealState.Message)
+		require.Equal(t, retrievalmarket.DealStatusFinalizing, dealState.Status)
+	})
+
+	t.Run("waits for more funds when not enough are added", func(t *testing.T) {
+		dealState := makeDealState(retrievalmarket.DealStatusInsufficientFunds)
+		dealState.FundsSpent = defaultTotalFunds
+		addFunds(t, dealState, big.Sub(defaultPaymentRequested, abi.NewTokenAmount(1)))
+		require.Equal(t, retrievalmarket.DealStatusFundsNeeded, dealState.Status)
+
+		runProcessPaymentRequested(t, dealState)
+		require.NotEmpty(t, dealState.Message)
+		require.Equal(t, defaultTotalFunds, dealState.FundsSpent)
+		require.Equal(t, retrievalmarket.DealStatusInsufficientFunds, dealState.Status)
+	})
+}
+
+func TestCancelDeal(t *testing.T) {
+	ctx := context.Background()
+	eventMachine, err := fsm.NewEventProcessor(retrievalmarket.ClientDealState{}, "Status", clientstates.ClientEvents)
+	require.NoError(t, err)
+	runCancelDeal := func(t *testing.T,
+		netParams testnet.TestDealStreamParams,
+		nodeParams testnodes.TestRetrievalClientNodeParams,
+		dealState *retrievalmarket.ClientDealState) {
+		ds := testnet.NewTestRetrievalDealStream(netParams)
+		node := testnodes.NewTestRetrievalClientNode(nodeParams)
+		environment := &fakeEnvironment{node, ds, 0, nil}
+		fsmCtx := fsmtest.NewTestContext(ctx, eventMachine)
+		err := clientstates.CancelDeal(fsmCtx, environment, *dealState)
+		require.NoError(t, err)
+		fsmCtx.ReplayEvents(t, dealState)
+	}
+	recordPayment := func(payment *retrievalmarket.DealPayment) testnet.DealPaymentWriter {
+		return func(dp retrievalmarket.DealPayment) error {
+			*payment = dp
+			return nil
+		}
+	}
+
+	testVoucher := &paych.SignedVoucher{}
+
+	t.Run("pays for bytes received", func(t *testing.T) {
+		var payment retrievalmarket.DealPayment
+		dealState := makeDealState(retrievalmarket.DealStatusCancelling)
+		dealStreamParams := testnet.TestDealStreamParams{
+			PaymentWriter: recordPayment(&payment),
+		}
+		nodeParams := testnodes.TestRetrievalClientNodeParams{
+			Voucher: testVoucher,
+		}
+		runCancelDeal(t, dealStreamParams, nodeParams, dealState)
+		require.True(t, payment.Cancel)
+		require.Equal(t, testVoucher, payment.PaymentVoucher)
+		require.Equal(t, retrievalmarket.DealStatusCancelled, dealState.Status)
+		require.Equal(t, big.Add(defaultFundsSpent, defaultPaymentRequested), dealState.FundsSpent)
+		require.Equal(t, defaultTotalReceived, dealState.BytesPaidFor)
+		require.Equal(t, abi.NewTokenAmount(0), dealState.PaymentRequested)
+	})
+
+	t.Run("pays no more than the deal's funds", func(t *testing.T) {
+		var payment retrievalmarket.DealPayment
+		dealState := makeDealState(retrievalmarket.DealStatusCancelling)
+		dealState.FundsSpent = big.Sub(defaultTotalFunds, abi.NewTokenAmount(200000))
+		dealStreamParams := testnet.TestDealStreamParams{
+			PaymentWriter: recordPayment(&payment),
+		}
+		nodeParams := testnodes.TestRetrievalClientNodeParams{
+			Voucher: testVoucher,
+		}
+		runCancelDeal(t, dealStreamParams, nodeParams, dealState)
+		require.True(t, payment.Cancel)
+		require.Equal(t, retrievalmarket.DealStatusCancelled, dealState.Status)
+		require.Equal(t, defaultTotalFunds, dealState.FundsSpent)
+		require.Equal(t, defaultBytesPaidFor+400, dealState.BytesPaidFor)
+	})
+
+	t.Run("sends no voucher when nothing is owed", func(t *testing.T) {
+		var payment retrievalmarket.DealPayment
+		dealState := makeDealState(retrievalmarket.DealStatusCancelling)
+		dealState.BytesPaidFor = defaultTotalReceived
+		dealStreamParams := testnet.TestDealStreamParams{
+			PaymentWriter: recordPayment(&payment),
+		}
+		nodeParams := testnodes.TestRetrievalClientNodeParams{
+			VoucherError: errors.New("no voucher should be created"),
+		}
+		runCancelDeal(t, dealStreamParams, nodeParams, dealState)
+		require.True(t, payment.Cancel)
+		require.Nil(t, payment.PaymentVoucher)
+		require.Equal(t, retrievalmarket.DealStatusCancelled, dealState.Status)
+		require.Equal(t, defaultFundsSpent, dealState.FundsSpent)
+	})
+
+	t.Run("voucher create fails", func(t *testing.T) {
+		dealState := makeDealState(retrievalmarket.DealStatusCancelling)
+		dealStreamParams := testnet.TestDealStreamParams{}
+		nodeParams := testnodes.TestRetrievalClientNodeParams{
+			VoucherError: errors.New("Something Went Wrong"),
+		}
+		runCancelDeal(t, dealStreamParams, nodeParams, dealState)
+		require.NotEmpty(t, dealState.Message)
+		require.Equal(t, retrievalmarket.DealStatusFailed, dealState.Status)
+	})
+
+	t.Run("unable to send cancellation", func(t *testing.T) {
+		dealState := makeDealState(retrievalmarket.DealStatusCancelling)
+		dealStreamParams := testnet.TestDealStreamParams{
+			PaymentWriter: testnet.FailDealPaymentWriter,
+		}
+		nodeParams := testnodes.TestRetrievalClientNodeParams{
+			Voucher: testVoucher,
+		}
+		runCancelDeal(t, dealStreamParams, nodeParams, dealState)
+		require.NotEmpty(t, dealState.Message)
+		require.Equal(t, retrievalmarket.DealStatusErrored, dealState.Status)
+	})
+}
+
 var defaultTotalFunds = abi.NewTokenAmount(4000000)
 var defaultCurrentInterval = uint64(1000)
 var defaultIntervalIncrease = uint64(500)
@@ -611,6 +837,7 @@ var defaultTotalReceived = uint64(6000)
 var defaultBytesPaidFor = uint64(5000)
 var defaultFundsSpent = abi.NewTokenAmount(2500000)
 var defaultPaymentRequested = abi.NewTokenAmount(500000)
+var defaultUnsealPrice = abi.NewTokenAmount(200000)
 
 func makeDealState(status retrievalmarket.DealStatus) *retrievalmarket.ClientDealState {
 	return &retrievalmarket.ClientDealState{
@@ -624,6 +851,7 @@ func makeDealState(status retrievalmarket.DealStatus) *retrievalmarket.ClientDea
 		CurrentInterval:  defaultCurrentInterval,
 		FundsSpent:       defaultFundsSpent,
 		PaymentRequested: defaultPaymentRequested,
+		UnsealFundsPaid:  big.Zero(),
 		DealProposal: retrievalmarket.DealProposal{
 			ID:     retrievalmarket.DealID(10),
 			Params: retrievalmarket.NewParamsV0(defaultPricePerByte, 0, defaultIntervalIncrease),
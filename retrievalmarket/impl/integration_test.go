@@ -12,6 +12,8 @@ import (
 	"github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
 	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dss "github.com/ipfs/go-datastore/sync"
 	"github.com/ipld/go-ipld-prime"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
 	basicnode "github.com/ipld/go-ipld-prime/node/basic"
@@ -23,6 +25,7 @@ import (
 	"github.com/filecoin-project/go-fil-markets/piecestore"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
 	retrievalimpl "github.com/filecoin-project/go-fil-markets/retrievalmarket/impl"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket/impl/storedask"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket/impl/testnodes"
 	rmnet "github.com/filecoin-project/go-fil-markets/retrievalmarket/network"
 	"github.com/filecoin-project/go-fil-markets/shared"
@@ -117,12 +120,15 @@ func requireSetupTestClientAndProvider(bgCtx context.Context, t *testing.T, payC
 	}
 
 	paymentAddress := address.TestAddress2
-	provider, err := retrievalimpl.NewProvider(paymentAddress, providerNode, nw2, pieceStore, testData.Bs2, testData.Ds2)
+	storedAsk, err := storedask.NewStoredAsk(dss.MutexWrap(datastore.NewMapDatastore()), datastore.NewKey("retrieval-ask"), providerNode, paymentAddress)
+	require.NoError(t, err)
+	provider, err := retrievalimpl.NewProvider(paymentAddress, providerNode, nw2, pieceStore, testData.Bs2, testData.Ds2, storedAsk)
 	require.NoError(t, err)
 
 	provider.SetPaymentInterval(expectedQR.MaxPaymentInterval, expectedQR.MaxPaymentIntervalIncrease)
 	provider.SetPricePerByte(expectedQR.MinPricePerByte)
 	require.NoError(t, provider.Start())
+	expectedQR.Ask = provider.GetAsk()
 
 	retrievalPeer := retrievalmarket.RetrievalPeer{
 		Address: paymentAddress,
@@ -148,6 +154,7 @@ func TestClientCanMakeDealWithProvider(t *testing.T) {
 		filesize                      uint64
 		voucherAmts                   []abi.TokenAmount
 		selector                      ipld.Node
+		unsealPrice                   abi.TokenAmount
 		paramsV1, unsealing, addFunds bool
 	}{
 		{name: "1 block file retrieval succeeds",
@@ -175,6 +182,18 @@ func TestClientCanMakeDealWithProvider(t *testing.T) {
 			filesize:    19000,
 			voucherAmts: []abi.TokenAmount{abi.NewTokenAmount(10136000), abi.NewTokenAmount(9784000)},
 			unsealing:   true},
+		{name: "multi-block file retrieval succeeds with unsealing and an unseal price",
+			filename:    "lorem.txt",
+			filesize:    19000,
+			voucherAmts: []abi.TokenAmount{abi.NewTokenAmount(100), abi.NewTokenAmount(10136000), abi.NewTokenAmount(9784000)},
+			unsealPrice: abi.NewTokenAmount(100),
+			unsealing:   true},
+		{name: "no unseal price when the file is already unsealed",
+			filename:    "lorem.txt",
+			filesize:    19000,
+			voucherAmts: []abi.TokenAmount{abi.NewTokenAmount(10136000), abi.NewTokenAmount(9784000)},
+			unsealPrice: abi.NewTokenAmount(100),
+			unsealing:   false},
 		{name: "multi-block file retrieval succeeds with V1 params and AllSelector",
 			filename:    "lorem.txt",
 			filesize:    19000,
@@ -220,6 +239,7 @@ func TestClientCanMakeDealWithProvider(t *testing.T) {
 				MinPricePerByte:            pricePerByte,
 				MaxPaymentInterval:         paymentInterval,
 				MaxPaymentIntervalIncrease: paymentIntervalIncrease,
+				UnsealPrice:                big.Zero(),
 			}
 
 			providerNode := testnodes.NewTestRetrievalProviderNode()
@@ -260,6 +280,13 @@ func TestClientCanMakeDealWithProvider(t *testing.T) {
 			}
 
 			provider := setupProvider(t, testData, payloadCID, pieceInfo, expectedQR, providerPaymentAddr, providerNode)
+			expectedUnsealPrice := big.Zero()
+			if !testCase.unsealPrice.Nil() {
+				provider.SetPricePerUnseal(testCase.unsealPrice)
+				if testCase.unsealing {
+					expectedUnsealPrice = testCase.unsealPrice
+				}
+			}
 
 			retrievalPeer := &retrievalmarket.RetrievalPeer{Address: providerPaymentAddr, ID: testData.Host2.ID()}
 
@@ -325,6 +352,7 @@ CurrentInterval: %d
 			resp, err := client.Query(bgCtx, *retrievalPeer, payloadCID, retrievalmarket.QueryParams{})
 			require.NoError(t, err)
 			require.Equal(t, retrievalmarket.QueryResponseAvailable, resp.Status)
+			require.Equal(t, expectedUnsealPrice, resp.UnsealPrice)
 
 			var rmParams retrievalmarket.Params
 			if testCase.paramsV1 {
@@ -333,6 +361,7 @@ CurrentInterval: %d
 			} else {
 				rmParams = retrievalmarket.NewParamsV0(pricePerByte, paymentInterval, paymentIntervalIncrease)
 			}
+			rmParams.UnsealPrice = resp.UnsealPrice
 
 			// *** Retrieve the piece
 			did, err := client.Retrieve(bgCtx, payloadCID, rmParams, expectedTotal, retrievalPeer.ID, clientPaymentChannel, retrievalPeer.Address)
@@ -378,6 +407,236 @@ CurrentInterval: %d
 
 }
 
+func TestClientCanAddFundsToDeal(t *testing.T) {
+	bgCtx := context.Background()
+	clientPaymentChannel, err := address.NewIDAddress(uint64(10))
+	require.NoError(t, err)
+
+	testData := tut.NewLibp2pTestData(bgCtx, t)
+	fpath := filepath.Join("retrievalmarket", "impl", "fixtures", "lorem.txt")
+	pieceLink := testData.LoadUnixFSFile(t, fpath, true)
+	payloadCID := pieceLink.(cidlink.Link).Cid
+	providerPaymentAddr, err := address.NewIDAddress(uint64(99))
+	require.NoError(t, err)
+	paymentInterval := uint64(10000)
+	paymentIntervalIncrease := uint64(1000)
+	pricePerByte := abi.NewTokenAmount(1000)
+
+	expectedQR := retrievalmarket.QueryResponse{
+		Size:                       1024,
+		PaymentAddress:             providerPaymentAddr,
+		MinPricePerByte:            pricePerByte,
+		MaxPaymentInterval:         paymentInterval,
+		MaxPaymentIntervalIncrease: paymentIntervalIncrease,
+		UnsealPrice:                big.Zero(),
+	}
+	providerNode := testnodes.NewTestRetrievalProviderNode()
+	pieceInfo := piecestore.PieceInfo{
+		Deals: []piecestore.DealInfo{{Length: expectedQR.Size}},
+	}
+	provider := setupProvider(t, testData, payloadCID, pieceInfo, expectedQR, providerPaymentAddr, providerNode)
+	retrievalPeer := &retrievalmarket.RetrievalPeer{Address: providerPaymentAddr, ID: testData.Host2.ID()}
+
+	expectedVoucher := tut.MakeTestSignedVoucher()
+	voucherAmts := []abi.TokenAmount{abi.NewTokenAmount(10136000), abi.NewTokenAmount(9784000)}
+	for _, voucherAmt := range voucherAmts {
+		require.NoError(t, providerNode.ExpectVoucher(clientPaymentChannel, expectedVoucher, []byte(""), voucherAmt, voucherAmt, nil))
+	}
+
+	nw1 := rmnet.NewFromLibp2pHost(testData.Host1)
+	addedToChan, _, _, client, err := setupClient(clientPaymentChannel, expectedVoucher, nw1, testData, false)
+	require.NoError(t, err)
+
+	// the client only has the funds for the first payment, so the deal runs out
+	// of funds when the provider asks for the last one
+	fundsExpended := make(chan retrievalmarket.ClientDealState, 1)
+	clientDealStateChan := make(chan retrievalmarket.ClientDealState, 1)
+	client.SubscribeToEvents(func(event retrievalmarket.ClientEvent, state retrievalmarket.ClientDealState) {
+		switch event {
+		case retrievalmarket.ClientEventFundsExpended:
+			fundsExpended <- state
+		case retrievalmarket.ClientEventComplete:
+			clientDealStateChan <- state
+		}
+	})
+	providerDealStateChan := make(chan retrievalmarket.ProviderDealState, 1)
+	provider.SubscribeToEvents(func(event retrievalmarket.ProviderEvent, state retrievalmarket.ProviderDealState) {
+		if event == retrievalmarket.ProviderEventComplete {
+			providerDealStateChan <- state
+		}
+	})
+
+	rmParams := retrievalmarket.NewParamsV0(pricePerByte, paymentInterval, paymentIntervalIncrease)
+	did, err := client.Retrieve(bgCtx, payloadCID, rmParams, voucherAmts[0], retrievalPeer.ID, clientPaymentChannel, retrievalPeer.Address)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(bgCtx, 10*time.Second)
+	defer cancel()
+
+	select {
+	case <-ctx.Done():
+		t.Fatal("deal never ran out of funds")
+	case state := <-fundsExpended:
+		require.Equal(t, retrievalmarket.DealStatusInsufficientFundsLastPayment, state.Status)
+		require.Equal(t, voucherAmts[0], state.FundsSpent)
+	}
+
+	// no payment channel message is sent for an amount that adds no funds
+	err = client.AddMoreFunds(did, abi.NewTokenAmount(0))
+	require.EqualError(t, err, "amount to add must be positive, got 0")
+	require.Equal(t, voucherAmts[0], addedToChan.amt)
+
+	err = client.AddMoreFunds(did, voucherAmts[1])
+	require.NoError(t, err)
+	require.Equal(t, voucherAmts[1], addedToChan.amt)
+
+	var clientDealState retrievalmarket.ClientDealState
+	select {
+	case <-ctx.Done():
+		t.Fatal("deal never completed")
+	case clientDealState = <-clientDealStateChan:
+	}
+	require.Equal(t, big.Add(voucherAmts[0], voucherAmts[1]), clientDealState.TotalFunds)
+	require.Equal(t, clientDealState.TotalFunds, clientDealState.FundsSpent)
+
+	select {
+	case <-ctx.Done():
+		t.Fatal("provider never saw completed deal")
+	case providerDealState := <-providerDealStateChan:
+		require.Equal(t, retrievalmarket.DealStatusCompleted, providerDealState.Status)
+	}
+
+	providerNode.VerifyExpectations(t)
+	testData.VerifyFileTransferred(t, pieceLink, false, 19000)
+
+	err = client.AddMoreFunds(did, voucherAmts[1])
+	require.EqualError(t, err, "deal 0 is not waiting for funds, status is DealStatusCompleted")
+}
+
+func TestClientCanCancelDeal(t *testing.T) {
+	bgCtx := context.Background()
+	clientPaymentChannel, err := address.NewIDAddress(uint64(10))
+	require.NoError(t, err)
+
+	testData := tut.NewLibp2pTestData(bgCtx, t)
+	fpath := filepath.Join("retrievalmarket", "impl", "fixtures", "lorem.txt")
+	pieceLink := testData.LoadUnixFSFile(t, fpath, true)
+	payloadCID := pieceLink.(cidlink.Link).Cid
+	providerPaymentAddr, err := address.NewIDAddress(uint64(99))
+	require.NoError(t, err)
+	paymentInterval := uint64(10000)
+	paymentIntervalIncrease := uint64(1000)
+	pricePerByte := abi.NewTokenAmount(1000)
+
+	expectedQR := retrievalmarket.QueryResponse{
+		Size:                       1024,
+		PaymentAddress:             providerPaymentAddr,
+		MinPricePerByte:            pricePerByte,
+		MaxPaymentInterval:         paymentInterval,
+		MaxPaymentIntervalIncrease: paymentIntervalIncrease,
+		UnsealPrice:                big.Zero(),
+	}
+	providerNode := testnodes.NewTestRetrievalProviderNode()
+	pieceInfo := piecestore.PieceInfo{
+		Deals: []piecestore.DealInfo{{Length: expectedQR.Size}},
+	}
+	provider := setupProvider(t, testData, payloadCID, pieceInfo, expectedQR, providerPaymentAddr, providerNode)
+	retrievalPeer := &retrievalmarket.RetrievalPeer{Address: providerPaymentAddr, ID: testData.Host2.ID()}
+
+	expectedVoucher := tut.MakeTestSignedVoucher()
+	firstPayment := abi.NewTokenAmount(10136000)
+	require.NoError(t, providerNode.ExpectVoucher(clientPaymentChannel, expectedVoucher, []byte(""), firstPayment, firstPayment, nil))
+
+	nw1 := rmnet.NewFromLibp2pHost(testData.Host1)
+	_, _, _, client, err := setupClient(clientPaymentChannel, expectedVoucher, nw1, testData, false)
+	require.NoError(t, err)
+
+	// the client only has the funds for the first payment, and cancels the
+	// deal instead of adding more when the provider asks for the last one
+	fundsExpended := make(chan retrievalmarket.ClientDealState, 1)
+	clientDealStateChan := make(chan retrievalmarket.ClientDealState, 1)
+	client.SubscribeToEvents(func(event retrievalmarket.ClientEvent, state retrievalmarket.ClientDealState) {
+		switch event {
+		case retrievalmarket.ClientEventFundsExpended:
+			fundsExpended <- state
+		case retrievalmarket.ClientEventCancelComplete:
+			clientDealStateChan <- state
+		}
+	})
+	providerDealStateChan := make(chan retrievalmarket.ProviderDealState, 1)
+	provider.SubscribeToEvents(func(event retrievalmarket.ProviderEvent, state retrievalmarket.ProviderDealState) {
+		if event == retrievalmarket.ProviderEventClientCancelled {
+			providerDealStateChan <- state
+		}
+	})
+
+	rmParams := retrievalmarket.NewParamsV0(pricePerByte, paymentInterval, paymentIntervalIncrease)
+	did, err := client.Retrieve(bgCtx, payloadCID, rmParams, firstPayment, retrievalPeer.ID, clientPaymentChannel, retrievalPeer.Address)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(bgCtx, 10*time.Second)
+	defer cancel()
+
+	select {
+	case <-ctx.Done():
+		t.Fatal("deal never ran out of funds")
+	case <-fundsExpended:
+	}
+
+	require.NoError(t, client.CancelDeal(did))
+
+	select {
+	case <-ctx.Done():
+		t.Fatal("deal was never cancelled")
+	case clientDealState := <-clientDealStateChan:
+		require.Equal(t, retrievalmarket.DealStatusCancelled, clientDealState.Status)
+		require.Equal(t, firstPayment, clientDealState.FundsSpent)
+	}
+
+	select {
+	case <-ctx.Done():
+		t.Fatal("provider never saw the deal cancelled")
+	case providerDealState := <-providerDealStateChan:
+		require.Equal(t, retrievalmarket.DealStatusCancelled, providerDealState.Status)
+		require.Equal(t, firstPayment, providerDealState.FundsReceived)
+	}
+
+	providerNode.VerifyExpectations(t)
+
+	err = client.CancelDeal(did)
+	require.EqualError(t, err, "deal 0 cannot be cancelled, status is DealStatusCancelled")
+
+	// the cancelled deal is listed with its final state on both sides
+	clientDeal, err := client.RetrievalStatus(did)
+	require.NoError(t, err)
+	require.Equal(t, retrievalmarket.DealStatusCancelled, clientDeal.Status)
+	_, err = client.RetrievalStatus(did + 1)
+	require.Error(t, err)
+	require.Equal(t, map[retrievalmarket.DealID]retrievalmarket.ClientDealState{did: clientDeal}, client.ListDeals())
+
+	clientDeals, err := client.FilterDeals(retrievalmarket.DealFilter{Peer: retrievalPeer.ID, PayloadCID: payloadCID})
+	require.NoError(t, err)
+	require.Len(t, clientDeals, 1)
+	clientDeals, err = client.FilterDeals(retrievalmarket.DealFilter{Statuses: []retrievalmarket.DealStatus{retrievalmarket.DealStatusOngoing}})
+	require.NoError(t, err)
+	require.Empty(t, clientDeals)
+	clientCounts, err := client.DealStatusCounts()
+	require.NoError(t, err)
+	require.Equal(t, map[retrievalmarket.DealStatus]int{retrievalmarket.DealStatusCancelled: 1}, clientCounts)
+
+	providerDealID := retrievalmarket.ProviderDealID{From: testData.Host1.ID(), ID: did}
+	require.Eventually(t, func() bool {
+		providerDeals := provider.ListDeals()
+		return len(providerDeals) == 1 && providerDeals[providerDealID].Status == retrievalmarket.DealStatusCancelled
+	}, time.Second, 10*time.Millisecond)
+	providerDeals, err := provider.FilterDeals(retrievalmarket.DealFilter{Peer: testData.Host2.ID()})
+	require.NoError(t, err)
+	require.Empty(t, providerDeals)
+	providerCounts, err := provider.DealStatusCounts()
+	require.NoError(t, err)
+	require.Equal(t, map[retrievalmarket.DealStatus]int{retrievalmarket.DealStatusCancelled: 1}, providerCounts)
+}
+
 func setupClient(
 	clientPaymentChannel address.Address,
 	expectedVoucher *paych.SignedVoucher,
@@ -433,7 +692,9 @@ func setupProvider(t *testing.T, testData *tut.Libp2pTestData, payloadCID cid.Ci
 	}
 	pieceStore.ExpectCID(payloadCID, cidInfo)
 	pieceStore.ExpectPiece(expectedPiece, pieceInfo)
-	provider, err := retrievalimpl.NewProvider(providerPaymentAddr, providerNode, nw2, pieceStore, testData.Bs2, testData.Ds2)
+	storedAsk, err := storedask.NewStoredAsk(dss.MutexWrap(datastore.NewMapDatastore()), datastore.NewKey("retrieval-ask"), providerNode, providerPaymentAddr)
+	require.NoError(t, err)
+	provider, err := retrievalimpl.NewProvider(providerPaymentAddr, providerNode, nw2, pieceStore, testData.Bs2, testData.Ds2, storedAsk)
 	require.NoError(t, err)
 	provider.SetPaymentInterval(expectedQR.MaxPaymentInterval, expectedQR.MaxPaymentIntervalIncrease)
 	provider.SetPricePerByte(expectedQR.MinPricePerByte)
@@ -27,27 +27,38 @@ import (
 )
 
 type provider struct {
-	bs                      blockstore.Blockstore
-	node                    retrievalmarket.RetrievalProviderNode
-	network                 rmnet.RetrievalMarketNetwork
-	paymentInterval         uint64
-	paymentIntervalIncrease uint64
-	minerAddress            address.Address
-	pieceStore              piecestore.PieceStore
-	pricePerByte            abi.TokenAmount
-	subscribers             []retrievalmarket.ProviderSubscriber
-	subscribersLk           sync.RWMutex
-	dealStreams             map[retrievalmarket.ProviderDealIdentifier]rmnet.RetrievalDealStream
-	blockReaders            map[retrievalmarket.ProviderDealIdentifier]blockio.BlockReader
-	stateMachines           fsm.Group
+	bs            blockstore.Blockstore
+	node          retrievalmarket.RetrievalProviderNode
+	network       rmnet.RetrievalMarketNetwork
+	minerAddress  address.Address
+	pieceStore    piecestore.PieceStore
+	storedAsk     StoredAsk
+	askLk         sync.Mutex
+	subscribers   []retrievalmarket.ProviderSubscriber
+	subscribersLk sync.RWMutex
+	dealsLk       sync.RWMutex
+	dealStreams   map[retrievalmarket.ProviderDealIdentifier]rmnet.RetrievalDealStream
+	blockReaders  map[retrievalmarket.ProviderDealIdentifier]blockio.BlockReader
+	stateMachines fsm.Group
 }
 
 var _ retrievalmarket.RetrievalProvider = &provider{}
 
+// StoredAsk is the retrieval ask a provider offers, which is kept across
+// restarts
+type StoredAsk interface {
+	GetAsk() *retrievalmarket.SignedRetrievalAsk
+	SetAsk(ask *retrievalmarket.RetrievalAsk) error
+}
+
 // DefaultPricePerByte is the charge per byte retrieved if the miner does
 // not specifically set it
 var DefaultPricePerByte = abi.NewTokenAmount(2)
 
+// DefaultPricePerUnseal is the charge to unseal a piece that is not already
+// unsealed, if the miner does not specifically set it
+var DefaultPricePerUnseal = abi.NewTokenAmount(0)
+
 // DefaultPaymentInterval is the baseline interval, set to 1Mb
 // if the miner does not explicitly set it otherwise
 var DefaultPaymentInterval = uint64(1 << 20)
@@ -57,20 +68,32 @@ var DefaultPaymentInterval = uint64(1 << 20)
 var DefaultPaymentIntervalIncrease = uint64(1 << 20)
 
 // NewProvider returns a new retrieval provider
-func NewProvider(minerAddress address.Address, node retrievalmarket.RetrievalProviderNode, network rmnet.RetrievalMarketNetwork, pieceStore piecestore.PieceStore, bs blockstore.Blockstore, ds datastore.Batching) (retrievalmarket.RetrievalProvider, error) {
+func NewProvider(minerAddress address.Address, node retrievalmarket.RetrievalProviderNode, network rmnet.RetrievalMarketNetwork, pieceStore piecestore.PieceStore, bs blockstore.Blockstore, ds datastore.Batching, storedAsk StoredAsk) (retrievalmarket.RetrievalProvider, error) {
 
 	p := &provider{
-		bs:                      bs,
-		node:                    node,
-		network:                 network,
-		minerAddress:            minerAddress,
-		pieceStore:              pieceStore,
-		pricePerByte:            DefaultPricePerByte, // TODO: allow setting
-		paymentInterval:         DefaultPaymentInterval,
-		paymentIntervalIncrease: DefaultPaymentIntervalIncrease,
-		dealStreams:             make(map[retrievalmarket.ProviderDealIdentifier]rmnet.RetrievalDealStream),
-		blockReaders:            make(map[retrievalmarket.ProviderDealIdentifier]blockio.BlockReader),
+		bs:           bs,
+		node:         node,
+		network:      network,
+		minerAddress: minerAddress,
+		pieceStore:   pieceStore,
+		storedAsk:    storedAsk,
+		dealStreams:  make(map[retrievalmarket.ProviderDealIdentifier]rmnet.RetrievalDealStream),
+		blockReaders: make(map[retrievalmarket.ProviderDealIdentifier]blockio.BlockReader),
+	}
+
+	// start with the default terms if the miner has never set an ask
+	if storedAsk.GetAsk() == nil {
+		err := storedAsk.SetAsk(&retrievalmarket.RetrievalAsk{
+			PricePerByte:            DefaultPricePerByte,
+			UnsealPrice:             DefaultPricePerUnseal,
+			PaymentInterval:         DefaultPaymentInterval,
+			PaymentIntervalIncrease: DefaultPaymentIntervalIncrease,
+		})
+		if err != nil {
+			return nil, xerrors.Errorf("setting the default retrieval ask: %w", err)
+		}
 	}
+
 	statemachines, err := fsm.New(ds, fsm.Parameters{
 		Environment:     p,
 		StateType:       retrievalmarket.ProviderDealState{},
@@ -99,14 +122,18 @@ func (p *provider) Start() error {
 // V0
 // SetPricePerByte sets the price per byte a miner charges for retrievals
 func (p *provider) SetPricePerByte(price abi.TokenAmount) {
-	p.pricePerByte = price
+	p.updateAsk(func(ask *retrievalmarket.RetrievalAsk) {
+		ask.PricePerByte = price
+	})
 }
 
 // SetPaymentInterval sets the maximum number of bytes a a provider will send before
 // requesting further payment, and the rate at which that value increases
 func (p *provider) SetPaymentInterval(paymentInterval uint64, paymentIntervalIncrease uint64) {
-	p.paymentInterval = paymentInterval
-	p.paymentIntervalIncrease = paymentIntervalIncrease
+	p.updateAsk(func(ask *retrievalmarket.RetrievalAsk) {
+		ask.PaymentInterval = paymentInterval
+		ask.PaymentIntervalIncrease = paymentIntervalIncrease
+	})
 }
 
 // unsubscribeAt returns a function that removes an item from the subscribers list by comparing
@@ -147,12 +174,72 @@ func (p *provider) SubscribeToEvents(subscriber retrievalmarket.ProviderSubscrib
 }
 
 // V1
+// SetPricePerUnseal sets the price a miner charges up front to unseal a piece
+// for retrieval, when it is not already unsealed
 func (p *provider) SetPricePerUnseal(price abi.TokenAmount) {
-	panic("not implemented")
+	p.updateAsk(func(ask *retrievalmarket.RetrievalAsk) {
+		ask.UnsealPrice = price
+	})
+}
+
+// GetAsk returns the signed retrieval terms the provider currently offers
+func (p *provider) GetAsk() *retrievalmarket.SignedRetrievalAsk {
+	return p.storedAsk.GetAsk()
+}
+
+// ask returns the terms the provider currently offers
+func (p *provider) ask() retrievalmarket.RetrievalAsk {
+	return *p.storedAsk.GetAsk().Ask
 }
 
+// updateAsk changes the terms the provider offers, saving them as a new
+// signed ask
+func (p *provider) updateAsk(update func(ask *retrievalmarket.RetrievalAsk)) {
+	p.askLk.Lock()
+	defer p.askLk.Unlock()
+	ask := p.ask()
+	update(&ask)
+	if err := p.storedAsk.SetAsk(&ask); err != nil {
+		log.Errorf("saving retrieval ask: %s", err)
+	}
+}
+
+// ListDeals returns all deals clients have made with the provider
 func (p *provider) ListDeals() map[retrievalmarket.ProviderDealID]retrievalmarket.ProviderDealState {
-	panic("not implemented")
+	deals, err := p.FilterDeals(retrievalmarket.DealFilter{})
+	if err != nil {
+		log.Errorf("listing retrieval deals: %s", err)
+	}
+	return deals
+}
+
+// FilterDeals returns the deals clients have made with the provider that
+// match the filter
+func (p *provider) FilterDeals(filter retrievalmarket.DealFilter) (map[retrievalmarket.ProviderDealID]retrievalmarket.ProviderDealState, error) {
+	var deals []retrievalmarket.ProviderDealState
+	if err := p.stateMachines.List(&deals); err != nil {
+		return nil, xerrors.Errorf("listing deals: %w", err)
+	}
+	out := make(map[retrievalmarket.ProviderDealID]retrievalmarket.ProviderDealState)
+	for _, deal := range deals {
+		if filter.MatchesProviderDeal(deal) {
+			out[retrievalmarket.ProviderDealID{From: deal.Receiver, ID: deal.ID}] = deal
+		}
+	}
+	return out, nil
+}
+
+// DealStatusCounts returns the number of deals the provider has in each status
+func (p *provider) DealStatusCounts() (map[retrievalmarket.DealStatus]int, error) {
+	var deals []retrievalmarket.ProviderDealState
+	if err := p.stateMachines.List(&deals); err != nil {
+		return nil, xerrors.Errorf("listing deals: %w", err)
+	}
+	counts := make(map[retrievalmarket.DealStatus]int)
+	for _, deal := range deals {
+		counts[deal.Status]++
+	}
+	return counts, nil
 }
 
 func (p *provider) HandleQueryStream(stream rmnet.RetrievalQueryStream) {
@@ -162,12 +249,14 @@ func (p *provider) HandleQueryStream(stream rmnet.RetrievalQueryStream) {
 		return
 	}
 
+	ask := p.storedAsk.GetAsk()
 	answer := retrievalmarket.QueryResponse{
 		Status:                     retrievalmarket.QueryResponseUnavailable,
 		PieceCIDFound:              retrievalmarket.QueryItemUnavailable,
-		MinPricePerByte:            p.pricePerByte,
-		MaxPaymentInterval:         p.paymentInterval,
-		MaxPaymentIntervalIncrease: p.paymentIntervalIncrease,
+		MinPricePerByte:            ask.Ask.PricePerByte,
+		MaxPaymentInterval:         ask.Ask.PaymentInterval,
+		MaxPaymentIntervalIncrease: ask.Ask.PaymentIntervalIncrease,
+		Ask:                        ask,
 	}
 
 	ctx := context.TODO()
@@ -194,9 +283,11 @@ func (p *provider) HandleQueryStream(stream rmnet.RetrievalQueryStream) {
 
 		if err == nil && len(pieceInfo.Deals) > 0 {
 			answer.Status = retrievalmarket.QueryResponseAvailable
-			// TODO: get price, look for already unsealed ref to reduce work
 			answer.Size = uint64(pieceInfo.Deals[0].Length) // TODO: verify on intermediate
 			answer.PieceCIDFound = retrievalmarket.QueryItemAvailable
+			if unsealPrice := p.UnsealPrice(query.PayloadCID); !unsealPrice.IsZero() {
+				answer.UnsealPrice = unsealPrice
+			}
 		}
 
 		if err != nil && !xerrors.Is(err, retrievalmarket.ErrNotFound) {
@@ -232,7 +323,9 @@ func (p *provider) newProviderDeal(stream rmnet.RetrievalDealStream) error {
 		Receiver:     stream.Receiver(),
 	}
 
+	p.dealsLk.Lock()
 	p.dealStreams[pds.Identifier()] = stream
+	p.dealsLk.Unlock()
 
 	loaderWithUnsealing := blockunsealing.NewLoaderWithUnsealing(context.TODO(), p.bs, p.pieceStore, cario.NewCarIO(), p.node.UnsealSector, dealProposal.PieceCID)
 
@@ -248,7 +341,9 @@ func (p *provider) newProviderDeal(stream rmnet.RetrievalDealStream) error {
 	}
 
 	br := blockio.NewSelectorBlockReader(cidlink.Link{Cid: dealProposal.PayloadCID}, sel, loaderWithUnsealing.Load)
+	p.dealsLk.Lock()
 	p.blockReaders[pds.Identifier()] = br
+	p.dealsLk.Unlock()
 
 	// start the deal processing, synchronously so we can log the error and close the stream if it doesn't start
 	err = p.stateMachines.Begin(pds.Identifier(), &pds)
@@ -269,30 +364,66 @@ func (p *provider) Node() retrievalmarket.RetrievalProviderNode {
 }
 
 func (p *provider) DealStream(id retrievalmarket.ProviderDealIdentifier) rmnet.RetrievalDealStream {
+	p.dealsLk.RLock()
+	defer p.dealsLk.RUnlock()
 	return p.dealStreams[id]
 }
 
 func (p *provider) CheckDealParams(pricePerByte abi.TokenAmount, paymentInterval uint64, paymentIntervalIncrease uint64) error {
-	if pricePerByte.LessThan(p.pricePerByte) {
+	ask := p.ask()
+	if pricePerByte.LessThan(ask.PricePerByte) {
 		return errors.New("Price per byte too low")
 	}
-	if paymentInterval > p.paymentInterval {
+	if paymentInterval > ask.PaymentInterval {
 		return errors.New("Payment interval too large")
 	}
-	if paymentIntervalIncrease > p.paymentIntervalIncrease {
+	if paymentIntervalIncrease > ask.PaymentIntervalIncrease {
 		return errors.New("Payment interval increase too large")
 	}
 	return nil
 }
 
+// UnsealPrice is the price to unseal the piece with the given payload, which
+// is zero when the payload is already unsealed in the blockstore
+func (p *provider) UnsealPrice(payloadCID cid.Cid) abi.TokenAmount {
+	has, err := p.bs.Has(payloadCID)
+	if err != nil {
+		log.Warnf("checking for unsealed payload %s: %s", payloadCID, err)
+	}
+	if has {
+		return abi.NewTokenAmount(0)
+	}
+	return p.ask().UnsealPrice
+}
+
 func (p *provider) NextBlock(ctx context.Context, id retrievalmarket.ProviderDealIdentifier) (retrievalmarket.Block, bool, error) {
+	p.dealsLk.RLock()
 	br, ok := p.blockReaders[id]
+	p.dealsLk.RUnlock()
 	if !ok {
 		return retrievalmarket.Block{}, false, errors.New("Could not read block")
 	}
 	return br.ReadBlock(ctx)
 }
 
+// CloseDeal stops reading blocks for a deal and closes its stream
+func (p *provider) CloseDeal(ctx context.Context, id retrievalmarket.ProviderDealIdentifier) error {
+	p.dealsLk.Lock()
+	br, hasReader := p.blockReaders[id]
+	stream, hasStream := p.dealStreams[id]
+	delete(p.blockReaders, id)
+	delete(p.dealStreams, id)
+	p.dealsLk.Unlock()
+
+	if hasReader {
+		br.Close(ctx)
+	}
+	if hasStream {
+		return stream.Close()
+	}
+	return nil
+}
+
 func (p *provider) GetPieceSize(c cid.Cid) (uint64, error) {
 	pieceInfo, err := getPieceInfoFromCid(p.pieceStore, c, cid.Undef)
 	if err != nil {
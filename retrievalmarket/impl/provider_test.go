@@ -16,6 +16,7 @@ import (
 	"github.com/filecoin-project/go-fil-markets/piecestore"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
 	retrievalimpl "github.com/filecoin-project/go-fil-markets/retrievalmarket/impl"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket/impl/storedask"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket/impl/testnodes"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket/network"
 	tut "github.com/filecoin-project/go-fil-markets/shared_testutil"
@@ -60,17 +61,20 @@ func TestHandleQueryStream(t *testing.T) {
 		return qs
 	}
 
-	receiveStreamOnProvider := func(qs network.RetrievalQueryStream, pieceStore piecestore.PieceStore) {
+	receiveStreamOnProvider := func(qs network.RetrievalQueryStream, pieceStore piecestore.PieceStore) *retrievalmarket.SignedRetrievalAsk {
 		node := testnodes.NewTestRetrievalProviderNode()
 		ds := dss.MutexWrap(datastore.NewMapDatastore())
 		bs := bstore.NewBlockstore(ds)
 		net := tut.NewTestRetrievalMarketNetwork(tut.TestNetworkParams{})
-		c, err := retrievalimpl.NewProvider(expectedAddress, node, net, pieceStore, bs, ds)
+		askStore, err := storedask.NewStoredAsk(dss.MutexWrap(datastore.NewMapDatastore()), datastore.NewKey("retrieval-ask"), node, expectedAddress)
+		require.NoError(t, err)
+		c, err := retrievalimpl.NewProvider(expectedAddress, node, net, pieceStore, bs, ds, askStore)
 		require.NoError(t, err)
 		c.SetPricePerByte(expectedPricePerByte)
 		c.SetPaymentInterval(expectedPaymentInterval, expectedPaymentIntervalIncrease)
 		_ = c.Start()
 		net.ReceiveQueryStream(qs)
+		return c.GetAsk()
 	}
 
 	testCases := []struct {
@@ -146,7 +150,7 @@ func TestHandleQueryStream(t *testing.T) {
 
 			tc.expFunc(t, pieceStore)
 
-			receiveStreamOnProvider(qs, pieceStore)
+			ask := receiveStreamOnProvider(qs, pieceStore)
 
 			actualResp, err := qs.ReadQueryResponse()
 			pieceStore.VerifyExpectations(t)
@@ -160,6 +164,7 @@ func TestHandleQueryStream(t *testing.T) {
 			tc.expResp.MinPricePerByte = expectedPricePerByte
 			tc.expResp.MaxPaymentInterval = expectedPaymentInterval
 			tc.expResp.MaxPaymentIntervalIncrease = expectedPaymentIntervalIncrease
+			tc.expResp.Ask = ask
 			assert.Equal(t, tc.expResp, actualResp)
 		})
 	}
@@ -214,6 +219,45 @@ func TestHandleQueryStream(t *testing.T) {
 
 }
 
+func TestProviderAsk(t *testing.T) {
+	node := testnodes.NewTestRetrievalProviderNode()
+	ds := dss.MutexWrap(datastore.NewMapDatastore())
+	bs := bstore.NewBlockstore(ds)
+	askDs := dss.MutexWrap(datastore.NewMapDatastore())
+	minerAddr := address.TestAddress2
+
+	newProvider := func() retrievalmarket.RetrievalProvider {
+		net := tut.NewTestRetrievalMarketNetwork(tut.TestNetworkParams{})
+		askStore, err := storedask.NewStoredAsk(askDs, datastore.NewKey("retrieval-ask"), node, minerAddr)
+		require.NoError(t, err)
+		p, err := retrievalimpl.NewProvider(minerAddr, node, net, tut.NewTestPieceStore(), bs, ds, askStore)
+		require.NoError(t, err)
+		return p
+	}
+
+	provider := newProvider()
+	ask := provider.GetAsk()
+	require.Equal(t, retrievalimpl.DefaultPricePerByte, ask.Ask.PricePerByte)
+	require.Equal(t, retrievalimpl.DefaultPaymentInterval, ask.Ask.PaymentInterval)
+	require.Equal(t, retrievalimpl.DefaultPaymentIntervalIncrease, ask.Ask.PaymentIntervalIncrease)
+	require.Equal(t, minerAddr, ask.Ask.Miner)
+	require.Equal(t, uint64(0), ask.Ask.SeqNo)
+
+	provider.SetPricePerByte(abi.NewTokenAmount(4321))
+	provider.SetPaymentInterval(4567, 100)
+	provider.SetPricePerUnseal(abi.NewTokenAmount(1000))
+	ask = provider.GetAsk()
+	require.Equal(t, abi.NewTokenAmount(4321), ask.Ask.PricePerByte)
+	require.Equal(t, uint64(4567), ask.Ask.PaymentInterval)
+	require.Equal(t, uint64(100), ask.Ask.PaymentIntervalIncrease)
+	require.Equal(t, abi.NewTokenAmount(1000), ask.Ask.UnsealPrice)
+	require.Equal(t, uint64(3), ask.Ask.SeqNo)
+
+	// the terms are kept when the provider restarts
+	restarted := newProvider()
+	require.Equal(t, ask, restarted.GetAsk())
+}
+
 // loadPieceCIDS sets expectations to receive expectedPieceCID and 3 other random PieceCIDs to
 // disinguish the case of a PayloadCID is found but the PieceCID is not
 func loadPieceCIDS(t *testing.T, pieceStore *tut.TestPieceStore, expPayloadCID, expectedPieceCID cid.Cid) {
@@ -85,8 +85,21 @@ var ProviderEvents = fsm.Events{
 			deal.CurrentInterval += deal.PaymentIntervalIncrease
 			return nil
 		}),
+	fsm.Event(rm.ProviderEventUnsealPaymentReceived).
+		From(rm.DealStatusFundsNeeded).To(rm.DealStatusOngoing).
+		Action(func(deal *rm.ProviderDealState, fundsReceived abi.TokenAmount) error {
+			deal.FundsReceived = big.Add(deal.FundsReceived, fundsReceived)
+			return nil
+		}),
 	fsm.Event(rm.ProviderEventComplete).
 		From(rm.DealStatusFinalizing).To(rm.DealStatusCompleted),
+	fsm.Event(rm.ProviderEventClientCancelled).
+		FromMany(rm.DealStatusAccepted, rm.DealStatusOngoing, rm.DealStatusFundsNeeded, rm.DealStatusFundsNeededLastPayment).To(rm.DealStatusCancelled).
+		Action(func(deal *rm.ProviderDealState, fundsReceived abi.TokenAmount) error {
+			deal.FundsReceived = big.Add(deal.FundsReceived, fundsReceived)
+			deal.Message = "deal cancelled by client"
+			return nil
+		}),
 }
 
 // ProviderStateEntryFuncs are the handlers for different states in a retrieval provider
@@ -100,4 +113,5 @@ var ProviderStateEntryFuncs = fsm.StateEntryFuncs{
 	rm.DealStatusFundsNeeded:            ProcessPayment,
 	rm.DealStatusFundsNeededLastPayment: ProcessPayment,
 	rm.DealStatusFinalizing:             Finalize,
+	rm.DealStatusCancelled:              CancelDeal,
 }
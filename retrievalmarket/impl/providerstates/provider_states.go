@@ -2,6 +2,7 @@ package providerstates
 
 import (
 	"context"
+	"io"
 
 	"github.com/filecoin-project/go-statemachine/fsm"
 	"github.com/filecoin-project/specs-actors/actors/abi"
@@ -20,6 +21,8 @@ type ProviderDealEnvironment interface {
 	DealStream(id rm.ProviderDealIdentifier) rmnet.RetrievalDealStream
 	NextBlock(context.Context, rm.ProviderDealIdentifier) (rm.Block, bool, error)
 	CheckDealParams(pricePerByte abi.TokenAmount, paymentInterval uint64, paymentIntervalIncrease uint64) error
+	UnsealPrice(payloadCID cid.Cid) abi.TokenAmount
+	CloseDeal(context.Context, rm.ProviderDealIdentifier) error
 }
 
 // ReceiveDeal receives and evaluates a deal proposal
@@ -41,6 +44,11 @@ func ReceiveDeal(ctx fsm.Context, environment ProviderDealEnvironment, deal rm.P
 		return ctx.Trigger(rm.ProviderEventDealRejected, err)
 	}
 
+	// check that the client agreed to pay to unseal the piece, if it isn't unsealed already
+	if dealProposal.UnsealPrice.LessThan(environment.UnsealPrice(dealProposal.PayloadCID)) {
+		return ctx.Trigger(rm.ProviderEventDealRejected, xerrors.New("Unseal price too low"))
+	}
+
 	err = environment.DealStream(deal.Identifier()).WriteDealResponse(rm.DealResponse{
 		Status: rm.DealStatusAccepted,
 		ID:     deal.ID,
@@ -55,8 +63,21 @@ func ReceiveDeal(ctx fsm.Context, environment ProviderDealEnvironment, deal rm.P
 
 // SendBlocks sends blocks to the client until funds are needed
 func SendBlocks(ctx fsm.Context, environment ProviderDealEnvironment, deal rm.ProviderDealState) error {
+	// request the unseal price before reading any blocks, which unseals the piece
+	if deal.FundsReceived.LessThan(deal.UnsealPrice) {
+		err := environment.DealStream(deal.Identifier()).WriteDealResponse(rm.DealResponse{
+			ID:          deal.ID,
+			Status:      rm.DealStatusFundsNeeded,
+			PaymentOwed: big.Sub(deal.UnsealPrice, deal.FundsReceived),
+		})
+		if err != nil {
+			return writeBlocksFailed(ctx, environment, deal, err)
+		}
+		return ctx.Trigger(rm.ProviderEventPaymentRequested, deal.TotalSent)
+	}
+
 	totalSent := deal.TotalSent
-	totalPaidFor := big.Div(deal.FundsReceived, deal.PricePerByte).Uint64()
+	totalPaidFor := big.Div(big.Sub(deal.FundsReceived, deal.UnsealPrice), deal.PricePerByte).Uint64()
 	var blocks []rm.Block
 
 	// read blocks until we reach current interval
@@ -89,12 +110,27 @@ func SendBlocks(ctx fsm.Context, environment ProviderDealEnvironment, deal rm.Pr
 	})
 
 	if err != nil {
-		return ctx.Trigger(rm.ProviderEventWriteResponseFailed, err)
+		return writeBlocksFailed(ctx, environment, deal, err)
 	}
 
 	return ctx.Trigger(rm.ProviderEventPaymentRequested, totalSent)
 }
 
+// writeBlocksFailed handles a response SendBlocks could not write. A client
+// that cancels while blocks are being sent writes its cancellation and
+// closes the stream without waiting for them, so the cancellation, or the end
+// of the stream, is read before failing the deal
+func writeBlocksFailed(ctx fsm.Context, environment ProviderDealEnvironment, deal rm.ProviderDealState, writeErr error) error {
+	payment, err := environment.DealStream(deal.Identifier()).ReadDealPayment()
+	if err == io.EOF {
+		return ctx.Trigger(rm.ProviderEventClientCancelled, big.Zero())
+	}
+	if err == nil && payment.Cancel {
+		return processCancellation(ctx, environment, deal, payment)
+	}
+	return ctx.Trigger(rm.ProviderEventWriteResponseFailed, writeErr)
+}
+
 // ProcessPayment processes a payment from the client and resumes the deal if successful
 func ProcessPayment(ctx fsm.Context, environment ProviderDealEnvironment, deal rm.ProviderDealState) error {
 	// read payment, or fail
@@ -102,6 +138,9 @@ func ProcessPayment(ctx fsm.Context, environment ProviderDealEnvironment, deal r
 	if err != nil {
 		return ctx.Trigger(rm.ProviderEventReadPaymentFailed, xerrors.Errorf("reading payment: %w", err))
 	}
+	if payment.Cancel {
+		return processCancellation(ctx, environment, deal, payment)
+	}
 
 	tok, _, err := environment.Node().GetChainHead(ctx.Context())
 	if err != nil {
@@ -109,8 +148,8 @@ func ProcessPayment(ctx fsm.Context, environment ProviderDealEnvironment, deal r
 	}
 
 	// attempt to redeem voucher
-	// (totalSent * pricePerbyte) - fundsReceived
-	paymentOwed := big.Sub(big.Mul(abi.NewTokenAmount(int64(deal.TotalSent)), deal.PricePerByte), deal.FundsReceived)
+	// (totalSent * pricePerbyte) + unsealPrice - fundsReceived
+	paymentOwed := big.Sub(big.Add(big.Mul(abi.NewTokenAmount(int64(deal.TotalSent)), deal.PricePerByte), deal.UnsealPrice), deal.FundsReceived)
 	received, err := environment.Node().SavePaymentVoucher(ctx.Context(), payment.PaymentChannel, payment.PaymentVoucher, nil, paymentOwed, tok)
 	if err != nil {
 		return ctx.Trigger(rm.ProviderEventSaveVoucherFailed, err)
@@ -136,10 +175,46 @@ func ProcessPayment(ctx fsm.Context, environment ProviderDealEnvironment, deal r
 		return ctx.Trigger(rm.ProviderEventPartialPaymentReceived, received)
 	}
 
+	// start sending blocks once the unseal price is paid
+	if deal.FundsReceived.LessThan(deal.UnsealPrice) {
+		return ctx.Trigger(rm.ProviderEventUnsealPaymentReceived, received)
+	}
+
 	// resume deal
 	return ctx.Trigger(rm.ProviderEventPaymentReceived, received)
 }
 
+// processCancellation saves the voucher a client sends when it cancels a
+// deal, which only pays for the data it received
+func processCancellation(ctx fsm.Context, environment ProviderDealEnvironment, deal rm.ProviderDealState, payment rm.DealPayment) error {
+	if payment.PaymentVoucher == nil {
+		return ctx.Trigger(rm.ProviderEventClientCancelled, big.Zero())
+	}
+
+	tok, _, err := environment.Node().GetChainHead(ctx.Context())
+	if err != nil {
+		return ctx.Trigger(rm.ProviderEventSaveVoucherFailed, err)
+	}
+
+	received, err := environment.Node().SavePaymentVoucher(ctx.Context(), payment.PaymentChannel, payment.PaymentVoucher, nil, big.Zero(), tok)
+	if err != nil {
+		return ctx.Trigger(rm.ProviderEventSaveVoucherFailed, err)
+	}
+	// as in ProcessPayment, a voucher that was already saved is counted
+	// from its amount
+	if big.Cmp(received, big.Zero()) == 0 {
+		received = big.Sub(payment.PaymentVoucher.Amount, deal.FundsReceived)
+	}
+
+	return ctx.Trigger(rm.ProviderEventClientCancelled, received)
+}
+
+// CancelDeal stops reading blocks for a deal the client cancelled, and
+// closes its stream
+func CancelDeal(ctx fsm.Context, environment ProviderDealEnvironment, deal rm.ProviderDealState) error {
+	return environment.CloseDeal(ctx.Context(), deal.Identifier())
+}
+
 // SendFailResponse sends a failure response before closing the deal
 func SendFailResponse(ctx fsm.Context, environment ProviderDealEnvironment, deal rm.ProviderDealState) error {
 	stream := environment.DealStream(deal.Identifier())
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"errors"
+	"io"
 	"testing"
 
 	"github.com/filecoin-project/go-address"
@@ -119,6 +120,27 @@ func TestReceiveDeal(t *testing.T) {
 		require.NotEmpty(t, dealState.Message)
 	})
 
+	t.Run("unseal price too low", func(t *testing.T) {
+		node := testnodes.NewTestRetrievalProviderNode()
+		dealState := blankDealState()
+		dealStreamParams := testnet.TestDealStreamParams{
+			ProposalReader: testnet.StubbedDealProposalReader(proposal),
+			ResponseWriter: testnet.ExpectDealResponseWriter(t, retrievalmarket.DealResponse{
+				Status:  retrievalmarket.DealStatusRejected,
+				ID:      proposal.ID,
+				Message: "Unseal price too low",
+			}),
+		}
+		setupEnv := func(fe *testProviderDealEnvironment) {
+			fe.ExpectPiece(expectedPiece, 10000)
+			fe.ExpectParams(defaultPricePerByte, defaultCurrentInterval, defaultIntervalIncrease, nil)
+			fe.unsealPrice = defaultUnsealPrice
+		}
+		runReceiveDeal(t, node, dealStreamParams, setupEnv, dealState)
+		require.Equal(t, dealState.Status, retrievalmarket.DealStatusRejected)
+		require.Equal(t, dealState.Message, "Unseal price too low")
+	})
+
 	t.Run("response write error", func(t *testing.T) {
 		node := testnodes.NewTestRetrievalProviderNode()
 		dealState := blankDealState()
@@ -188,6 +210,44 @@ func TestSendBlocks(t *testing.T) {
 		require.Empty(t, dealState.Message)
 	})
 
+	t.Run("requests the unseal price first", func(t *testing.T) {
+		_, responses := generateResponses(10, 100, false, false)
+		dealState := makeDealState(retrievalmarket.DealStatusAccepted)
+		dealState.UnsealPrice = defaultUnsealPrice
+		dealState.TotalSent = 0
+		dealState.FundsReceived = big.Zero()
+		dealStreamParams := testnet.TestDealStreamParams{
+			ResponseWriter: testnet.ExpectDealResponseWriter(t, retrievalmarket.DealResponse{
+				Status:      retrievalmarket.DealStatusFundsNeeded,
+				PaymentOwed: defaultUnsealPrice,
+				ID:          dealState.ID,
+			}),
+		}
+		runSendBlocks(t, dealStreamParams, responses, dealState)
+		require.Equal(t, dealState.Status, retrievalmarket.DealStatusFundsNeeded)
+		require.Equal(t, dealState.TotalSent, uint64(0))
+		require.Empty(t, dealState.Message)
+	})
+
+	t.Run("sends blocks once the unseal price is paid", func(t *testing.T) {
+		blocks, responses := generateResponses(10, 100, false, false)
+		dealState := makeDealState(retrievalmarket.DealStatusOngoing)
+		dealState.UnsealPrice = defaultUnsealPrice
+		dealState.FundsReceived = big.Add(defaultFundsReceived, defaultUnsealPrice)
+		dealStreamParams := testnet.TestDealStreamParams{
+			ResponseWriter: testnet.ExpectDealResponseWriter(t, retrievalmarket.DealResponse{
+				Status:      retrievalmarket.DealStatusFundsNeeded,
+				PaymentOwed: defaultPaymentPerInterval,
+				Blocks:      blocks,
+				ID:          dealState.ID,
+			}),
+		}
+		runSendBlocks(t, dealStreamParams, responses, dealState)
+		require.Equal(t, dealState.Status, retrievalmarket.DealStatusFundsNeeded)
+		require.Equal(t, dealState.TotalSent, defaultTotalSent+defaultCurrentInterval)
+		require.Empty(t, dealState.Message)
+	})
+
 	t.Run("error reading a block", func(t *testing.T) {
 		_, responses := generateResponses(10, 100, false, true)
 		dealState := makeDealState(retrievalmarket.DealStatusAccepted)
@@ -213,6 +273,43 @@ func TestSendBlocks(t *testing.T) {
 		require.Equal(t, dealState.Status, retrievalmarket.DealStatusErrored)
 		require.NotEmpty(t, dealState.Message)
 	})
+
+	t.Run("client cancels while blocks are sent", func(t *testing.T) {
+		_, responses := generateResponses(10, 100, false, false)
+		partialPayment := abi.NewTokenAmount(400000)
+		cancelVoucher := testnet.MakeTestSignedVoucher()
+		cancelVoucher.Amount = big.Add(defaultFundsReceived, partialPayment)
+		err := node.ExpectVoucher(address.TestAddress, cancelVoucher, nil, big.Zero(), partialPayment, nil)
+		require.NoError(t, err)
+		dealState := makeDealState(retrievalmarket.DealStatusOngoing)
+		dealStreamParams := testnet.TestDealStreamParams{
+			ResponseWriter: testnet.FailDealResponseWriter,
+			PaymentReader: testnet.StubbedDealPaymentReader(retrievalmarket.DealPayment{
+				ID:             dealID,
+				PaymentChannel: address.TestAddress,
+				PaymentVoucher: cancelVoucher,
+				Cancel:         true,
+			}),
+		}
+		runSendBlocks(t, dealStreamParams, responses, dealState)
+		node.VerifyExpectations(t)
+		require.Equal(t, dealState.Status, retrievalmarket.DealStatusCancelled)
+		require.Equal(t, dealState.FundsReceived, big.Add(defaultFundsReceived, partialPayment))
+	})
+
+	t.Run("client closes the stream while blocks are sent", func(t *testing.T) {
+		_, responses := generateResponses(10, 100, false, false)
+		dealState := makeDealState(retrievalmarket.DealStatusOngoing)
+		dealStreamParams := testnet.TestDealStreamParams{
+			ResponseWriter: testnet.FailDealResponseWriter,
+			PaymentReader: func() (retrievalmarket.DealPayment, error) {
+				return retrievalmarket.DealPaymentUndefined, io.EOF
+			},
+		}
+		runSendBlocks(t, dealStreamParams, responses, dealState)
+		require.Equal(t, dealState.Status, retrievalmarket.DealStatusCancelled)
+		require.Equal(t, dealState.FundsReceived, defaultFundsReceived)
+	})
 }
 
 func TestProcessPayment(t *testing.T) {
@@ -270,6 +367,30 @@ func TestProcessPayment(t *testing.T) {
 		require.Empty(t, dealState.Message)
 	})
 
+	t.Run("unseal payment", func(t *testing.T) {
+		node := testnodes.NewTestRetrievalProviderNode()
+		unsealVoucher := testnet.MakeTestSignedVoucher()
+		unsealVoucher.Amount = defaultUnsealPrice
+		err := node.ExpectVoucher(payCh, unsealVoucher, nil, defaultUnsealPrice, defaultUnsealPrice, nil)
+		require.NoError(t, err)
+		dealState := makeDealState(retrievalmarket.DealStatusFundsNeeded)
+		dealState.UnsealPrice = defaultUnsealPrice
+		dealState.TotalSent = 0
+		dealState.FundsReceived = big.Zero()
+		dealStreamParams := testnet.TestDealStreamParams{
+			PaymentReader: testnet.StubbedDealPaymentReader(retrievalmarket.DealPayment{
+				ID:             dealID,
+				PaymentChannel: payCh,
+				PaymentVoucher: unsealVoucher,
+			}),
+		}
+		runProcessPayment(t, node, dealStreamParams, dealState)
+		require.Equal(t, dealState.Status, retrievalmarket.DealStatusOngoing)
+		require.Equal(t, dealState.FundsReceived, defaultUnsealPrice)
+		require.Equal(t, dealState.CurrentInterval, defaultCurrentInterval)
+		require.Empty(t, dealState.Message)
+	})
+
 	t.Run("not enough funds sent", func(t *testing.T) {
 		node := testnodes.NewTestRetrievalProviderNode()
 		smallerPayment := abi.NewTokenAmount(400000)
@@ -339,6 +460,60 @@ func TestProcessPayment(t *testing.T) {
 		require.Equal(t, dealState.Status, retrievalmarket.DealStatusErrored)
 		require.NotEmpty(t, dealState.Message)
 	})
+
+	t.Run("client cancels with a final payment", func(t *testing.T) {
+		node := testnodes.NewTestRetrievalProviderNode()
+		partialPayment := abi.NewTokenAmount(400000)
+		cancelVoucher := testnet.MakeTestSignedVoucher()
+		cancelVoucher.Amount = big.Add(defaultFundsReceived, partialPayment)
+		err := node.ExpectVoucher(payCh, cancelVoucher, nil, big.Zero(), partialPayment, nil)
+		require.NoError(t, err)
+		dealState := makeDealState(retrievalmarket.DealStatusFundsNeeded)
+		dealState.TotalSent = defaultTotalSent + defaultCurrentInterval
+		dealStreamParams := testnet.TestDealStreamParams{
+			PaymentReader: testnet.StubbedDealPaymentReader(retrievalmarket.DealPayment{
+				ID:             dealID,
+				PaymentChannel: payCh,
+				PaymentVoucher: cancelVoucher,
+				Cancel:         true,
+			}),
+		}
+		runProcessPayment(t, node, dealStreamParams, dealState)
+		require.Equal(t, dealState.Status, retrievalmarket.DealStatusCancelled)
+		require.Equal(t, dealState.FundsReceived, big.Add(defaultFundsReceived, partialPayment))
+		require.Equal(t, dealState.CurrentInterval, defaultCurrentInterval)
+	})
+
+	t.Run("client cancels with nothing owed", func(t *testing.T) {
+		node := testnodes.NewTestRetrievalProviderNode()
+		dealState := makeDealState(retrievalmarket.DealStatusFundsNeededLastPayment)
+		dealState.TotalSent = defaultTotalSent + defaultCurrentInterval
+		dealStreamParams := testnet.TestDealStreamParams{
+			PaymentReader: testnet.StubbedDealPaymentReader(retrievalmarket.DealPayment{
+				ID:     dealID,
+				Cancel: true,
+			}),
+		}
+		runProcessPayment(t, node, dealStreamParams, dealState)
+		require.Equal(t, dealState.Status, retrievalmarket.DealStatusCancelled)
+		require.Equal(t, dealState.FundsReceived, defaultFundsReceived)
+	})
+}
+
+func TestCancelDeal(t *testing.T) {
+	ctx := context.Background()
+	eventMachine, err := fsm.NewEventProcessor(retrievalmarket.ProviderDealState{}, "Status", providerstates.ProviderEvents)
+	require.NoError(t, err)
+	node := testnodes.NewTestRetrievalProviderNode()
+	ds := testnet.NewTestRetrievalDealStream(testnet.TestDealStreamParams{})
+	environment := NewTestProviderDealEnvironment(node, ds, nil)
+	fsmCtx := fsmtest.NewTestContext(ctx, eventMachine)
+	dealState := makeDealState(retrievalmarket.DealStatusCancelled)
+	err = providerstates.CancelDeal(fsmCtx, environment, *dealState)
+	require.NoError(t, err)
+	require.True(t, environment.closed)
+	fsmCtx.ReplayEvents(t, dealState)
+	require.Equal(t, dealState.Status, retrievalmarket.DealStatusCancelled)
 }
 
 type readBlockResponse struct {
@@ -364,6 +539,8 @@ type testProviderDealEnvironment struct {
 	expectedMissingCIDs map[cid.Cid]struct{}
 	receivedCIDs        map[cid.Cid]struct{}
 	receivedMissingCIDs map[cid.Cid]struct{}
+	unsealPrice         abi.TokenAmount
+	closed              bool
 }
 
 func NewTestProviderDealEnvironment(node retrievalmarket.RetrievalProviderNode,
@@ -379,7 +556,8 @@ func NewTestProviderDealEnvironment(node retrievalmarket.RetrievalProviderNode,
 		expectedCIDs:        make(map[cid.Cid]uint64),
 		expectedMissingCIDs: make(map[cid.Cid]struct{}),
 		receivedCIDs:        make(map[cid.Cid]struct{}),
-		receivedMissingCIDs: make(map[cid.Cid]struct{})}
+		receivedMissingCIDs: make(map[cid.Cid]struct{}),
+		unsealPrice:         big.Zero()}
 }
 
 // ExpectPiece records a piece being expected to be queried and return the given piece info
@@ -413,6 +591,11 @@ func (te *testProviderDealEnvironment) DealStream(_ retrievalmarket.ProviderDeal
 	return te.ds
 }
 
+func (te *testProviderDealEnvironment) CloseDeal(_ context.Context, _ retrievalmarket.ProviderDealIdentifier) error {
+	te.closed = true
+	return nil
+}
+
 func (te *testProviderDealEnvironment) GetPieceSize(c cid.Cid) (uint64, error) {
 	pio, ok := te.expectedCIDs[c]
 	if ok {
@@ -437,6 +620,10 @@ func (te *testProviderDealEnvironment) CheckDealParams(pricePerByte abi.TokenAmo
 	return err
 }
 
+func (te *testProviderDealEnvironment) UnsealPrice(_ cid.Cid) abi.TokenAmount {
+	return te.unsealPrice
+}
+
 func (te *testProviderDealEnvironment) NextBlock(_ context.Context, _ retrievalmarket.ProviderDealIdentifier) (rm.Block, bool, error) {
 	if te.nextResponse >= len(te.responses) {
 		return rm.EmptyBlock, false, errors.New("Something went wrong")
@@ -453,6 +640,7 @@ var defaultPricePerByte = abi.NewTokenAmount(500)
 var defaultPaymentPerInterval = big.Mul(defaultPricePerByte, abi.NewTokenAmount(int64(defaultCurrentInterval)))
 var defaultTotalSent = uint64(5000)
 var defaultFundsReceived = abi.NewTokenAmount(2500000)
+var defaultUnsealPrice = abi.NewTokenAmount(200000)
 
 func makeDealState(status retrievalmarket.DealStatus) *retrievalmarket.ProviderDealState {
 	return &retrievalmarket.ProviderDealState{
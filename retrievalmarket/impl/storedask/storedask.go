@@ -0,0 +1,123 @@
+// Package storedask keeps the terms a retrieval provider offers in a
+// datastore, so they survive restarts
+package storedask
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/filecoin-project/go-address"
+	cborutil "github.com/filecoin-project/go-cbor-util"
+	"github.com/ipfs/go-datastore"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+)
+
+// StoredAsk is a retrieval ask that is signed by the miner's worker and saved
+// in a datastore
+type StoredAsk struct {
+	askLk sync.Mutex
+	ask   *retrievalmarket.SignedRetrievalAsk
+	ds    datastore.Batching
+	dsKey datastore.Key
+	node  retrievalmarket.RetrievalProviderNode
+	actor address.Address
+}
+
+// NewStoredAsk returns the stored ask for the given miner, loading the last
+// ask saved at dsKey. If no ask was saved, GetAsk returns nil until one is set
+func NewStoredAsk(ds datastore.Batching, dsKey datastore.Key, node retrievalmarket.RetrievalProviderNode, actor address.Address) (*StoredAsk, error) {
+	s := &StoredAsk{
+		ds:    ds,
+		dsKey: dsKey,
+		node:  node,
+		actor: actor,
+	}
+
+	if err := s.loadAsk(); err != nil && !xerrors.Is(err, datastore.ErrNotFound) {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetAsk signs the given terms as the miner's new ask, with the next sequence
+// number, and saves it
+func (s *StoredAsk) SetAsk(ask *retrievalmarket.RetrievalAsk) error {
+	s.askLk.Lock()
+	defer s.askLk.Unlock()
+
+	newAsk := *ask
+	newAsk.Miner = s.actor
+	newAsk.SeqNo = 0
+	if s.ask != nil {
+		newAsk.SeqNo = s.ask.Ask.SeqNo + 1
+	}
+
+	ctx := context.TODO()
+	tok, _, err := s.node.GetChainHead(ctx)
+	if err != nil {
+		return err
+	}
+
+	msg, err := cborutil.Dump(&newAsk)
+	if err != nil {
+		return xerrors.Errorf("serializing: %w", err)
+	}
+
+	worker, err := s.node.GetMinerWorkerAddress(ctx, s.actor, tok)
+	if err != nil {
+		return err
+	}
+
+	sig, err := s.node.SignBytes(ctx, worker, msg)
+	if err != nil {
+		return xerrors.Errorf("failed to sign: %w", err)
+	}
+
+	return s.saveAsk(&retrievalmarket.SignedRetrievalAsk{
+		Ask:       &newAsk,
+		Signature: sig,
+	})
+}
+
+// GetAsk returns the current ask, or nil if none has been set
+func (s *StoredAsk) GetAsk() *retrievalmarket.SignedRetrievalAsk {
+	s.askLk.Lock()
+	defer s.askLk.Unlock()
+	if s.ask == nil {
+		return nil
+	}
+	ask := *s.ask
+	return &ask
+}
+
+func (s *StoredAsk) loadAsk() error {
+	askb, err := s.ds.Get(s.dsKey)
+	if err != nil {
+		return xerrors.Errorf("failed to load most recent retrieval ask from disk: %w", err)
+	}
+
+	var ask retrievalmarket.SignedRetrievalAsk
+	if err := cborutil.ReadCborRPC(bytes.NewReader(askb), &ask); err != nil {
+		return err
+	}
+
+	s.ask = &ask
+	return nil
+}
+
+func (s *StoredAsk) saveAsk(ask *retrievalmarket.SignedRetrievalAsk) error {
+	b, err := cborutil.Dump(ask)
+	if err != nil {
+		return err
+	}
+
+	if err := s.ds.Put(s.dsKey, b); err != nil {
+		return err
+	}
+
+	s.ask = ask
+	return nil
+}
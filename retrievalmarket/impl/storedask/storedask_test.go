@@ -0,0 +1,74 @@
+package storedask_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-datastore"
+	dss "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket/impl/storedask"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket/impl/testnodes"
+	tut "github.com/filecoin-project/go-fil-markets/shared_testutil"
+)
+
+func TestStoredAsk(t *testing.T) {
+	ds := dss.MutexWrap(datastore.NewMapDatastore())
+	node := testnodes.NewTestRetrievalProviderNode()
+	actor := address.TestAddress2
+	storedAsk, err := storedask.NewStoredAsk(ds, datastore.NewKey("retrieval-ask"), node, actor)
+	require.NoError(t, err)
+
+	testAsk := &retrievalmarket.RetrievalAsk{
+		PricePerByte:            abi.NewTokenAmount(1000),
+		UnsealPrice:             abi.NewTokenAmount(5000),
+		PaymentInterval:         1 << 20,
+		PaymentIntervalIncrease: 1 << 10,
+	}
+
+	t.Run("no ask before one is set", func(t *testing.T) {
+		require.Nil(t, storedAsk.GetAsk())
+	})
+	t.Run("setting an ask", func(t *testing.T) {
+		err := storedAsk.SetAsk(testAsk)
+		require.NoError(t, err)
+		ask := storedAsk.GetAsk()
+		require.NotNil(t, ask)
+		require.Equal(t, testAsk.PricePerByte, ask.Ask.PricePerByte)
+		require.Equal(t, testAsk.UnsealPrice, ask.Ask.UnsealPrice)
+		require.Equal(t, testAsk.PaymentInterval, ask.Ask.PaymentInterval)
+		require.Equal(t, testAsk.PaymentIntervalIncrease, ask.Ask.PaymentIntervalIncrease)
+		require.Equal(t, actor, ask.Ask.Miner)
+		require.Equal(t, uint64(0), ask.Ask.SeqNo)
+		require.Equal(t, tut.MakeTestSignature(), ask.Signature)
+	})
+	t.Run("updating an ask increments the sequence number", func(t *testing.T) {
+		updated := *testAsk
+		updated.PricePerByte = abi.NewTokenAmount(2000)
+		err := storedAsk.SetAsk(&updated)
+		require.NoError(t, err)
+		ask := storedAsk.GetAsk()
+		require.Equal(t, updated.PricePerByte, ask.Ask.PricePerByte)
+		require.Equal(t, uint64(1), ask.Ask.SeqNo)
+	})
+	t.Run("reloading stored ask from disk", func(t *testing.T) {
+		storedAsk2, err := storedask.NewStoredAsk(ds, datastore.NewKey("retrieval-ask"), node, actor)
+		require.NoError(t, err)
+		require.Equal(t, storedAsk.GetAsk(), storedAsk2.GetAsk())
+	})
+	t.Run("signing error", func(t *testing.T) {
+		nodeSignBytesErr := testnodes.NewTestRetrievalProviderNode()
+		nodeSignBytesErr.SignBytesError = errors.New("something went wrong")
+		// should load cause ask is still in data store
+		storedAskError, err := storedask.NewStoredAsk(ds, datastore.NewKey("retrieval-ask"), nodeSignBytesErr, actor)
+		require.NoError(t, err)
+		err = storedAskError.SetAsk(testAsk)
+		require.EqualError(t, err, "failed to sign: something went wrong")
+		// the previous ask is kept
+		require.Equal(t, uint64(1), storedAskError.GetAsk().Ask.SeqNo)
+	})
+}
@@ -17,6 +17,7 @@ import (
 // are stubbed
 type TestRetrievalClientNode struct {
 	addFundsOnly                            bool // set this to true to test adding funds to an existing payment channel
+	channelCreated                          bool // later calls add funds to the channel once it is created
 	payCh                                   address.Address
 	payChErr                                error
 	createPaychMsgCID, addFundsMsgCID       cid.Cid
@@ -24,6 +25,7 @@ type TestRetrievalClientNode struct {
 	laneError                               error
 	voucher                                 *paych.SignedVoucher
 	voucherError, waitCreateErr, waitAddErr error
+	askValidationErr                        error
 
 	allocateLaneRecorder            func(address.Address)
 	createPaymentVoucherRecorder    func(voucher *paych.SignedVoucher)
@@ -44,6 +46,7 @@ type TestRetrievalClientNodeParams struct {
 	PaymentChannelRecorder                 func(address.Address, address.Address, abi.TokenAmount)
 	AddFundsOnly                           bool
 	WaitForAddFundsErr, WaitForChCreateErr error
+	AskValidationErr                       error
 }
 
 var _ retrievalmarket.RetrievalClientNode = &TestRetrievalClientNode{}
@@ -65,17 +68,19 @@ func NewTestRetrievalClientNode(params TestRetrievalClientNodeParams) *TestRetri
 		getCreatePaymentChannelRecorder: params.PaymentChannelRecorder,
 		createPaychMsgCID:               params.CreatePaychCID,
 		addFundsMsgCID:                  params.AddFundsCID,
+		askValidationErr:                params.AskValidationErr,
 	}
 }
 
-// GetOrCreatePaymentChannel returns a mocked payment channel
+// GetOrCreatePaymentChannel returns a mocked payment channel, adding funds to
+// it if it already exists
 func (trcn *TestRetrievalClientNode) GetOrCreatePaymentChannel(ctx context.Context, clientAddress address.Address, minerAddress address.Address, clientFundsAvailable abi.TokenAmount, tok shared.TipSetToken) (address.Address, cid.Cid, error) {
 	if trcn.getCreatePaymentChannelRecorder != nil {
 		trcn.getCreatePaymentChannelRecorder(clientAddress, minerAddress, clientFundsAvailable)
 	}
 	var payCh address.Address
 	msgCID := trcn.createPaychMsgCID
-	if trcn.addFundsOnly {
+	if trcn.addFundsOnly || trcn.channelCreated {
 		payCh = trcn.payCh
 		msgCID = trcn.addFundsMsgCID
 	}
@@ -112,5 +117,12 @@ func (trcn *TestRetrievalClientNode) WaitForPaymentChannelCreation(messageCID ci
 	if messageCID != trcn.createPaychMsgCID {
 		return address.Undef, fmt.Errorf("expected messageCID: %s does not match actual: %s", trcn.createPaychMsgCID, messageCID)
 	}
+	trcn.channelCreated = trcn.waitCreateErr == nil
 	return trcn.payCh, trcn.waitCreateErr
 }
+
+// ValidateAskSignature returns the stubbed validation error and a boolean value
+// communicating the validity of the provided signature
+func (trcn *TestRetrievalClientNode) ValidateAskSignature(ctx context.Context, ask *retrievalmarket.SignedRetrievalAsk, tok shared.TipSetToken) (bool, error) {
+	return trcn.askValidationErr == nil, trcn.askValidationErr
+}
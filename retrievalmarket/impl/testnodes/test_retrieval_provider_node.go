@@ -12,10 +12,12 @@ import (
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
+	"github.com/filecoin-project/specs-actors/actors/crypto"
 	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
 	"github.com/filecoin-project/go-fil-markets/shared"
+	"github.com/filecoin-project/go-fil-markets/shared_testutil"
 )
 
 type expectedVoucherKey struct {
@@ -44,6 +46,9 @@ type TestRetrievalProviderNode struct {
 	received         map[sectorKey]struct{}
 	expectedVouchers map[expectedVoucherKey]voucherResult
 	receivedVouchers map[expectedVoucherKey]struct{}
+
+	// SignBytesError is returned by SignBytes when it is set
+	SignBytesError error
 }
 
 var _ retrievalmarket.RetrievalProviderNode = &TestRetrievalProviderNode{}
@@ -115,6 +120,14 @@ func (trpn *TestRetrievalProviderNode) SavePaymentVoucher(
 	return abi.TokenAmount{}, errors.New("SavePaymentVoucher failed")
 }
 
+// SignBytes simulates signing data by returning a test signature
+func (trpn *TestRetrievalProviderNode) SignBytes(ctx context.Context, signer address.Address, b []byte) (*crypto.Signature, error) {
+	if trpn.SignBytesError == nil {
+		return shared_testutil.MakeTestSignature(), nil
+	}
+	return nil, trpn.SignBytesError
+}
+
 // GetMinerWorker translates an address
 func (trpn *TestRetrievalProviderNode) GetMinerWorkerAddress(ctx context.Context, addr address.Address, tok shared.TipSetToken) (address.Address, error) {
 	return addr, nil
@@ -27,13 +27,13 @@ type libp2pRetrievalMarketNetwork struct {
 }
 
 func (impl *libp2pRetrievalMarketNetwork) NewQueryStream(id peer.ID) (RetrievalQueryStream, error) {
-	s, err := impl.host.NewStream(context.Background(), id, retrievalmarket.QueryProtocolID)
+	s, err := impl.host.NewStream(context.Background(), id, retrievalmarket.QueryProtocolIDV1, retrievalmarket.QueryProtocolID)
 	if err != nil {
 		log.Warn(err)
 		return nil, err
 	}
 	buffered := bufio.NewReaderSize(s, 16)
-	return &QueryStream{p: id, rw: s, buffered: buffered}, nil
+	return &QueryStream{p: id, rw: s, buffered: buffered, protocol: s.Protocol()}, nil
 }
 
 func (impl *libp2pRetrievalMarketNetwork) NewDealStream(id peer.ID) (RetrievalDealStream, error) {
@@ -49,6 +49,7 @@ func (impl *libp2pRetrievalMarketNetwork) SetDelegate(r RetrievalReceiver) error
 	impl.receiver = r
 	impl.host.SetStreamHandler(retrievalmarket.ProtocolID, impl.handleNewDealStream)
 	impl.host.SetStreamHandler(retrievalmarket.QueryProtocolID, impl.handleNewQueryStream)
+	impl.host.SetStreamHandler(retrievalmarket.QueryProtocolIDV1, impl.handleNewQueryStream)
 	return nil
 }
 
@@ -56,6 +57,7 @@ func (impl *libp2pRetrievalMarketNetwork) StopHandlingRequests() error {
 	impl.receiver = nil
 	impl.host.RemoveStreamHandler(retrievalmarket.ProtocolID)
 	impl.host.RemoveStreamHandler(retrievalmarket.QueryProtocolID)
+	impl.host.RemoveStreamHandler(retrievalmarket.QueryProtocolIDV1)
 	return nil
 }
 
@@ -67,7 +69,7 @@ func (impl *libp2pRetrievalMarketNetwork) handleNewQueryStream(s network.Stream)
 	}
 	remotePID := s.Conn().RemotePeer()
 	buffered := bufio.NewReaderSize(s, 16)
-	qs := &QueryStream{remotePID, s, buffered}
+	qs := &QueryStream{remotePID, s, buffered, s.Protocol()}
 	impl.receiver.HandleQueryStream(qs)
 }
 
@@ -1,6 +1,7 @@
 package network_test
 
 import (
+	"bufio"
 	"context"
 	"math/big"
 	"math/rand"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	"github.com/filecoin-project/go-address"
+	cborutil "github.com/filecoin-project/go-cbor-util"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/stretchr/testify/assert"
@@ -128,6 +130,52 @@ func TestQueryStreamSendReceiveMultipleSuccessful(t *testing.T) {
 	assert.Equal(t, qr, resp)
 }
 
+func TestQueryStreamOriginalProtocolReceivesOriginalFields(t *testing.T) {
+	ctxBg := context.Background()
+	td := shared_testutil.NewLibp2pTestData(ctxBg, t)
+	nw2 := network.NewFromLibp2pHost(td.Host2)
+	require.NoError(t, td.Host1.Connect(ctxBg, peer.AddrInfo{ID: td.Host2.ID()}))
+
+	// host2 answers with an unseal price and an ask
+	qr := shared_testutil.MakeTestQueryResponse()
+	qr.UnsealPrice = abi.NewTokenAmount(100)
+	qr.Ask = &retrievalmarket.SignedRetrievalAsk{
+		Ask: &retrievalmarket.RetrievalAsk{
+			PricePerByte: qr.MinPricePerByte,
+			UnsealPrice:  qr.UnsealPrice,
+			Miner:        address.TestAddress2,
+		},
+		Signature: shared_testutil.MakeTestSignature(),
+	}
+	tr2 := &testReceiver{t: t, queryStreamHandler: func(s network.RetrievalQueryStream) {
+		_, err := s.ReadQuery()
+		require.NoError(t, err)
+		require.NoError(t, s.WriteQueryResponse(qr))
+	}}
+	require.NoError(t, nw2.SetDelegate(tr2))
+
+	ctx, cancel := context.WithTimeout(ctxBg, 10*time.Second)
+	defer cancel()
+
+	// host1 only speaks the original query protocol
+	s, err := td.Host1.NewStream(ctx, td.Host2.ID(), retrievalmarket.QueryProtocolID)
+	require.NoError(t, err)
+	defer s.Close()
+	require.NoError(t, cborutil.WriteCborRPC(s, &retrievalmarket.Query{PayloadCID: shared_testutil.GenerateCids(1)[0]}))
+
+	buffered := bufio.NewReader(s)
+	header, err := buffered.Peek(1)
+	require.NoError(t, err)
+	// an array of the original eight fields
+	require.Equal(t, byte(0x88), header[0])
+
+	var resp retrievalmarket.QueryResponse
+	require.NoError(t, resp.UnmarshalCBOR(buffered))
+	require.Nil(t, resp.Ask)
+	require.True(t, resp.UnsealPrice.IsZero())
+	require.Equal(t, qr.MinPricePerByte, resp.MinPricePerByte)
+}
+
 func TestDealStreamSendReceiveDealProposal(t *testing.T) {
 	// send proposal, read in handler
 	ctx := context.Background()
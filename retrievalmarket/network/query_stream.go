@@ -4,8 +4,10 @@ import (
 	"bufio"
 
 	cborutil "github.com/filecoin-project/go-cbor-util"
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/libp2p/go-libp2p-core/mux"
 	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
 
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
 )
@@ -14,6 +16,7 @@ type QueryStream struct {
 	p        peer.ID
 	rw       mux.MuxedStream
 	buffered *bufio.Reader
+	protocol protocol.ID
 }
 
 var _ RetrievalQueryStream = (*QueryStream)(nil)
@@ -46,6 +49,13 @@ func (qs *QueryStream) ReadQueryResponse() (retrievalmarket.QueryResponse, error
 }
 
 func (qs *QueryStream) WriteQueryResponse(qr retrievalmarket.QueryResponse) error {
+	// clients on the original query protocol fail to read a response with
+	// more fields than they know. Without the unseal price they propose no
+	// payment for unsealing, which the provider then rejects
+	if qs.protocol == retrievalmarket.QueryProtocolID {
+		qr.UnsealPrice = abi.TokenAmount{}
+		qr.Ask = nil
+	}
 	return cborutil.WriteCborRPC(qs.rw, &qr)
 }
 
@@ -11,6 +11,7 @@ import (
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
+	"github.com/filecoin-project/specs-actors/actors/crypto"
 	"github.com/ipfs/go-cid"
 	"github.com/ipld/go-ipld-prime"
 	"github.com/ipld/go-ipld-prime/codec/dagcbor"
@@ -20,7 +21,7 @@ import (
 	"github.com/filecoin-project/go-fil-markets/shared"
 )
 
-//go:generate cbor-gen-for Query QueryResponse DealProposal DealResponse Params QueryParams DealPayment Block ClientDealState ProviderDealState PaymentInfo
+//go:generate cbor-gen-for Query DealProposal DealResponse QueryParams Block clientDealStateTuple ProviderDealState PaymentInfo RetrievalAsk SignedRetrievalAsk queryResponseTuple paramsTuple dealPaymentTuple
 
 // ProtocolID is the protocol for proposing / responding to retrieval deals
 const ProtocolID = "/fil/retrieval/0.0.1"
@@ -29,6 +30,11 @@ const ProtocolID = "/fil/retrieval/0.0.1"
 // deal parameters
 const QueryProtocolID = "/fil/retrieval/qry/0.0.1"
 
+// QueryProtocolIDV1 is the query protocol for clients that read the
+// UnsealPrice and Ask in a QueryResponse. Clients on QueryProtocolID only
+// read the original fields, so providers leave the new ones out for them
+const QueryProtocolIDV1 = "/fil/retrieval/qry/1.0.0"
+
 // Unsubscribe is a function that unsubscribes a subscriber for either the
 // client or the provider
 type Unsubscribe func()
@@ -56,6 +62,7 @@ type ClientDealState struct {
 	PaymentRequested abi.TokenAmount
 	FundsSpent       abi.TokenAmount
 	WaitMsgCID       *cid.Cid // the CID of any message the client deal is waiting for
+	UnsealFundsPaid  abi.TokenAmount
 }
 
 // ClientEvent is an event that occurs in a deal lifecycle on the client
@@ -150,6 +157,22 @@ const (
 
 	// ClientEventComplete indicates a deal has completed
 	ClientEventComplete
+
+	// ClientEventFundsAdded indicates the client added funds to a deal that
+	// had run out of them, so it can pay the provider and continue
+	ClientEventFundsAdded
+
+	// ClientEventCancel indicates the client asked to stop a deal before it
+	// completed
+	ClientEventCancel
+
+	// ClientEventCancelComplete indicates the client paid for the data it
+	// received and stopped the deal
+	ClientEventCancelComplete
+
+	// ClientEventUnsealPaymentSent indicates the client paid the provider to
+	// unseal the piece, before receiving any data
+	ClientEventUnsealPaymentSent
 )
 
 // ClientSubscriber is a callback that is registered to listen for retrieval events
@@ -187,8 +210,18 @@ type RetrievalClient interface {
 	// V1
 	AddMoreFunds(id DealID, amount abi.TokenAmount) error
 	CancelDeal(id DealID) error
-	RetrievalStatus(id DealID)
+
+	// RetrievalStatus returns the current state of a deal
+	RetrievalStatus(id DealID) (ClientDealState, error)
+
+	// ListDeals returns all deals the client has made
 	ListDeals() map[DealID]ClientDealState
+
+	// FilterDeals returns the deals the client has made that match the filter
+	FilterDeals(filter DealFilter) (map[DealID]ClientDealState, error)
+
+	// DealStatusCounts returns the number of deals the client has in each status
+	DealStatusCounts() (map[DealStatus]int, error)
 }
 
 // RetrievalClientNode are the node dependencies for a RetrievalClient
@@ -216,6 +249,10 @@ type RetrievalClientNode interface {
 	// WaitForPaymentChannelCreation waits for a message on chain that a
 	// payment channel has been created
 	WaitForPaymentChannelCreation(messageCID cid.Cid) (address.Address, error)
+
+	// ValidateAskSignature checks that a retrieval ask was signed by the
+	// worker of the miner it is for
+	ValidateAskSignature(ctx context.Context, ask *SignedRetrievalAsk, tok shared.TipSetToken) (bool, error)
 }
 
 // ProviderDealState is the current state of a deal from the point of view
@@ -300,6 +337,14 @@ const (
 
 	// ProviderEventComplete indicates a retrieval deal was completed for a client
 	ProviderEventComplete
+
+	// ProviderEventClientCancelled happens when the client stops a deal before
+	// it completes, paying only for the data it received
+	ProviderEventClientCancelled
+
+	// ProviderEventUnsealPaymentReceived happens when a provider receives the
+	// up front payment to unseal a piece, and can start sending blocks
+	ProviderEventUnsealPaymentReceived
 )
 
 // ProviderDealID is a unique identifier for a deal on a provider -- it is
@@ -334,8 +379,22 @@ type RetrievalProvider interface {
 	SubscribeToEvents(subscriber ProviderSubscriber) Unsubscribe
 
 	// V1
+	// SetPricePerUnseal sets the price charged up front to unseal a piece for
+	// retrieval, when it is not already unsealed
 	SetPricePerUnseal(price abi.TokenAmount)
+
+	// GetAsk returns the signed retrieval terms the provider currently offers
+	GetAsk() *SignedRetrievalAsk
+
+	// ListDeals returns all deals clients have made with the provider
 	ListDeals() map[ProviderDealID]ProviderDealState
+
+	// FilterDeals returns the deals clients have made with the provider that
+	// match the filter
+	FilterDeals(filter DealFilter) (map[ProviderDealID]ProviderDealState, error)
+
+	// DealStatusCounts returns the number of deals the provider has in each status
+	DealStatusCounts() (map[DealStatus]int, error)
 }
 
 // RetrievalProviderNode are the node depedencies for a RetrevalProvider
@@ -346,6 +405,9 @@ type RetrievalProviderNode interface {
 	GetMinerWorkerAddress(ctx context.Context, miner address.Address, tok shared.TipSetToken) (address.Address, error)
 	UnsealSector(ctx context.Context, sectorID uint64, offset uint64, length uint64) (io.ReadCloser, error)
 	SavePaymentVoucher(ctx context.Context, paymentChannel address.Address, voucher *paych.SignedVoucher, proof []byte, expectedAmount abi.TokenAmount, tok shared.TipSetToken) (abi.TokenAmount, error)
+
+	// SignBytes signs the given data with the given address's private key
+	SignBytes(ctx context.Context, signer address.Address, b []byte) (*crypto.Signature, error)
 }
 
 // PeerResolver is an interface for looking up providers that may have a piece
@@ -435,14 +497,42 @@ type QueryResponse struct {
 	MaxPaymentInterval         uint64
 	MaxPaymentIntervalIncrease uint64
 	Message                    string
+	// UnsealPrice is charged up front when the piece has to be unsealed
+	// before it can be retrieved, and is zero when it is already unsealed
+	UnsealPrice abi.TokenAmount
+	// Ask is the signed ask the terms above were quoted from, so clients can
+	// verify them. Providers that predate stored asks leave it unset
+	Ask *SignedRetrievalAsk
+}
+
+// RetrievalAsk is the terms a retrieval provider offers for all retrievals
+type RetrievalAsk struct {
+	PricePerByte            abi.TokenAmount
+	UnsealPrice             abi.TokenAmount
+	PaymentInterval         uint64
+	PaymentIntervalIncrease uint64
+
+	Miner address.Address
+	SeqNo uint64
+}
+
+// SignedRetrievalAsk is a retrieval ask signed by the miner's worker
+type SignedRetrievalAsk struct {
+	Ask       *RetrievalAsk
+	Signature *crypto.Signature
 }
 
 // QueryResponseUndefined is an empty QueryResponse
 var QueryResponseUndefined = QueryResponse{}
 
-// PieceRetrievalPrice is the total price to retrieve the piece (size * MinPricePerByte)
+// PieceRetrievalPrice is the total price to retrieve the piece (size * MinPricePerByte),
+// plus the price to unseal it if it has to be unsealed
 func (qr QueryResponse) PieceRetrievalPrice() abi.TokenAmount {
-	return big.Mul(qr.MinPricePerByte, abi.NewTokenAmount(int64(qr.Size)))
+	price := big.Mul(qr.MinPricePerByte, abi.NewTokenAmount(int64(qr.Size)))
+	if qr.UnsealPrice.Nil() {
+		return price
+	}
+	return big.Add(price, qr.UnsealPrice)
 }
 
 // PayloadRetrievalPrice is the expected price to retrieve just the given payload
@@ -515,26 +605,48 @@ const (
 	// DealStatusFinalizing means the last payment has been received and
 	// we are just confirming the deal is complete
 	DealStatusFinalizing
+
+	// DealStatusInsufficientFunds means the provider requested a payment the
+	// client doesn't have the funds for, and the deal is waiting for the client
+	// to add more
+	DealStatusInsufficientFunds
+
+	// DealStatusInsufficientFundsLastPayment means the provider requested the
+	// last payment for a deal, and the deal is waiting for the client to add
+	// the funds for it
+	DealStatusInsufficientFundsLastPayment
+
+	// DealStatusCancelling means the client is paying for the data it has
+	// received and stopping the deal
+	DealStatusCancelling
+
+	// DealStatusCancelled means the deal was stopped by the client before it
+	// completed
+	DealStatusCancelled
 )
 
 // DealStatuses maps deal status to a human readable representation
 var DealStatuses = map[DealStatus]string{
-	DealStatusNew:                       "DealStatusNew",
-	DealStatusPaymentChannelCreating:    "DealStatusPaymentChannelCreating",
-	DealStatusPaymentChannelAddingFunds: "DealStatusPaymentChannelAddingFunds",
-	DealStatusPaymentChannelReady:       "DealStatusPaymentChannelReady",
-	DealStatusAccepted:                  "DealStatusAccepted",
-	DealStatusFailed:                    "DealStatusFailed",
-	DealStatusRejected:                  "DealStatusRejected",
-	DealStatusFundsNeeded:               "DealStatusFundsNeeded",
-	DealStatusOngoing:                   "DealStatusOngoing",
-	DealStatusFundsNeededLastPayment:    "DealStatusFundsNeededLastPayment",
-	DealStatusCompleted:                 "DealStatusCompleted",
-	DealStatusDealNotFound:              "DealStatusDealNotFound",
-	DealStatusVerified:                  "DealStatusVerified",
-	DealStatusErrored:                   "DealStatusErrored",
-	DealStatusBlocksComplete:            "DealStatusBlocksComplete",
-	DealStatusFinalizing:                "DealStatusFinalizing",
+	DealStatusNew:                          "DealStatusNew",
+	DealStatusPaymentChannelCreating:       "DealStatusPaymentChannelCreating",
+	DealStatusPaymentChannelAddingFunds:    "DealStatusPaymentChannelAddingFunds",
+	DealStatusPaymentChannelReady:          "DealStatusPaymentChannelReady",
+	DealStatusAccepted:                     "DealStatusAccepted",
+	DealStatusFailed:                       "DealStatusFailed",
+	DealStatusRejected:                     "DealStatusRejected",
+	DealStatusFundsNeeded:                  "DealStatusFundsNeeded",
+	DealStatusOngoing:                      "DealStatusOngoing",
+	DealStatusFundsNeededLastPayment:       "DealStatusFundsNeededLastPayment",
+	DealStatusCompleted:                    "DealStatusCompleted",
+	DealStatusDealNotFound:                 "DealStatusDealNotFound",
+	DealStatusVerified:                     "DealStatusVerified",
+	DealStatusErrored:                      "DealStatusErrored",
+	DealStatusBlocksComplete:               "DealStatusBlocksComplete",
+	DealStatusFinalizing:                   "DealStatusFinalizing",
+	DealStatusInsufficientFunds:            "DealStatusInsufficientFunds",
+	DealStatusInsufficientFundsLastPayment: "DealStatusInsufficientFundsLastPayment",
+	DealStatusCancelling:                   "DealStatusCancelling",
+	DealStatusCancelled:                    "DealStatusCancelled",
 }
 
 // IsTerminalError returns true if this status indicates processing of this deal
@@ -552,9 +664,9 @@ func IsTerminalSuccess(status DealStatus) bool {
 }
 
 // IsTerminalStatus returns true if this status indicates processing of a deal is
-// complete (either success or error)
+// complete (either success or error), or was cancelled
 func IsTerminalStatus(status DealStatus) bool {
-	return IsTerminalError(status) || IsTerminalSuccess(status)
+	return IsTerminalError(status) || IsTerminalSuccess(status) || status == DealStatusCancelled
 }
 
 // Params are the parameters requested for a retrieval deal proposal
@@ -562,8 +674,9 @@ type Params struct {
 	Selector                *cbg.Deferred // V1
 	PieceCID                *cid.Cid
 	PricePerByte            abi.TokenAmount
-	PaymentInterval         uint64 // when to request payment
-	PaymentIntervalIncrease uint64 //
+	PaymentInterval         uint64          // when to request payment
+	PaymentIntervalIncrease uint64          //
+	UnsealPrice             abi.TokenAmount // paid up front, from QueryResponse.UnsealPrice
 }
 
 // NewParamsV0 generates parameters for a retrieval deal, which is always a whole piece deal
@@ -572,6 +685,7 @@ func NewParamsV0(pricePerByte abi.TokenAmount, paymentInterval uint64, paymentIn
 		PricePerByte:            pricePerByte,
 		PaymentInterval:         paymentInterval,
 		PaymentIntervalIncrease: paymentIntervalIncrease,
+		UnsealPrice:             big.Zero(),
 	}
 }
 
@@ -589,6 +703,7 @@ func NewParamsV1(pricePerByte abi.TokenAmount, paymentInterval uint64, paymentIn
 		PricePerByte:            pricePerByte,
 		PaymentInterval:         paymentInterval,
 		PaymentIntervalIncrease: paymentIntervalIncrease,
+		UnsealPrice:             big.Zero(),
 	}
 }
 
@@ -638,6 +753,53 @@ type DealPayment struct {
 	ID             DealID
 	PaymentChannel address.Address
 	PaymentVoucher *paych.SignedVoucher
+
+	// Cancel is set on the last message a client sends before it stops a
+	// deal. The voucher only pays for the data received so far, and is nil
+	// if that has all been paid for already
+	Cancel bool
+}
+
+// DealFilter selects deals when listing them. Fields left empty match
+// every deal
+type DealFilter struct {
+	// Statuses matches deals in any of the given statuses
+	Statuses []DealStatus
+
+	// Peer matches deals with the given counterparty: the provider for a
+	// client deal and the client for a provider deal
+	Peer peer.ID
+
+	// PayloadCID matches deals for the given payload
+	PayloadCID cid.Cid
+}
+
+// MatchesClientDeal returns true if the filter selects a client deal
+func (f DealFilter) MatchesClientDeal(deal ClientDealState) bool {
+	return f.matches(deal.Status, deal.Sender, deal.PayloadCID)
+}
+
+// MatchesProviderDeal returns true if the filter selects a provider deal
+func (f DealFilter) MatchesProviderDeal(deal ProviderDealState) bool {
+	return f.matches(deal.Status, deal.Receiver, deal.PayloadCID)
+}
+
+func (f DealFilter) matches(status DealStatus, p peer.ID, payloadCID cid.Cid) bool {
+	if f.Peer != "" && f.Peer != p {
+		return false
+	}
+	if f.PayloadCID.Defined() && !f.PayloadCID.Equals(payloadCID) {
+		return false
+	}
+	if len(f.Statuses) == 0 {
+		return true
+	}
+	for _, s := range f.Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
 }
 
 // DealPaymentUndefined is an undefined deal payment
@@ -0,0 +1,75 @@
+package retrievalmarket
+
+import (
+	"io"
+
+	"github.com/filecoin-project/go-fil-markets/shared"
+)
+
+// queryResponseTuple is the cbor-gen encoding of QueryResponse, whose
+// UnsealPrice and Ask were added after its original eight fields
+type queryResponseTuple QueryResponse
+
+var queryResponseCodec = shared.NewTupleCodec(8, &queryResponseTuple{})
+
+// MarshalCBOR encodes a QueryResponse, leaving out the UnsealPrice and Ask
+// when unset
+func (t *QueryResponse) MarshalCBOR(w io.Writer) error {
+	return queryResponseCodec.Marshal(w, (*queryResponseTuple)(t))
+}
+
+// UnmarshalCBOR decodes a QueryResponse, with or without the UnsealPrice and
+// Ask
+func (t *QueryResponse) UnmarshalCBOR(r io.Reader) error {
+	return queryResponseCodec.Unmarshal(r, (*queryResponseTuple)(t))
+}
+
+// paramsTuple is the cbor-gen encoding of Params, whose UnsealPrice was
+// added after its original five fields
+type paramsTuple Params
+
+var paramsCodec = shared.NewTupleCodec(5, &paramsTuple{})
+
+// MarshalCBOR encodes Params, leaving out the UnsealPrice when unset
+func (t *Params) MarshalCBOR(w io.Writer) error {
+	return paramsCodec.Marshal(w, (*paramsTuple)(t))
+}
+
+// UnmarshalCBOR decodes Params, with or without the UnsealPrice
+func (t *Params) UnmarshalCBOR(r io.Reader) error {
+	return paramsCodec.Unmarshal(r, (*paramsTuple)(t))
+}
+
+// dealPaymentTuple is the cbor-gen encoding of DealPayment, whose Cancel
+// was added after its original three fields
+type dealPaymentTuple DealPayment
+
+var dealPaymentCodec = shared.NewTupleCodec(3, &dealPaymentTuple{})
+
+// MarshalCBOR encodes a DealPayment, leaving out Cancel when unset
+func (t *DealPayment) MarshalCBOR(w io.Writer) error {
+	return dealPaymentCodec.Marshal(w, (*dealPaymentTuple)(t))
+}
+
+// UnmarshalCBOR decodes a DealPayment, with or without Cancel
+func (t *DealPayment) UnmarshalCBOR(r io.Reader) error {
+	return dealPaymentCodec.Unmarshal(r, (*dealPaymentTuple)(t))
+}
+
+// clientDealStateTuple is the cbor-gen encoding of ClientDealState, whose
+// UnsealFundsPaid was added after its original fourteen fields
+type clientDealStateTuple ClientDealState
+
+var clientDealStateCodec = shared.NewTupleCodec(14, &clientDealStateTuple{})
+
+// MarshalCBOR encodes a ClientDealState, leaving out UnsealFundsPaid when
+// unset
+func (t *ClientDealState) MarshalCBOR(w io.Writer) error {
+	return clientDealStateCodec.Marshal(w, (*clientDealStateTuple)(t))
+}
+
+// UnmarshalCBOR decodes a ClientDealState, including ones stored before
+// UnsealFundsPaid was added
+func (t *ClientDealState) UnmarshalCBOR(r io.Reader) error {
+	return clientDealStateCodec.Unmarshal(r, (*clientDealStateTuple)(t))
+}
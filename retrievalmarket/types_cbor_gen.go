@@ -7,6 +7,7 @@ import (
 	"io"
 
 	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
+	"github.com/filecoin-project/specs-actors/actors/crypto"
 	"github.com/libp2p/go-libp2p-core/peer"
 	cbg "github.com/whyrusleeping/cbor-gen"
 	xerrors "golang.org/x/xerrors"
@@ -75,185 +76,6 @@ func (t *Query) UnmarshalCBOR(r io.Reader) error {
 	return nil
 }
 
-func (t *QueryResponse) MarshalCBOR(w io.Writer) error {
-	if t == nil {
-		_, err := w.Write(cbg.CborNull)
-		return err
-	}
-	if _, err := w.Write([]byte{136}); err != nil {
-		return err
-	}
-
-	// t.Status (retrievalmarket.QueryResponseStatus) (uint64)
-
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Status))); err != nil {
-		return err
-	}
-
-	// t.PieceCIDFound (retrievalmarket.QueryItemStatus) (uint64)
-
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.PieceCIDFound))); err != nil {
-		return err
-	}
-
-	// t.Size (uint64) (uint64)
-
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Size))); err != nil {
-		return err
-	}
-
-	// t.PaymentAddress (address.Address) (struct)
-	if err := t.PaymentAddress.MarshalCBOR(w); err != nil {
-		return err
-	}
-
-	// t.MinPricePerByte (big.Int) (struct)
-	if err := t.MinPricePerByte.MarshalCBOR(w); err != nil {
-		return err
-	}
-
-	// t.MaxPaymentInterval (uint64) (uint64)
-
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.MaxPaymentInterval))); err != nil {
-		return err
-	}
-
-	// t.MaxPaymentIntervalIncrease (uint64) (uint64)
-
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.MaxPaymentIntervalIncrease))); err != nil {
-		return err
-	}
-
-	// t.Message (string) (string)
-	if len(t.Message) > cbg.MaxLength {
-		return xerrors.Errorf("Value in field t.Message was too long")
-	}
-
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.Message)))); err != nil {
-		return err
-	}
-	if _, err := w.Write([]byte(t.Message)); err != nil {
-		return err
-	}
-	return nil
-}
-
-func (t *QueryResponse) UnmarshalCBOR(r io.Reader) error {
-	br := cbg.GetPeeker(r)
-
-	maj, extra, err := cbg.CborReadHeader(br)
-	if err != nil {
-		return err
-	}
-	if maj != cbg.MajArray {
-		return fmt.Errorf("cbor input should be of type array")
-	}
-
-	if extra != 8 {
-		return fmt.Errorf("cbor input had wrong number of fields")
-	}
-
-	// t.Status (retrievalmarket.QueryResponseStatus) (uint64)
-
-	{
-
-		maj, extra, err = cbg.CborReadHeader(br)
-		if err != nil {
-			return err
-		}
-		if maj != cbg.MajUnsignedInt {
-			return fmt.Errorf("wrong type for uint64 field")
-		}
-		t.Status = QueryResponseStatus(extra)
-
-	}
-	// t.PieceCIDFound (retrievalmarket.QueryItemStatus) (uint64)
-
-	{
-
-		maj, extra, err = cbg.CborReadHeader(br)
-		if err != nil {
-			return err
-		}
-		if maj != cbg.MajUnsignedInt {
-			return fmt.Errorf("wrong type for uint64 field")
-		}
-		t.PieceCIDFound = QueryItemStatus(extra)
-
-	}
-	// t.Size (uint64) (uint64)
-
-	{
-
-		maj, extra, err = cbg.CborReadHeader(br)
-		if err != nil {
-			return err
-		}
-		if maj != cbg.MajUnsignedInt {
-			return fmt.Errorf("wrong type for uint64 field")
-		}
-		t.Size = uint64(extra)
-
-	}
-	// t.PaymentAddress (address.Address) (struct)
-
-	{
-
-		if err := t.PaymentAddress.UnmarshalCBOR(br); err != nil {
-			return xerrors.Errorf("unmarshaling t.PaymentAddress: %w", err)
-		}
-
-	}
-	// t.MinPricePerByte (big.Int) (struct)
-
-	{
-
-		if err := t.MinPricePerByte.UnmarshalCBOR(br); err != nil {
-			return xerrors.Errorf("unmarshaling t.MinPricePerByte: %w", err)
-		}
-
-	}
-	// t.MaxPaymentInterval (uint64) (uint64)
-
-	{
-
-		maj, extra, err = cbg.CborReadHeader(br)
-		if err != nil {
-			return err
-		}
-		if maj != cbg.MajUnsignedInt {
-			return fmt.Errorf("wrong type for uint64 field")
-		}
-		t.MaxPaymentInterval = uint64(extra)
-
-	}
-	// t.MaxPaymentIntervalIncrease (uint64) (uint64)
-
-	{
-
-		maj, extra, err = cbg.CborReadHeader(br)
-		if err != nil {
-			return err
-		}
-		if maj != cbg.MajUnsignedInt {
-			return fmt.Errorf("wrong type for uint64 field")
-		}
-		t.MaxPaymentIntervalIncrease = uint64(extra)
-
-	}
-	// t.Message (string) (string)
-
-	{
-		sval, err := cbg.ReadString(br)
-		if err != nil {
-			return err
-		}
-
-		t.Message = string(sval)
-	}
-	return nil
-}
-
 func (t *DealProposal) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
@@ -483,17 +305,12 @@ func (t *DealResponse) UnmarshalCBOR(r io.Reader) error {
 	return nil
 }
 
-func (t *Params) MarshalCBOR(w io.Writer) error {
+func (t *QueryParams) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
 		return err
 	}
-	if _, err := w.Write([]byte{133}); err != nil {
-		return err
-	}
-
-	// t.Selector (typegen.Deferred) (struct)
-	if err := t.Selector.MarshalCBOR(w); err != nil {
+	if _, err := w.Write([]byte{129}); err != nil {
 		return err
 	}
 
@@ -509,27 +326,10 @@ func (t *Params) MarshalCBOR(w io.Writer) error {
 		}
 	}
 
-	// t.PricePerByte (big.Int) (struct)
-	if err := t.PricePerByte.MarshalCBOR(w); err != nil {
-		return err
-	}
-
-	// t.PaymentInterval (uint64) (uint64)
-
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.PaymentInterval))); err != nil {
-		return err
-	}
-
-	// t.PaymentIntervalIncrease (uint64) (uint64)
-
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.PaymentIntervalIncrease))); err != nil {
-		return err
-	}
-
 	return nil
 }
 
-func (t *Params) UnmarshalCBOR(r io.Reader) error {
+func (t *QueryParams) UnmarshalCBOR(r io.Reader) error {
 	br := cbg.GetPeeker(r)
 
 	maj, extra, err := cbg.CborReadHeader(br)
@@ -540,31 +340,10 @@ func (t *Params) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input should be of type array")
 	}
 
-	if extra != 5 {
+	if extra != 1 {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
-	// t.Selector (typegen.Deferred) (struct)
-
-	{
-
-		pb, err := br.PeekByte()
-		if err != nil {
-			return err
-		}
-		if pb == cbg.CborNull[0] {
-			var nbuf [1]byte
-			if _, err := br.Read(nbuf[:]); err != nil {
-				return err
-			}
-		} else {
-			t.Selector = new(cbg.Deferred)
-			if err := t.Selector.UnmarshalCBOR(br); err != nil {
-				return xerrors.Errorf("unmarshaling t.Selector pointer: %w", err)
-			}
-		}
-
-	}
 	// t.PieceCID (cid.Cid) (struct)
 
 	{
@@ -589,226 +368,34 @@ func (t *Params) UnmarshalCBOR(r io.Reader) error {
 		}
 
 	}
-	// t.PricePerByte (big.Int) (struct)
+	return nil
+}
 
-	{
+func (t *Block) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{130}); err != nil {
+		return err
+	}
 
-		if err := t.PricePerByte.UnmarshalCBOR(br); err != nil {
-			return xerrors.Errorf("unmarshaling t.PricePerByte: %w", err)
-		}
+	// t.Prefix ([]uint8) (slice)
+	if len(t.Prefix) > cbg.ByteArrayMaxLen {
+		return xerrors.Errorf("Byte array in field t.Prefix was too long")
+	}
 
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajByteString, uint64(len(t.Prefix)))); err != nil {
+		return err
+	}
+	if _, err := w.Write(t.Prefix); err != nil {
+		return err
 	}
-	// t.PaymentInterval (uint64) (uint64)
 
-	{
-
-		maj, extra, err = cbg.CborReadHeader(br)
-		if err != nil {
-			return err
-		}
-		if maj != cbg.MajUnsignedInt {
-			return fmt.Errorf("wrong type for uint64 field")
-		}
-		t.PaymentInterval = uint64(extra)
-
-	}
-	// t.PaymentIntervalIncrease (uint64) (uint64)
-
-	{
-
-		maj, extra, err = cbg.CborReadHeader(br)
-		if err != nil {
-			return err
-		}
-		if maj != cbg.MajUnsignedInt {
-			return fmt.Errorf("wrong type for uint64 field")
-		}
-		t.PaymentIntervalIncrease = uint64(extra)
-
-	}
-	return nil
-}
-
-func (t *QueryParams) MarshalCBOR(w io.Writer) error {
-	if t == nil {
-		_, err := w.Write(cbg.CborNull)
-		return err
-	}
-	if _, err := w.Write([]byte{129}); err != nil {
-		return err
-	}
-
-	// t.PieceCID (cid.Cid) (struct)
-
-	if t.PieceCID == nil {
-		if _, err := w.Write(cbg.CborNull); err != nil {
-			return err
-		}
-	} else {
-		if err := cbg.WriteCid(w, *t.PieceCID); err != nil {
-			return xerrors.Errorf("failed to write cid field t.PieceCID: %w", err)
-		}
-	}
-
-	return nil
-}
-
-func (t *QueryParams) UnmarshalCBOR(r io.Reader) error {
-	br := cbg.GetPeeker(r)
-
-	maj, extra, err := cbg.CborReadHeader(br)
-	if err != nil {
-		return err
-	}
-	if maj != cbg.MajArray {
-		return fmt.Errorf("cbor input should be of type array")
-	}
-
-	if extra != 1 {
-		return fmt.Errorf("cbor input had wrong number of fields")
-	}
-
-	// t.PieceCID (cid.Cid) (struct)
-
-	{
-
-		pb, err := br.PeekByte()
-		if err != nil {
-			return err
-		}
-		if pb == cbg.CborNull[0] {
-			var nbuf [1]byte
-			if _, err := br.Read(nbuf[:]); err != nil {
-				return err
-			}
-		} else {
-
-			c, err := cbg.ReadCid(br)
-			if err != nil {
-				return xerrors.Errorf("failed to read cid field t.PieceCID: %w", err)
-			}
-
-			t.PieceCID = &c
-		}
-
-	}
-	return nil
-}
-
-func (t *DealPayment) MarshalCBOR(w io.Writer) error {
-	if t == nil {
-		_, err := w.Write(cbg.CborNull)
-		return err
-	}
-	if _, err := w.Write([]byte{131}); err != nil {
-		return err
-	}
-
-	// t.ID (retrievalmarket.DealID) (uint64)
-
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.ID))); err != nil {
-		return err
-	}
-
-	// t.PaymentChannel (address.Address) (struct)
-	if err := t.PaymentChannel.MarshalCBOR(w); err != nil {
-		return err
-	}
-
-	// t.PaymentVoucher (paych.SignedVoucher) (struct)
-	if err := t.PaymentVoucher.MarshalCBOR(w); err != nil {
-		return err
-	}
-	return nil
-}
-
-func (t *DealPayment) UnmarshalCBOR(r io.Reader) error {
-	br := cbg.GetPeeker(r)
-
-	maj, extra, err := cbg.CborReadHeader(br)
-	if err != nil {
-		return err
-	}
-	if maj != cbg.MajArray {
-		return fmt.Errorf("cbor input should be of type array")
-	}
-
-	if extra != 3 {
-		return fmt.Errorf("cbor input had wrong number of fields")
-	}
-
-	// t.ID (retrievalmarket.DealID) (uint64)
-
-	{
-
-		maj, extra, err = cbg.CborReadHeader(br)
-		if err != nil {
-			return err
-		}
-		if maj != cbg.MajUnsignedInt {
-			return fmt.Errorf("wrong type for uint64 field")
-		}
-		t.ID = DealID(extra)
-
-	}
-	// t.PaymentChannel (address.Address) (struct)
-
-	{
-
-		if err := t.PaymentChannel.UnmarshalCBOR(br); err != nil {
-			return xerrors.Errorf("unmarshaling t.PaymentChannel: %w", err)
-		}
-
-	}
-	// t.PaymentVoucher (paych.SignedVoucher) (struct)
-
-	{
-
-		pb, err := br.PeekByte()
-		if err != nil {
-			return err
-		}
-		if pb == cbg.CborNull[0] {
-			var nbuf [1]byte
-			if _, err := br.Read(nbuf[:]); err != nil {
-				return err
-			}
-		} else {
-			t.PaymentVoucher = new(paych.SignedVoucher)
-			if err := t.PaymentVoucher.UnmarshalCBOR(br); err != nil {
-				return xerrors.Errorf("unmarshaling t.PaymentVoucher pointer: %w", err)
-			}
-		}
-
-	}
-	return nil
-}
-
-func (t *Block) MarshalCBOR(w io.Writer) error {
-	if t == nil {
-		_, err := w.Write(cbg.CborNull)
-		return err
-	}
-	if _, err := w.Write([]byte{130}); err != nil {
-		return err
-	}
-
-	// t.Prefix ([]uint8) (slice)
-	if len(t.Prefix) > cbg.ByteArrayMaxLen {
-		return xerrors.Errorf("Byte array in field t.Prefix was too long")
-	}
-
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajByteString, uint64(len(t.Prefix)))); err != nil {
-		return err
-	}
-	if _, err := w.Write(t.Prefix); err != nil {
-		return err
-	}
-
-	// t.Data ([]uint8) (slice)
-	if len(t.Data) > cbg.ByteArrayMaxLen {
-		return xerrors.Errorf("Byte array in field t.Data was too long")
-	}
+	// t.Data ([]uint8) (slice)
+	if len(t.Data) > cbg.ByteArrayMaxLen {
+		return xerrors.Errorf("Byte array in field t.Data was too long")
+	}
 
 	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajByteString, uint64(len(t.Data)))); err != nil {
 		return err
@@ -871,12 +458,12 @@ func (t *Block) UnmarshalCBOR(r io.Reader) error {
 	return nil
 }
 
-func (t *ClientDealState) MarshalCBOR(w io.Writer) error {
+func (t *clientDealStateTuple) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
 		return err
 	}
-	if _, err := w.Write([]byte{142}); err != nil {
+	if _, err := w.Write([]byte{143}); err != nil {
 		return err
 	}
 
@@ -975,10 +562,14 @@ func (t *ClientDealState) MarshalCBOR(w io.Writer) error {
 		}
 	}
 
+	// t.UnsealFundsPaid (big.Int) (struct)
+	if err := t.UnsealFundsPaid.MarshalCBOR(w); err != nil {
+		return err
+	}
 	return nil
 }
 
-func (t *ClientDealState) UnmarshalCBOR(r io.Reader) error {
+func (t *clientDealStateTuple) UnmarshalCBOR(r io.Reader) error {
 	br := cbg.GetPeeker(r)
 
 	maj, extra, err := cbg.CborReadHeader(br)
@@ -989,7 +580,7 @@ func (t *ClientDealState) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input should be of type array")
 	}
 
-	if extra != 14 {
+	if extra != 15 {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
@@ -1167,6 +758,15 @@ func (t *ClientDealState) UnmarshalCBOR(r io.Reader) error {
 			t.WaitMsgCID = &c
 		}
 
+	}
+	// t.UnsealFundsPaid (big.Int) (struct)
+
+	{
+
+		if err := t.UnsealFundsPaid.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.UnsealFundsPaid: %w", err)
+		}
+
 	}
 	return nil
 }
@@ -1396,3 +996,705 @@ func (t *PaymentInfo) UnmarshalCBOR(r io.Reader) error {
 	}
 	return nil
 }
+
+func (t *RetrievalAsk) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{134}); err != nil {
+		return err
+	}
+
+	// t.PricePerByte (big.Int) (struct)
+	if err := t.PricePerByte.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.UnsealPrice (big.Int) (struct)
+	if err := t.UnsealPrice.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.PaymentInterval (uint64) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.PaymentInterval))); err != nil {
+		return err
+	}
+
+	// t.PaymentIntervalIncrease (uint64) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.PaymentIntervalIncrease))); err != nil {
+		return err
+	}
+
+	// t.Miner (address.Address) (struct)
+	if err := t.Miner.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.SeqNo (uint64) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.SeqNo))); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *RetrievalAsk) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 6 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.PricePerByte (big.Int) (struct)
+
+	{
+
+		if err := t.PricePerByte.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.PricePerByte: %w", err)
+		}
+
+	}
+	// t.UnsealPrice (big.Int) (struct)
+
+	{
+
+		if err := t.UnsealPrice.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.UnsealPrice: %w", err)
+		}
+
+	}
+	// t.PaymentInterval (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.PaymentInterval = uint64(extra)
+
+	}
+	// t.PaymentIntervalIncrease (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.PaymentIntervalIncrease = uint64(extra)
+
+	}
+	// t.Miner (address.Address) (struct)
+
+	{
+
+		if err := t.Miner.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Miner: %w", err)
+		}
+
+	}
+	// t.SeqNo (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.SeqNo = uint64(extra)
+
+	}
+	return nil
+}
+
+func (t *SignedRetrievalAsk) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{130}); err != nil {
+		return err
+	}
+
+	// t.Ask (retrievalmarket.RetrievalAsk) (struct)
+	if err := t.Ask.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Signature (crypto.Signature) (struct)
+	if err := t.Signature.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *SignedRetrievalAsk) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 2 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Ask (retrievalmarket.RetrievalAsk) (struct)
+
+	{
+
+		pb, err := br.PeekByte()
+		if err != nil {
+			return err
+		}
+		if pb == cbg.CborNull[0] {
+			var nbuf [1]byte
+			if _, err := br.Read(nbuf[:]); err != nil {
+				return err
+			}
+		} else {
+			t.Ask = new(RetrievalAsk)
+			if err := t.Ask.UnmarshalCBOR(br); err != nil {
+				return xerrors.Errorf("unmarshaling t.Ask pointer: %w", err)
+			}
+		}
+
+	}
+	// t.Signature (crypto.Signature) (struct)
+
+	{
+
+		pb, err := br.PeekByte()
+		if err != nil {
+			return err
+		}
+		if pb == cbg.CborNull[0] {
+			var nbuf [1]byte
+			if _, err := br.Read(nbuf[:]); err != nil {
+				return err
+			}
+		} else {
+			t.Signature = new(crypto.Signature)
+			if err := t.Signature.UnmarshalCBOR(br); err != nil {
+				return xerrors.Errorf("unmarshaling t.Signature pointer: %w", err)
+			}
+		}
+
+	}
+	return nil
+}
+
+func (t *queryResponseTuple) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{138}); err != nil {
+		return err
+	}
+
+	// t.Status (retrievalmarket.QueryResponseStatus) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Status))); err != nil {
+		return err
+	}
+
+	// t.PieceCIDFound (retrievalmarket.QueryItemStatus) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.PieceCIDFound))); err != nil {
+		return err
+	}
+
+	// t.Size (uint64) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Size))); err != nil {
+		return err
+	}
+
+	// t.PaymentAddress (address.Address) (struct)
+	if err := t.PaymentAddress.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.MinPricePerByte (big.Int) (struct)
+	if err := t.MinPricePerByte.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.MaxPaymentInterval (uint64) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.MaxPaymentInterval))); err != nil {
+		return err
+	}
+
+	// t.MaxPaymentIntervalIncrease (uint64) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.MaxPaymentIntervalIncrease))); err != nil {
+		return err
+	}
+
+	// t.Message (string) (string)
+	if len(t.Message) > cbg.MaxLength {
+		return xerrors.Errorf("Value in field t.Message was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.Message)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.Message)); err != nil {
+		return err
+	}
+
+	// t.UnsealPrice (big.Int) (struct)
+	if err := t.UnsealPrice.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Ask (retrievalmarket.SignedRetrievalAsk) (struct)
+	if err := t.Ask.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *queryResponseTuple) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 10 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Status (retrievalmarket.QueryResponseStatus) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.Status = QueryResponseStatus(extra)
+
+	}
+	// t.PieceCIDFound (retrievalmarket.QueryItemStatus) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.PieceCIDFound = QueryItemStatus(extra)
+
+	}
+	// t.Size (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.Size = uint64(extra)
+
+	}
+	// t.PaymentAddress (address.Address) (struct)
+
+	{
+
+		if err := t.PaymentAddress.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.PaymentAddress: %w", err)
+		}
+
+	}
+	// t.MinPricePerByte (big.Int) (struct)
+
+	{
+
+		if err := t.MinPricePerByte.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.MinPricePerByte: %w", err)
+		}
+
+	}
+	// t.MaxPaymentInterval (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.MaxPaymentInterval = uint64(extra)
+
+	}
+	// t.MaxPaymentIntervalIncrease (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.MaxPaymentIntervalIncrease = uint64(extra)
+
+	}
+	// t.Message (string) (string)
+
+	{
+		sval, err := cbg.ReadString(br)
+		if err != nil {
+			return err
+		}
+
+		t.Message = string(sval)
+	}
+	// t.UnsealPrice (big.Int) (struct)
+
+	{
+
+		if err := t.UnsealPrice.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.UnsealPrice: %w", err)
+		}
+
+	}
+	// t.Ask (retrievalmarket.SignedRetrievalAsk) (struct)
+
+	{
+
+		pb, err := br.PeekByte()
+		if err != nil {
+			return err
+		}
+		if pb == cbg.CborNull[0] {
+			var nbuf [1]byte
+			if _, err := br.Read(nbuf[:]); err != nil {
+				return err
+			}
+		} else {
+			t.Ask = new(SignedRetrievalAsk)
+			if err := t.Ask.UnmarshalCBOR(br); err != nil {
+				return xerrors.Errorf("unmarshaling t.Ask pointer: %w", err)
+			}
+		}
+
+	}
+	return nil
+}
+
+func (t *paramsTuple) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{134}); err != nil {
+		return err
+	}
+
+	// t.Selector (typegen.Deferred) (struct)
+	if err := t.Selector.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.PieceCID (cid.Cid) (struct)
+
+	if t.PieceCID == nil {
+		if _, err := w.Write(cbg.CborNull); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteCid(w, *t.PieceCID); err != nil {
+			return xerrors.Errorf("failed to write cid field t.PieceCID: %w", err)
+		}
+	}
+
+	// t.PricePerByte (big.Int) (struct)
+	if err := t.PricePerByte.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.PaymentInterval (uint64) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.PaymentInterval))); err != nil {
+		return err
+	}
+
+	// t.PaymentIntervalIncrease (uint64) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.PaymentIntervalIncrease))); err != nil {
+		return err
+	}
+
+	// t.UnsealPrice (big.Int) (struct)
+	if err := t.UnsealPrice.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *paramsTuple) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 6 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Selector (typegen.Deferred) (struct)
+
+	{
+
+		pb, err := br.PeekByte()
+		if err != nil {
+			return err
+		}
+		if pb == cbg.CborNull[0] {
+			var nbuf [1]byte
+			if _, err := br.Read(nbuf[:]); err != nil {
+				return err
+			}
+		} else {
+			t.Selector = new(cbg.Deferred)
+			if err := t.Selector.UnmarshalCBOR(br); err != nil {
+				return xerrors.Errorf("unmarshaling t.Selector pointer: %w", err)
+			}
+		}
+
+	}
+	// t.PieceCID (cid.Cid) (struct)
+
+	{
+
+		pb, err := br.PeekByte()
+		if err != nil {
+			return err
+		}
+		if pb == cbg.CborNull[0] {
+			var nbuf [1]byte
+			if _, err := br.Read(nbuf[:]); err != nil {
+				return err
+			}
+		} else {
+
+			c, err := cbg.ReadCid(br)
+			if err != nil {
+				return xerrors.Errorf("failed to read cid field t.PieceCID: %w", err)
+			}
+
+			t.PieceCID = &c
+		}
+
+	}
+	// t.PricePerByte (big.Int) (struct)
+
+	{
+
+		if err := t.PricePerByte.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.PricePerByte: %w", err)
+		}
+
+	}
+	// t.PaymentInterval (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.PaymentInterval = uint64(extra)
+
+	}
+	// t.PaymentIntervalIncrease (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.PaymentIntervalIncrease = uint64(extra)
+
+	}
+	// t.UnsealPrice (big.Int) (struct)
+
+	{
+
+		if err := t.UnsealPrice.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.UnsealPrice: %w", err)
+		}
+
+	}
+	return nil
+}
+
+func (t *dealPaymentTuple) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{132}); err != nil {
+		return err
+	}
+
+	// t.ID (retrievalmarket.DealID) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.ID))); err != nil {
+		return err
+	}
+
+	// t.PaymentChannel (address.Address) (struct)
+	if err := t.PaymentChannel.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.PaymentVoucher (paych.SignedVoucher) (struct)
+	if err := t.PaymentVoucher.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Cancel (bool) (bool)
+	if err := cbg.WriteBool(w, t.Cancel); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *dealPaymentTuple) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 4 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.ID (retrievalmarket.DealID) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.ID = DealID(extra)
+
+	}
+	// t.PaymentChannel (address.Address) (struct)
+
+	{
+
+		if err := t.PaymentChannel.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.PaymentChannel: %w", err)
+		}
+
+	}
+	// t.PaymentVoucher (paych.SignedVoucher) (struct)
+
+	{
+
+		pb, err := br.PeekByte()
+		if err != nil {
+			return err
+		}
+		if pb == cbg.CborNull[0] {
+			var nbuf [1]byte
+			if _, err := br.Read(nbuf[:]); err != nil {
+				return err
+			}
+		} else {
+			t.PaymentVoucher = new(paych.SignedVoucher)
+			if err := t.PaymentVoucher.UnmarshalCBOR(br); err != nil {
+				return xerrors.Errorf("unmarshaling t.PaymentVoucher pointer: %w", err)
+			}
+		}
+
+	}
+	// t.Cancel (bool) (bool)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajOther {
+		return fmt.Errorf("booleans must be major type 7")
+	}
+	switch extra {
+	case 20:
+		t.Cancel = false
+	case 21:
+		t.Cancel = true
+	default:
+		return fmt.Errorf("booleans are either major type 7, value 20 or 21 (got %d)", extra)
+	}
+	return nil
+}
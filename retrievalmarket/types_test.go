@@ -2,18 +2,95 @@ package retrievalmarket_test
 
 import (
 	"bytes"
+	"encoding/hex"
 	"testing"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
 	"github.com/ipld/go-ipld-prime/codec/dagcbor"
 	basicnode "github.com/ipld/go-ipld-prime/node/basic"
+	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
 	"github.com/filecoin-project/go-fil-markets/shared"
 	tut "github.com/filecoin-project/go-fil-markets/shared_testutil"
 )
 
+func TestQueryResponseMarshalUnmarshal(t *testing.T) {
+	response := retrievalmarket.QueryResponse{
+		Status:                     retrievalmarket.QueryResponseAvailable,
+		PieceCIDFound:              retrievalmarket.QueryItemAvailable,
+		Size:                       1024,
+		PaymentAddress:             address.TestAddress,
+		MinPricePerByte:            abi.NewTokenAmount(2),
+		MaxPaymentInterval:         1000,
+		MaxPaymentIntervalIncrease: 500,
+		Message:                    "hello",
+		UnsealPrice:                abi.NewTokenAmount(0),
+	}
+
+	t.Run("without an unseal price, matches the original encoding", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		err := response.MarshalCBOR(buf)
+		require.NoError(t, err)
+		// an array of the original eight fields
+		require.Equal(t, byte(0x88), buf.Bytes()[0])
+
+		var unmarshalled retrievalmarket.QueryResponse
+		err = unmarshalled.UnmarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, response, unmarshalled)
+	})
+
+	t.Run("with an unseal price", func(t *testing.T) {
+		withUnsealPrice := response
+		withUnsealPrice.UnsealPrice = abi.NewTokenAmount(100)
+		buf := new(bytes.Buffer)
+		err := withUnsealPrice.MarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, byte(0x89), buf.Bytes()[0])
+
+		var unmarshalled retrievalmarket.QueryResponse
+		err = unmarshalled.UnmarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, withUnsealPrice, unmarshalled)
+		require.Equal(t, abi.NewTokenAmount(2148), unmarshalled.PieceRetrievalPrice())
+	})
+
+	t.Run("with an ask", func(t *testing.T) {
+		withAsk := response
+		withAsk.Ask = &retrievalmarket.SignedRetrievalAsk{
+			Ask: &retrievalmarket.RetrievalAsk{
+				PricePerByte:            abi.NewTokenAmount(2),
+				UnsealPrice:             abi.NewTokenAmount(0),
+				PaymentInterval:         1000,
+				PaymentIntervalIncrease: 500,
+				Miner:                   address.TestAddress,
+				SeqNo:                   3,
+			},
+			Signature: tut.MakeTestSignature(),
+		}
+		buf := new(bytes.Buffer)
+		err := withAsk.MarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, byte(0x8a), buf.Bytes()[0])
+
+		var unmarshalled retrievalmarket.QueryResponse
+		err = unmarshalled.UnmarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, withAsk, unmarshalled)
+	})
+
+	t.Run("wrong number of fields", func(t *testing.T) {
+		var unmarshalled retrievalmarket.QueryResponse
+		err := unmarshalled.UnmarshalCBOR(bytes.NewReader([]byte{0x87}))
+		require.EqualError(t, err, "cbor input had wrong number of fields")
+	})
+}
+
 func TestParamsMarshalUnmarshal(t *testing.T) {
 	pieceCid := tut.GenerateCids(1)[0]
 
@@ -36,3 +113,217 @@ func TestParamsMarshalUnmarshal(t *testing.T) {
 	sel := nb.Build()
 	assert.Equal(t, sel, allSelector)
 }
+
+func TestParamsUnsealPriceMarshalUnmarshal(t *testing.T) {
+	pieceCid, err := cid.Decode("bafkreibuenncyubohem5h4ak6xnlxb6llcxpivtlcbrr6ks5xfevb277xu")
+	require.NoError(t, err)
+	params := retrievalmarket.NewParamsV1(abi.NewTokenAmount(2), 100, 10, shared.AllSelector(), &pieceCid)
+
+	// params encoded before UnsealPrice was added
+	original, err := hex.DecodeString("85a16152a2616ca1646e6f6e65a0623a3ea16161a1613ea16140a0d82a5825000155122034235a2c502e3919d3f00af5dabb87cb58aef4566b10631f2a5db94950ebffbd42000218640a")
+	require.NoError(t, err)
+
+	t.Run("reads the original encoding", func(t *testing.T) {
+		var unmarshalled retrievalmarket.Params
+		err := unmarshalled.UnmarshalCBOR(bytes.NewReader(original))
+		require.NoError(t, err)
+		require.Equal(t, params, unmarshalled)
+	})
+
+	t.Run("without an unseal price, matches the original encoding", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		err := params.MarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, original, buf.Bytes())
+	})
+
+	t.Run("with an unseal price", func(t *testing.T) {
+		withUnsealPrice := params
+		withUnsealPrice.UnsealPrice = abi.NewTokenAmount(100)
+		buf := new(bytes.Buffer)
+		err := withUnsealPrice.MarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, byte(0x86), buf.Bytes()[0])
+
+		var unmarshalled retrievalmarket.Params
+		err = unmarshalled.UnmarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, withUnsealPrice, unmarshalled)
+	})
+
+	t.Run("wrong number of fields", func(t *testing.T) {
+		var unmarshalled retrievalmarket.Params
+		err := unmarshalled.UnmarshalCBOR(bytes.NewReader([]byte{0x84}))
+		require.EqualError(t, err, "cbor input had wrong number of fields")
+	})
+}
+
+func TestDealPaymentMarshalUnmarshal(t *testing.T) {
+	payment := retrievalmarket.DealPayment{
+		ID:             5,
+		PaymentChannel: address.TestAddress,
+	}
+
+	// payment encoded before Cancel was added
+	original, err := hex.DecodeString("830555024716b023b7fe84b6e7dcda303c3d754b1a8ff2fcf6")
+	require.NoError(t, err)
+
+	t.Run("reads the original encoding", func(t *testing.T) {
+		var unmarshalled retrievalmarket.DealPayment
+		err := unmarshalled.UnmarshalCBOR(bytes.NewReader(original))
+		require.NoError(t, err)
+		require.Equal(t, payment, unmarshalled)
+	})
+
+	t.Run("without cancel, matches the original encoding", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		err := payment.MarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, original, buf.Bytes())
+	})
+
+	t.Run("with cancel", func(t *testing.T) {
+		cancel := payment
+		cancel.PaymentVoucher = tut.MakeTestSignedVoucher()
+		cancel.Cancel = true
+		buf := new(bytes.Buffer)
+		err := cancel.MarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, byte(0x84), buf.Bytes()[0])
+
+		var unmarshalled retrievalmarket.DealPayment
+		err = unmarshalled.UnmarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, cancel, unmarshalled)
+	})
+
+	t.Run("wrong number of fields", func(t *testing.T) {
+		var unmarshalled retrievalmarket.DealPayment
+		err := unmarshalled.UnmarshalCBOR(bytes.NewReader([]byte{0x82}))
+		require.EqualError(t, err, "cbor input had wrong number of fields")
+	})
+}
+
+func TestClientDealStateMarshalUnmarshal(t *testing.T) {
+	dealState := makeTestClientDealState(t)
+
+	// deal state stored before UnsealFundsPaid was added
+	original, err := hex.DecodeString("8e83d82a582500015512204813494d137e1631bba301d5acab6e7bb7aa74ce1185d456565ef51d737677b20185f6f64200021903e81901f443000bb855024716b023b7fe84b6e7dcda303c3d754b1a8ff2fc5502c0d06605cef612c0e217c6364c5d056c480634e38255024716b023b7fe84b6e7dcda303c3d754b1a8ff2fc02096673656e646572190400676d6573736167651902001903e8420004420005d82a582500015512204813494d137e1631bba301d5acab6e7bb7aa74ce1185d456565ef51d737677b2")
+	require.NoError(t, err)
+
+	t.Run("reads the original encoding", func(t *testing.T) {
+		var unmarshalled retrievalmarket.ClientDealState
+		err := unmarshalled.UnmarshalCBOR(bytes.NewReader(original))
+		require.NoError(t, err)
+		require.Equal(t, dealState, unmarshalled)
+	})
+
+	t.Run("without unseal funds paid, matches the original encoding", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		err := dealState.MarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, original, buf.Bytes())
+	})
+
+	t.Run("with unseal funds paid", func(t *testing.T) {
+		unsealed := dealState
+		unsealed.UnsealFundsPaid = abi.NewTokenAmount(100)
+		buf := new(bytes.Buffer)
+		err := unsealed.MarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, byte(0x8f), buf.Bytes()[0])
+
+		var unmarshalled retrievalmarket.ClientDealState
+		err = unmarshalled.UnmarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, unsealed, unmarshalled)
+	})
+}
+
+func TestDealFilter(t *testing.T) {
+	payloadCIDs := tut.GenerateCids(2)
+	peers := tut.GeneratePeers(2)
+	clientDeal := retrievalmarket.ClientDealState{
+		DealProposal: retrievalmarket.DealProposal{PayloadCID: payloadCIDs[0]},
+		Status:       retrievalmarket.DealStatusOngoing,
+		Sender:       peers[0],
+	}
+	providerDeal := retrievalmarket.ProviderDealState{
+		DealProposal: retrievalmarket.DealProposal{PayloadCID: payloadCIDs[0]},
+		Status:       retrievalmarket.DealStatusOngoing,
+		Receiver:     peers[0],
+	}
+
+	testCases := map[string]struct {
+		filter  retrievalmarket.DealFilter
+		matches bool
+	}{
+		"empty filter matches": {
+			filter:  retrievalmarket.DealFilter{},
+			matches: true,
+		},
+		"matching status": {
+			filter:  retrievalmarket.DealFilter{Statuses: []retrievalmarket.DealStatus{retrievalmarket.DealStatusCompleted, retrievalmarket.DealStatusOngoing}},
+			matches: true,
+		},
+		"other status": {
+			filter:  retrievalmarket.DealFilter{Statuses: []retrievalmarket.DealStatus{retrievalmarket.DealStatusCompleted}},
+			matches: false,
+		},
+		"matching peer and payload": {
+			filter:  retrievalmarket.DealFilter{Peer: peers[0], PayloadCID: payloadCIDs[0]},
+			matches: true,
+		},
+		"other peer": {
+			filter:  retrievalmarket.DealFilter{Peer: peers[1]},
+			matches: false,
+		},
+		"other payload": {
+			filter:  retrievalmarket.DealFilter{PayloadCID: payloadCIDs[1]},
+			matches: false,
+		},
+		"undefined payload matches": {
+			filter:  retrievalmarket.DealFilter{Peer: peers[0], PayloadCID: cid.Undef},
+			matches: true,
+		},
+		"any field failing fails": {
+			filter:  retrievalmarket.DealFilter{Peer: peers[0], PayloadCID: payloadCIDs[0], Statuses: []retrievalmarket.DealStatus{retrievalmarket.DealStatusNew}},
+			matches: false,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.matches, tc.filter.MatchesClientDeal(clientDeal))
+			assert.Equal(t, tc.matches, tc.filter.MatchesProviderDeal(providerDeal))
+		})
+	}
+}
+
+func makeTestClientDealState(t *testing.T) retrievalmarket.ClientDealState {
+	payloadCid, err := cid.Decode("bafkreicicneu2e36cyy3xiyb2wwkw3t3w6vhjtqrqxkfmvs66uoxg5txwi")
+	require.NoError(t, err)
+	return retrievalmarket.ClientDealState{
+		DealProposal: retrievalmarket.DealProposal{
+			PayloadCID: payloadCid,
+			ID:         1,
+			Params:     retrievalmarket.NewParamsV0(abi.NewTokenAmount(2), 1000, 500),
+		},
+		TotalFunds:   abi.NewTokenAmount(3000),
+		ClientWallet: address.TestAddress,
+		MinerWallet:  address.TestAddress2,
+		PaymentInfo: &retrievalmarket.PaymentInfo{
+			PayCh: address.TestAddress,
+			Lane:  2,
+		},
+		Status:           retrievalmarket.DealStatusOngoing,
+		Sender:           peer.ID("sender"),
+		TotalReceived:    1024,
+		Message:          "message",
+		BytesPaidFor:     512,
+		CurrentInterval:  1000,
+		PaymentRequested: abi.NewTokenAmount(4),
+		FundsSpent:       abi.NewTokenAmount(5),
+		WaitMsgCID:       &payloadCid,
+		UnsealFundsPaid:  abi.NewTokenAmount(0),
+	}
+}
@@ -0,0 +1,160 @@
+package shared
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+// TupleCodec keeps a cbor-gen tuple encoding compatible with peers and
+// stored records that predate fields added to the end of a type.
+//
+// Types encoded with cbor-gen are written as a CBOR array of their fields,
+// and cbor-gen only reads an array with exactly as many fields as the type
+// has. A type that gains fields wraps its cbor-gen encoding in a TupleCodec,
+// with the new fields appended after the original ones:
+//   - when writing, trailing fields past the original ones are left off for
+//     as long as they hold their zero value, so code that only knows the
+//     original fields can read anything that does not use the new ones
+//   - when reading, an array with fewer fields has the missing ones set to
+//     their zero value, and fields past the ones the type knows, written by
+//     newer code, are skipped
+type TupleCodec struct {
+	minFields  int
+	zeroFields [][]byte
+}
+
+// NewTupleCodec returns a codec for a struct type, given as a pointer to a
+// value of it, that originally had minFields fields. The fields after those
+// must have a zero value that can be encoded: numbers, strings, bools,
+// slices, pointers and types that encode themselves
+func NewTupleCodec(minFields int, v interface{}) *TupleCodec {
+	typ := reflect.TypeOf(v).Elem()
+	if typ.NumField() < minFields {
+		panic(fmt.Sprintf("%s has %d fields, fewer than the original %d", typ, typ.NumField(), minFields))
+	}
+	zeroFields := make([][]byte, typ.NumField())
+	for i := minFields; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		zero, err := zeroEncoding(field.Type)
+		if err != nil {
+			panic(fmt.Sprintf("%s.%s: %s", typ, field.Name, err))
+		}
+		zeroFields[i] = zero
+	}
+	return &TupleCodec{minFields: minFields, zeroFields: zeroFields}
+}
+
+// Marshal writes the cbor-gen encoding of v, without the trailing new fields
+// that are unset
+func (c *TupleCodec) Marshal(w io.Writer, v cbg.CBORMarshaler) error {
+	buf := new(bytes.Buffer)
+	if err := v.MarshalCBOR(buf); err != nil {
+		return err
+	}
+	if bytes.Equal(buf.Bytes(), cbg.CborNull) {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+
+	fields, err := readTuple(buf, c.minFields)
+	if err != nil {
+		return err
+	}
+	n := len(fields)
+	for n > c.minFields && bytes.Equal(fields[n-1], c.zeroFields[n-1]) {
+		n--
+	}
+	return writeTuple(w, fields[:n])
+}
+
+// Unmarshal reads v with its cbor-gen decoder from an array of at least the
+// original fields
+func (c *TupleCodec) Unmarshal(r io.Reader, v cbg.CBORUnmarshaler) error {
+	fields, err := readTuple(cbg.GetPeeker(r), c.minFields)
+	if err != nil {
+		return err
+	}
+	if len(fields) > len(c.zeroFields) {
+		fields = fields[:len(c.zeroFields)]
+	}
+	fields = append(fields, c.zeroFields[len(fields):]...)
+
+	buf := new(bytes.Buffer)
+	if err := writeTuple(buf, fields); err != nil {
+		return err
+	}
+	return v.UnmarshalCBOR(buf)
+}
+
+// zeroEncoding returns the cbor-gen encoding of the zero value of typ
+func zeroEncoding(typ reflect.Type) ([]byte, error) {
+	if m, ok := reflect.New(typ).Interface().(cbg.CBORMarshaler); ok {
+		buf := new(bytes.Buffer)
+		if err := m.MarshalCBOR(buf); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	switch typ.Kind() {
+	case reflect.Ptr:
+		return cbg.CborNull, nil
+	case reflect.Bool:
+		return cbg.CborEncodeMajorType(cbg.MajOther, 20), nil
+	case reflect.String:
+		return cbg.CborEncodeMajorType(cbg.MajTextString, 0), nil
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return cbg.CborEncodeMajorType(cbg.MajByteString, 0), nil
+		}
+		return cbg.CborEncodeMajorType(cbg.MajArray, 0), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cbg.CborEncodeMajorType(cbg.MajUnsignedInt, 0), nil
+	default:
+		return nil, fmt.Errorf("no zero encoding for %s", typ)
+	}
+}
+
+// readTuple reads a CBOR array of at least minFields items, returning the
+// encoding of each of them
+func readTuple(r io.Reader, minFields int) ([][]byte, error) {
+	maj, extra, err := cbg.CborReadHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if maj != cbg.MajArray {
+		return nil, fmt.Errorf("cbor input should be of type array")
+	}
+	if extra < uint64(minFields) {
+		return nil, fmt.Errorf("cbor input had wrong number of fields")
+	}
+	if extra > cbg.MaxLength {
+		return nil, fmt.Errorf("cbor input had too many fields (%d)", extra)
+	}
+
+	fields := make([][]byte, 0, extra)
+	for i := uint64(0); i < extra; i++ {
+		var field cbg.Deferred
+		if err := field.UnmarshalCBOR(r); err != nil {
+			return nil, err
+		}
+		fields = append(fields, field.Raw)
+	}
+	return fields, nil
+}
+
+func writeTuple(w io.Writer, fields [][]byte) error {
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajArray, uint64(len(fields)))); err != nil {
+		return err
+	}
+	for _, field := range fields {
+		if _, err := w.Write(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -20,6 +20,7 @@ type TestFileStoreParams struct {
 	AvailableTempFiles []filestore.File
 	ExpectedDeletions  []filestore.Path
 	ExpectedOpens      []filestore.Path
+	ReserveError       error
 }
 
 // TestFileStore is a mocked file store that can provide programmed returns
@@ -31,6 +32,9 @@ type TestFileStore struct {
 	expectedOpens      map[filestore.Path]struct{}
 	deletedFiles       map[filestore.Path]struct{}
 	openedFiles        map[filestore.Path]struct{}
+	reserveError       error
+	reservations       map[string]uint64
+	releases           map[string]struct{}
 }
 
 // NewTestFileStore returns a new test file store from the given parameters
@@ -42,6 +46,9 @@ func NewTestFileStore(params TestFileStoreParams) *TestFileStore {
 		expectedOpens:      make(map[filestore.Path]struct{}),
 		deletedFiles:       make(map[filestore.Path]struct{}),
 		openedFiles:        make(map[filestore.Path]struct{}),
+		reserveError:       params.ReserveError,
+		reservations:       make(map[string]uint64),
+		releases:           make(map[string]struct{}),
 	}
 	for _, path := range params.ExpectedDeletions {
 		fs.expectedDeletions[path] = struct{}{}
@@ -108,6 +115,42 @@ func (fs *TestFileStore) CreateTemp() (filestore.File, error) {
 	return tempFile, nil
 }
 
+// Reserve records a reservation, or returns the programmed error
+func (fs *TestFileStore) Reserve(key string, size uint64) error {
+	if fs.reserveError != nil {
+		return fs.reserveError
+	}
+	fs.reservations[key] = size
+	return nil
+}
+
+// Release removes a reservation and records that it was released
+func (fs *TestFileStore) Release(key string) {
+	delete(fs.reservations, key)
+	fs.releases[key] = struct{}{}
+}
+
+// Usage reports the space currently reserved
+func (fs *TestFileStore) Usage() (filestore.Usage, error) {
+	var reserved uint64
+	for _, size := range fs.reservations {
+		reserved += size
+	}
+	return filestore.Usage{Reserved: reserved}, nil
+}
+
+// Reservation returns the size reserved under a key, if any
+func (fs *TestFileStore) Reservation(key string) (uint64, bool) {
+	size, ok := fs.reservations[key]
+	return size, ok
+}
+
+// Released returns true if the reservation for the key was released
+func (fs *TestFileStore) Released(key string) bool {
+	_, ok := fs.releases[key]
+	return ok
+}
+
 // VerifyExpectations will verify that the correct files were opened and deleted
 func (fs *TestFileStore) VerifyExpectations(t *testing.T) {
 	require.Equal(t, fs.openedFiles, fs.expectedOpens)
@@ -73,6 +73,7 @@ func MakeTestQueryResponse() retrievalmarket.QueryResponse {
 		MinPricePerByte:            MakeTestTokenAmount(),
 		MaxPaymentInterval:         rand.Uint64(),
 		MaxPaymentIntervalIncrease: rand.Uint64(),
+		UnsealPrice:                abi.NewTokenAmount(0),
 	}
 }
 
@@ -200,12 +201,14 @@ func MakeTestMinerDeal(state storagemarket.StorageDealStatus, clientDealProposal
 // MakeTestStorageAsk generates a storage ask
 func MakeTestStorageAsk() *storagemarket.StorageAsk {
 	return &storagemarket.StorageAsk{
-		Price:        MakeTestTokenAmount(),
-		MinPieceSize: abi.PaddedPieceSize(rand.Uint64()),
-		Miner:        address.TestAddress2,
-		Timestamp:    abi.ChainEpoch(rand.Int63()),
-		Expiry:       abi.ChainEpoch(rand.Int63()),
-		SeqNo:        rand.Uint64(),
+		Price:                 MakeTestTokenAmount(),
+		MinPieceSize:          abi.PaddedPieceSize(rand.Uint64()),
+		MinProviderCollateral: MakeTestTokenAmount(),
+		MaxProviderCollateral: MakeTestTokenAmount(),
+		Miner:                 address.TestAddress2,
+		Timestamp:             abi.ChainEpoch(rand.Int63()),
+		Expiry:                abi.ChainEpoch(rand.Int63()),
+		SeqNo:                 rand.Uint64(),
 	}
 }
 
@@ -259,6 +262,41 @@ func MakeTestStorageAskResponse() smnet.AskResponse {
 	}
 }
 
+// MakeTestDealStatusRequest generates a request to get a provider's view of a deal
+func MakeTestDealStatusRequest() smnet.DealStatusRequest {
+	return smnet.DealStatusRequest{
+		Proposal:  GenerateCids(1)[0],
+		Epoch:     abi.ChainEpoch(rand.Int63()),
+		Signature: *MakeTestSignature(),
+	}
+}
+
+// MakeTestDealStatusResponse generates a response to a deal status request
+func MakeTestDealStatusResponse() smnet.DealStatusResponse {
+	proposal := MakeTestUnsignedDealProposal()
+	proposalCid := GenerateCids(1)[0]
+	publishCid := GenerateCids(1)[0]
+	return smnet.DealStatusResponse{
+		DealState: storagemarket.ProviderDealState{
+			State:       storagemarket.StorageDealSealing,
+			Proposal:    &proposal,
+			ProposalCid: &proposalCid,
+			PublishCid:  &publishCid,
+			DealID:      abi.DealID(rand.Uint64()),
+		},
+		Signature: *MakeTestSignature(),
+	}
+}
+
+// MakeTestDealCancellation generates a client's notice that it cancelled a deal
+func MakeTestDealCancellation() smnet.DealCancellation {
+	return smnet.DealCancellation{
+		Proposal:  GenerateCids(1)[0],
+		Epoch:     abi.ChainEpoch(rand.Int63()),
+		Signature: *MakeTestSignature(),
+	}
+}
+
 func RequireGenerateRetrievalPeers(t *testing.T, numPeers int) []retrievalmarket.RetrievalPeer {
 	peers := make([]retrievalmarket.RetrievalPeer, numPeers)
 	for i := range peers {
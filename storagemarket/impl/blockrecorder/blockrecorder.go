@@ -6,6 +6,7 @@ import (
 
 	"github.com/ipfs/go-cid"
 	"github.com/ipld/go-car"
+	"github.com/ipld/go-car/util"
 )
 
 //go:generate cbor-gen-for PieceBlockMetadata
@@ -32,6 +33,47 @@ func RecordEachBlockTo(out io.Writer) car.OnNewCarBlockFunc {
 	}
 }
 
+// ReadCarBlocks reads a CAR file and calls onNewCarBlock for each of its blocks
+// with the block's location in the file, as writing a selective CAR does, so
+// that the blocks of a CAR file written elsewhere can be recorded with
+// RecordEachBlockTo. It returns the header of the CAR file
+func ReadCarBlocks(in io.Reader, onNewCarBlock car.OnNewCarBlockFunc) (*car.CarHeader, error) {
+	br := bufio.NewReader(in)
+	header, err := car.ReadHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := car.HeaderSize(header)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		section, err := util.LdRead(br)
+		if err == io.EOF {
+			return header, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		c, n, err := util.ReadCid(section)
+		if err != nil {
+			return nil, err
+		}
+		size := util.LdSize(section)
+		err = onNewCarBlock(car.Block{
+			BlockCID: c,
+			Data:     section[n:],
+			Offset:   offset,
+			Size:     size,
+		})
+		if err != nil {
+			return nil, err
+		}
+		offset += size
+	}
+}
+
 // ReadBlockMetadata reads previously recorded block metadata
 func ReadBlockMetadata(input io.Reader) ([]PieceBlockMetadata, error) {
 	var metadatas []PieceBlockMetadata
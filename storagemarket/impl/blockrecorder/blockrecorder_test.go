@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
 	"github.com/ipld/go-car"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
 	basicnode "github.com/ipld/go-ipld-prime/node/basic"
@@ -77,4 +78,20 @@ func TestBlockRecording(t *testing.T) {
 		}
 		require.False(t, found)
 	}
+
+	t.Run("reading the CAR file records the same locations", func(t *testing.T) {
+		readLocationBuf := new(bytes.Buffer)
+		header, err := blockrecorder.ReadCarBlocks(bytes.NewReader(carBytes), blockrecorder.RecordEachBlockTo(readLocationBuf))
+		require.NoError(t, err)
+		require.Equal(t, []cid.Cid{testData.RootNodeLnk.(cidlink.Link).Cid}, header.Roots)
+
+		readMetadata, err := blockrecorder.ReadBlockMetadata(readLocationBuf)
+		require.NoError(t, err)
+		require.Equal(t, metadata, readMetadata)
+	})
+
+	t.Run("reading a truncated CAR file fails", func(t *testing.T) {
+		_, err := blockrecorder.ReadCarBlocks(bytes.NewReader(carBytes[:len(carBytes)-1]), blockrecorder.RecordEachBlockTo(new(bytes.Buffer)))
+		require.Error(t, err)
+	})
 }
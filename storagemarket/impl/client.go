@@ -3,6 +3,9 @@ package storageimpl
 import (
 	"context"
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	cborutil "github.com/filecoin-project/go-cbor-util"
@@ -38,6 +41,21 @@ var log = logging.Logger("storagemarket_impl")
 
 var _ storagemarket.StorageClient = &Client{}
 
+// DefaultPollingInterval is the frequency with which we query the provider for deal state updates
+var DefaultPollingInterval = 30 * time.Second
+
+// DefaultAskTimeout is how long a client waits for each provider to answer an
+// ask request when searching for storage offers
+var DefaultAskTimeout = 10 * time.Second
+
+// DefaultDealStatusTimeout is how long a client waits for a provider to
+// answer a deal status request
+var DefaultDealStatusTimeout = 10 * time.Second
+
+// DefaultMaxFinishedReplications is how many finished replications a client
+// keeps the status of by default
+var DefaultMaxFinishedReplications = 100
+
 type Client struct {
 	net network.StorageMarketNetwork
 
@@ -46,10 +64,127 @@ type Client struct {
 	pio          pieceio.PieceIO
 	discovery    *discovery.Local
 
-	node          storagemarket.StorageClientNode
-	pubSub        *pubsub.PubSub
-	statemachines fsm.Group
-	conns         *connmanager.ConnManager
+	node             storagemarket.StorageClientNode
+	pubSub           *pubsub.PubSub
+	commPPubSub      *pubsub.PubSub
+	statemachines    fsm.Group
+	conns            *connmanager.ConnManager
+	collateralPolicy storagemarket.CollateralPolicy
+	pollingInterval  time.Duration
+	askTimeout       time.Duration
+	statusTimeout    time.Duration
+	commPCache       *pieceio.CommPCache
+	commP            pieceio.CommPFunc
+
+	replicationsLk          sync.Mutex
+	replications            map[storagemarket.ReplicationID]*replication
+	finishedReplications    []storagemarket.ReplicationID
+	nextReplicationID       storagemarket.ReplicationID
+	maxFinishedReplications int
+	// replicationCtx is cancelled when the client stops, abandoning the
+	// proposals of replications still in progress
+	replicationCtx   context.Context
+	stopReplications context.CancelFunc
+}
+
+// StorageClientOption allows custom configuration of a storage client
+type StorageClientOption func(c *Client)
+
+// ClientCollateralPolicy sets the policy a client uses to decide the collateral
+// in the deals it proposes, when the provider collateral is not given to
+// ProposeStorageDeal. By default it uses DefaultCollateralPolicy
+func ClientCollateralPolicy(policy storagemarket.CollateralPolicy) StorageClientOption {
+	return func(c *Client) {
+		c.collateralPolicy = policy
+	}
+}
+
+// DealPollingInterval sets the interval at which a client polls the provider
+// for the state of a deal it is waiting on
+func DealPollingInterval(interval time.Duration) StorageClientOption {
+	return func(c *Client) {
+		c.pollingInterval = interval
+	}
+}
+
+// AskTimeout sets how long a client waits for each provider to answer an ask
+// request when searching for storage offers
+func AskTimeout(timeout time.Duration) StorageClientOption {
+	return func(c *Client) {
+		c.askTimeout = timeout
+	}
+}
+
+// DealStatusTimeout sets how long a client waits for a provider to answer a
+// deal status request
+func DealStatusTimeout(timeout time.Duration) StorageClientOption {
+	return func(c *Client) {
+		c.statusTimeout = timeout
+	}
+}
+
+// ClientCommPCache makes a client cache the piece commitments of the data it
+// proposes deals for in the given datastore, and look them up there before
+// computing them. The datastore must not be shared with other data. Without
+// this option commitments are not cached
+func ClientCommPCache(ds datastore.Datastore) StorageClientOption {
+	return func(c *Client) {
+		c.commPCache = pieceio.NewCommPCache(ds)
+	}
+}
+
+// ClientCommPFunc sets the function a client computes piece commitments with,
+// e.g. commp.GeneratePieceCID to avoid calling into filecoin-ffi
+func ClientCommPFunc(commP pieceio.CommPFunc) StorageClientOption {
+	return func(c *Client) {
+		c.commP = commP
+	}
+}
+
+// MaxFinishedReplications sets how many finished replications a client keeps
+// the status of. Once there are more, the oldest are forgotten
+func MaxFinishedReplications(max int) StorageClientOption {
+	return func(c *Client) {
+		c.maxFinishedReplications = max
+	}
+}
+
+// DefaultCollateralPolicy is the default collateral policy. It asks the
+// provider for one attoFIL of collateral per byte of unpadded piece data,
+// moved into the range the ask accepts, and puts up no client collateral
+type DefaultCollateralPolicy struct{}
+
+// ProviderCollateral returns the unpadded piece size, within the bounds of the ask
+func (DefaultCollateralPolicy) ProviderCollateral(ask storagemarket.StorageAsk, pieceSize abi.PaddedPieceSize, duration abi.ChainEpoch) (abi.TokenAmount, error) {
+	collateral := abi.NewTokenAmount(int64(pieceSize.Unpadded()))
+	min, max := storagemarket.ProviderCollateralBounds(ask, pieceSize)
+	if collateral.LessThan(min) {
+		return min, nil
+	}
+	if !max.Nil() && collateral.GreaterThan(max) {
+		return max, nil
+	}
+	return collateral, nil
+}
+
+// ClientCollateral returns zero
+func (DefaultCollateralPolicy) ClientCollateral(ask storagemarket.StorageAsk, pieceSize abi.PaddedPieceSize, duration abi.ChainEpoch) (abi.TokenAmount, error) {
+	return big.Zero(), nil
+}
+
+// MinimumCollateralPolicy asks the provider for the lowest collateral its ask
+// accepts, and puts up no client collateral
+type MinimumCollateralPolicy struct{}
+
+// ProviderCollateral returns the minimum provider collateral for the ask
+func (MinimumCollateralPolicy) ProviderCollateral(ask storagemarket.StorageAsk, pieceSize abi.PaddedPieceSize, duration abi.ChainEpoch) (abi.TokenAmount, error) {
+	min, _ := storagemarket.ProviderCollateralBounds(ask, pieceSize)
+	return min, nil
+}
+
+// ClientCollateral returns zero
+func (MinimumCollateralPolicy) ClientCollateral(ask storagemarket.StorageAsk, pieceSize abi.PaddedPieceSize, duration abi.ChainEpoch) (abi.TokenAmount, error) {
+	return big.Zero(), nil
 }
 
 func NewClient(
@@ -59,20 +194,32 @@ func NewClient(
 	discovery *discovery.Local,
 	ds datastore.Batching,
 	scn storagemarket.StorageClientNode,
+	options ...StorageClientOption,
 ) (*Client, error) {
-	carIO := cario.NewCarIO()
-	pio := pieceio.NewPieceIO(carIO, bs)
-
 	c := &Client{
-		net:          net,
-		dataTransfer: dataTransfer,
-		bs:           bs,
-		pio:          pio,
-		discovery:    discovery,
-		node:         scn,
-		pubSub:       pubsub.New(clientDispatcher),
-		conns:        connmanager.NewConnManager(),
-	}
+		net:              net,
+		dataTransfer:     dataTransfer,
+		bs:               bs,
+		discovery:        discovery,
+		node:             scn,
+		pubSub:           pubsub.New(clientDispatcher),
+		commPPubSub:      pubsub.New(commPProgressDispatcher),
+		conns:            connmanager.NewConnManager(),
+		collateralPolicy: DefaultCollateralPolicy{},
+		pollingInterval:  DefaultPollingInterval,
+		askTimeout:       DefaultAskTimeout,
+		statusTimeout:    DefaultDealStatusTimeout,
+		replications:     make(map[storagemarket.ReplicationID]*replication),
+		commP:            pieceio.GeneratePieceCID,
+
+		maxFinishedReplications: DefaultMaxFinishedReplications,
+	}
+	c.replicationCtx, c.stopReplications = context.WithCancel(context.Background())
+
+	for _, option := range options {
+		option(c)
+	}
+	c.pio = pieceio.NewPieceIO(cario.NewCarIO(), bs, pieceio.WithCommPCache(c.commPCache), pieceio.WithCommPFunc(c.commP))
 
 	statemachines, err := fsm.New(ds, fsm.Parameters{
 		Environment:     &clientDealEnvironment{c},
@@ -93,10 +240,40 @@ func NewClient(
 	return c, nil
 }
 
+// Run restarts deals that did not complete before the client was last shut
+// down. Deals that can be resumed pick up where they left off, while deals that
+// were waiting on a stream to the provider are failed. Restarting stops early
+// if ctx is cancelled.
 func (c *Client) Run(ctx context.Context) {
+	var deals []storagemarket.ClientDeal
+	err := c.statemachines.List(&deals)
+	if err != nil {
+		log.Errorf("listing deals to restart: %s", err)
+		return
+	}
+
+	for _, deal := range deals {
+		if ctx.Err() != nil {
+			return
+		}
+		if containsState(deal.State, clientstates.ClientFinalityStates) {
+			continue
+		}
+
+		evt := storagemarket.ClientEventRestart
+		if containsState(deal.State, clientstates.ClientStreamStates) {
+			evt = storagemarket.ClientEventStreamLost
+		}
+
+		err = c.statemachines.Send(deal.ProposalCid, evt)
+		if err != nil {
+			log.Errorf("restarting deal %s: %s", deal.ProposalCid, err)
+		}
+	}
 }
 
 func (c *Client) Stop() {
+	c.stopReplications()
 	_ = c.statemachines.Stop(context.TODO())
 }
 
@@ -157,6 +334,19 @@ func (c *Client) GetAsk(ctx context.Context, info storagemarket.StorageProviderI
 	if err != nil {
 		return nil, xerrors.Errorf("failed to open stream to miner: %w", err)
 	}
+	defer s.Close()
+
+	// reading from the stream does not watch ctx, so reset the stream once
+	// ctx is done rather than wait on an unresponsive provider
+	readDone := make(chan struct{})
+	defer close(readDone)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = s.Reset()
+		case <-readDone:
+		}
+	}()
 
 	request := network.AskRequest{Miner: info.Address}
 	if err := s.WriteAskRequest(request); err != nil {
@@ -165,6 +355,9 @@ func (c *Client) GetAsk(ctx context.Context, info storagemarket.StorageProviderI
 
 	out, err := s.ReadAskResponse()
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, xerrors.Errorf("waiting for ask: %w", ctx.Err())
+		}
 		return nil, xerrors.Errorf("failed to read ask response: %w", err)
 	}
 
@@ -176,11 +369,15 @@ func (c *Client) GetAsk(ctx context.Context, info storagemarket.StorageProviderI
 		return nil, xerrors.Errorf("got back ask for wrong miner")
 	}
 
-	tok, _, err := c.node.GetChainHead(ctx)
+	tok, height, err := c.node.GetChainHead(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	if out.Ask.Ask.Expiry <= height {
+		return nil, xerrors.Errorf("ask expired at epoch %d", out.Ask.Ask.Expiry)
+	}
+
 	isValid, err := c.node.ValidateAskSignature(ctx, out.Ask, tok)
 	if err != nil {
 		return nil, err
@@ -193,6 +390,228 @@ func (c *Client) GetAsk(ctx context.Context, info storagemarket.StorageProviderI
 	return out.Ask, nil
 }
 
+// FindStorageOffers queries every storage provider for its ask in parallel, and
+// returns up to limit offers that satisfy the criteria, cheapest first. A limit
+// of zero returns every matching offer. Providers that do not answer within the
+// ask timeout, or that send back an invalid ask, are skipped
+func (c *Client) FindStorageOffers(ctx context.Context, criteria storagemarket.AskCriteria, limit uint) ([]*storagemarket.StorageOffer, error) {
+	tok, height, err := c.node.GetChainHead(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	providers, err := c.node.ListStorageProviders(ctx, tok)
+	if err != nil {
+		return nil, xerrors.Errorf("listing storage providers: %w", err)
+	}
+
+	offers := make(chan *storagemarket.StorageOffer, len(providers))
+	var wg sync.WaitGroup
+	for _, p := range providers {
+		if criteria.SectorSize != 0 && p.SectorSize != criteria.SectorSize {
+			continue
+		}
+
+		wg.Add(1)
+		go func(info storagemarket.StorageProviderInfo) {
+			defer wg.Done()
+
+			ask, err := c.getAskWithTimeout(ctx, info)
+			if err != nil {
+				log.Debugf("getting ask from provider %s: %s", info.Address, err)
+				return
+			}
+
+			if offer, ok := makeStorageOffer(info, ask, criteria, height); ok {
+				offers <- offer
+			}
+		}(*p)
+	}
+	wg.Wait()
+	close(offers)
+
+	var out []*storagemarket.StorageOffer
+	for offer := range offers {
+		out = append(out, offer)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].TotalPrice.Equals(out[j].TotalPrice) {
+			return out[i].TotalPrice.LessThan(out[j].TotalPrice)
+		}
+		return out[i].Provider.Address.String() < out[j].Provider.Address.String()
+	})
+
+	if limit > 0 && uint(len(out)) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// getAskWithTimeout gets a provider's ask, giving up if the provider does not
+// answer within the client's ask timeout
+func (c *Client) getAskWithTimeout(ctx context.Context, info storagemarket.StorageProviderInfo) (*storagemarket.SignedStorageAsk, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.askTimeout)
+	defer cancel()
+	return c.GetAsk(ctx, info)
+}
+
+// makeStorageOffer prices a deal for the criteria against the provider's ask,
+// returning false if the ask does not satisfy the criteria
+func makeStorageOffer(info storagemarket.StorageProviderInfo, ask *storagemarket.SignedStorageAsk, criteria storagemarket.AskCriteria, height abi.ChainEpoch) (*storagemarket.StorageOffer, bool) {
+	if ask.Ask.Expiry <= height {
+		return nil, false
+	}
+	if criteria.MinAskExpiry != 0 && ask.Ask.Expiry < criteria.MinAskExpiry {
+		return nil, false
+	}
+	if criteria.PieceSize < ask.Ask.MinPieceSize || criteria.PieceSize > ask.Ask.MaxPieceSize {
+		return nil, false
+	}
+	if !criteria.MaxPrice.Nil() && storagemarket.AskPrice(*ask.Ask, criteria.PieceSize, criteria.Duration).GreaterThan(criteria.MaxPrice) {
+		return nil, false
+	}
+
+	pricePerEpoch := storagemarket.DealPricePerEpoch(*ask.Ask, criteria.PieceSize, criteria.Duration)
+	return &storagemarket.StorageOffer{
+		Provider:      info,
+		Ask:           ask,
+		PricePerEpoch: pricePerEpoch,
+		TotalPrice:    big.Mul(pricePerEpoch, abi.NewTokenAmount(int64(criteria.Duration))),
+	}, true
+}
+
+// GetProviderDealState queries the provider of a deal for the provider's view of the deal
+func (c *Client) GetProviderDealState(ctx context.Context, proposalCid cid.Cid) (*storagemarket.ProviderDealState, error) {
+	var deal storagemarket.ClientDeal
+	err := c.statemachines.Get(proposalCid).Get(&deal)
+	if err != nil {
+		return nil, xerrors.Errorf("could not get client deal state: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.statusTimeout)
+	defer cancel()
+
+	s, err := c.net.NewDealStatusStream(deal.Miner)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to open stream to miner: %w", err)
+	}
+	defer s.Close()
+
+	// reading from the stream does not watch ctx, so reset the stream once
+	// ctx is done rather than wait on an unresponsive provider
+	readDone := make(chan struct{})
+	defer close(readDone)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = s.Reset()
+		case <-readDone:
+		}
+	}()
+
+	tok, epoch, err := c.node.GetChainHead(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	request := network.DealStatusRequest{Proposal: proposalCid, Epoch: epoch}
+	msg, err := request.SigningBytes()
+	if err != nil {
+		return nil, xerrors.Errorf("serializing deal status request: %w", err)
+	}
+	sig, err := c.node.SignBytes(ctx, deal.Proposal.Client, msg)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to sign deal status request: %w", err)
+	}
+	request.Signature = *sig
+
+	if err := s.WriteDealStatusRequest(request); err != nil {
+		return nil, xerrors.Errorf("failed to send deal status request: %w", err)
+	}
+
+	resp, err := s.ReadDealStatusResponse()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, xerrors.Errorf("waiting for deal status: %w", ctx.Err())
+		}
+		return nil, xerrors.Errorf("failed to read deal status response: %w", err)
+	}
+
+	if err := clientutils.VerifyDealStatusResponse(ctx, resp, deal.MinerWorker, tok, c.node.VerifySignature); err != nil {
+		return nil, xerrors.Errorf("verifying deal status response: %w", err)
+	}
+
+	return &resp.DealState, nil
+}
+
+// CancelDeal cancels a deal the provider has not yet accepted. It stops any
+// transfer of the deal data, closes the deal stream and tells the provider
+// the deal is cancelled
+func (c *Client) CancelDeal(ctx context.Context, proposalCid cid.Cid) error {
+	var deal storagemarket.ClientDeal
+	if err := c.statemachines.Get(proposalCid).Get(&deal); err != nil {
+		return xerrors.Errorf("could not get client deal state: %w", err)
+	}
+
+	// move the deal out of its current state first, so that the errors from
+	// interrupting the transfer and the stream below are ignored. The deal
+	// state machine only applies the event while the deal can be cancelled
+	if err := c.statemachines.SendSync(ctx, proposalCid, storagemarket.ClientEventCancelled); err != nil {
+		return xerrors.Errorf("cannot cancel deal %s in state %s: %w", proposalCid, storagemarket.DealStates[deal.State], err)
+	}
+
+	for _, chid := range dtutils.DealChannels(c.dataTransfer.InProgressChannels(), proposalCid) {
+		c.dataTransfer.CloseDataTransferChannel(chid)
+	}
+
+	if s, err := c.conns.DealStream(proposalCid); err == nil {
+		s.UntagProtectedConnection(proposalCid.String())
+		if err := c.conns.Disconnect(proposalCid); err != nil {
+			log.Warnf("closing deal stream for deal %s: %s", proposalCid, err)
+		}
+	}
+
+	// the provider has not heard of the deal until the proposal is sent
+	if deal.State == storagemarket.StorageDealEnsureClientFunds || deal.State == storagemarket.StorageDealClientFunding {
+		return nil
+	}
+
+	if err := c.notifyCancellation(ctx, deal); err != nil {
+		return xerrors.Errorf("deal %s cancelled, but notifying provider failed: %w", proposalCid, err)
+	}
+	return nil
+}
+
+func (c *Client) notifyCancellation(ctx context.Context, deal storagemarket.ClientDeal) error {
+	s, err := c.net.NewDealCancellationStream(deal.Miner)
+	if err != nil {
+		return xerrors.Errorf("failed to open stream to miner: %w", err)
+	}
+	defer s.Close()
+
+	_, epoch, err := c.node.GetChainHead(ctx)
+	if err != nil {
+		return err
+	}
+
+	cancellation := network.DealCancellation{Proposal: deal.ProposalCid, Epoch: epoch}
+	msg, err := cancellation.SigningBytes()
+	if err != nil {
+		return xerrors.Errorf("serializing deal cancellation: %w", err)
+	}
+	sig, err := c.node.SignBytes(ctx, deal.Proposal.Client, msg)
+	if err != nil {
+		return xerrors.Errorf("failed to sign deal cancellation: %w", err)
+	}
+	cancellation.Signature = *sig
+
+	if err := s.WriteDealCancellation(cancellation); err != nil {
+		return xerrors.Errorf("failed to send deal cancellation: %w", err)
+	}
+	return nil
+}
+
 func (c *Client) ProposeStorageDeal(
 	ctx context.Context,
 	addr address.Address,
@@ -204,7 +623,7 @@ func (c *Client) ProposeStorageDeal(
 	collateral abi.TokenAmount,
 	rt abi.RegisteredProof,
 ) (*storagemarket.ProposeStorageDealResult, error) {
-	commP, pieceSize, err := clientutils.CommP(ctx, c.pio, rt, data)
+	commP, pieceSize, err := clientutils.CommP(ctx, c.pio, rt, data, c.commPProgress(data.Root, info.PeerID))
 	if err != nil {
 		return nil, xerrors.Errorf("computing commP failed: %w", err)
 	}
@@ -213,6 +632,64 @@ func (c *Client) ProposeStorageDeal(
 		return nil, fmt.Errorf("cannot propose a deal whose piece size (%d) is greater than sector size (%d)", pieceSize.Padded(), info.SectorSize)
 	}
 
+	// the provider's ask is only needed to decide the collateral when the
+	// caller has not, and a given provider collateral is left for the
+	// provider to check
+	providerCollateral, clientCollateral := collateral, big.Zero()
+	if collateral.Nil() || collateral.IsZero() {
+		ask, err := c.GetAsk(ctx, *info)
+		if err != nil {
+			return nil, xerrors.Errorf("getting provider ask: %w", err)
+		}
+		providerCollateral, clientCollateral, err = c.dealCollateral(*ask.Ask, pieceSize.Padded(), endEpoch-startEpoch, collateral)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c.proposeDeal(ctx, addr, info, data, commP, pieceSize, startEpoch, endEpoch, price, providerCollateral, clientCollateral)
+}
+
+// dealCollateral returns the provider and client collateral to propose for a
+// deal with the given ask. An explicitly requested provider collateral takes
+// precedence over the collateral policy, but must still be accepted by the ask
+func (c *Client) dealCollateral(ask storagemarket.StorageAsk, pieceSize abi.PaddedPieceSize, duration abi.ChainEpoch, collateral abi.TokenAmount) (abi.TokenAmount, abi.TokenAmount, error) {
+	var err error
+	providerCollateral := collateral
+	if providerCollateral.Nil() || providerCollateral.IsZero() {
+		providerCollateral, err = c.collateralPolicy.ProviderCollateral(ask, pieceSize, duration)
+		if err != nil {
+			return abi.TokenAmount{}, abi.TokenAmount{}, xerrors.Errorf("computing provider collateral: %w", err)
+		}
+	}
+
+	minCollateral, maxCollateral := storagemarket.ProviderCollateralBounds(ask, pieceSize)
+	if providerCollateral.LessThan(minCollateral) || (!maxCollateral.Nil() && providerCollateral.GreaterThan(maxCollateral)) {
+		return abi.TokenAmount{}, abi.TokenAmount{}, xerrors.Errorf("provider collateral %s is outside the range accepted by the provider", providerCollateral)
+	}
+
+	clientCollateral, err := c.collateralPolicy.ClientCollateral(ask, pieceSize, duration)
+	if err != nil {
+		return abi.TokenAmount{}, abi.TokenAmount{}, xerrors.Errorf("computing client collateral: %w", err)
+	}
+	return providerCollateral, clientCollateral, nil
+}
+
+// proposeDeal signs a proposal for a piece whose commP is already known, and
+// starts tracking the deal
+func (c *Client) proposeDeal(
+	ctx context.Context,
+	addr address.Address,
+	info *storagemarket.StorageProviderInfo,
+	data *storagemarket.DataRef,
+	commP cid.Cid,
+	pieceSize abi.UnpaddedPieceSize,
+	startEpoch abi.ChainEpoch,
+	endEpoch abi.ChainEpoch,
+	price abi.TokenAmount,
+	providerCollateral abi.TokenAmount,
+	clientCollateral abi.TokenAmount,
+) (*storagemarket.ProposeStorageDealResult, error) {
 	dealProposal := market.DealProposal{
 		PieceCID:             commP,
 		PieceSize:            pieceSize.Padded(),
@@ -221,8 +698,8 @@ func (c *Client) ProposeStorageDeal(
 		StartEpoch:           startEpoch,
 		EndEpoch:             endEpoch,
 		StoragePricePerEpoch: price,
-		ProviderCollateral:   abi.NewTokenAmount(int64(pieceSize)), // TODO: real calc
-		ClientCollateral:     big.Zero(),
+		ProviderCollateral:   providerCollateral,
+		ClientCollateral:     clientCollateral,
 	}
 
 	clientDealProposal, err := c.node.SignProposal(ctx, addr, dealProposal)
@@ -317,6 +794,50 @@ func (c *Client) dispatch(eventName fsm.EventName, deal fsm.StateType) {
 	}
 }
 
+// SubscribeToCommPProgress listens for progress computing the commP of data
+// for deals that are being proposed
+func (c *Client) SubscribeToCommPProgress(subscriber storagemarket.CommPProgressSubscriber) shared.Unsubscribe {
+	return shared.Unsubscribe(c.commPPubSub.Subscribe(subscriber))
+}
+
+// commPProgress returns a progress function that publishes the progress
+// computing the commP of a payload, at most once for each percent processed
+func (c *Client) commPProgress(payloadCid cid.Cid, provider peer.ID) pieceio.ProgressFunc {
+	lastPercent := uint64(0)
+	return func(processed uint64, total uint64) {
+		percent := uint64(100)
+		if total > 0 {
+			percent = processed * 100 / total
+		}
+		if percent == lastPercent && processed < total {
+			return
+		}
+		lastPercent = percent
+		progress := storagemarket.CommPProgress{
+			PayloadCid: payloadCid,
+			Provider:   provider,
+			Processed:  processed,
+			Total:      total,
+		}
+		if err := c.commPPubSub.Publish(progress); err != nil {
+			log.Errorf("failed to publish commP progress for %s: %s", payloadCid, err)
+		}
+	}
+}
+
+func commPProgressDispatcher(evt pubsub.Event, fn pubsub.SubscriberFn) error {
+	progress, ok := evt.(storagemarket.CommPProgress)
+	if !ok {
+		return xerrors.New("wrong type of event")
+	}
+	cb, ok := fn.(storagemarket.CommPProgressSubscriber)
+	if !ok {
+		return xerrors.New("wrong type of event")
+	}
+	cb(progress)
+	return nil
+}
+
 type internalClientEvent struct {
 	evt  storagemarket.ClientEvent
 	deal storagemarket.ClientDeal
@@ -386,6 +907,14 @@ func (c *clientDealEnvironment) CloseStream(proposalCid cid.Cid) error {
 	return c.c.conns.Disconnect(proposalCid)
 }
 
+func (c *clientDealEnvironment) GetProviderDealState(ctx context.Context, proposalCid cid.Cid) (*storagemarket.ProviderDealState, error) {
+	return c.c.GetProviderDealState(ctx, proposalCid)
+}
+
+func (c *clientDealEnvironment) PollingInterval() time.Duration {
+	return c.c.pollingInterval
+}
+
 func (c *clientDealEnvironment) StartDataTransfer(ctx context.Context, to peer.ID, voucher datatransfer.Voucher, baseCid cid.Cid, selector ipld.Node) error {
 	_, err := c.c.dataTransfer.OpenPushDataChannel(ctx, to, voucher, baseCid, selector)
 	return err
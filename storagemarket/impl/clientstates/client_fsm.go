@@ -65,23 +65,39 @@ var ClientEvents = fsm.Events{
 	fsm.Event(storagemarket.ClientEventDataTransferComplete).
 		FromMany(storagemarket.StorageDealTransferring, storagemarket.StorageDealWaitingForDataRequest).To(storagemarket.StorageDealValidating),
 	fsm.Event(storagemarket.ClientEventResponseDealDidNotMatch).
-		From(storagemarket.StorageDealValidating).To(storagemarket.StorageDealFailing).
+		FromMany(storagemarket.StorageDealValidating, storagemarket.StorageDealCheckForAcceptance).To(storagemarket.StorageDealFailing).
 		Action(func(deal *storagemarket.ClientDeal, responseCid cid.Cid, proposalCid cid.Cid) error {
 			deal.Message = xerrors.Errorf("miner responded to a wrong proposal: %s != %s", responseCid, proposalCid).Error()
 			return nil
 		}),
 	fsm.Event(storagemarket.ClientEventDealRejected).
-		From(storagemarket.StorageDealValidating).To(storagemarket.StorageDealFailing).
+		FromMany(storagemarket.StorageDealWaitingForDataRequest, storagemarket.StorageDealValidating, storagemarket.StorageDealCheckForAcceptance).To(storagemarket.StorageDealFailing).
 		Action(func(deal *storagemarket.ClientDeal, state storagemarket.StorageDealStatus, reason string) error {
 			deal.Message = xerrors.Errorf("deal failed: (State=%d) %s", state, reason).Error()
 			return nil
 		}),
 	fsm.Event(storagemarket.ClientEventDealAccepted).
-		From(storagemarket.StorageDealValidating).To(storagemarket.StorageDealProposalAccepted).
+		FromMany(storagemarket.StorageDealValidating, storagemarket.StorageDealCheckForAcceptance).To(storagemarket.StorageDealProposalAccepted).
 		Action(func(deal *storagemarket.ClientDeal, publishMessage *cid.Cid) error {
 			deal.PublishMessage = publishMessage
 			return nil
 		}),
+	fsm.Event(storagemarket.ClientEventCheckForAcceptance).
+		From(storagemarket.StorageDealWaitingForDataRequest).To(storagemarket.StorageDealCheckForAcceptance).
+		Action(func(deal *storagemarket.ClientDeal) error {
+			deal.ConnectionClosed = true
+			return nil
+		}),
+	fsm.Event(storagemarket.ClientEventWaitForDealState).
+		From(storagemarket.StorageDealCheckForAcceptance).ToNoChange(),
+	fsm.Event(storagemarket.ClientEventCancelled).
+		FromMany(ClientCancellableStates...).To(storagemarket.StorageDealFailing).
+		Action(func(deal *storagemarket.ClientDeal) error {
+			// the client closes the deal stream itself when it cancels
+			deal.ConnectionClosed = true
+			deal.Message = "deal cancelled by client"
+			return nil
+		}),
 	fsm.Event(storagemarket.ClientEventStreamCloseError).
 		FromAny().To(storagemarket.StorageDealError).
 		Action(func(deal *storagemarket.ClientDeal, err error) error {
@@ -110,6 +126,55 @@ var ClientEvents = fsm.Events{
 		From(storagemarket.StorageDealSealing).To(storagemarket.StorageDealActive),
 	fsm.Event(storagemarket.ClientEventFailed).
 		From(storagemarket.StorageDealFailing).To(storagemarket.StorageDealError),
+	fsm.Event(storagemarket.ClientEventRestart).
+		From(storagemarket.StorageDealUnknown).To(storagemarket.StorageDealEnsureClientFunds).
+		From(storagemarket.StorageDealValidating).To(storagemarket.StorageDealCheckForAcceptance).
+		FromMany(storagemarket.StorageDealEnsureClientFunds, storagemarket.StorageDealClientFunding,
+			storagemarket.StorageDealFundsEnsured, storagemarket.StorageDealProposalAccepted,
+			storagemarket.StorageDealSealing, storagemarket.StorageDealFailing,
+			storagemarket.StorageDealCheckForAcceptance).ToNoChange().
+		Action(func(deal *storagemarket.ClientDeal) error {
+			// any stream opened before the restart is gone
+			if deal.State == storagemarket.StorageDealFailing || deal.State == storagemarket.StorageDealValidating {
+				deal.ConnectionClosed = true
+			}
+			return nil
+		}),
+	fsm.Event(storagemarket.ClientEventStreamLost).
+		FromMany(ClientStreamStates...).To(storagemarket.StorageDealFailing).
+		Action(func(deal *storagemarket.ClientDeal) error {
+			deal.ConnectionClosed = true
+			deal.Message = "client restarted while waiting on the provider stream"
+			return nil
+		}),
+}
+
+// ClientFinalityStates are the states that terminate deal processing for a deal.
+// When a client restarts, it restarts only deals that are not in a finality state.
+var ClientFinalityStates = []fsm.StateKey{
+	storagemarket.StorageDealActive,
+	storagemarket.StorageDealError,
+}
+
+// ClientStreamStates are the states in which a deal depends on an open stream
+// to the provider. The stream does not survive a restart, so deals in these
+// states cannot be resumed. A deal waiting on the provider to accept it is not
+// among them, as the client can poll the provider for the deal state instead.
+var ClientStreamStates = []fsm.StateKey{
+	storagemarket.StorageDealWaitingForDataRequest,
+	storagemarket.StorageDealTransferring,
+}
+
+// ClientCancellableStates are the states from which a client can cancel a
+// deal, i.e. every state before the provider accepts the deal
+var ClientCancellableStates = []fsm.StateKey{
+	storagemarket.StorageDealEnsureClientFunds,
+	storagemarket.StorageDealClientFunding,
+	storagemarket.StorageDealFundsEnsured,
+	storagemarket.StorageDealWaitingForDataRequest,
+	storagemarket.StorageDealTransferring,
+	storagemarket.StorageDealValidating,
+	storagemarket.StorageDealCheckForAcceptance,
 }
 
 // ClientStateEntryFuncs are the handlers for different states in a storage client
@@ -119,6 +184,7 @@ var ClientStateEntryFuncs = fsm.StateEntryFuncs{
 	storagemarket.StorageDealFundsEnsured:          ProposeDeal,
 	storagemarket.StorageDealWaitingForDataRequest: WaitingForDataRequest,
 	storagemarket.StorageDealValidating:            VerifyDealResponse,
+	storagemarket.StorageDealCheckForAcceptance:    CheckForDealAcceptance,
 	storagemarket.StorageDealProposalAccepted:      ValidateDealPublished,
 	storagemarket.StorageDealSealing:               VerifyDealActivated,
 	storagemarket.StorageDealFailing:               FailDeal,
@@ -2,6 +2,7 @@ package clientstates
 
 import (
 	"context"
+	"time"
 
 	datatransfer "github.com/filecoin-project/go-data-transfer"
 	"github.com/filecoin-project/go-statemachine/fsm"
@@ -29,6 +30,8 @@ type ClientDealEnvironment interface {
 	TagConnection(proposalCid cid.Cid) error
 	ReadDealResponse(proposalCid cid.Cid) (network.SignedResponse, error)
 	CloseStream(proposalCid cid.Cid) error
+	GetProviderDealState(ctx context.Context, proposalCid cid.Cid) (*storagemarket.ProviderDealState, error)
+	PollingInterval() time.Duration
 	StartDataTransfer(ctx context.Context, to peer.ID, voucher datatransfer.Voucher, baseCid cid.Cid, selector ipld.Node) error
 }
 
@@ -105,15 +108,32 @@ func WaitingForDataRequest(ctx fsm.Context, environment ClientDealEnvironment, d
 		return ctx.Trigger(storagemarket.ClientEventResponseVerificationFailed)
 	}
 
+	// the provider rejected the deal before asking for its data
+	if resp.Response.State == storagemarket.StorageDealFailing {
+		return ctx.Trigger(storagemarket.ClientEventDealRejected, resp.Response.State, resp.Response.Message)
+	}
+
 	if resp.Response.State != storagemarket.StorageDealWaitingForData {
 		return ctx.Trigger(storagemarket.ClientEventUnexpectedDealState, resp.Response.State)
 	}
 
-	if deal.DataRef.TransferType == storagemarket.TTManual {
-		log.Infof("manual data transfer for deal %s", deal.ProposalCid)
+	if deal.DataRef.TransferType == storagemarket.TTManual || deal.DataRef.TransferType == storagemarket.TTHTTP {
+		log.Infof("%s data transfer for deal %s", deal.DataRef.TransferType, deal.ProposalCid)
+
+		// the provider may take a long time to import or download the data,
+		// so poll it for the deal state rather than waiting on the stream
+		if err := environment.CloseStream(deal.ProposalCid); err != nil {
+			return ctx.Trigger(storagemarket.ClientEventStreamCloseError, err)
+		}
+
+		return ctx.Trigger(storagemarket.ClientEventCheckForAcceptance)
+	}
 
-		// Temporary, we will move to a query/response protocol to check on deal status
-		return ctx.Trigger(storagemarket.ClientEventDataTransferComplete)
+	// the provider opens a channel to pull the data itself, and the data
+	// transfer events for that channel move the deal on
+	if deal.DataRef.TransferType == storagemarket.TTProviderPull {
+		log.Infof("waiting for provider to pull data for deal %s", deal.ProposalCid)
+		return ctx.Trigger(storagemarket.ClientEventDataTransferInitiated)
 	}
 
 	log.Infof("sending data for a deal %s", deal.ProposalCid)
@@ -166,6 +186,57 @@ func VerifyDealResponse(ctx fsm.Context, environment ClientDealEnvironment, deal
 	return ctx.Trigger(storagemarket.ClientEventDealAccepted, resp.Response.PublishMessage)
 }
 
+// CheckForDealAcceptance queries the provider for the state of the deal, until
+// the provider accepts or rejects it
+func CheckForDealAcceptance(ctx fsm.Context, environment ClientDealEnvironment, deal storagemarket.ClientDeal) error {
+	dealState, err := environment.GetProviderDealState(ctx.Context(), deal.ProposalCid)
+	if err != nil {
+		log.Warnf("querying provider for state of deal %s: %s", deal.ProposalCid, err)
+		return waitForDealState(ctx, environment)
+	}
+
+	if dealState.ProposalCid != nil && *dealState.ProposalCid != deal.ProposalCid {
+		return ctx.Trigger(storagemarket.ClientEventResponseDealDidNotMatch, *dealState.ProposalCid, deal.ProposalCid)
+	}
+
+	if isFailed(dealState.State) {
+		return ctx.Trigger(storagemarket.ClientEventDealRejected, dealState.State, dealState.Message)
+	}
+
+	if isAccepted(dealState.State) && dealState.PublishCid != nil {
+		return ctx.Trigger(storagemarket.ClientEventDealAccepted, dealState.PublishCid)
+	}
+
+	return waitForDealState(ctx, environment)
+}
+
+// waitForDealState queries the provider again once the polling interval elapses
+func waitForDealState(ctx fsm.Context, environment ClientDealEnvironment) error {
+	t := time.NewTimer(environment.PollingInterval())
+	go func() {
+		select {
+		case <-t.C:
+			_ = ctx.Trigger(storagemarket.ClientEventWaitForDealState)
+		case <-ctx.Context().Done():
+			t.Stop()
+		}
+	}()
+	return nil
+}
+
+func isAccepted(status storagemarket.StorageDealStatus) bool {
+	return status == storagemarket.StorageDealPublishing ||
+		status == storagemarket.StorageDealStaged ||
+		status == storagemarket.StorageDealSealing ||
+		status == storagemarket.StorageDealActive ||
+		status == storagemarket.StorageDealCompleted
+}
+
+func isFailed(status storagemarket.StorageDealStatus) bool {
+	return status == storagemarket.StorageDealFailing ||
+		status == storagemarket.StorageDealError
+}
+
 // ValidateDealPublished confirms with the chain that a deal was published
 func ValidateDealPublished(ctx fsm.Context, environment ClientDealEnvironment, deal storagemarket.ClientDeal) error {
 
@@ -5,10 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	cborutil "github.com/filecoin-project/go-cbor-util"
 	datatransfer "github.com/filecoin-project/go-data-transfer"
+	"github.com/filecoin-project/go-statemachine"
 	"github.com/filecoin-project/go-statemachine/fsm"
 	fsmtest "github.com/filecoin-project/go-statemachine/fsm/testutil"
 	"github.com/filecoin-project/specs-actors/actors/abi"
@@ -139,6 +141,21 @@ func TestWaitingForDataRequest(t *testing.T) {
 			},
 		})
 	})
+	t.Run("provider rejects deal", func(t *testing.T) {
+		runAndInspect(t, storagemarket.StorageDealWaitingForDataRequest, clientstates.WaitingForDataRequest, testCase{
+			envParams: envParams{
+				dealStream: testResponseStream(t, responseParams{
+					proposal: clientDealProposal,
+					state:    storagemarket.StorageDealFailing,
+					message:  "deal rejected: transfer type: transfer type graphsync is not accepted",
+				}),
+			},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				assert.Equal(t, "deal failed: (State=7) deal rejected: transfer type: transfer type graphsync is not accepted", deal.Message)
+			},
+		})
+	})
 	t.Run("read response fails", func(t *testing.T) {
 		runAndInspect(t, storagemarket.StorageDealWaitingForDataRequest, clientstates.WaitingForDataRequest, testCase{
 			envParams: envParams{
@@ -154,7 +171,7 @@ func TestWaitingForDataRequest(t *testing.T) {
 			},
 		})
 	})
-	t.Run("waits for another response with manual transfers", func(t *testing.T) {
+	t.Run("polls for the deal state with manual transfers", func(t *testing.T) {
 		runAndInspect(t, storagemarket.StorageDealWaitingForDataRequest, clientstates.WaitingForDataRequest, testCase{
 			envParams: envParams{
 				dealStream: testResponseStream(t, responseParams{
@@ -164,7 +181,59 @@ func TestWaitingForDataRequest(t *testing.T) {
 				manualTransfer: true,
 			},
 			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
-				tut.AssertDealState(t, storagemarket.StorageDealValidating, deal.State)
+				tut.AssertDealState(t, storagemarket.StorageDealCheckForAcceptance, deal.State)
+				assert.True(t, deal.ConnectionClosed)
+				assert.Len(t, env.closeStreamCalls, 1)
+				assert.Len(t, env.startDataTransferCalls, 0)
+			},
+		})
+	})
+	t.Run("polls for the deal state with HTTP transfers", func(t *testing.T) {
+		runAndInspect(t, storagemarket.StorageDealWaitingForDataRequest, clientstates.WaitingForDataRequest, testCase{
+			envParams: envParams{
+				dealStream: testResponseStream(t, responseParams{
+					proposal: clientDealProposal,
+					state:    storagemarket.StorageDealWaitingForData,
+				}),
+				transferType: storagemarket.TTHTTP,
+			},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealCheckForAcceptance, deal.State)
+				assert.True(t, deal.ConnectionClosed)
+				assert.Len(t, env.closeStreamCalls, 1)
+				assert.Len(t, env.startDataTransferCalls, 0)
+			},
+		})
+	})
+	t.Run("waits for the provider to pull data", func(t *testing.T) {
+		runAndInspect(t, storagemarket.StorageDealWaitingForDataRequest, clientstates.WaitingForDataRequest, testCase{
+			envParams: envParams{
+				dealStream: testResponseStream(t, responseParams{
+					proposal: clientDealProposal,
+					state:    storagemarket.StorageDealWaitingForData,
+				}),
+				transferType: storagemarket.TTProviderPull,
+			},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealTransferring, deal.State)
+				assert.False(t, deal.ConnectionClosed)
+				assert.Len(t, env.startDataTransferCalls, 0)
+			},
+		})
+	})
+	t.Run("closing the stream fails with manual transfers", func(t *testing.T) {
+		runAndInspect(t, storagemarket.StorageDealWaitingForDataRequest, clientstates.WaitingForDataRequest, testCase{
+			envParams: envParams{
+				dealStream: testResponseStream(t, responseParams{
+					proposal: clientDealProposal,
+					state:    storagemarket.StorageDealWaitingForData,
+				}),
+				closeStreamErr: errors.New("something went wrong"),
+				manualTransfer: true,
+			},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealError, deal.State)
+				assert.Equal(t, "error attempting to close stream: something went wrong", deal.Message)
 			},
 		})
 	})
@@ -254,6 +323,69 @@ func TestVerifyDealResponse(t *testing.T) {
 	})
 }
 
+func TestCheckForDealAcceptance(t *testing.T) {
+	t.Run("succeeds when the provider has published the deal", func(t *testing.T) {
+		publishCid := tut.GenerateCids(1)[0]
+		runAndInspect(t, storagemarket.StorageDealCheckForAcceptance, clientstates.CheckForDealAcceptance, testCase{
+			envParams: envParams{
+				providerDealState: makeProviderDealState(t, storagemarket.StorageDealPublishing, &publishCid, ""),
+			},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealProposalAccepted, deal.State)
+				assert.Equal(t, &publishCid, deal.PublishMessage)
+			},
+		})
+	})
+	t.Run("deal rejected", func(t *testing.T) {
+		runAndInspect(t, storagemarket.StorageDealCheckForAcceptance, clientstates.CheckForDealAcceptance, testCase{
+			envParams: envParams{
+				providerDealState: makeProviderDealState(t, storagemarket.StorageDealFailing, nil, "because reasons"),
+			},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				expErr := fmt.Sprintf("deal failed: (State=%d) because reasons", storagemarket.StorageDealFailing)
+
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				assert.Equal(t, expErr, deal.Message)
+			},
+		})
+	})
+	t.Run("incorrect proposal cid", func(t *testing.T) {
+		dealState := makeProviderDealState(t, storagemarket.StorageDealSealing, nil, "")
+		dealState.ProposalCid = &tut.GenerateCids(1)[0]
+		runAndInspect(t, storagemarket.StorageDealCheckForAcceptance, clientstates.CheckForDealAcceptance, testCase{
+			envParams: envParams{providerDealState: dealState},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				assert.Regexp(t, "^miner responded to a wrong proposal:", deal.Message)
+			},
+		})
+	})
+	t.Run("waits while the provider has not decided", func(t *testing.T) {
+		runAndInspect(t, storagemarket.StorageDealCheckForAcceptance, clientstates.CheckForDealAcceptance, testCase{
+			envParams: envParams{
+				providerDealState: makeProviderDealState(t, storagemarket.StorageDealWaitingForData, nil, ""),
+				pollingInterval:   time.Hour,
+			},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealCheckForAcceptance, deal.State)
+				assert.Equal(t, 1, env.getProviderDealStateCalls)
+			},
+		})
+	})
+	t.Run("waits when querying the provider fails", func(t *testing.T) {
+		runAndInspect(t, storagemarket.StorageDealCheckForAcceptance, clientstates.CheckForDealAcceptance, testCase{
+			envParams: envParams{
+				getProviderDealStateError: errors.New("provider offline"),
+				pollingInterval:           time.Hour,
+			},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealCheckForAcceptance, deal.State)
+				assert.Equal(t, "", deal.Message)
+			},
+		})
+	})
+}
+
 func TestValidateDealPublished(t *testing.T) {
 	t.Run("succeeds", func(t *testing.T) {
 		runAndInspect(t, storagemarket.StorageDealProposalAccepted, clientstates.ValidateDealPublished, testCase{
@@ -336,11 +468,97 @@ func TestFailDeal(t *testing.T) {
 	})
 }
 
+func TestRestart(t *testing.T) {
+	ctx := context.Background()
+	eventProcessor, err := fsm.NewEventProcessor(storagemarket.ClientDeal{}, "State", clientstates.ClientEvents)
+	assert.NoError(t, err)
+
+	restart := func(t *testing.T, initialState storagemarket.StorageDealStatus, evt storagemarket.ClientEvent) storagemarket.ClientDeal {
+		dealState, err := tut.MakeTestClientDeal(initialState, clientDealProposal, false)
+		assert.NoError(t, err)
+		fsmCtx := fsmtest.NewTestContext(ctx, eventProcessor)
+		err = fsmCtx.Trigger(evt)
+		assert.NoError(t, err)
+		fsmCtx.ReplayEvents(t, dealState)
+		return *dealState
+	}
+
+	t.Run("resumes deals that do not depend on the provider stream", func(t *testing.T) {
+		for _, state := range []storagemarket.StorageDealStatus{
+			storagemarket.StorageDealEnsureClientFunds,
+			storagemarket.StorageDealClientFunding,
+			storagemarket.StorageDealFundsEnsured,
+			storagemarket.StorageDealProposalAccepted,
+			storagemarket.StorageDealSealing,
+		} {
+			deal := restart(t, state, storagemarket.ClientEventRestart)
+			tut.AssertDealState(t, state, deal.State)
+			assert.Equal(t, "", deal.Message)
+		}
+	})
+	t.Run("opens deals that were never opened", func(t *testing.T) {
+		deal := restart(t, storagemarket.StorageDealUnknown, storagemarket.ClientEventRestart)
+		tut.AssertDealState(t, storagemarket.StorageDealEnsureClientFunds, deal.State)
+	})
+	t.Run("finishes failing deals, marking the stream lost in the restart as closed", func(t *testing.T) {
+		deal := restart(t, storagemarket.StorageDealFailing, storagemarket.ClientEventRestart)
+		tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+		assert.True(t, deal.ConnectionClosed)
+	})
+	t.Run("polls the provider for deals waiting on acceptance", func(t *testing.T) {
+		deal := restart(t, storagemarket.StorageDealValidating, storagemarket.ClientEventRestart)
+		tut.AssertDealState(t, storagemarket.StorageDealCheckForAcceptance, deal.State)
+		assert.True(t, deal.ConnectionClosed)
+
+		deal = restart(t, storagemarket.StorageDealCheckForAcceptance, storagemarket.ClientEventRestart)
+		tut.AssertDealState(t, storagemarket.StorageDealCheckForAcceptance, deal.State)
+	})
+	t.Run("fails deals that depend on the provider stream", func(t *testing.T) {
+		for _, state := range clientstates.ClientStreamStates {
+			deal := restart(t, state.(storagemarket.StorageDealStatus), storagemarket.ClientEventStreamLost)
+			tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+			assert.True(t, deal.ConnectionClosed)
+			assert.Equal(t, "client restarted while waiting on the provider stream", deal.Message)
+		}
+	})
+}
+
+func TestCancel(t *testing.T) {
+	ctx := context.Background()
+	eventProcessor, err := fsm.NewEventProcessor(storagemarket.ClientDeal{}, "State", clientstates.ClientEvents)
+	assert.NoError(t, err)
+
+	for _, state := range clientstates.ClientCancellableStates {
+		dealState, err := tut.MakeTestClientDeal(state.(storagemarket.StorageDealStatus), clientDealProposal, false)
+		assert.NoError(t, err)
+		fsmCtx := fsmtest.NewTestContext(ctx, eventProcessor)
+		assert.NoError(t, fsmCtx.Trigger(storagemarket.ClientEventCancelled))
+		fsmCtx.ReplayEvents(t, dealState)
+
+		tut.AssertDealState(t, storagemarket.StorageDealFailing, dealState.State)
+		assert.True(t, dealState.ConnectionClosed)
+		assert.Equal(t, "deal cancelled by client", dealState.Message)
+	}
+
+	t.Run("accepted deals cannot be cancelled", func(t *testing.T) {
+		dealState, err := tut.MakeTestClientDeal(storagemarket.StorageDealProposalAccepted, clientDealProposal, false)
+		assert.NoError(t, err)
+		evt, err := eventProcessor.Generate(ctx, storagemarket.ClientEventCancelled, nil)
+		assert.NoError(t, err)
+		_, err = eventProcessor.Apply(statemachine.Event{User: evt}, dealState)
+		assert.Error(t, err)
+	})
+}
+
 type envParams struct {
-	dealStream             smnet.StorageDealStream
-	closeStreamErr         error
-	startDataTransferError error
-	manualTransfer         bool
+	dealStream                smnet.StorageDealStream
+	closeStreamErr            error
+	startDataTransferError    error
+	manualTransfer            bool
+	transferType              string
+	providerDealState         *storagemarket.ProviderDealState
+	getProviderDealStateError error
+	pollingInterval           time.Duration
 }
 
 type dealStateParams struct {
@@ -366,6 +584,9 @@ func makeExecutor(ctx context.Context,
 		node := makeNode(nodeParams)
 		dealState, err := tut.MakeTestClientDeal(initialState, clientDealProposal, envParams.manualTransfer)
 		assert.NoError(t, err)
+		if envParams.transferType != "" {
+			dealState.DataRef.TransferType = envParams.transferType
+		}
 		dealState.AddFundsCid = &tut.GenerateCids(1)[0]
 		dealState.ConnectionClosed = dealParams.connectionClosed
 
@@ -374,10 +595,13 @@ func makeExecutor(ctx context.Context,
 		}
 
 		environment := &fakeEnvironment{
-			node:                   node,
-			dealStream:             envParams.dealStream,
-			closeStreamErr:         envParams.closeStreamErr,
-			startDataTransferError: envParams.startDataTransferError,
+			node:                      node,
+			dealStream:                envParams.dealStream,
+			closeStreamErr:            envParams.closeStreamErr,
+			startDataTransferError:    envParams.startDataTransferError,
+			providerDealState:         envParams.providerDealState,
+			getProviderDealStateError: envParams.getProviderDealStateError,
+			pollingInterval:           envParams.pollingInterval,
 		}
 		fsmCtx := fsmtest.NewTestContext(ctx, eventProcessor)
 		err = stateEntryFunc(fsmCtx, environment, *dealState)
@@ -427,12 +651,16 @@ func makeNode(params nodeParams) storagemarket.StorageClientNode {
 }
 
 type fakeEnvironment struct {
-	node                   storagemarket.StorageClientNode
-	dealStream             smnet.StorageDealStream
-	closeStreamErr         error
-	closeStreamCalls       []cid.Cid
-	startDataTransferError error
-	startDataTransferCalls []dataTransferParams
+	node                      storagemarket.StorageClientNode
+	dealStream                smnet.StorageDealStream
+	closeStreamErr            error
+	closeStreamCalls          []cid.Cid
+	startDataTransferError    error
+	startDataTransferCalls    []dataTransferParams
+	providerDealState         *storagemarket.ProviderDealState
+	getProviderDealStateError error
+	getProviderDealStateCalls int
+	pollingInterval           time.Duration
 }
 
 type dataTransferParams struct {
@@ -474,8 +702,30 @@ func (fe *fakeEnvironment) CloseStream(proposalCid cid.Cid) error {
 	return fe.closeStreamErr
 }
 
+func (fe *fakeEnvironment) GetProviderDealState(ctx context.Context, proposalCid cid.Cid) (*storagemarket.ProviderDealState, error) {
+	fe.getProviderDealStateCalls++
+	return fe.providerDealState, fe.getProviderDealStateError
+}
+
+func (fe *fakeEnvironment) PollingInterval() time.Duration {
+	return fe.pollingInterval
+}
+
 var _ clientstates.ClientDealEnvironment = &fakeEnvironment{}
 
+func makeProviderDealState(t *testing.T, state storagemarket.StorageDealStatus, publishCid *cid.Cid, message string) *storagemarket.ProviderDealState {
+	proposalNd, err := cborutil.AsIpld(clientDealProposal)
+	assert.NoError(t, err)
+	proposalCid := proposalNd.Cid()
+	return &storagemarket.ProviderDealState{
+		State:       state,
+		Message:     message,
+		Proposal:    &clientDealProposal.Proposal,
+		ProposalCid: &proposalCid,
+		PublishCid:  publishCid,
+	}
+}
+
 type responseParams struct {
 	proposal       *market.ClientDealProposal
 	state          storagemarket.StorageDealStatus
@@ -516,7 +766,9 @@ type testCase struct {
 }
 
 func runAndInspect(t *testing.T, initialState storagemarket.StorageDealStatus, stateFunc clientstates.ClientStateEntryFunc, tc testCase) {
-	ctx := context.Background()
+	// cancelling the context stops any timer a state entry function left waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	eventProcessor, err := fsm.NewEventProcessor(storagemarket.ClientDeal{}, "State", clientstates.ClientEvents)
 	assert.NoError(t, err)
 	executor := makeExecutor(ctx, eventProcessor, initialState, stateFunc, tc.stateParams, clientDealProposal)
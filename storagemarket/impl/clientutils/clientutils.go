@@ -16,8 +16,9 @@ import (
 	"github.com/filecoin-project/go-fil-markets/storagemarket/network"
 )
 
-// CommP calculates the commP for a given dataref
-func CommP(ctx context.Context, pieceIO pieceio.PieceIO, rt abi.RegisteredProof, data *storagemarket.DataRef) (cid.Cid, abi.UnpaddedPieceSize, error) {
+// CommP calculates the commP for a given dataref, reporting progress through
+// the given function if it has to be computed
+func CommP(ctx context.Context, pieceIO pieceio.PieceIO, rt abi.RegisteredProof, data *storagemarket.DataRef, progress pieceio.ProgressFunc) (cid.Cid, abi.UnpaddedPieceSize, error) {
 	if data.PieceCid != nil {
 		return *data.PieceCid, data.PieceSize, nil
 	}
@@ -26,7 +27,11 @@ func CommP(ctx context.Context, pieceIO pieceio.PieceIO, rt abi.RegisteredProof,
 		return cid.Undef, 0, xerrors.New("Piece CID and size must be set for manual transfer")
 	}
 
-	commp, paddedSize, err := pieceIO.GeneratePieceCommitment(rt, data.Root, shared.AllSelector())
+	if data.TransferType == storagemarket.TTHTTP {
+		return cid.Undef, 0, xerrors.New("Piece CID and size must be set for http transfer")
+	}
+
+	commp, paddedSize, err := pieceIO.GeneratePieceCommitmentWithProgress(ctx, rt, data.Root, shared.AllSelector(), progress)
 	if err != nil {
 		return cid.Undef, 0, xerrors.Errorf("generating CommP: %w", err)
 	}
@@ -55,3 +60,22 @@ func VerifyResponse(ctx context.Context, resp network.SignedResponse, minerAddr
 
 	return nil
 }
+
+// VerifyDealStatusResponse verifies the signature on the given deal status response
+// matches the given miner address, using the given signature verification function
+func VerifyDealStatusResponse(ctx context.Context, resp network.DealStatusResponse, minerAddr address.Address, tok shared.TipSetToken, verifier VerifyFunc) error {
+	b, err := cborutil.Dump(&resp.DealState)
+	if err != nil {
+		return err
+	}
+	verified, err := verifier(ctx, resp.Signature, minerAddr, b, tok)
+	if err != nil {
+		return err
+	}
+
+	if !verified {
+		return xerrors.New("could not verify signature")
+	}
+
+	return nil
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/ipld/go-ipld-prime"
 	"github.com/stretchr/testify/require"
 
+	"github.com/filecoin-project/go-fil-markets/pieceio"
 	"github.com/filecoin-project/go-fil-markets/shared"
 	"github.com/filecoin-project/go-fil-markets/shared_testutil"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
@@ -33,12 +34,22 @@ func TestCommP(t *testing.T) {
 			PieceCid:     pieceCid,
 			PieceSize:    pieceSize,
 		}
-		respcid, ressize, err := clientutils.CommP(ctx, nil, proofType, data)
+		respcid, ressize, err := clientutils.CommP(ctx, nil, proofType, data, nil)
 		require.NoError(t, err)
 		require.Equal(t, respcid, *pieceCid)
 		require.Equal(t, ressize, pieceSize)
 	})
 
+	t.Run("when PieceCID is not present on data ref for an HTTP transfer", func(t *testing.T) {
+		data := &storagemarket.DataRef{
+			TransferType: storagemarket.TTHTTP,
+			Root:         shared_testutil.GenerateCids(1)[0],
+			URL:          "http://example.com/data.car",
+		}
+		_, _, err := clientutils.CommP(ctx, nil, proofType, data, nil)
+		require.EqualError(t, err, "Piece CID and size must be set for http transfer")
+	})
+
 	t.Run("when PieceCID is not present on data ref", func(t *testing.T) {
 		root := shared_testutil.GenerateCids(1)[0]
 		data := &storagemarket.DataRef{
@@ -50,16 +61,21 @@ func TestCommP(t *testing.T) {
 			pieceCid := shared_testutil.GenerateCids(1)[0]
 			pieceSize := abi.UnpaddedPieceSize(rand.Uint64())
 			pieceIO := &testPieceIO{t, proofType, root, allSelector, pieceCid, pieceSize, nil}
-			respcid, ressize, err := clientutils.CommP(ctx, pieceIO, proofType, data)
+			var processed uint64
+			progress := func(p uint64, total uint64) {
+				processed = p
+			}
+			respcid, ressize, err := clientutils.CommP(ctx, pieceIO, proofType, data, progress)
 			require.NoError(t, err)
 			require.Equal(t, respcid, pieceCid)
 			require.Equal(t, ressize, pieceSize)
+			require.Equal(t, uint64(pieceSize), processed)
 		})
 
 		t.Run("when pieceIO fails", func(t *testing.T) {
 			expectedMsg := "something went wrong"
 			pieceIO := &testPieceIO{t, proofType, root, allSelector, cid.Undef, 0, errors.New(expectedMsg)}
-			respcid, ressize, err := clientutils.CommP(ctx, pieceIO, proofType, data)
+			respcid, ressize, err := clientutils.CommP(ctx, pieceIO, proofType, data, nil)
 			require.EqualError(t, err, fmt.Sprintf("generating CommP: %s", expectedMsg))
 			require.Equal(t, respcid, cid.Undef)
 			require.Equal(t, ressize, abi.UnpaddedPieceSize(0))
@@ -106,6 +122,39 @@ func TestVerifyResponse(t *testing.T) {
 	}
 }
 
+func TestVerifyDealStatusResponse(t *testing.T) {
+	tests := map[string]struct {
+		verifier  clientutils.VerifyFunc
+		shouldErr bool
+	}{
+		"successful verification": {
+			verifier: func(context.Context, crypto.Signature, address.Address, []byte, shared.TipSetToken) (bool, error) {
+				return true, nil
+			},
+			shouldErr: false,
+		},
+		"verification fails": {
+			verifier: func(context.Context, crypto.Signature, address.Address, []byte, shared.TipSetToken) (bool, error) {
+				return false, nil
+			},
+			shouldErr: true,
+		},
+		"verifier errors": {
+			verifier: func(context.Context, crypto.Signature, address.Address, []byte, shared.TipSetToken) (bool, error) {
+				return false, errors.New("something went wrong")
+			},
+			shouldErr: true,
+		},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp := shared_testutil.MakeTestDealStatusResponse()
+			err := clientutils.VerifyDealStatusResponse(context.Background(), resp, address.TestAddress, shared.TipSetToken{}, data.verifier)
+			require.Equal(t, err != nil, data.shouldErr)
+		})
+	}
+}
+
 type testPieceIO struct {
 	t                  *testing.T
 	expectedRt         abi.RegisteredProof
@@ -117,9 +166,16 @@ type testPieceIO struct {
 }
 
 func (t *testPieceIO) GeneratePieceCommitment(rt abi.RegisteredProof, payloadCid cid.Cid, selector ipld.Node) (cid.Cid, abi.UnpaddedPieceSize, error) {
+	return t.GeneratePieceCommitmentWithProgress(context.Background(), rt, payloadCid, selector, nil)
+}
+
+func (t *testPieceIO) GeneratePieceCommitmentWithProgress(ctx context.Context, rt abi.RegisteredProof, payloadCid cid.Cid, selector ipld.Node, progress pieceio.ProgressFunc) (cid.Cid, abi.UnpaddedPieceSize, error) {
 	require.Equal(t.t, rt, t.expectedRt)
 	require.Equal(t.t, payloadCid, t.expectedPayloadCid)
 	require.Equal(t.t, selector, t.expectedSelector)
+	if t.err == nil && progress != nil {
+		progress(uint64(t.pieceSize), uint64(t.pieceSize))
+	}
 	return t.pieceCID, t.pieceSize, t.err
 }
 
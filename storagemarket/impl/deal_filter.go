@@ -0,0 +1,199 @@
+package storageimpl
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+)
+
+// DealFilter is a single named rule in a DealFilterChain
+type DealFilter struct {
+	// Name identifies the rule in the reason given for rejecting a deal
+	Name string
+	// Decide evaluates the deal against the rule
+	Decide DealDeciderFunc
+}
+
+// DealFilterChain evaluates a deal against an ordered list of filters. The
+// first filter to reject the deal or to error decides the outcome, and the
+// filters after it are not run
+type DealFilterChain struct {
+	filters []DealFilter
+}
+
+// NewDealFilterChain returns a chain that runs the given filters in order
+func NewDealFilterChain(filters ...DealFilter) *DealFilterChain {
+	return &DealFilterChain{filters: filters}
+}
+
+// Append adds filters to the end of the chain
+func (c *DealFilterChain) Append(filters ...DealFilter) {
+	c.filters = append(c.filters, filters...)
+}
+
+// Decide runs the deal through the chain. It has the signature of a
+// DealDeciderFunc, so a chain can be passed to CustomDealDecisionLogic.
+// The reason for a rejection is prefixed with the name of the rejecting filter
+func (c *DealFilterChain) Decide(ctx context.Context, deal storagemarket.MinerDeal) (bool, string, error) {
+	for _, filter := range c.filters {
+		accept, reason, err := filter.Decide(ctx, deal)
+		if err != nil {
+			return false, "", xerrors.Errorf("deal filter %s: %w", filter.Name, err)
+		}
+		if !accept {
+			return false, fmt.Sprintf("%s: %s", filter.Name, reason), nil
+		}
+	}
+	return true, "", nil
+}
+
+// DealFilters configures a provider to decide on incoming deals with a chain
+// of the given filters. It replaces any CustomDealDecisionLogic
+func DealFilters(filters ...DealFilter) StorageProviderOption {
+	return CustomDealDecisionLogic(NewDealFilterChain(filters...).Decide)
+}
+
+// AllowClientAddresses accepts only deals proposed by one of the given client addresses
+func AllowClientAddresses(addrs ...address.Address) DealFilter {
+	allowed := make(map[address.Address]struct{}, len(addrs))
+	for _, addr := range addrs {
+		allowed[addr] = struct{}{}
+	}
+	return DealFilter{
+		Name: "client address allow list",
+		Decide: func(ctx context.Context, deal storagemarket.MinerDeal) (bool, string, error) {
+			if _, ok := allowed[deal.Proposal.Client]; !ok {
+				return false, fmt.Sprintf("client %s is not allowed", deal.Proposal.Client), nil
+			}
+			return true, "", nil
+		},
+	}
+}
+
+// DenyClientAddresses rejects deals proposed by any of the given client addresses
+func DenyClientAddresses(addrs ...address.Address) DealFilter {
+	denied := make(map[address.Address]struct{}, len(addrs))
+	for _, addr := range addrs {
+		denied[addr] = struct{}{}
+	}
+	return DealFilter{
+		Name: "client address deny list",
+		Decide: func(ctx context.Context, deal storagemarket.MinerDeal) (bool, string, error) {
+			if _, ok := denied[deal.Proposal.Client]; ok {
+				return false, fmt.Sprintf("client %s is denied", deal.Proposal.Client), nil
+			}
+			return true, "", nil
+		},
+	}
+}
+
+// AllowClientPeers accepts only deals proposed from one of the given peers
+func AllowClientPeers(peers ...peer.ID) DealFilter {
+	allowed := make(map[peer.ID]struct{}, len(peers))
+	for _, p := range peers {
+		allowed[p] = struct{}{}
+	}
+	return DealFilter{
+		Name: "client peer allow list",
+		Decide: func(ctx context.Context, deal storagemarket.MinerDeal) (bool, string, error) {
+			if _, ok := allowed[deal.Client]; !ok {
+				return false, fmt.Sprintf("peer %s is not allowed", deal.Client), nil
+			}
+			return true, "", nil
+		},
+	}
+}
+
+// DenyClientPeers rejects deals proposed from any of the given peers
+func DenyClientPeers(peers ...peer.ID) DealFilter {
+	denied := make(map[peer.ID]struct{}, len(peers))
+	for _, p := range peers {
+		denied[p] = struct{}{}
+	}
+	return DealFilter{
+		Name: "client peer deny list",
+		Decide: func(ctx context.Context, deal storagemarket.MinerDeal) (bool, string, error) {
+			if _, ok := denied[deal.Client]; ok {
+				return false, fmt.Sprintf("peer %s is denied", deal.Client), nil
+			}
+			return true, "", nil
+		},
+	}
+}
+
+// BlockPieceCids rejects deals for any of the given pieces
+func BlockPieceCids(pieceCids ...cid.Cid) DealFilter {
+	blocked := make(map[cid.Cid]struct{}, len(pieceCids))
+	for _, pieceCid := range pieceCids {
+		blocked[pieceCid] = struct{}{}
+	}
+	return DealFilter{
+		Name: "blocked pieces",
+		Decide: func(ctx context.Context, deal storagemarket.MinerDeal) (bool, string, error) {
+			if _, ok := blocked[deal.Proposal.PieceCID]; ok {
+				return false, fmt.Sprintf("piece %s is blocked", deal.Proposal.PieceCID), nil
+			}
+			return true, "", nil
+		},
+	}
+}
+
+// MaxDealDuration rejects deals that last longer than the given number of epochs
+func MaxDealDuration(maxDuration abi.ChainEpoch) DealFilter {
+	return DealFilter{
+		Name: "maximum duration",
+		Decide: func(ctx context.Context, deal storagemarket.MinerDeal) (bool, string, error) {
+			duration := deal.Proposal.Duration()
+			if duration > maxDuration {
+				return false, fmt.Sprintf("deal duration %d is longer than %d epochs", duration, maxDuration), nil
+			}
+			return true, "", nil
+		},
+	}
+}
+
+// MinStartEpochLead rejects deals that start less than the given number of
+// epochs after the current chain head
+func MinStartEpochLead(node storagemarket.StorageProviderNode, minLead abi.ChainEpoch) DealFilter {
+	return DealFilter{
+		Name: "minimum start epoch lead",
+		Decide: func(ctx context.Context, deal storagemarket.MinerDeal) (bool, string, error) {
+			_, height, err := node.GetChainHead(ctx)
+			if err != nil {
+				return false, "", xerrors.Errorf("getting chain head: %w", err)
+			}
+			if deal.Proposal.StartEpoch-height < minLead {
+				return false, fmt.Sprintf("deal start epoch %d is less than %d epochs after the current epoch %d", deal.Proposal.StartEpoch, minLead, height), nil
+			}
+			return true, "", nil
+		},
+	}
+}
+
+// AcceptTransferTypes accepts only deals whose data is sent with one of the
+// given transfer types, e.g. storagemarket.TTGraphsync or storagemarket.TTManual
+func AcceptTransferTypes(transferTypes ...string) DealFilter {
+	accepted := make(map[string]struct{}, len(transferTypes))
+	for _, transferType := range transferTypes {
+		accepted[transferType] = struct{}{}
+	}
+	return DealFilter{
+		Name: "transfer type",
+		Decide: func(ctx context.Context, deal storagemarket.MinerDeal) (bool, string, error) {
+			if deal.Ref == nil {
+				return false, "deal has no data reference", nil
+			}
+			if _, ok := accepted[deal.Ref.TransferType]; !ok {
+				return false, fmt.Sprintf("transfer type %s is not accepted", deal.Ref.TransferType), nil
+			}
+			return true, "", nil
+		},
+	}
+}
@@ -0,0 +1,173 @@
+package storageimpl_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/require"
+
+	tut "github.com/filecoin-project/go-fil-markets/shared_testutil"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	storageimpl "github.com/filecoin-project/go-fil-markets/storagemarket/impl"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/testnodes"
+)
+
+func makeFilterDeal(t *testing.T) storagemarket.MinerDeal {
+	proposal := tut.MakeTestClientDealProposal()
+	proposal.Proposal.StartEpoch = 200
+	proposal.Proposal.EndEpoch = 400
+	deal, err := tut.MakeTestMinerDeal(storagemarket.StorageDealAcceptWait, proposal, tut.MakeTestDataRef(false))
+	require.NoError(t, err)
+	deal.Ref.TransferType = storagemarket.TTGraphsync
+	return *deal
+}
+
+func TestDealFilterChain(t *testing.T) {
+	ctx := context.Background()
+	var ran []string
+	filter := func(name string, accept bool, err error) storageimpl.DealFilter {
+		return storageimpl.DealFilter{
+			Name: name,
+			Decide: func(context.Context, storagemarket.MinerDeal) (bool, string, error) {
+				ran = append(ran, name)
+				return accept, "no thanks", err
+			},
+		}
+	}
+
+	t.Run("accepts when every filter accepts", func(t *testing.T) {
+		ran = nil
+		chain := storageimpl.NewDealFilterChain(filter("first", true, nil), filter("second", true, nil))
+		accept, reason, err := chain.Decide(ctx, makeFilterDeal(t))
+		require.NoError(t, err)
+		require.True(t, accept)
+		require.Empty(t, reason)
+		require.Equal(t, []string{"first", "second"}, ran)
+	})
+
+	t.Run("accepts with no filters", func(t *testing.T) {
+		accept, _, err := storageimpl.NewDealFilterChain().Decide(ctx, makeFilterDeal(t))
+		require.NoError(t, err)
+		require.True(t, accept)
+	})
+
+	t.Run("stops at the first rejection", func(t *testing.T) {
+		ran = nil
+		chain := storageimpl.NewDealFilterChain(filter("first", true, nil), filter("second", false, nil))
+		chain.Append(filter("third", true, nil))
+		accept, reason, err := chain.Decide(ctx, makeFilterDeal(t))
+		require.NoError(t, err)
+		require.False(t, accept)
+		require.Equal(t, "second: no thanks", reason)
+		require.Equal(t, []string{"first", "second"}, ran)
+	})
+
+	t.Run("stops at the first error", func(t *testing.T) {
+		ran = nil
+		chain := storageimpl.NewDealFilterChain(filter("first", true, errors.New("something went wrong")), filter("second", true, nil))
+		accept, _, err := chain.Decide(ctx, makeFilterDeal(t))
+		require.EqualError(t, err, "deal filter first: something went wrong")
+		require.False(t, accept)
+		require.Equal(t, []string{"first"}, ran)
+	})
+}
+
+func TestBuiltinDealFilters(t *testing.T) {
+	ctx := context.Background()
+	deal := makeFilterDeal(t)
+	otherPeer := peer.ID("other peer")
+	node := &testnodes.FakeProviderNode{
+		FakeCommonNode: testnodes.FakeCommonNode{SMState: testnodes.NewStorageMarketState()},
+	}
+	node.SMState.Epoch = 100
+
+	tests := map[string]struct {
+		filter         storageimpl.DealFilter
+		expectedAccept bool
+		expectedReason string
+	}{
+		"client address allowed": {
+			filter:         storageimpl.AllowClientAddresses(address.TestAddress),
+			expectedAccept: true,
+		},
+		"client address not allowed": {
+			filter:         storageimpl.AllowClientAddresses(address.TestAddress2),
+			expectedReason: "client " + address.TestAddress.String() + " is not allowed",
+		},
+		"client address denied": {
+			filter:         storageimpl.DenyClientAddresses(address.TestAddress),
+			expectedReason: "client " + address.TestAddress.String() + " is denied",
+		},
+		"client address not denied": {
+			filter:         storageimpl.DenyClientAddresses(address.TestAddress2),
+			expectedAccept: true,
+		},
+		"client peer allowed": {
+			filter:         storageimpl.AllowClientPeers(deal.Client),
+			expectedAccept: true,
+		},
+		"client peer not allowed": {
+			filter:         storageimpl.AllowClientPeers(otherPeer),
+			expectedReason: "peer " + deal.Client.String() + " is not allowed",
+		},
+		"client peer denied": {
+			filter:         storageimpl.DenyClientPeers(deal.Client),
+			expectedReason: "peer " + deal.Client.String() + " is denied",
+		},
+		"client peer not denied": {
+			filter:         storageimpl.DenyClientPeers(otherPeer),
+			expectedAccept: true,
+		},
+		"piece blocked": {
+			filter:         storageimpl.BlockPieceCids(deal.Proposal.PieceCID),
+			expectedReason: "piece " + deal.Proposal.PieceCID.String() + " is blocked",
+		},
+		"piece not blocked": {
+			filter:         storageimpl.BlockPieceCids(tut.GenerateCids(1)[0]),
+			expectedAccept: true,
+		},
+		"duration within maximum": {
+			filter:         storageimpl.MaxDealDuration(200),
+			expectedAccept: true,
+		},
+		"duration too long": {
+			filter:         storageimpl.MaxDealDuration(199),
+			expectedReason: "deal duration 200 is longer than 199 epochs",
+		},
+		"start epoch far enough ahead": {
+			filter:         storageimpl.MinStartEpochLead(node, 100),
+			expectedAccept: true,
+		},
+		"start epoch too soon": {
+			filter:         storageimpl.MinStartEpochLead(node, 101),
+			expectedReason: "deal start epoch 200 is less than 101 epochs after the current epoch 100",
+		},
+		"transfer type accepted": {
+			filter:         storageimpl.AcceptTransferTypes(storagemarket.TTManual, storagemarket.TTGraphsync),
+			expectedAccept: true,
+		},
+		"transfer type not accepted": {
+			filter:         storageimpl.AcceptTransferTypes(storagemarket.TTManual),
+			expectedReason: "transfer type graphsync is not accepted",
+		},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			accept, reason, err := data.filter.Decide(ctx, deal)
+			require.NoError(t, err)
+			require.Equal(t, data.expectedAccept, accept)
+			require.Equal(t, data.expectedReason, reason)
+		})
+	}
+
+	t.Run("start epoch lead errors when the chain head is unavailable", func(t *testing.T) {
+		failingNode := &testnodes.FakeProviderNode{
+			FakeCommonNode: testnodes.FakeCommonNode{GetChainHeadError: errors.New("no chain")},
+		}
+		_, _, err := storageimpl.MinStartEpochLead(failingNode, 10).Decide(ctx, deal)
+		require.EqualError(t, err, "getting chain head: no chain")
+	})
+}
@@ -0,0 +1,76 @@
+package storageimpl
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+)
+
+// downloads runs the HTTP downloads of deal data in the background, so that a
+// slow or unresponsive server does not hold up the deal state machines. Each
+// download can be cancelled on its own, and they are all cancelled when the
+// provider stops
+type downloads struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	lk      sync.Mutex
+	running map[cid.Cid]context.CancelFunc
+}
+
+func newDownloads() *downloads {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &downloads{
+		ctx:     ctx,
+		cancel:  cancel,
+		running: make(map[cid.Cid]context.CancelFunc),
+	}
+}
+
+// start runs download in the background, unless the download for the deal is
+// already running. The context download is given is cancelled when the deal's
+// download is cancelled or all downloads stop
+func (d *downloads) start(proposalCid cid.Cid, download func(ctx context.Context)) {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+
+	if _, ok := d.running[proposalCid]; ok || d.ctx.Err() != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(d.ctx)
+	d.running[proposalCid] = cancel
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		defer func() {
+			d.lk.Lock()
+			delete(d.running, proposalCid)
+			d.lk.Unlock()
+			cancel()
+		}()
+		download(ctx)
+	}()
+}
+
+// cancelDownload cancels the download for a deal, if one is running
+func (d *downloads) cancelDownload(proposalCid cid.Cid) {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+
+	if cancel, ok := d.running[proposalCid]; ok {
+		cancel()
+	}
+}
+
+// stopping returns true once all downloads have been told to stop
+func (d *downloads) stopping() bool {
+	return d.ctx.Err() != nil
+}
+
+// stop cancels every download and waits for them to return
+func (d *downloads) stop() {
+	d.cancel()
+	d.wg.Wait()
+}
@@ -7,6 +7,7 @@ import (
 
 	datatransfer "github.com/filecoin-project/go-data-transfer"
 	"github.com/filecoin-project/go-statemachine/fsm"
+	"github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log/v2"
 
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
@@ -88,3 +89,17 @@ func ClientDataTransferSubscriber(deals EventReceiver) datatransfer.Subscriber {
 		}
 	}
 }
+
+// DealChannels returns the IDs of the channels among the given in progress
+// channels that transfer data for the deal with the given proposal cid
+func DealChannels(channels map[datatransfer.ChannelID]datatransfer.ChannelState, proposalCid cid.Cid) []datatransfer.ChannelID {
+	var ids []datatransfer.ChannelID
+	for id, channelState := range channels {
+		voucher, ok := channelState.Voucher().(*requestvalidation.StorageDataTransferVoucher)
+		if !ok || !voucher.Proposal.Equals(proposalCid) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
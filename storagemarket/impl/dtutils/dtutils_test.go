@@ -144,6 +144,26 @@ func TestClientDataTransferSubscriber(t *testing.T) {
 	}
 }
 
+func TestDealChannels(t *testing.T) {
+	proposalCids := shared_testutil.GenerateCids(2)
+	channel := func(id datatransfer.TransferID, voucher datatransfer.Voucher) (datatransfer.ChannelID, datatransfer.ChannelState) {
+		return datatransfer.ChannelID{ID: id}, datatransfer.ChannelState{
+			Channel: datatransfer.NewChannel(id, cid.Undef, nil, voucher, peer.ID(""), peer.ID(""), 0),
+		}
+	}
+
+	channels := make(map[datatransfer.ChannelID]datatransfer.ChannelState)
+	dealChannel, state := channel(1, &requestvalidation.StorageDataTransferVoucher{Proposal: proposalCids[0]})
+	channels[dealChannel] = state
+	otherDealChannel, state := channel(2, &requestvalidation.StorageDataTransferVoucher{Proposal: proposalCids[1]})
+	channels[otherDealChannel] = state
+	otherVoucherChannel, state := channel(3, nil)
+	channels[otherVoucherChannel] = state
+
+	require.Equal(t, []datatransfer.ChannelID{dealChannel}, dtutils.DealChannels(channels, proposalCids[0]))
+	require.Empty(t, dtutils.DealChannels(channels, shared_testutil.GenerateCids(1)[0]))
+}
+
 type fakeDealGroup struct {
 	returnedErr error
 	called      bool
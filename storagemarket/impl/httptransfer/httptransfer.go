@@ -0,0 +1,220 @@
+// Package httptransfer downloads storage deal data that clients host on their
+// own HTTP servers
+package httptransfer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/filestore"
+)
+
+var log = logging.Logger("httptransfer")
+
+// DefaultMaxAttempts is the number of requests a download makes before giving
+// up, when it loses the connection part way through the file
+const DefaultMaxAttempts = 5
+
+// DefaultClient is the client downloads are made with unless another is
+// given. It limits how long connecting to a server and waiting for it to
+// respond may take, but not the download as a whole, as deal data can take a
+// long time to transfer
+var DefaultClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+		ExpectContinueTimeout: time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          100,
+	},
+}
+
+// ErrTooLarge means the file is larger than the download size limit
+var ErrTooLarge = xerrors.New("file is larger than the size limit")
+
+// ValidateURL checks that a deal data URL can be downloaded from
+func ValidateURL(rawurl string) error {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return xerrors.Errorf("parsing url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return xerrors.Errorf("unsupported url scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return xerrors.New("url has no host")
+	}
+	return nil
+}
+
+// Downloader downloads files into a filestore
+type Downloader struct {
+	client      *http.Client
+	fs          filestore.FileStore
+	maxAttempts int
+}
+
+// NewDownloader returns a downloader that writes to the given filestore,
+// making requests with the given client
+func NewDownloader(client *http.Client, fs filestore.FileStore) *Downloader {
+	return &Downloader{
+		client:      client,
+		fs:          fs,
+		maxAttempts: DefaultMaxAttempts,
+	}
+}
+
+// Download downloads the file at the given URL to the given path in the
+// filestore, adding the headers to each request. Data already at the path is
+// kept and only the rest of the file is requested, so a download interrupted
+// by a lost connection or a restart picks up where it left off. Servers that
+// don't support range requests send the whole file again. Download fails if
+// the file is larger than maxSize bytes
+func (d *Downloader) Download(ctx context.Context, rawurl string, headers http.Header, path filestore.Path, maxSize uint64) error {
+	if err := ValidateURL(rawurl); err != nil {
+		return err
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < d.maxAttempts; attempt++ {
+		done, err := d.request(ctx, rawurl, headers, path, maxSize)
+		if done {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Warnf("download of %s interrupted, resuming: %s", rawurl, err)
+		lastErr = err
+	}
+	return xerrors.Errorf("download failed after %d attempts: %w", d.maxAttempts, lastErr)
+}
+
+// request makes a single request for the part of the file that isn't at the
+// path yet. It returns done = false when the error may go away if the
+// request is retried
+func (d *Downloader) request(ctx context.Context, rawurl string, headers http.Header, path filestore.Path, maxSize uint64) (bool, error) {
+	file, err := d.openFile(path)
+	if err != nil {
+		return true, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	offset := uint64(file.Size())
+	if offset > maxSize {
+		return true, ErrTooLarge
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawurl, nil)
+	if err != nil {
+		return true, xerrors.Errorf("creating request: %w", err)
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return false, xerrors.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// the server sent the whole file, so start it over
+		if offset > 0 {
+			if err := file.Close(); err != nil {
+				return true, xerrors.Errorf("closing file: %w", err)
+			}
+			if err := d.fs.Delete(path); err != nil {
+				return true, xerrors.Errorf("deleting partial file: %w", err)
+			}
+			file, err = d.fs.Create(path)
+			if err != nil {
+				return true, xerrors.Errorf("creating file: %w", err)
+			}
+			offset = 0
+		}
+	case http.StatusPartialContent:
+		start, err := rangeStart(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return true, err
+		}
+		if start != offset {
+			return true, xerrors.Errorf("server sent data from offset %d, requested %d", start, offset)
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the whole file was downloaded before the last request failed
+		if offset > 0 && resp.Header.Get("Content-Range") == fmt.Sprintf("bytes */%d", offset) {
+			return true, nil
+		}
+		return true, xerrors.Errorf("unexpected response status %s", resp.Status)
+	default:
+		return true, xerrors.Errorf("unexpected response status %s", resp.Status)
+	}
+
+	if resp.ContentLength > 0 && offset+uint64(resp.ContentLength) > maxSize {
+		return true, ErrTooLarge
+	}
+
+	n, err := io.Copy(file, io.LimitReader(resp.Body, int64(maxSize-offset)+1))
+	if offset+uint64(n) > maxSize {
+		return true, ErrTooLarge
+	}
+	if err != nil {
+		return false, xerrors.Errorf("reading response: %w", err)
+	}
+	return true, nil
+}
+
+// openFile opens the file at the path to append to it, creating it if it
+// doesn't exist
+func (d *Downloader) openFile(path filestore.Path) (filestore.File, error) {
+	file, err := d.fs.Open(path)
+	if err == nil {
+		return file, nil
+	}
+	file, err = d.fs.Create(path)
+	if err != nil {
+		return nil, xerrors.Errorf("creating file: %w", err)
+	}
+	return file, nil
+}
+
+// rangeStart returns the offset of the first byte in a partial response from
+// its Content-Range header, e.g. "bytes 100-199/200"
+func rangeStart(contentRange string) (uint64, error) {
+	if !strings.HasPrefix(contentRange, "bytes ") {
+		return 0, xerrors.Errorf("invalid content range %q", contentRange)
+	}
+	byteRange := strings.TrimPrefix(contentRange, "bytes ")
+	dash := strings.Index(byteRange, "-")
+	if dash < 0 {
+		return 0, xerrors.Errorf("invalid content range %q", contentRange)
+	}
+	start, err := strconv.ParseUint(byteRange[:dash], 10, 64)
+	if err != nil {
+		return 0, xerrors.Errorf("invalid content range %q: %w", contentRange, err)
+	}
+	return start, nil
+}
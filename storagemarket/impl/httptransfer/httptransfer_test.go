@@ -0,0 +1,202 @@
+package httptransfer_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/filestore"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/httptransfer"
+)
+
+const path = filestore.Path("download.car")
+
+func newFileStore(t *testing.T) (filestore.FileStore, func()) {
+	dir, err := ioutil.TempDir("", "httptransfer_test")
+	require.NoError(t, err)
+	fs, err := filestore.NewLocalFileStore(filestore.OsPath(dir))
+	require.NoError(t, err)
+	return fs, func() { _ = os.RemoveAll(dir) }
+}
+
+func readFile(t *testing.T, fs filestore.FileStore) []byte {
+	f, err := fs.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	return data
+}
+
+func writeFile(t *testing.T, fs filestore.FileStore, data []byte) {
+	f, err := fs.Create(path)
+	require.NoError(t, err)
+	_, err = f.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+// serveContent serves data with support for range requests, recording the
+// Range header of each request
+func serveContent(data []byte, ranges *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*ranges = append(*ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "data.car", time.Time{}, bytes.NewReader(data))
+	}
+}
+
+func TestDownload(t *testing.T) {
+	ctx := context.Background()
+	data := make([]byte, 10000)
+	_, _ = rand.New(rand.NewSource(1)).Read(data)
+
+	t.Run("downloads the file", func(t *testing.T) {
+		var ranges []string
+		server := httptest.NewServer(serveContent(data, &ranges))
+		defer server.Close()
+		fs, cleanup := newFileStore(t)
+		defer cleanup()
+
+		err := httptransfer.NewDownloader(server.Client(), fs).Download(ctx, server.URL, nil, path, uint64(len(data)))
+		require.NoError(t, err)
+		require.Equal(t, data, readFile(t, fs))
+		require.Equal(t, []string{""}, ranges)
+	})
+
+	t.Run("sends the given headers", func(t *testing.T) {
+		var auth string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth = r.Header.Get("Authorization")
+			_, _ = w.Write(data)
+		}))
+		defer server.Close()
+		fs, cleanup := newFileStore(t)
+		defer cleanup()
+
+		headers := http.Header{}
+		headers.Set("Authorization", "Bearer token")
+		err := httptransfer.NewDownloader(server.Client(), fs).Download(ctx, server.URL, headers, path, uint64(len(data)))
+		require.NoError(t, err)
+		require.Equal(t, "Bearer token", auth)
+	})
+
+	t.Run("resumes a partial download", func(t *testing.T) {
+		var ranges []string
+		server := httptest.NewServer(serveContent(data, &ranges))
+		defer server.Close()
+		fs, cleanup := newFileStore(t)
+		defer cleanup()
+		writeFile(t, fs, data[:4000])
+
+		err := httptransfer.NewDownloader(server.Client(), fs).Download(ctx, server.URL, nil, path, uint64(len(data)))
+		require.NoError(t, err)
+		require.Equal(t, data, readFile(t, fs))
+		require.Equal(t, []string{"bytes=4000-"}, ranges)
+	})
+
+	t.Run("resumes after losing the connection", func(t *testing.T) {
+		var ranges []string
+		resumed := serveContent(data, &ranges)
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests > 1 {
+				resumed(w, r)
+				return
+			}
+			// promise the whole file but only send part of it
+			ranges = append(ranges, r.Header.Get("Range"))
+			w.Header().Set("Content-Length", "10000")
+			_, _ = w.Write(data[:3000])
+		}))
+		defer server.Close()
+		fs, cleanup := newFileStore(t)
+		defer cleanup()
+
+		err := httptransfer.NewDownloader(server.Client(), fs).Download(ctx, server.URL, nil, path, uint64(len(data)))
+		require.NoError(t, err)
+		require.Equal(t, data, readFile(t, fs))
+		require.Equal(t, []string{"", "bytes=3000-"}, ranges)
+	})
+
+	t.Run("starts over when the server ignores the range", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(data)
+		}))
+		defer server.Close()
+		fs, cleanup := newFileStore(t)
+		defer cleanup()
+		writeFile(t, fs, data[:4000])
+
+		err := httptransfer.NewDownloader(server.Client(), fs).Download(ctx, server.URL, nil, path, uint64(len(data)))
+		require.NoError(t, err)
+		require.Equal(t, data, readFile(t, fs))
+	})
+
+	t.Run("completes a file that was already downloaded", func(t *testing.T) {
+		var ranges []string
+		server := httptest.NewServer(serveContent(data, &ranges))
+		defer server.Close()
+		fs, cleanup := newFileStore(t)
+		defer cleanup()
+		writeFile(t, fs, data)
+
+		err := httptransfer.NewDownloader(server.Client(), fs).Download(ctx, server.URL, nil, path, uint64(len(data)))
+		require.NoError(t, err)
+		require.Equal(t, data, readFile(t, fs))
+	})
+
+	t.Run("fails when the file is too large", func(t *testing.T) {
+		var ranges []string
+		server := httptest.NewServer(serveContent(data, &ranges))
+		defer server.Close()
+		fs, cleanup := newFileStore(t)
+		defer cleanup()
+
+		err := httptransfer.NewDownloader(server.Client(), fs).Download(ctx, server.URL, nil, path, uint64(len(data)-1))
+		require.True(t, xerrors.Is(err, httptransfer.ErrTooLarge))
+	})
+
+	t.Run("fails when the size is not given in advance", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// write in chunks so that the response has no content length
+			for i := 0; i < len(data); i += 1000 {
+				_, _ = w.Write(data[i : i+1000])
+				w.(http.Flusher).Flush()
+			}
+		}))
+		defer server.Close()
+		fs, cleanup := newFileStore(t)
+		defer cleanup()
+
+		err := httptransfer.NewDownloader(server.Client(), fs).Download(ctx, server.URL, nil, path, 5000)
+		require.True(t, xerrors.Is(err, httptransfer.ErrTooLarge))
+	})
+
+	t.Run("fails on error responses", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		defer server.Close()
+		fs, cleanup := newFileStore(t)
+		defer cleanup()
+
+		err := httptransfer.NewDownloader(server.Client(), fs).Download(ctx, server.URL, nil, path, uint64(len(data)))
+		require.EqualError(t, err, "unexpected response status 404 Not Found")
+	})
+}
+
+func TestValidateURL(t *testing.T) {
+	require.NoError(t, httptransfer.ValidateURL("http://localhost:1234/data.car"))
+	require.NoError(t, httptransfer.ValidateURL("https://example.com/data.car?token=abc"))
+	require.EqualError(t, httptransfer.ValidateURL("file:///etc/passwd"), `unsupported url scheme "file"`)
+	require.EqualError(t, httptransfer.ValidateURL("http:///data.car"), "url has no host")
+	require.Error(t, httptransfer.ValidateURL("://"))
+}
@@ -1,19 +1,25 @@
 package storageimpl
 
 import (
+	"bufio"
 	"context"
 	"io"
+	"net/http"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	cborutil "github.com/filecoin-project/go-cbor-util"
 	datatransfer "github.com/filecoin-project/go-data-transfer"
+	"github.com/filecoin-project/go-padreader"
 	"github.com/filecoin-project/go-statemachine/fsm"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/crypto"
 	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
 	"github.com/hannahhoward/go-pubsub"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/ipld/go-car"
 	"github.com/ipld/go-ipld-prime"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"golang.org/x/xerrors"
@@ -24,8 +30,10 @@ import (
 	"github.com/filecoin-project/go-fil-markets/piecestore"
 	"github.com/filecoin-project/go-fil-markets/shared"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/blockrecorder"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/connmanager"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/dtutils"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/httptransfer"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/providerstates"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/providerutils"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/network"
@@ -34,6 +42,11 @@ import (
 var DefaultDealAcceptanceBuffer = abi.ChainEpoch(100)
 var _ storagemarket.StorageProvider = &Provider{}
 
+// clientMessageEpochTolerance is how far from the provider's chain head the
+// epoch a client signed a status request or cancellation at may be, allowing
+// for the client's view of the chain to lag or lead a little
+const clientMessageEpochTolerance = abi.ChainEpoch(10)
+
 type StoredAsk interface {
 	GetAsk(address.Address) *storagemarket.SignedStorageAsk
 	AddAsk(price abi.TokenAmount, duration abi.ChainEpoch, options ...storagemarket.StorageAskOption) error
@@ -56,6 +69,16 @@ type Provider struct {
 	universalRetrievalEnabled bool
 	customDealDeciderFunc     DealDeciderFunc
 	dealAcceptanceBuffer      abi.ChainEpoch
+	publishWindow             time.Duration
+	maxDealsPerPublish        int
+	publishBatcher            *publishBatcher
+	manualApproval            bool
+	approvalRejectBuffer      abi.ChainEpoch
+	approvalCheckInterval     time.Duration
+	stopApprovalWatcher       context.CancelFunc
+	commPCache                *pieceio.CommPCache
+	httpClient                *http.Client
+	downloads                 *downloads
 	pubSub                    *pubsub.PubSub
 
 	deals fsm.Group
@@ -80,6 +103,39 @@ func DealAcceptanceBuffer(buffer abi.ChainEpoch) StorageProviderOption {
 	}
 }
 
+// PublishBatching allows a provider to publish several deals in a single message.
+// Deals that are ready to publish are collected until maxDeals deals are
+// waiting or window has elapsed since the first of them, whichever comes first.
+// It only takes effect when passed to NewProvider.
+func PublishBatching(window time.Duration, maxDeals int) StorageProviderOption {
+	return func(p *Provider) {
+		p.publishWindow = window
+		p.maxDealsPerPublish = maxDeals
+	}
+}
+
+// ProviderCommPCache makes a provider cache the piece commitments of data
+// received for deals in the given datastore, and look them up there before
+// computing them. The datastore must not be shared with other data. Without
+// this option commitments are not cached. It only takes effect when passed to
+// NewProvider.
+//
+// Data imported for offline deals never uses the cache, see ImportDataForDeal
+func ProviderCommPCache(ds datastore.Datastore) StorageProviderOption {
+	return func(p *Provider) {
+		p.commPCache = pieceio.NewCommPCache(ds)
+	}
+}
+
+// HTTPTransferClient sets the client a provider uses to download the data of
+// deals with the storagemarket.TTHTTP transfer type. By default it uses
+// httptransfer.DefaultClient.
+func HTTPTransferClient(client *http.Client) StorageProviderOption {
+	return func(p *Provider) {
+		p.httpClient = client
+	}
+}
+
 // DealDeciderFunc is a function which evaluates an incoming deal to decide if
 // it its accepted
 // It returns:
@@ -96,24 +152,43 @@ func CustomDealDecisionLogic(decider DealDeciderFunc) StorageProviderOption {
 	}
 }
 
+// DefaultApprovalCheckInterval is how often a provider in manual approval mode
+// checks for pending deals that have run out of time to be approved
+var DefaultApprovalCheckInterval = time.Minute
+
+// ManualDealApproval parks deals that pass validation and the custom decision
+// logic until the operator approves them with ApproveDeal or rejects them with
+// RejectDeal. Deals still pending rejectBuffer epochs before the latest epoch
+// they could be accepted at (StartEpoch - DealAcceptanceBuffer) are rejected
+// automatically. Pending deals are checked every checkInterval; a zero value
+// uses DefaultApprovalCheckInterval.
+func ManualDealApproval(rejectBuffer abi.ChainEpoch, checkInterval time.Duration) StorageProviderOption {
+	return func(p *Provider) {
+		p.manualApproval = true
+		p.approvalRejectBuffer = rejectBuffer
+		p.approvalCheckInterval = checkInterval
+	}
+}
+
 // NewProvider returns a new storage provider
 func NewProvider(net network.StorageMarketNetwork, ds datastore.Batching, bs blockstore.Blockstore, fs filestore.FileStore, pieceStore piecestore.PieceStore, dataTransfer datatransfer.Manager, spn storagemarket.StorageProviderNode, minerAddress address.Address, rt abi.RegisteredProof, storedAsk StoredAsk, options ...StorageProviderOption) (storagemarket.StorageProvider, error) {
-	carIO := cario.NewCarIO()
-	pio := pieceio.NewPieceIOWithStore(carIO, fs, bs)
-
 	h := &Provider{
-		net:                  net,
-		proofType:            rt,
-		spn:                  spn,
-		fs:                   fs,
-		pio:                  pio,
-		pieceStore:           pieceStore,
-		conns:                connmanager.NewConnManager(),
-		storedAsk:            storedAsk,
-		actor:                minerAddress,
-		dataTransfer:         dataTransfer,
-		dealAcceptanceBuffer: DefaultDealAcceptanceBuffer,
-		pubSub:               pubsub.New(providerDispatcher),
+		net:                   net,
+		proofType:             rt,
+		spn:                   spn,
+		fs:                    fs,
+		pieceStore:            pieceStore,
+		conns:                 connmanager.NewConnManager(),
+		storedAsk:             storedAsk,
+		actor:                 minerAddress,
+		dataTransfer:          dataTransfer,
+		dealAcceptanceBuffer:  DefaultDealAcceptanceBuffer,
+		publishWindow:         DefaultPublishWindow,
+		maxDealsPerPublish:    DefaultMaxDealsPerPublish,
+		approvalCheckInterval: DefaultApprovalCheckInterval,
+		httpClient:            httptransfer.DefaultClient,
+		downloads:             newDownloads(),
+		pubSub:                pubsub.New(providerDispatcher),
 	}
 
 	deals, err := fsm.New(ds, fsm.Parameters{
@@ -131,6 +206,13 @@ func NewProvider(net network.StorageMarketNetwork, ds datastore.Batching, bs blo
 	h.deals = deals
 
 	h.Configure(options...)
+	if h.approvalCheckInterval <= 0 {
+		h.approvalCheckInterval = DefaultApprovalCheckInterval
+	}
+
+	h.pio = pieceio.NewPieceIOWithStore(cario.NewCarIO(), fs, bs, pieceio.WithCommPCache(h.commPCache))
+
+	h.publishBatcher = newPublishBatcher(spn.PublishDeals, deals.Send, h.publishWindow, h.maxDealsPerPublish)
 
 	// register a data transfer event handler -- this will send events to the state machines based on DT events
 	dataTransfer.SubscribeToEvents(dtutils.ProviderDataTransferSubscriber(deals))
@@ -138,12 +220,67 @@ func NewProvider(net network.StorageMarketNetwork, ds datastore.Batching, bs blo
 	return h, nil
 }
 
+// Start initializes deal processing on a Provider, restarts deals that did not
+// complete before the provider was last shut down, and sets up the network
+// handlers
 func (p *Provider) Start(ctx context.Context) error {
-	// TODO: restore state
-	err := p.net.SetDelegate(p)
+	err := p.restartDeals()
 	if err != nil {
 		return err
 	}
+	err = p.net.SetDelegate(p)
+	if err != nil {
+		return err
+	}
+	if p.manualApproval {
+		watcherCtx, cancel := context.WithCancel(ctx)
+		p.stopApprovalWatcher = cancel
+		go p.watchPendingDeals(watcherCtx)
+	}
+	return nil
+}
+
+// restartDeals sends a restart event to every deal that has not reached a
+// finality state. The provider state machine decides, based on the state the
+// deal was in, whether to resume processing, fail the deal, or keep waiting
+// for the client
+func (p *Provider) restartDeals() error {
+	var deals []storagemarket.MinerDeal
+	err := p.deals.List(&deals)
+	if err != nil {
+		return xerrors.Errorf("listing deals to restart: %w", err)
+	}
+
+	for _, deal := range deals {
+		if containsState(deal.State, providerstates.ProviderFinalityStates) {
+			continue
+		}
+
+		// deals in the approval queue keep waiting for the operator. Staging
+		// space is only reserved once a deal is approved
+		if deal.State == storagemarket.StorageDealAcceptWait && (deal.PendingApproval || deal.Approved) {
+			err = p.deals.Send(deal.ProposalCid, storagemarket.ProviderEventRestartAwaitingApproval)
+			if err != nil {
+				return xerrors.Errorf("restarting deal %s: %w", deal.ProposalCid, err)
+			}
+			continue
+		}
+
+		// reservations are not persisted, so claim the staging space again
+		// for deals whose data is not staged yet. Deals that fail on restart
+		// release it right away
+		if deal.PiecePath == filestore.Path("") {
+			err = p.fs.Reserve(deal.ProposalCid.String(), uint64(deal.Proposal.PieceSize))
+			if err != nil {
+				log.Warnf("reserving staging space for restarted deal %s: %s", deal.ProposalCid, err)
+			}
+		}
+
+		err = p.deals.Send(deal.ProposalCid, storagemarket.ProviderEventRestart)
+		if err != nil {
+			return xerrors.Errorf("restarting deal %s: %w", deal.ProposalCid, err)
+		}
+	}
 	return nil
 }
 
@@ -190,6 +327,16 @@ func (p *Provider) receiveDeal(s network.StorageDealStream) error {
 }
 
 func (p *Provider) Stop() error {
+	if p.stopApprovalWatcher != nil {
+		p.stopApprovalWatcher()
+	}
+
+	// downloads that are cut short resume when their deals restart
+	p.downloads.stop()
+
+	// publish deals still waiting in a batch before deal processing stops
+	p.publishBatcher.stop(context.TODO())
+
 	err := p.deals.Stop(context.TODO())
 	if err != nil {
 		return err
@@ -197,8 +344,11 @@ func (p *Provider) Stop() error {
 	return p.net.StopHandlingRequests()
 }
 
+// ImportDataForDeal stages the CAR data read from data for an offline deal.
+// The commitment is always computed over the data, rather than looked up in
+// the commP cache, as it is what proves the bytes handed off are the piece
+// the client proposed
 func (p *Provider) ImportDataForDeal(ctx context.Context, propCid cid.Cid, data io.Reader) error {
-	// TODO: be able to check if we have enough disk space
 	var d storagemarket.MinerDeal
 	if err := p.deals.Get(propCid).Get(&d); err != nil {
 		return xerrors.Errorf("failed getting deal %s: %w", propCid, err)
@@ -213,36 +363,255 @@ func (p *Provider) ImportDataForDeal(ctx context.Context, propCid cid.Cid, data
 		_ = p.fs.Delete(tempfi.Path())
 	}
 
-	n, err := io.Copy(tempfi, data)
+	// staging space for the piece was reserved when the deal was accepted,
+	// so refuse to write more data than fits in it
+	n, err := io.Copy(tempfi, io.LimitReader(data, int64(d.Proposal.PieceSize)+1))
 	if err != nil {
 		cleanup()
 		return xerrors.Errorf("importing deal data failed: %w", err)
 	}
 
-	_ = n // TODO: verify n?
+	if n > int64(d.Proposal.PieceSize) {
+		cleanup()
+		return xerrors.Errorf("imported data is larger than the deal piece size %d", d.Proposal.PieceSize)
+	}
+
+	if err := p.verifyDealData(d, tempfi); err != nil {
+		cleanup()
+		return err
+	}
+
+	// the staged data now takes up space on disk itself
+	p.fs.Release(propCid.String())
+
+	return p.deals.Send(propCid, storagemarket.ProviderEventVerifiedData, tempfi.Path(), filestore.Path(""))
+
+}
+
+// ImportDataForDealFromFile hands the CAR file at the given path in the
+// provider's filestore to an offline deal. The file is used in place rather
+// than copied. Once it is imported the deal owns it, and deletes it once the
+// piece is handed off or the deal fails. The file must
+// have the deal payload as its only root, and pad to the piece size in the
+// deal's data ref. When universal retrieval is enabled the location of each
+// block in the file is recorded, like for data transferred over graphsync. As
+// with ImportDataForDeal, the commitment is computed over the file
+func (p *Provider) ImportDataForDealFromFile(ctx context.Context, propCid cid.Cid, path filestore.Path) error {
+	var d storagemarket.MinerDeal
+	if err := p.deals.Get(propCid).Get(&d); err != nil {
+		return xerrors.Errorf("failed getting deal %s: %w", propCid, err)
+	}
+
+	f, err := p.fs.Open(path)
+	if err != nil {
+		return xerrors.Errorf("failed to open deal data file: %w", err)
+	}
+	defer f.Close()
 
-	pieceSize := uint64(tempfi.Size())
+	carSize := uint64(f.Size())
+	if carSize > uint64(d.Proposal.PieceSize) {
+		return xerrors.Errorf("deal data file is larger than the deal piece size %d", d.Proposal.PieceSize)
+	}
 
-	_, err = tempfi.Seek(0, io.SeekStart)
+	metadataPath, err := p.readDealCar(d, f)
 	if err != nil {
+		return err
+	}
+	cleanup := func() {
+		if metadataPath != filestore.Path("") {
+			_ = p.fs.Delete(metadataPath)
+		}
+	}
+
+	if d.Ref.PieceSize != 0 {
+		if pieceSize := padreader.PaddedSize(carSize); pieceSize != d.Ref.PieceSize {
+			cleanup()
+			return xerrors.Errorf("CAR file of %d bytes pads to piece size %d, but the deal data ref has piece size %d", carSize, pieceSize, d.Ref.PieceSize)
+		}
+	}
+
+	if err := p.verifyDealData(d, f); err != nil {
 		cleanup()
+		return err
+	}
+
+	// the file is already on disk, so no staging space is needed for it
+	p.fs.Release(propCid.String())
+
+	return p.deals.Send(propCid, storagemarket.ProviderEventVerifiedData, path, metadataPath)
+}
+
+// readDealCar checks that the CAR file written to f holds the payload of the
+// given deal. When universal retrieval is enabled it also records the location
+// of each block in the file, and returns the path of the recorded metadata
+func (p *Provider) readDealCar(d storagemarket.MinerDeal, f filestore.File) (filestore.Path, error) {
+	var header *car.CarHeader
+	var metadataPath filestore.Path
+	if p.universalRetrievalEnabled {
+		metadataFile, err := p.fs.CreateTemp()
+		if err != nil {
+			return "", xerrors.Errorf("failed to create block metadata file: %w", err)
+		}
+		header, err = blockrecorder.ReadCarBlocks(f, blockrecorder.RecordEachBlockTo(metadataFile))
+		_ = metadataFile.Close()
+		if err != nil {
+			_ = p.fs.Delete(metadataFile.Path())
+			return "", xerrors.Errorf("failed to read deal data file: %w", err)
+		}
+		metadataPath = metadataFile.Path()
+	} else {
+		var err error
+		header, err = car.ReadHeader(bufio.NewReader(f))
+		if err != nil {
+			return "", xerrors.Errorf("failed to read deal data file header: %w", err)
+		}
+	}
+
+	if len(header.Roots) != 1 || !header.Roots[0].Equals(d.Ref.Root) {
+		if metadataPath != filestore.Path("") {
+			_ = p.fs.Delete(metadataPath)
+		}
+		return "", xerrors.Errorf("CAR file roots %s do not match deal payload root %s", header.Roots, d.Ref.Root)
+	}
+	return metadataPath, nil
+}
+
+// verifyDealData checks that the CAR file written to f holds the data for the
+// given deal, by comparing its commP with the one in the deal proposal
+func (p *Provider) verifyDealData(d storagemarket.MinerDeal, f filestore.File) error {
+	pieceSize := uint64(f.Size())
+
+	_, err := f.Seek(0, io.SeekStart)
+	if err != nil {
 		return xerrors.Errorf("failed to seek through temp imported file: %w", err)
 	}
 
-	pieceCid, _, err := pieceio.GeneratePieceCommitment(p.proofType, tempfi, pieceSize)
+	pieceCid, _, err := pieceio.GeneratePieceCommitment(p.proofType, f, pieceSize)
 	if err != nil {
-		cleanup()
 		return xerrors.Errorf("failed to generate commP")
 	}
 
 	// Verify CommP matches
 	if !pieceCid.Equals(d.Proposal.PieceCID) {
-		cleanup()
 		return xerrors.Errorf("given data does not match expected commP (got: %x, expected %x)", pieceCid, d.Proposal.PieceCID)
 	}
 
-	return p.deals.Send(propCid, storagemarket.ProviderEventVerifiedData, tempfi.Path(), filestore.Path(""))
+	return nil
+}
+
+// ListPendingDeals lists the deals waiting for the operator to approve or reject them
+func (p *Provider) ListPendingDeals() ([]storagemarket.MinerDeal, error) {
+	var deals []storagemarket.MinerDeal
+	if err := p.deals.List(&deals); err != nil {
+		return nil, err
+	}
+
+	var pending []storagemarket.MinerDeal
+	for _, deal := range deals {
+		if deal.State == storagemarket.StorageDealAcceptWait && deal.PendingApproval {
+			pending = append(pending, deal)
+		}
+	}
+	return pending, nil
+}
 
+// ApproveDeal accepts a deal waiting for operator approval
+func (p *Provider) ApproveDeal(proposalCid cid.Cid) error {
+	// the event is processed before returning so that an error is returned
+	// when the deal is not awaiting approval
+	err := p.deals.SendSync(context.TODO(), proposalCid, storagemarket.ProviderEventDealApproved)
+	if err != nil {
+		return xerrors.Errorf("approving deal %s: %w", proposalCid, err)
+	}
+	return nil
+}
+
+// RejectDeal rejects a deal waiting for operator approval, giving the client the reason
+func (p *Provider) RejectDeal(proposalCid cid.Cid, reason string) error {
+	err := p.deals.SendSync(context.TODO(), proposalCid, storagemarket.ProviderEventApprovalRejected, xerrors.New(reason))
+	if err != nil {
+		return xerrors.Errorf("rejecting deal %s: %w", proposalCid, err)
+	}
+	return nil
+}
+
+// watchPendingDeals periodically rejects pending deals that can no longer be
+// approved in time for the provider to accept them
+func (p *Provider) watchPendingDeals(ctx context.Context) {
+	ticker := time.NewTicker(p.approvalCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.rejectExpiredPendingDeals(ctx); err != nil {
+				log.Errorf("rejecting expired pending deals: %s", err)
+			}
+		}
+	}
+}
+
+func (p *Provider) rejectExpiredPendingDeals(ctx context.Context) error {
+	pending, err := p.ListPendingDeals()
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	_, height, err := p.spn.GetChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	for _, deal := range pending {
+		deadline := deal.Proposal.StartEpoch - p.dealAcceptanceBuffer - p.approvalRejectBuffer
+		if height < deadline {
+			continue
+		}
+		err := p.deals.Send(deal.ProposalCid, storagemarket.ProviderEventApprovalRejected,
+			xerrors.Errorf("operator did not approve deal before epoch %d", deadline))
+		if err != nil {
+			log.Errorf("rejecting expired pending deal %s: %s", deal.ProposalCid, err)
+		}
+	}
+	return nil
+}
+
+// CancelDeal aborts a deal that has not yet been queued for publishing. It
+// fails the deal, which deletes its staged data and tells the client why if
+// the deal stream is still open, then closes any data transfer for the deal
+func (p *Provider) CancelDeal(ctx context.Context, proposalCid cid.Cid, reason string) error {
+	var deal storagemarket.MinerDeal
+	if err := p.deals.Get(proposalCid).Get(&deal); err != nil {
+		return xerrors.Errorf("failed getting deal %s: %w", proposalCid, err)
+	}
+	return p.cancelDeal(ctx, deal, storagemarket.ProviderEventDealCancelled, reason)
+}
+
+// cancelDeal sends the deal a cancellation event, which the deal state machine
+// only applies while the deal can still be cancelled. The data transfer is
+// only torn down once the deal has moved on, so that its failure is not taken
+// for a transfer error
+func (p *Provider) cancelDeal(ctx context.Context, deal storagemarket.MinerDeal, evt storagemarket.ProviderEvent, args ...interface{}) error {
+	if err := p.deals.SendSync(ctx, deal.ProposalCid, evt, args...); err != nil {
+		return xerrors.Errorf("cannot cancel deal %s in state %s: %w", deal.ProposalCid, storagemarket.DealStates[deal.State], err)
+	}
+
+	for _, chid := range dtutils.DealChannels(p.dataTransfer.InProgressChannels(), deal.ProposalCid) {
+		p.dataTransfer.CloseDataTransferChannel(chid)
+	}
+	p.downloads.cancelDownload(deal.ProposalCid)
+	return nil
+}
+
+// StagingUsage reports the space reserved for, used by, and still available to
+// deal data staged in the provider's filestore
+func (p *Provider) StagingUsage() (filestore.Usage, error) {
+	return p.fs.Usage()
 }
 
 func (p *Provider) ListAsks(addr address.Address) []*storagemarket.SignedStorageAsk {
@@ -327,6 +696,125 @@ func (p *Provider) HandleAskStream(s network.StorageAskStream) {
 	}
 }
 
+// HandleDealStatusStream answers a client's query for the provider's view of a deal
+func (p *Provider) HandleDealStatusStream(s network.DealStatusStream) {
+	ctx := context.TODO()
+	defer s.Close()
+	request, err := s.ReadDealStatusRequest()
+	if err != nil {
+		log.Errorf("failed to read DealStatusRequest from incoming stream: %s", err)
+		return
+	}
+
+	tok, epoch, err := p.spn.GetChainHead(ctx)
+	if err != nil {
+		log.Errorf("failed to get chain head: %s", err)
+		return
+	}
+
+	dealState, err := p.processDealStatusRequest(ctx, request, tok, epoch)
+	if err != nil {
+		log.Errorf("failed to process deal status request: %s", err)
+		return
+	}
+
+	signature, err := providerutils.SignMinerData(ctx, dealState, p.actor, tok, p.spn.GetMinerWorkerAddress, p.spn.SignBytes)
+	if err != nil {
+		log.Errorf("failed to sign deal status response: %s", err)
+		return
+	}
+
+	resp := network.DealStatusResponse{
+		DealState: *dealState,
+		Signature: *signature,
+	}
+
+	if err := s.WriteDealStatusResponse(resp); err != nil {
+		log.Errorf("failed to write deal status response: %s", err)
+		return
+	}
+}
+
+// HandleDealCancellationStream cancels a deal at the request of its client
+func (p *Provider) HandleDealCancellationStream(s network.DealCancellationStream) {
+	ctx := context.TODO()
+	defer s.Close()
+	cancellation, err := s.ReadDealCancellation()
+	if err != nil {
+		log.Errorf("failed to read DealCancellation from incoming stream: %s", err)
+		return
+	}
+
+	if err := p.processDealCancellation(ctx, cancellation); err != nil {
+		log.Errorf("failed to cancel deal %s for client: %s", cancellation.Proposal, err)
+	}
+}
+
+func (p *Provider) processDealCancellation(ctx context.Context, cancellation network.DealCancellation) error {
+	var md storagemarket.MinerDeal
+	if err := p.deals.Get(cancellation.Proposal).Get(&md); err != nil {
+		return xerrors.Errorf("proposal cid not found: %w", err)
+	}
+
+	tok, epoch, err := p.spn.GetChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	// only the client of a deal may cancel it
+	msg, err := cancellation.SigningBytes()
+	if err != nil {
+		return xerrors.Errorf("serializing deal cancellation: %w", err)
+	}
+	if err := p.verifyClientMessage(ctx, md, cancellation.Signature, msg, cancellation.Epoch, tok, epoch); err != nil {
+		return err
+	}
+
+	return p.cancelDeal(ctx, md, storagemarket.ProviderEventClientCancelled)
+}
+
+func (p *Provider) processDealStatusRequest(ctx context.Context, request network.DealStatusRequest, tok shared.TipSetToken, epoch abi.ChainEpoch) (*storagemarket.ProviderDealState, error) {
+	var md storagemarket.MinerDeal
+	if err := p.deals.Get(request.Proposal).Get(&md); err != nil {
+		return nil, xerrors.Errorf("proposal cid not found: %w", err)
+	}
+
+	// only the client of a deal may query its status
+	msg, err := request.SigningBytes()
+	if err != nil {
+		return nil, xerrors.Errorf("serializing deal status request: %w", err)
+	}
+	if err := p.verifyClientMessage(ctx, md, request.Signature, msg, request.Epoch, tok, epoch); err != nil {
+		return nil, err
+	}
+
+	return &storagemarket.ProviderDealState{
+		State:       md.State,
+		Message:     md.Message,
+		Proposal:    &md.Proposal,
+		ProposalCid: &md.ProposalCid,
+		PublishCid:  md.PublishCid,
+		DealID:      md.DealID,
+	}, nil
+}
+
+// verifyClientMessage checks that a message about a deal was signed by the
+// client of the deal, at an epoch close enough to the current one
+func (p *Provider) verifyClientMessage(ctx context.Context, md storagemarket.MinerDeal, signature crypto.Signature, msg []byte, msgEpoch abi.ChainEpoch, tok shared.TipSetToken, epoch abi.ChainEpoch) error {
+	if msgEpoch < epoch-clientMessageEpochTolerance || msgEpoch > epoch+clientMessageEpochTolerance {
+		return xerrors.Errorf("message signed at epoch %d, too far from current epoch %d", msgEpoch, epoch)
+	}
+
+	verified, err := p.spn.VerifySignature(ctx, signature, md.Proposal.Client, msg, tok)
+	if err != nil {
+		return xerrors.Errorf("verifying signature: %w", err)
+	}
+	if !verified {
+		return xerrors.New("could not verify signature")
+	}
+	return nil
+}
+
 func (p *Provider) Configure(options ...StorageProviderOption) {
 	for _, option := range options {
 		option(p)
@@ -410,6 +898,68 @@ func (p *providerDealEnvironment) StartDataTransfer(ctx context.Context, to peer
 	return err
 }
 
+func (p *providerDealEnvironment) StartDownload(deal storagemarket.MinerDeal) {
+	p.p.downloads.start(deal.ProposalCid, func(ctx context.Context) {
+		p.p.download(ctx, deal)
+	})
+}
+
+// download fetches the data for a TTHTTP deal, and sends the deal on once the
+// data is verified. When the deal is cancelled the partial file is deleted,
+// but when the provider stops it is kept, so the download resumes from it when
+// the deal restarts
+func (p *Provider) download(ctx context.Context, deal storagemarket.MinerDeal) {
+	// the file is named after the deal, so that a download interrupted by a
+	// restart resumes from the data already written
+	path := filestore.Path("deal-" + deal.ProposalCid.String() + ".car")
+
+	err := p.downloadData(ctx, deal, path)
+	if ctx.Err() != nil {
+		if !p.downloads.stopping() {
+			_ = p.fs.Delete(path)
+		}
+		return
+	}
+	if err != nil {
+		_ = p.fs.Delete(path)
+		err = p.deals.Send(deal.ProposalCid, storagemarket.ProviderEventDataTransferFailed, xerrors.Errorf("downloading data: %w", err))
+		if err != nil {
+			log.Errorf("failing deal %s: %s", deal.ProposalCid, err)
+		}
+		return
+	}
+
+	p.fs.Release(deal.ProposalCid.String())
+	// the deal may have been cancelled while the download was finishing, in
+	// which case nothing else deletes the file
+	err = p.deals.SendSync(context.TODO(), deal.ProposalCid, storagemarket.ProviderEventVerifiedData, path, filestore.Path(""))
+	if err != nil {
+		_ = p.fs.Delete(path)
+		log.Errorf("handing off downloaded data for deal %s: %s", deal.ProposalCid, err)
+	}
+}
+
+func (p *Provider) downloadData(ctx context.Context, deal storagemarket.MinerDeal, path filestore.Path) error {
+	headers := make(http.Header, len(deal.Ref.Headers))
+	for _, header := range deal.Ref.Headers {
+		headers.Add(header.Name, header.Value)
+	}
+
+	downloader := httptransfer.NewDownloader(p.httpClient, p.fs)
+	err := downloader.Download(ctx, deal.Ref.URL, headers, path, uint64(deal.Proposal.PieceSize))
+	if err != nil {
+		return err
+	}
+
+	f, err := p.fs.Open(path)
+	if err != nil {
+		return xerrors.Errorf("opening downloaded file: %w", err)
+	}
+	defer f.Close()
+
+	return p.verifyDealData(deal, f)
+}
+
 func (p *providerDealEnvironment) GeneratePieceCommitmentToFile(payloadCid cid.Cid, selector ipld.Node) (cid.Cid, filestore.Path, filestore.Path, error) {
 	if p.p.universalRetrievalEnabled {
 		return providerutils.GeneratePieceCommitmentWithMetadata(p.p.fs, p.p.pio.GeneratePieceCommitmentToFile, p.p.proofType, payloadCid, selector)
@@ -473,6 +1023,10 @@ func (p *providerDealEnvironment) Disconnect(proposalCid cid.Cid) error {
 	return p.p.conns.Disconnect(proposalCid)
 }
 
+func (p *providerDealEnvironment) QueueDealForPublish(deal storagemarket.MinerDeal) error {
+	return p.p.publishBatcher.add(deal)
+}
+
 func (p *providerDealEnvironment) DealAcceptanceBuffer() abi.ChainEpoch {
 	return p.p.dealAcceptanceBuffer
 }
@@ -484,4 +1038,18 @@ func (p *providerDealEnvironment) RunCustomDecisionLogic(ctx context.Context, de
 	return p.p.customDealDeciderFunc(ctx, deal)
 }
 
+func (p *providerDealEnvironment) ManualApprovalRequired() bool {
+	return p.p.manualApproval
+}
+
 var _ providerstates.ProviderDealEnvironment = &providerDealEnvironment{}
+
+// containsState returns true if st is one of the given states
+func containsState(st storagemarket.StorageDealStatus, states []fsm.StateKey) bool {
+	for _, s := range states {
+		if s == st {
+			return true
+		}
+	}
+	return false
+}
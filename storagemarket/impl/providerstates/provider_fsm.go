@@ -26,10 +26,45 @@ var ProviderEvents = fsm.Events{
 		}),
 	fsm.Event(storagemarket.ProviderEventDealDeciding).
 		From(storagemarket.StorageDealValidating).To(storagemarket.StorageDealAcceptWait),
+	fsm.Event(storagemarket.ProviderEventAwaitingApproval).
+		From(storagemarket.StorageDealAcceptWait).ToNoChange().
+		Action(func(deal *storagemarket.MinerDeal) error {
+			deal.PendingApproval = true
+			return nil
+		}),
+	fsm.Event(storagemarket.ProviderEventDealApproved).
+		From(storagemarket.StorageDealAcceptWait).ToNoChange().
+		Action(func(deal *storagemarket.MinerDeal) error {
+			// the check is made here, where events for the deal are applied
+			// one at a time, so that a deal can only be approved once
+			if !deal.PendingApproval {
+				return xerrors.New("deal is not awaiting approval")
+			}
+			deal.PendingApproval = false
+			deal.Approved = true
+			return nil
+		}),
+	fsm.Event(storagemarket.ProviderEventApprovalRejected).
+		From(storagemarket.StorageDealAcceptWait).To(storagemarket.StorageDealFailing).
+		Action(func(deal *storagemarket.MinerDeal, err error) error {
+			if !deal.PendingApproval {
+				return xerrors.New("deal is not awaiting approval")
+			}
+			deal.PendingApproval = false
+			deal.Message = xerrors.Errorf("deal rejected: %w", err).Error()
+			return nil
+		}),
 	fsm.Event(storagemarket.ProviderEventDataRequested).
-		From(storagemarket.StorageDealAcceptWait).To(storagemarket.StorageDealWaitingForData),
+		From(storagemarket.StorageDealAcceptWait).To(storagemarket.StorageDealWaitingForData).
+		Action(func(deal *storagemarket.MinerDeal) error {
+			// the deal stream is closed once an offline or HTTP deal is accepted
+			if deal.Ref.TransferType == storagemarket.TTManual || deal.Ref.TransferType == storagemarket.TTHTTP {
+				deal.ConnectionClosed = true
+			}
+			return nil
+		}),
 	fsm.Event(storagemarket.ProviderEventDataTransferFailed).
-		From(storagemarket.StorageDealTransferring).To(storagemarket.StorageDealFailing).
+		FromMany(storagemarket.StorageDealWaitingForData, storagemarket.StorageDealTransferring).To(storagemarket.StorageDealFailing).
 		Action(func(deal *storagemarket.MinerDeal, err error) error {
 			deal.Message = xerrors.Errorf("error transferring data: %w", err).Error()
 			return nil
@@ -61,8 +96,9 @@ var ProviderEvents = fsm.Events{
 		FromMany(storagemarket.StorageDealProviderFunding, storagemarket.StorageDealEnsureProviderFunds).To(storagemarket.StorageDealPublish),
 	fsm.Event(storagemarket.ProviderEventDealPublishInitiated).
 		From(storagemarket.StorageDealPublish).To(storagemarket.StorageDealPublishing).
-		Action(func(deal *storagemarket.MinerDeal, publishCid cid.Cid) error {
+		Action(func(deal *storagemarket.MinerDeal, publishCid cid.Cid, publishIndex uint64) error {
 			deal.PublishCid = &publishCid
+			deal.PublishIndex = publishIndex
 			return nil
 		}),
 	fsm.Event(storagemarket.ProviderEventDealPublishError).
@@ -85,7 +121,7 @@ var ProviderEvents = fsm.Events{
 			return nil
 		}),
 	fsm.Event(storagemarket.ProviderEventFileStoreErrored).
-		FromMany(storagemarket.StorageDealStaged, storagemarket.StorageDealSealing, storagemarket.StorageDealActive).To(storagemarket.StorageDealFailing).
+		FromMany(storagemarket.StorageDealAcceptWait, storagemarket.StorageDealStaged, storagemarket.StorageDealSealing, storagemarket.StorageDealActive).To(storagemarket.StorageDealFailing).
 		Action(func(deal *storagemarket.MinerDeal, err error) error {
 			deal.Message = xerrors.Errorf("accessing file store: %w", err).Error()
 			return nil
@@ -120,12 +156,73 @@ var ProviderEvents = fsm.Events{
 			return nil
 		}),
 	fsm.Event(storagemarket.ProviderEventFailed).From(storagemarket.StorageDealFailing).To(storagemarket.StorageDealError),
+	fsm.Event(storagemarket.ProviderEventRestart).
+		FromMany(storagemarket.StorageDealUnknown, storagemarket.StorageDealValidating, storagemarket.StorageDealAcceptWait,
+			storagemarket.StorageDealTransferring).To(storagemarket.StorageDealFailing).
+		FromMany(storagemarket.StorageDealWaitingForData, storagemarket.StorageDealVerifyData,
+			storagemarket.StorageDealEnsureProviderFunds, storagemarket.StorageDealProviderFunding,
+			storagemarket.StorageDealPublish, storagemarket.StorageDealPublishing, storagemarket.StorageDealStaged,
+			storagemarket.StorageDealSealing, storagemarket.StorageDealActive, storagemarket.StorageDealFailing).ToNoChange().
+		Action(func(deal *storagemarket.MinerDeal) error {
+			// the deal stream does not survive a restart, so there is no way
+			// left to send the client a response
+			deal.ConnectionClosed = true
+			switch deal.State {
+			case storagemarket.StorageDealUnknown, storagemarket.StorageDealValidating, storagemarket.StorageDealAcceptWait:
+				deal.Message = "provider restarted before deal was accepted"
+			case storagemarket.StorageDealTransferring:
+				deal.Message = "provider restarted during data transfer"
+			}
+			return nil
+		}),
+	fsm.Event(storagemarket.ProviderEventDealCancelled).
+		FromMany(ProviderCancellableStates...).To(storagemarket.StorageDealFailing).
+		Action(func(deal *storagemarket.MinerDeal, reason string) error {
+			deal.Message = xerrors.Errorf("deal cancelled by provider: %s", reason).Error()
+			return nil
+		}),
+	fsm.Event(storagemarket.ProviderEventClientCancelled).
+		FromMany(ProviderCancellableStates...).To(storagemarket.StorageDealFailing).
+		Action(func(deal *storagemarket.MinerDeal) error {
+			// the client closes the deal stream when it cancels
+			deal.ConnectionClosed = true
+			deal.Message = "deal cancelled by client"
+			return nil
+		}),
+	fsm.Event(storagemarket.ProviderEventRestartAwaitingApproval).
+		From(storagemarket.StorageDealAcceptWait).ToNoChange().
+		Action(func(deal *storagemarket.MinerDeal) error {
+			// the decision is stored in the deal, so it survives the restart,
+			// but the deal stream does not
+			deal.ConnectionClosed = true
+			return nil
+		}),
+}
+
+// ProviderFinalityStates are the states that terminate deal processing for a deal.
+// When a provider restarts, it restarts only deals that are not in a finality state.
+var ProviderFinalityStates = []fsm.StateKey{
+	storagemarket.StorageDealError,
+	storagemarket.StorageDealCompleted,
+}
+
+// ProviderCancellableStates are the states from which a provider can cancel a
+// deal. Once a deal is queued for publishing it can no longer be cancelled.
+var ProviderCancellableStates = []fsm.StateKey{
+	storagemarket.StorageDealValidating,
+	storagemarket.StorageDealAcceptWait,
+	storagemarket.StorageDealWaitingForData,
+	storagemarket.StorageDealTransferring,
+	storagemarket.StorageDealVerifyData,
+	storagemarket.StorageDealEnsureProviderFunds,
+	storagemarket.StorageDealProviderFunding,
 }
 
 // ProviderStateEntryFuncs are the handlers for different states in a storage client
 var ProviderStateEntryFuncs = fsm.StateEntryFuncs{
 	storagemarket.StorageDealValidating:          ValidateDealProposal,
 	storagemarket.StorageDealAcceptWait:          DecideOnProposal,
+	storagemarket.StorageDealWaitingForData:      WaitForData,
 	storagemarket.StorageDealVerifyData:          VerifyData,
 	storagemarket.StorageDealEnsureProviderFunds: EnsureProviderFunds,
 	storagemarket.StorageDealProviderFunding:     WaitForFunding,
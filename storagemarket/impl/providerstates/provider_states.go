@@ -10,7 +10,6 @@ import (
 	"github.com/filecoin-project/go-padreader"
 	"github.com/filecoin-project/go-statemachine/fsm"
 	"github.com/filecoin-project/specs-actors/actors/abi"
-	"github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/filecoin-project/specs-actors/actors/builtin/market"
 	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
 	"github.com/ipfs/go-cid"
@@ -23,7 +22,9 @@ import (
 	"github.com/filecoin-project/go-fil-markets/piecestore"
 	"github.com/filecoin-project/go-fil-markets/shared"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/httptransfer"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/providerutils"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/requestvalidation"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/network"
 )
 
@@ -36,14 +37,17 @@ type ProviderDealEnvironment interface {
 	Node() storagemarket.StorageProviderNode
 	Ask() storagemarket.StorageAsk
 	StartDataTransfer(ctx context.Context, to peer.ID, voucher datatransfer.Voucher, baseCid cid.Cid, selector ipld.Node) error
+	StartDownload(deal storagemarket.MinerDeal)
 	GeneratePieceCommitmentToFile(payloadCid cid.Cid, selector ipld.Node) (cid.Cid, filestore.Path, filestore.Path, error)
 	SendSignedResponse(ctx context.Context, response *network.Response) error
 	TagConnection(proposalCid cid.Cid) error
 	Disconnect(proposalCid cid.Cid) error
+	QueueDealForPublish(deal storagemarket.MinerDeal) error
 	FileStore() filestore.FileStore
 	PieceStore() piecestore.PieceStore
 	DealAcceptanceBuffer() abi.ChainEpoch
 	RunCustomDecisionLogic(context.Context, storagemarket.MinerDeal) (bool, string, error)
+	ManualApprovalRequired() bool
 }
 
 // ProviderStateEntryFunc is the signature for a StateEntryFunc in the provider FSM
@@ -73,22 +77,46 @@ func ValidateDealProposal(ctx fsm.Context, environment ProviderDealEnvironment,
 		return ctx.Trigger(storagemarket.ProviderEventDealRejected, xerrors.Errorf("deal start epoch is too soon or deal already expired"))
 	}
 
-	// TODO: check StorageCollateral
+	ask := environment.Ask()
+	if ask.Price.Nil() {
+		return ctx.Trigger(storagemarket.ProviderEventDealRejected, xerrors.Errorf("provider has no storage ask"))
+	}
+
+	if ask.Expiry <= height {
+		return ctx.Trigger(storagemarket.ProviderEventDealRejected, xerrors.Errorf("storage ask expired at epoch %d", ask.Expiry))
+	}
 
-	minPrice := big.Div(big.Mul(environment.Ask().Price, abi.NewTokenAmount(int64(deal.Proposal.PieceSize))), abi.NewTokenAmount(1<<30))
+	minCollateral, maxCollateral := storagemarket.ProviderCollateralBounds(ask, deal.Proposal.PieceSize)
+	if deal.Proposal.ProviderCollateral.LessThan(minCollateral) {
+		return ctx.Trigger(storagemarket.ProviderEventDealRejected,
+			xerrors.Errorf("proposed provider collateral below minimum: %s < %s", deal.Proposal.ProviderCollateral, minCollateral))
+	}
+
+	if !maxCollateral.Nil() && deal.Proposal.ProviderCollateral.GreaterThan(maxCollateral) {
+		return ctx.Trigger(storagemarket.ProviderEventDealRejected,
+			xerrors.Errorf("proposed provider collateral above maximum: %s > %s", deal.Proposal.ProviderCollateral, maxCollateral))
+	}
+
+	minPrice := storagemarket.DealPricePerEpoch(ask, deal.Proposal.PieceSize, deal.Proposal.Duration())
 	if deal.Proposal.StoragePricePerEpoch.LessThan(minPrice) {
 		return ctx.Trigger(storagemarket.ProviderEventDealRejected,
 			xerrors.Errorf("storage price per epoch less than asking price: %s < %s", deal.Proposal.StoragePricePerEpoch, minPrice))
 	}
 
-	if deal.Proposal.PieceSize < environment.Ask().MinPieceSize {
+	if deal.Proposal.PieceSize < ask.MinPieceSize {
 		return ctx.Trigger(storagemarket.ProviderEventDealRejected,
-			xerrors.Errorf("piece size less than minimum required size: %d < %d", deal.Proposal.PieceSize, environment.Ask().MinPieceSize))
+			xerrors.Errorf("piece size less than minimum required size: %d < %d", deal.Proposal.PieceSize, ask.MinPieceSize))
 	}
 
-	if deal.Proposal.PieceSize > environment.Ask().MaxPieceSize {
+	if deal.Proposal.PieceSize > ask.MaxPieceSize {
 		return ctx.Trigger(storagemarket.ProviderEventDealRejected,
-			xerrors.Errorf("piece size more than maximum allowed size: %d > %d", deal.Proposal.PieceSize, environment.Ask().MaxPieceSize))
+			xerrors.Errorf("piece size more than maximum allowed size: %d > %d", deal.Proposal.PieceSize, ask.MaxPieceSize))
+	}
+
+	if deal.Ref.TransferType == storagemarket.TTHTTP {
+		if err := httptransfer.ValidateURL(deal.Ref.URL); err != nil {
+			return ctx.Trigger(storagemarket.ProviderEventDealRejected, xerrors.Errorf("invalid data url: %w", err))
+		}
 	}
 
 	// check market funds
@@ -114,28 +142,97 @@ func ValidateDealProposal(ctx fsm.Context, environment ProviderDealEnvironment,
 // DecideOnProposal allows custom decision logic to run before accepting a deal, such as allowing a manual
 // operator to decide whether or not to accept the deal
 func DecideOnProposal(ctx fsm.Context, environment ProviderDealEnvironment, deal storagemarket.MinerDeal) error {
-	accept, reason, err := environment.RunCustomDecisionLogic(ctx.Context(), deal)
-	if err != nil {
-		return ctx.Trigger(storagemarket.ProviderEventNodeErrored, xerrors.Errorf("custom deal decision logic failed: %w", err))
+	// the deal stays parked until the operator approves or rejects it
+	if deal.PendingApproval {
+		return nil
 	}
 
-	if !accept {
-		return ctx.Trigger(storagemarket.ProviderEventDealRejected, fmt.Errorf(reason))
+	if !deal.Approved {
+		accept, reason, err := environment.RunCustomDecisionLogic(ctx.Context(), deal)
+		if err != nil {
+			return ctx.Trigger(storagemarket.ProviderEventNodeErrored, xerrors.Errorf("custom deal decision logic failed: %w", err))
+		}
+
+		if !accept {
+			return ctx.Trigger(storagemarket.ProviderEventDealRejected, fmt.Errorf(reason))
+		}
+
+		if environment.ManualApprovalRequired() {
+			return ctx.Trigger(storagemarket.ProviderEventAwaitingApproval)
+		}
 	}
 
-	// Send intent to accept
-	err = environment.SendSignedResponse(ctx.Context(), &network.Response{
-		State:    storagemarket.StorageDealWaitingForData,
-		Proposal: deal.ProposalCid,
-	})
+	// a graphsync client only starts the transfer once it gets the response
+	// on the deal stream, so a deal approved after a restart would wait for
+	// data forever
+	if deal.ConnectionClosed && deal.Ref.TransferType == storagemarket.TTGraphsync {
+		return ctx.Trigger(storagemarket.ProviderEventDealRejected, xerrors.New("provider restarted before the client was told the deal was accepted"))
+	}
 
+	// make sure there is room to stage the deal data before accepting it
+	err := environment.FileStore().Reserve(deal.ProposalCid.String(), uint64(deal.Proposal.PieceSize))
 	if err != nil {
-		return ctx.Trigger(storagemarket.ProviderEventSendResponseFailed, err)
+		if xerrors.Is(err, filestore.ErrNotEnoughSpace) {
+			return ctx.Trigger(storagemarket.ProviderEventDealRejected, xerrors.Errorf("insufficient staging space: %w", err))
+		}
+		return ctx.Trigger(storagemarket.ProviderEventFileStoreErrored, xerrors.Errorf("reserving staging space: %w", err))
+	}
+
+	// an offline or HTTP deal approved after a restart has no stream left to
+	// respond on, so the client learns of the acceptance through the deal
+	// status protocol
+	if !deal.ConnectionClosed {
+		// Send intent to accept
+		err = environment.SendSignedResponse(ctx.Context(), &network.Response{
+			State:    storagemarket.StorageDealWaitingForData,
+			Proposal: deal.ProposalCid,
+		})
+
+		if err != nil {
+			environment.FileStore().Release(deal.ProposalCid.String())
+			return ctx.Trigger(storagemarket.ProviderEventSendResponseFailed, err)
+		}
+
+		// clients poll the deal status protocol for offline and HTTP deals
+		// rather than holding the deal stream open until the data is imported
+		if deal.Ref.TransferType == storagemarket.TTManual || deal.Ref.TransferType == storagemarket.TTHTTP {
+			if err := environment.Disconnect(deal.ProposalCid); err != nil {
+				log.Warnf("closing client connection: %+v", err)
+			}
+		}
 	}
 
 	return ctx.Trigger(storagemarket.ProviderEventDataRequested)
 }
 
+// WaitForData fetches the deal data when the client asked the provider to
+// get it, by opening a channel to pull the data from the client or by
+// downloading it over HTTP. Otherwise the client pushes the data or it is
+// imported manually, so there is nothing to do
+func WaitForData(ctx fsm.Context, environment ProviderDealEnvironment, deal storagemarket.MinerDeal) error {
+	switch deal.Ref.TransferType {
+	case storagemarket.TTProviderPull:
+		// the pull completes asynchronously and data transfer events move the
+		// deal on from here
+		err := environment.StartDataTransfer(ctx.Context(),
+			deal.Client,
+			&requestvalidation.StorageDataTransferVoucher{Proposal: deal.ProposalCid},
+			deal.Ref.Root,
+			shared.AllSelector(),
+		)
+		if err != nil {
+			return ctx.Trigger(storagemarket.ProviderEventDataTransferFailed, xerrors.Errorf("failed to open pull data channel: %w", err))
+		}
+	case storagemarket.TTHTTP:
+		// the download runs in the background and moves the deal on once the
+		// data is verified. The deal waits for data until then, so that a
+		// download interrupted by a restart resumes when the deal restarts
+		environment.StartDownload(deal)
+	}
+
+	return nil
+}
+
 // VerifyData verifies that data received for a deal matches the pieceCID
 // in the proposal
 func VerifyData(ctx fsm.Context, environment ProviderDealEnvironment, deal storagemarket.MinerDeal) error {
@@ -150,6 +247,10 @@ func VerifyData(ctx fsm.Context, environment ProviderDealEnvironment, deal stora
 		return ctx.Trigger(storagemarket.ProviderEventDealRejected, xerrors.Errorf("proposal CommP doesn't match calculated CommP"))
 	}
 
+	// the staged piece now takes up space on disk itself, so keeping the
+	// reservation would count it twice
+	environment.FileStore().Release(deal.ProposalCid.String())
+
 	return ctx.Trigger(storagemarket.ProviderEventVerifiedData, piecePath, metadataPath)
 }
 
@@ -194,7 +295,7 @@ func WaitForFunding(ctx fsm.Context, environment ProviderDealEnvironment, deal s
 	})
 }
 
-// PublishDeal sends a message to publish a deal on chain
+// PublishDeal queues a deal to be published on chain
 func PublishDeal(ctx fsm.Context, environment ProviderDealEnvironment, deal storagemarket.MinerDeal) error {
 	smDeal := storagemarket.MinerDeal{
 		Client:             deal.Client,
@@ -204,12 +305,14 @@ func PublishDeal(ctx fsm.Context, environment ProviderDealEnvironment, deal stor
 		Ref:                deal.Ref,
 	}
 
-	mcid, err := environment.Node().PublishDeals(ctx.Context(), smDeal)
+	// the deal is published together with other deals in a batch, which
+	// triggers ProviderEventDealPublishInitiated once the message is sent
+	err := environment.QueueDealForPublish(smDeal)
 	if err != nil {
 		return ctx.Trigger(storagemarket.ProviderEventNodeErrored, xerrors.Errorf("publishing deal: %w", err))
 	}
 
-	return ctx.Trigger(storagemarket.ProviderEventDealPublishInitiated, mcid)
+	return nil
 }
 
 // WaitForPublish waits for the publish message on chain and sends the deal id back to the client
@@ -227,21 +330,29 @@ func WaitForPublish(ctx fsm.Context, environment ProviderDealEnvironment, deal s
 			return ctx.Trigger(storagemarket.ProviderEventDealPublishError, xerrors.Errorf("PublishStorageDeals error unmarshalling result: %w", err))
 		}
 
-		err = environment.SendSignedResponse(ctx.Context(), &network.Response{
-			State:          storagemarket.StorageDealProposalAccepted,
-			Proposal:       deal.ProposalCid,
-			PublishMessage: deal.PublishCid,
-		})
-
-		if err != nil {
-			return ctx.Trigger(storagemarket.ProviderEventSendResponseFailed, err)
+		if deal.PublishIndex >= uint64(len(retval.IDs)) {
+			return ctx.Trigger(storagemarket.ProviderEventDealPublishError, xerrors.Errorf("PublishStorageDeals returned %d deal IDs, expected index %d", len(retval.IDs), deal.PublishIndex))
 		}
 
-		if err := environment.Disconnect(deal.ProposalCid); err != nil {
-			log.Warnf("closing client connection: %+v", err)
+		// the connection may already be closed if the provider restarted
+		// while waiting for the message
+		if !deal.ConnectionClosed {
+			err = environment.SendSignedResponse(ctx.Context(), &network.Response{
+				State:          storagemarket.StorageDealProposalAccepted,
+				Proposal:       deal.ProposalCid,
+				PublishMessage: deal.PublishCid,
+			})
+
+			if err != nil {
+				return ctx.Trigger(storagemarket.ProviderEventSendResponseFailed, err)
+			}
+
+			if err := environment.Disconnect(deal.ProposalCid); err != nil {
+				log.Warnf("closing client connection: %+v", err)
+			}
 		}
 
-		return ctx.Trigger(storagemarket.ProviderEventDealPublished, retval.IDs[0])
+		return ctx.Trigger(storagemarket.ProviderEventDealPublished, retval.IDs[deal.PublishIndex])
 
 	})
 }
@@ -345,6 +456,7 @@ func RecordPieceInfo(ctx fsm.Context, environment ProviderDealEnvironment, deal
 			log.Warnf("deleting piece at path %s: %w", deal.MetadataPath, err)
 		}
 	}
+	environment.FileStore().Release(deal.ProposalCid.String())
 
 	return ctx.Trigger(storagemarket.ProviderEventDealCompleted)
 }
@@ -354,6 +466,8 @@ func FailDeal(ctx fsm.Context, environment ProviderDealEnvironment, deal storage
 
 	log.Warnf("deal %s failed: %s", deal.ProposalCid, deal.Message)
 
+	environment.FileStore().Release(deal.ProposalCid.String())
+
 	if !deal.ConnectionClosed {
 		err := environment.SendSignedResponse(ctx.Context(), &network.Response{
 			State:    storagemarket.StorageDealFailing,
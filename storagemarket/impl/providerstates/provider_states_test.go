@@ -10,6 +10,7 @@ import (
 
 	"github.com/filecoin-project/go-address"
 	datatransfer "github.com/filecoin-project/go-data-transfer"
+	"github.com/filecoin-project/go-statemachine"
 	"github.com/filecoin-project/go-statemachine/fsm"
 	fsmtest "github.com/filecoin-project/go-statemachine/fsm/testutil"
 	"github.com/filecoin-project/specs-actors/actors/abi"
@@ -38,6 +39,19 @@ func TestValidateDealProposal(t *testing.T) {
 	runValidateDealProposal := makeExecutor(ctx, eventProcessor, providerstates.ValidateDealProposal, storagemarket.StorageDealValidating)
 	otherAddr, err := address.NewActorAddress([]byte("applesauce"))
 	require.NoError(t, err)
+	// for the default 1MiB piece, collateral must be between 1024 and 2048
+	collateralAsk := defaultAsk
+	collateralAsk.MinProviderCollateral = abi.NewTokenAmount(1 << 20)
+	collateralAsk.MaxProviderCollateral = abi.NewTokenAmount(1 << 21)
+	// the piece size tier halves the price of the default 1MiB piece, and the
+	// duration tier is for deals longer than the default
+	tieredAsk := defaultAsk
+	tieredAsk.PriceTiers = []storagemarket.PriceTier{
+		{MinPieceSize: 1 << 20, Price: abi.NewTokenAmount(5000000)},
+		{MinDuration: defaultEndEpoch - defaultStartEpoch + 1, Price: abi.NewTokenAmount(1)},
+	}
+	expiredAsk := defaultAsk
+	expiredAsk.Expiry = defaultHeight
 	tests := map[string]struct {
 		nodeParams        nodeParams
 		dealParams        dealParams
@@ -98,6 +112,15 @@ func TestValidateDealProposal(t *testing.T) {
 				require.Equal(t, "deal rejected: deal start epoch is too soon or deal already expired", deal.Message)
 			},
 		},
+		"expired ask fails": {
+			environmentParams: environmentParams{
+				Ask: expiredAsk,
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, "deal rejected: storage ask expired at epoch 50", deal.Message)
+			},
+		},
 		"PricePerEpoch too low": {
 			dealParams: dealParams{
 				StoragePricePerEpoch: abi.NewTokenAmount(5000),
@@ -107,6 +130,30 @@ func TestValidateDealProposal(t *testing.T) {
 				require.Equal(t, "deal rejected: storage price per epoch less than asking price: 5000 < 9765", deal.Message)
 			},
 		},
+		"PricePerEpoch at matching price tier succeeds": {
+			environmentParams: environmentParams{
+				Ask:          tieredAsk,
+				TagsProposal: true,
+			},
+			dealParams: dealParams{
+				StoragePricePerEpoch: abi.NewTokenAmount(4882),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealAcceptWait, deal.State)
+			},
+		},
+		"PricePerEpoch below matching price tier": {
+			environmentParams: environmentParams{
+				Ask: tieredAsk,
+			},
+			dealParams: dealParams{
+				StoragePricePerEpoch: abi.NewTokenAmount(4881),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, "deal rejected: storage price per epoch less than asking price: 4881 < 4882", deal.Message)
+			},
+		},
 		"PieceSize < MinPieceSize": {
 			dealParams: dealParams{
 				PieceSize: abi.PaddedPieceSize(128),
@@ -116,6 +163,55 @@ func TestValidateDealProposal(t *testing.T) {
 				require.Equal(t, "deal rejected: piece size less than minimum required size: 128 < 256", deal.Message)
 			},
 		},
+		"HTTP transfer without a valid url": {
+			dealParams: dealParams{
+				DataRef: &storagemarket.DataRef{
+					Root:         tut.GenerateCids(1)[0],
+					TransferType: storagemarket.TTHTTP,
+					URL:          "ftp://example.com/data.car",
+				},
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, "deal rejected: invalid data url: unsupported url scheme \"ftp\"", deal.Message)
+			},
+		},
+		"ProviderCollateral within ask bounds succeeds": {
+			environmentParams: environmentParams{
+				Ask:          collateralAsk,
+				TagsProposal: true,
+			},
+			dealParams: dealParams{
+				ProviderCollateral: abi.NewTokenAmount(1500),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealAcceptWait, deal.State)
+			},
+		},
+		"ProviderCollateral < MinProviderCollateral": {
+			environmentParams: environmentParams{
+				Ask: collateralAsk,
+			},
+			dealParams: dealParams{
+				ProviderCollateral: abi.NewTokenAmount(1000),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, "deal rejected: proposed provider collateral below minimum: 1000 < 1024", deal.Message)
+			},
+		},
+		"ProviderCollateral > MaxProviderCollateral": {
+			environmentParams: environmentParams{
+				Ask: collateralAsk,
+			},
+			dealParams: dealParams{
+				ProviderCollateral: abi.NewTokenAmount(3000),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, "deal rejected: proposed provider collateral above maximum: 3000 > 2048", deal.Message)
+			},
+		},
 		"Get balance error": {
 			nodeParams: nodeParams{
 				ClientMarketBalanceError: errors.New("could not get balance"),
@@ -158,6 +254,57 @@ func TestDecideOnProposal(t *testing.T) {
 		"succeeds": {
 			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
 				tut.AssertDealState(t, storagemarket.StorageDealWaitingForData, deal.State)
+				require.False(t, deal.ConnectionClosed)
+				reserved, ok := env.fs.(*tut.TestFileStore).Reservation(deal.ProposalCid.String())
+				require.True(t, ok)
+				require.Equal(t, uint64(defaultPieceSize), reserved)
+			},
+		},
+		"succeeds, closes connection for manual transfer": {
+			dealParams: dealParams{
+				DataRef: &storagemarket.DataRef{
+					Root:         tut.GenerateCids(1)[0],
+					TransferType: storagemarket.TTManual,
+				},
+			},
+			environmentParams: environmentParams{
+				// the client polls for the deal status instead of waiting on the stream
+				DisconnectError: errors.New("could not disconnect"),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealWaitingForData, deal.State)
+				require.True(t, deal.ConnectionClosed)
+			},
+		},
+		"succeeds, closes connection for HTTP transfer": {
+			dealParams: dealParams{
+				DataRef: &storagemarket.DataRef{
+					Root:         tut.GenerateCids(1)[0],
+					TransferType: storagemarket.TTHTTP,
+					URL:          "http://example.com/data.car",
+				},
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealWaitingForData, deal.State)
+				require.True(t, deal.ConnectionClosed)
+			},
+		},
+		"not enough staging space": {
+			fileStoreParams: tut.TestFileStoreParams{
+				ReserveError: filestore.ErrNotEnoughSpace,
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, "deal rejected: insufficient staging space: not enough space in filestore", deal.Message)
+			},
+		},
+		"reserving staging space errors": {
+			fileStoreParams: tut.TestFileStoreParams{
+				ReserveError: errors.New("disk unavailable"),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, "accessing file store: reserving staging space: disk unavailable", deal.Message)
 			},
 		},
 		"Custom Decision Rejects Deal": {
@@ -186,6 +333,89 @@ func TestDecideOnProposal(t *testing.T) {
 			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
 				tut.AssertDealState(t, storagemarket.StorageDealError, deal.State)
 				require.Equal(t, "sending response to deal: could not send", deal.Message)
+				require.True(t, env.fs.(*tut.TestFileStore).Released(deal.ProposalCid.String()))
+			},
+		},
+		"parks deal for operator approval": {
+			environmentParams: environmentParams{
+				ManualApproval: true,
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealAcceptWait, deal.State)
+				require.True(t, deal.PendingApproval)
+				_, ok := env.fs.(*tut.TestFileStore).Reservation(deal.ProposalCid.String())
+				require.False(t, ok)
+			},
+		},
+		"custom decision rejects deal before operator approval": {
+			environmentParams: environmentParams{
+				ManualApproval: true,
+				RejectDeal:     true,
+				RejectReason:   "I just don't like it",
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.False(t, deal.PendingApproval)
+			},
+		},
+		"pending deal keeps waiting": {
+			dealParams: dealParams{
+				PendingApproval: true,
+			},
+			environmentParams: environmentParams{
+				ManualApproval: true,
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealAcceptWait, deal.State)
+				require.True(t, deal.PendingApproval)
+			},
+		},
+		"approved deal proceeds": {
+			dealParams: dealParams{
+				Approved: true,
+			},
+			environmentParams: environmentParams{
+				ManualApproval: true,
+				// the decision was already made, so the decision logic is not consulted
+				RejectDeal: true,
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealWaitingForData, deal.State)
+				reserved, ok := env.fs.(*tut.TestFileStore).Reservation(deal.ProposalCid.String())
+				require.True(t, ok)
+				require.Equal(t, uint64(defaultPieceSize), reserved)
+			},
+		},
+		"approved deal does not respond on a closed connection": {
+			dealParams: dealParams{
+				Approved:         true,
+				ConnectionClosed: true,
+				DataRef: &storagemarket.DataRef{
+					Root:         tut.GenerateCids(1)[0],
+					TransferType: storagemarket.TTManual,
+				},
+			},
+			environmentParams: environmentParams{
+				ManualApproval:          true,
+				SendSignedResponseError: errors.New("no stream"),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealWaitingForData, deal.State)
+			},
+		},
+		"approved graphsync deal fails on a closed connection": {
+			dealParams: dealParams{
+				Approved:         true,
+				ConnectionClosed: true,
+			},
+			environmentParams: environmentParams{
+				ManualApproval: true,
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, "deal rejected: provider restarted before the client was told the deal was accepted", deal.Message)
+				_, ok := env.fs.(*tut.TestFileStore).Reservation(deal.ProposalCid.String())
+				require.False(t, ok)
 			},
 		},
 	}
@@ -196,6 +426,73 @@ func TestDecideOnProposal(t *testing.T) {
 	}
 }
 
+func TestWaitForData(t *testing.T) {
+	ctx := context.Background()
+	eventProcessor, err := fsm.NewEventProcessor(storagemarket.MinerDeal{}, "State", providerstates.ProviderEvents)
+	require.NoError(t, err)
+	runWaitForData := makeExecutor(ctx, eventProcessor, providerstates.WaitForData, storagemarket.StorageDealWaitingForData)
+	tests := map[string]struct {
+		nodeParams        nodeParams
+		dealParams        dealParams
+		environmentParams environmentParams
+		fileStoreParams   tut.TestFileStoreParams
+		pieceStoreParams  tut.TestPieceStoreParams
+		dealInspector     func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment)
+	}{
+		"waits for the client to push data": {
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealWaitingForData, deal.State)
+				require.Empty(t, env.dataTransfersStarted)
+			},
+		},
+		"pulls data from the client": {
+			dealParams: dealParams{
+				DataRef: &storagemarket.DataRef{
+					TransferType: storagemarket.TTProviderPull,
+					Root:         defaultDataRef.Root,
+				},
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealWaitingForData, deal.State)
+				require.Equal(t, []peer.ID{deal.Client}, env.dataTransfersStarted)
+			},
+		},
+		"starts downloading data over HTTP": {
+			dealParams: dealParams{
+				DataRef: &storagemarket.DataRef{
+					TransferType: storagemarket.TTHTTP,
+					Root:         defaultDataRef.Root,
+					URL:          "http://example.com/data.car",
+				},
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealWaitingForData, deal.State)
+				require.Equal(t, []cid.Cid{deal.ProposalCid}, env.downloadsStarted)
+			},
+		},
+		"opening the pull channel fails": {
+			dealParams: dealParams{
+				DataRef: &storagemarket.DataRef{
+					TransferType: storagemarket.TTProviderPull,
+					Root:         defaultDataRef.Root,
+				},
+			},
+			environmentParams: environmentParams{
+				DataTransferError: errors.New("could not connect"),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, "error transferring data: failed to open pull data channel: could not connect", deal.Message)
+			},
+		},
+	}
+	for test, data := range tests {
+		t.Run(test, func(t *testing.T) {
+			runWaitForData(t, data.nodeParams, data.environmentParams, data.dealParams, data.fileStoreParams, data.pieceStoreParams, data.dealInspector)
+		})
+	}
+}
+
 func TestVerifyData(t *testing.T) {
 	ctx := context.Background()
 	eventProcessor, err := fsm.NewEventProcessor(storagemarket.MinerDeal{}, "State", providerstates.ProviderEvents)
@@ -220,6 +517,7 @@ func TestVerifyData(t *testing.T) {
 				tut.AssertDealState(t, storagemarket.StorageDealEnsureProviderFunds, deal.State)
 				require.Equal(t, expPath, deal.PiecePath)
 				require.Equal(t, expMetaPath, deal.MetadataPath)
+				require.True(t, env.fs.(*tut.TestFileStore).Released(deal.ProposalCid.String()))
 			},
 		},
 		"generate piece CID fails": {
@@ -360,16 +658,18 @@ func TestPublishDeal(t *testing.T) {
 	}{
 		"succeeds": {
 			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
-				tut.AssertDealState(t, storagemarket.StorageDealPublishing, deal.State)
+				tut.AssertDealState(t, storagemarket.StorageDealPublish, deal.State)
+				require.Len(t, env.queuedDeals, 1)
+				require.Equal(t, deal.ProposalCid, env.queuedDeals[0].ProposalCid)
 			},
 		},
-		"PublishDealsErrors errors": {
-			nodeParams: nodeParams{
-				PublishDealsError: errors.New("could not post to chain"),
+		"QueueDealForPublish errors": {
+			environmentParams: environmentParams{
+				QueuePublishError: errors.New("publish batcher stopped"),
 			},
 			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
 				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
-				require.Equal(t, "error calling node: publishing deal: could not post to chain", deal.Message)
+				require.Equal(t, "error calling node: publishing deal: publish batcher stopped", deal.Message)
 			},
 		},
 	}
@@ -386,6 +686,7 @@ func TestWaitForPublish(t *testing.T) {
 	require.NoError(t, err)
 	runWaitForPublish := makeExecutor(ctx, eventProcessor, providerstates.WaitForPublish, storagemarket.StorageDealPublishing)
 	expDealID, psdReturnBytes := generatePublishDealsReturn(t)
+	batchDealIDs, batchReturnBytes := generateBatchPublishDealsReturn(t, 3)
 
 	tests := map[string]struct {
 		nodeParams        nodeParams
@@ -426,6 +727,46 @@ func TestWaitForPublish(t *testing.T) {
 				require.Equal(t, "sending response to deal: could not send", deal.Message)
 			},
 		},
+		"succeeds for a deal published in a batch": {
+			nodeParams: nodeParams{
+				WaitForMessageRetBytes: batchReturnBytes,
+			},
+			dealParams: dealParams{
+				PublishIndex: 1,
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealStaged, deal.State)
+				require.Equal(t, batchDealIDs[1], deal.DealID)
+			},
+		},
+		"publish index out of range": {
+			nodeParams: nodeParams{
+				WaitForMessageRetBytes: psdReturnBytes,
+			},
+			dealParams: dealParams{
+				PublishIndex: 1,
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, "PublishStorageDeal error: PublishStorageDeals returned 1 deal IDs, expected index 1", deal.Message)
+			},
+		},
+		"succeeds without response when connection already closed": {
+			nodeParams: nodeParams{
+				WaitForMessageRetBytes: psdReturnBytes,
+			},
+			dealParams: dealParams{
+				ConnectionClosed: true,
+			},
+			environmentParams: environmentParams{
+				SendSignedResponseError: errors.New("could not send"),
+				DisconnectError:         errors.New("could not disconnect"),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealStaged, deal.State)
+				require.Equal(t, expDealID, deal.DealID)
+			},
+		},
 	}
 	for test, data := range tests {
 		t.Run(test, func(t *testing.T) {
@@ -559,6 +900,7 @@ func TestRecordPieceInfo(t *testing.T) {
 			},
 			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
 				tut.AssertDealState(t, storagemarket.StorageDealCompleted, deal.State)
+				require.True(t, env.fs.(*tut.TestFileStore).Released(deal.ProposalCid.String()))
 			},
 		},
 		"succeeds w metadata": {
@@ -638,6 +980,7 @@ func TestFailDeal(t *testing.T) {
 		"succeeds": {
 			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
 				tut.AssertDealState(t, storagemarket.StorageDealError, deal.State)
+				require.True(t, env.fs.(*tut.TestFileStore).Released(deal.ProposalCid.String()))
 			},
 		},
 		"succeeds, skips response": {
@@ -685,6 +1028,196 @@ func TestFailDeal(t *testing.T) {
 	}
 }
 
+func TestRestart(t *testing.T) {
+	ctx := context.Background()
+	eventProcessor, err := fsm.NewEventProcessor(storagemarket.MinerDeal{}, "State", providerstates.ProviderEvents)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		initialState  storagemarket.StorageDealStatus
+		expectedState storagemarket.StorageDealStatus
+		message       string
+	}{
+		"unknown deal fails": {
+			initialState:  storagemarket.StorageDealUnknown,
+			expectedState: storagemarket.StorageDealFailing,
+			message:       "provider restarted before deal was accepted",
+		},
+		"validating deal fails": {
+			initialState:  storagemarket.StorageDealValidating,
+			expectedState: storagemarket.StorageDealFailing,
+			message:       "provider restarted before deal was accepted",
+		},
+		"deal waiting for acceptance fails": {
+			initialState:  storagemarket.StorageDealAcceptWait,
+			expectedState: storagemarket.StorageDealFailing,
+			message:       "provider restarted before deal was accepted",
+		},
+		"transferring deal fails": {
+			initialState:  storagemarket.StorageDealTransferring,
+			expectedState: storagemarket.StorageDealFailing,
+			message:       "provider restarted during data transfer",
+		},
+		"deal waiting for data keeps waiting": {
+			initialState:  storagemarket.StorageDealWaitingForData,
+			expectedState: storagemarket.StorageDealWaitingForData,
+		},
+		"verifying deal re-verifies": {
+			initialState:  storagemarket.StorageDealVerifyData,
+			expectedState: storagemarket.StorageDealVerifyData,
+		},
+		"deal ensuring funds re-ensures": {
+			initialState:  storagemarket.StorageDealEnsureProviderFunds,
+			expectedState: storagemarket.StorageDealEnsureProviderFunds,
+		},
+		"deal waiting for funds keeps waiting": {
+			initialState:  storagemarket.StorageDealProviderFunding,
+			expectedState: storagemarket.StorageDealProviderFunding,
+		},
+		"deal ready to publish re-publishes": {
+			initialState:  storagemarket.StorageDealPublish,
+			expectedState: storagemarket.StorageDealPublish,
+		},
+		"publishing deal keeps waiting": {
+			initialState:  storagemarket.StorageDealPublishing,
+			expectedState: storagemarket.StorageDealPublishing,
+		},
+		"staged deal is handed off again": {
+			initialState:  storagemarket.StorageDealStaged,
+			expectedState: storagemarket.StorageDealStaged,
+		},
+		"sealing deal keeps waiting": {
+			initialState:  storagemarket.StorageDealSealing,
+			expectedState: storagemarket.StorageDealSealing,
+		},
+		"active deal records piece info": {
+			initialState:  storagemarket.StorageDealActive,
+			expectedState: storagemarket.StorageDealActive,
+		},
+		"failing deal finishes failing": {
+			initialState:  storagemarket.StorageDealFailing,
+			expectedState: storagemarket.StorageDealFailing,
+		},
+	}
+	for test, data := range tests {
+		t.Run(test, func(t *testing.T) {
+			signedProposal := tut.MakeTestClientDealProposal()
+			dealState, err := tut.MakeTestMinerDeal(data.initialState, signedProposal, &defaultDataRef)
+			require.NoError(t, err)
+
+			fsmCtx := fsmtest.NewTestContext(ctx, eventProcessor)
+			err = fsmCtx.Trigger(storagemarket.ProviderEventRestart)
+			require.NoError(t, err)
+			fsmCtx.ReplayEvents(t, dealState)
+
+			tut.AssertDealState(t, data.expectedState, dealState.State)
+			require.Equal(t, data.message, dealState.Message)
+			require.True(t, dealState.ConnectionClosed)
+		})
+	}
+
+	t.Run("terminal deals cannot be restarted", func(t *testing.T) {
+		for _, state := range providerstates.ProviderFinalityStates {
+			signedProposal := tut.MakeTestClientDealProposal()
+			dealState, err := tut.MakeTestMinerDeal(state.(storagemarket.StorageDealStatus), signedProposal, &defaultDataRef)
+			require.NoError(t, err)
+			evt, err := eventProcessor.Generate(ctx, storagemarket.ProviderEventRestart, nil)
+			require.NoError(t, err)
+			_, err = eventProcessor.Apply(statemachine.Event{User: evt}, dealState)
+			require.Error(t, err)
+		}
+	})
+
+	t.Run("deal awaiting approval keeps waiting", func(t *testing.T) {
+		signedProposal := tut.MakeTestClientDealProposal()
+		dealState, err := tut.MakeTestMinerDeal(storagemarket.StorageDealAcceptWait, signedProposal, &defaultDataRef)
+		require.NoError(t, err)
+		dealState.PendingApproval = true
+
+		fsmCtx := fsmtest.NewTestContext(ctx, eventProcessor)
+		err = fsmCtx.Trigger(storagemarket.ProviderEventRestartAwaitingApproval)
+		require.NoError(t, err)
+		fsmCtx.ReplayEvents(t, dealState)
+
+		tut.AssertDealState(t, storagemarket.StorageDealAcceptWait, dealState.State)
+		require.True(t, dealState.PendingApproval)
+		require.True(t, dealState.ConnectionClosed)
+	})
+}
+
+func TestCancel(t *testing.T) {
+	ctx := context.Background()
+	eventProcessor, err := fsm.NewEventProcessor(storagemarket.MinerDeal{}, "State", providerstates.ProviderEvents)
+	require.NoError(t, err)
+
+	for _, state := range providerstates.ProviderCancellableStates {
+		t.Run(storagemarket.DealStates[state.(storagemarket.StorageDealStatus)], func(t *testing.T) {
+			signedProposal := tut.MakeTestClientDealProposal()
+			dealState, err := tut.MakeTestMinerDeal(state.(storagemarket.StorageDealStatus), signedProposal, &defaultDataRef)
+			require.NoError(t, err)
+
+			fsmCtx := fsmtest.NewTestContext(ctx, eventProcessor)
+			err = fsmCtx.Trigger(storagemarket.ProviderEventDealCancelled, "changed my mind")
+			require.NoError(t, err)
+			fsmCtx.ReplayEvents(t, dealState)
+
+			tut.AssertDealState(t, storagemarket.StorageDealFailing, dealState.State)
+			require.Equal(t, "deal cancelled by provider: changed my mind", dealState.Message)
+		})
+	}
+
+	t.Run("deals queued for publishing cannot be cancelled", func(t *testing.T) {
+		for _, state := range []storagemarket.StorageDealStatus{storagemarket.StorageDealPublish, storagemarket.StorageDealPublishing, storagemarket.StorageDealStaged} {
+			signedProposal := tut.MakeTestClientDealProposal()
+			dealState, err := tut.MakeTestMinerDeal(state, signedProposal, &defaultDataRef)
+			require.NoError(t, err)
+			evt, err := eventProcessor.Generate(ctx, storagemarket.ProviderEventDealCancelled, nil, "changed my mind")
+			require.NoError(t, err)
+			_, err = eventProcessor.Apply(statemachine.Event{User: evt}, dealState)
+			require.Error(t, err)
+		}
+	})
+}
+
+func TestApproval(t *testing.T) {
+	ctx := context.Background()
+	eventProcessor, err := fsm.NewEventProcessor(storagemarket.MinerDeal{}, "State", providerstates.ProviderEvents)
+	require.NoError(t, err)
+
+	apply := func(t *testing.T, deal *storagemarket.MinerDeal, event storagemarket.ProviderEvent, args ...interface{}) error {
+		evt, err := eventProcessor.Generate(ctx, event, nil, args...)
+		require.NoError(t, err)
+		_, err = eventProcessor.Apply(statemachine.Event{User: evt}, deal)
+		return err
+	}
+	pendingDeal := func(t *testing.T) *storagemarket.MinerDeal {
+		deal, err := tut.MakeTestMinerDeal(storagemarket.StorageDealAcceptWait, tut.MakeTestClientDealProposal(), &defaultDataRef)
+		require.NoError(t, err)
+		deal.PendingApproval = true
+		return deal
+	}
+
+	t.Run("a deal can only be approved once", func(t *testing.T) {
+		deal := pendingDeal(t)
+		require.NoError(t, apply(t, deal, storagemarket.ProviderEventDealApproved))
+		require.True(t, deal.Approved)
+		require.False(t, deal.PendingApproval)
+		require.Error(t, apply(t, deal, storagemarket.ProviderEventDealApproved))
+	})
+	t.Run("rejecting a pending deal fails it", func(t *testing.T) {
+		deal := pendingDeal(t)
+		require.NoError(t, apply(t, deal, storagemarket.ProviderEventApprovalRejected, errors.New("not today")))
+		tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+		require.Equal(t, "deal rejected: not today", deal.Message)
+	})
+	t.Run("an approved deal cannot be rejected", func(t *testing.T) {
+		deal := pendingDeal(t)
+		require.NoError(t, apply(t, deal, storagemarket.ProviderEventDealApproved))
+		require.Error(t, apply(t, deal, storagemarket.ProviderEventApprovalRejected, errors.New("not today")))
+		tut.AssertDealState(t, storagemarket.StorageDealAcceptWait, deal.State)
+	})
+}
+
 // all of these default parameters are setup to allow a deal to complete each handler with no errors
 var defaultHeight = abi.ChainEpoch(50)
 var defaultTipSetToken = []byte{1, 2, 3}
@@ -710,6 +1243,7 @@ var defaultAsk = storagemarket.StorageAsk{
 	Price:        abi.NewTokenAmount(10000000),
 	MinPieceSize: abi.PaddedPieceSize(256),
 	MaxPieceSize: 1 << 20,
+	Expiry:       1000,
 }
 
 var testData = tut.NewTestIPLDTree()
@@ -738,6 +1272,20 @@ func generatePublishDealsReturn(t *testing.T) (abi.DealID, []byte) {
 	return dealId, psdReturnBytes.Bytes()
 }
 
+func generateBatchPublishDealsReturn(t *testing.T, count int) ([]abi.DealID, []byte) {
+	dealIDs := make([]abi.DealID, 0, count)
+	for i := 0; i < count; i++ {
+		dealIDs = append(dealIDs, abi.DealID(rand.Uint64()))
+	}
+
+	psdReturn := market.PublishStorageDealsReturn{IDs: dealIDs}
+	psdReturnBytes := bytes.NewBuffer([]byte{})
+	err := psdReturn.MarshalCBOR(psdReturnBytes)
+	require.NoError(t, err)
+
+	return dealIDs, psdReturnBytes.Bytes()
+}
+
 type nodeParams struct {
 	MinerAddr                           address.Address
 	MinerWorkerError                    error
@@ -767,12 +1315,15 @@ type dealParams struct {
 	MetadataPath         filestore.Path
 	ConnectionClosed     bool
 	DealID               abi.DealID
+	PublishIndex         uint64
 	DataRef              *storagemarket.DataRef
 	StoragePricePerEpoch abi.TokenAmount
 	ProviderCollateral   abi.TokenAmount
 	PieceSize            abi.PaddedPieceSize
 	StartEpoch           abi.ChainEpoch
 	EndEpoch             abi.ChainEpoch
+	PendingApproval      bool
+	Approved             bool
 }
 
 type environmentParams struct {
@@ -790,6 +1341,8 @@ type environmentParams struct {
 	RejectDeal              bool
 	RejectReason            string
 	DecisionError           error
+	QueuePublishError       error
+	ManualApproval          bool
 }
 
 type executor func(t *testing.T,
@@ -907,6 +1460,9 @@ func makeExecutor(ctx context.Context,
 		if dealParams.DealID != abi.DealID(0) {
 			dealState.DealID = dealParams.DealID
 		}
+		dealState.PublishIndex = dealParams.PublishIndex
+		dealState.PendingApproval = dealParams.PendingApproval
+		dealState.Approved = dealParams.Approved
 		fs := tut.NewTestFileStore(fileStoreParams)
 		pieceStore := tut.NewTestPieceStoreWithParams(pieceStoreParams)
 		expectedTags := make(map[string]struct{})
@@ -929,6 +1485,8 @@ func makeExecutor(ctx context.Context,
 			rejectDeal:              params.RejectDeal,
 			rejectReason:            params.RejectReason,
 			decisionError:           params.DecisionError,
+			queuePublishError:       params.QueuePublishError,
+			manualApproval:          params.ManualApproval,
 			dealAcceptanceBuffer:    abi.ChainEpoch(params.DealAcceptanceBuffer),
 			fs:                      fs,
 			pieceStore:              pieceStore,
@@ -945,7 +1503,7 @@ func makeExecutor(ctx context.Context,
 		if environment.address == address.Undef {
 			environment.address = defaultProviderAddress
 		}
-		if environment.ask == storagemarket.StorageAskUndefined {
+		if environment.ask.Price.Nil() {
 			environment.ask = defaultAsk
 		}
 
@@ -975,6 +1533,11 @@ type fakeEnvironment struct {
 	rejectDeal              bool
 	rejectReason            string
 	decisionError           error
+	queuePublishError       error
+	manualApproval          bool
+	queuedDeals             []storagemarket.MinerDeal
+	dataTransfersStarted    []peer.ID
+	downloadsStarted        []cid.Cid
 	fs                      filestore.FileStore
 	pieceStore              piecestore.PieceStore
 	dealAcceptanceBuffer    abi.ChainEpoch
@@ -995,9 +1558,14 @@ func (fe *fakeEnvironment) Ask() storagemarket.StorageAsk {
 }
 
 func (fe *fakeEnvironment) StartDataTransfer(ctx context.Context, to peer.ID, voucher datatransfer.Voucher, baseCid cid.Cid, selector ipld.Node) error {
+	fe.dataTransfersStarted = append(fe.dataTransfersStarted, to)
 	return fe.dataTransferError
 }
 
+func (fe *fakeEnvironment) StartDownload(deal storagemarket.MinerDeal) {
+	fe.downloadsStarted = append(fe.downloadsStarted, deal.ProposalCid)
+}
+
 func (fe *fakeEnvironment) GeneratePieceCommitmentToFile(payloadCid cid.Cid, selector ipld.Node) (cid.Cid, filestore.Path, filestore.Path, error) {
 	return fe.pieceCid, fe.path, fe.metadataPath, fe.generateCommPError
 }
@@ -1019,6 +1587,14 @@ func (fe *fakeEnvironment) Disconnect(proposalCid cid.Cid) error {
 	return fe.disconnectError
 }
 
+func (fe *fakeEnvironment) QueueDealForPublish(deal storagemarket.MinerDeal) error {
+	if fe.queuePublishError != nil {
+		return fe.queuePublishError
+	}
+	fe.queuedDeals = append(fe.queuedDeals, deal)
+	return nil
+}
+
 func (fe *fakeEnvironment) FileStore() filestore.FileStore {
 	return fe.fs
 }
@@ -1034,3 +1610,7 @@ func (fe *fakeEnvironment) DealAcceptanceBuffer() abi.ChainEpoch {
 func (fe *fakeEnvironment) RunCustomDecisionLogic(context.Context, storagemarket.MinerDeal) (bool, string, error) {
 	return !fe.rejectDeal, fe.rejectReason, fe.decisionError
 }
+
+func (fe *fakeEnvironment) ManualApprovalRequired() bool {
+	return fe.manualApproval
+}
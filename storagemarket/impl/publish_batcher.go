@@ -0,0 +1,137 @@
+package storageimpl
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-statemachine/fsm"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+)
+
+// DefaultPublishWindow is the default amount of time a provider waits to
+// collect deals into a single publish message
+var DefaultPublishWindow = time.Duration(0)
+
+// DefaultMaxDealsPerPublish is the default number of deals a provider
+// publishes in a single message
+var DefaultMaxDealsPerPublish = 1
+
+type publishFunc func(ctx context.Context, deals []storagemarket.MinerDeal) (cid.Cid, error)
+type sendFunc func(id interface{}, name fsm.EventName, args ...interface{}) error
+
+// publishBatcher accumulates deals that are ready to be published and
+// publishes them together in a single message, either when the batch is full
+// or when the publish window since the first deal in the batch has elapsed
+type publishBatcher struct {
+	publish  publishFunc
+	send     sendFunc
+	window   time.Duration
+	maxDeals int
+
+	lk      sync.Mutex
+	pending []storagemarket.MinerDeal
+	batch   uint64
+	timer   *time.Timer
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+func newPublishBatcher(publish publishFunc, send sendFunc, window time.Duration, maxDeals int) *publishBatcher {
+	if maxDeals < 1 {
+		maxDeals = 1
+	}
+	return &publishBatcher{
+		publish:  publish,
+		send:     send,
+		window:   window,
+		maxDeals: maxDeals,
+	}
+}
+
+// add queues a deal for publishing
+func (b *publishBatcher) add(deal storagemarket.MinerDeal) error {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	if b.stopped {
+		return xerrors.New("publish batcher stopped")
+	}
+
+	b.pending = append(b.pending, deal)
+	if len(b.pending) >= b.maxDeals || b.window <= 0 {
+		b.flushLocked(context.Background())
+		return nil
+	}
+
+	if b.timer == nil {
+		batch := b.batch
+		b.timer = time.AfterFunc(b.window, func() {
+			b.flushWindow(batch)
+		})
+	}
+	return nil
+}
+
+// flushWindow publishes a batch when its window elapses. Stopping the timer
+// does not stop a callback that is already waiting for the lock, so a batch
+// that was flushed in the meantime is ignored rather than flushing the next
+// batch before its own window ends
+func (b *publishBatcher) flushWindow(batch uint64) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	if batch != b.batch {
+		return
+	}
+	b.flushLocked(context.Background())
+}
+
+// stop publishes any deals still waiting in the batch, waits for all
+// outstanding publish messages to be sent, and refuses further deals
+func (b *publishBatcher) stop(ctx context.Context) {
+	b.lk.Lock()
+	b.stopped = true
+	b.flushLocked(ctx)
+	b.lk.Unlock()
+
+	b.wg.Wait()
+}
+
+func (b *publishBatcher) flushLocked(ctx context.Context) {
+	b.batch++
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.pending) == 0 {
+		return
+	}
+
+	deals := b.pending
+	b.pending = nil
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.publishBatch(ctx, deals)
+	}()
+}
+
+func (b *publishBatcher) publishBatch(ctx context.Context, deals []storagemarket.MinerDeal) {
+	mcid, publishErr := b.publish(ctx, deals)
+	for i, deal := range deals {
+		var err error
+		if publishErr != nil {
+			err = b.send(deal.ProposalCid, storagemarket.ProviderEventNodeErrored, xerrors.Errorf("publishing deal: %w", publishErr))
+		} else {
+			// the index lets the deal find its ID in the message return value
+			err = b.send(deal.ProposalCid, storagemarket.ProviderEventDealPublishInitiated, mcid, uint64(i))
+		}
+		if err != nil {
+			log.Errorf("notifying deal %s of publish result: %s", deal.ProposalCid, err)
+		}
+	}
+}
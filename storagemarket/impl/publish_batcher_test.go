@@ -0,0 +1,172 @@
+package storageimpl
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-statemachine/fsm"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	tut "github.com/filecoin-project/go-fil-markets/shared_testutil"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+)
+
+type sentEvent struct {
+	id   interface{}
+	name fsm.EventName
+	args []interface{}
+}
+
+type fakePublisher struct {
+	lk      sync.Mutex
+	err     error
+	mcid    cid.Cid
+	batches [][]storagemarket.MinerDeal
+	events  []sentEvent
+}
+
+func (fp *fakePublisher) publish(ctx context.Context, deals []storagemarket.MinerDeal) (cid.Cid, error) {
+	fp.lk.Lock()
+	defer fp.lk.Unlock()
+	fp.batches = append(fp.batches, deals)
+	return fp.mcid, fp.err
+}
+
+func (fp *fakePublisher) send(id interface{}, name fsm.EventName, args ...interface{}) error {
+	fp.lk.Lock()
+	defer fp.lk.Unlock()
+	fp.events = append(fp.events, sentEvent{id, name, args})
+	return nil
+}
+
+func makeBatcherDeals(count int) []storagemarket.MinerDeal {
+	deals := make([]storagemarket.MinerDeal, 0, count)
+	for _, proposalCid := range tut.GenerateCids(count) {
+		deals = append(deals, storagemarket.MinerDeal{ProposalCid: proposalCid})
+	}
+	return deals
+}
+
+func TestPublishBatcher(t *testing.T) {
+	ctx := context.Background()
+	mcid := tut.GenerateCids(1)[0]
+
+	t.Run("publishes immediately by default", func(t *testing.T) {
+		fp := &fakePublisher{mcid: mcid}
+		b := newPublishBatcher(fp.publish, fp.send, DefaultPublishWindow, DefaultMaxDealsPerPublish)
+		deals := makeBatcherDeals(2)
+		for _, deal := range deals {
+			require.NoError(t, b.add(deal))
+		}
+		b.stop(ctx)
+
+		require.Len(t, fp.batches, 2)
+		require.Len(t, fp.events, 2)
+		for _, evt := range fp.events {
+			require.Equal(t, storagemarket.ProviderEventDealPublishInitiated, evt.name)
+			require.Equal(t, []interface{}{mcid, uint64(0)}, evt.args)
+		}
+	})
+
+	t.Run("publishes when the batch is full", func(t *testing.T) {
+		fp := &fakePublisher{mcid: mcid}
+		b := newPublishBatcher(fp.publish, fp.send, time.Hour, 3)
+		deals := makeBatcherDeals(3)
+		for _, deal := range deals {
+			require.NoError(t, b.add(deal))
+		}
+		b.stop(ctx)
+
+		require.Len(t, fp.batches, 1)
+		require.Equal(t, deals, fp.batches[0])
+		require.Len(t, fp.events, 3)
+		for i, evt := range fp.events {
+			require.Equal(t, deals[i].ProposalCid, evt.id)
+			require.Equal(t, storagemarket.ProviderEventDealPublishInitiated, evt.name)
+			require.Equal(t, []interface{}{mcid, uint64(i)}, evt.args)
+		}
+	})
+
+	t.Run("publishes when the window elapses", func(t *testing.T) {
+		fp := &fakePublisher{mcid: mcid}
+		b := newPublishBatcher(fp.publish, fp.send, 10*time.Millisecond, 10)
+		deals := makeBatcherDeals(2)
+		for _, deal := range deals {
+			require.NoError(t, b.add(deal))
+		}
+
+		require.Eventually(t, func() bool {
+			fp.lk.Lock()
+			defer fp.lk.Unlock()
+			return len(fp.events) == 2
+		}, time.Second, 5*time.Millisecond)
+		b.stop(ctx)
+
+		require.Len(t, fp.batches, 1)
+		require.Equal(t, deals, fp.batches[0])
+	})
+
+	t.Run("ignores the window of a batch that was already published", func(t *testing.T) {
+		fp := &fakePublisher{mcid: mcid}
+		b := newPublishBatcher(fp.publish, fp.send, time.Hour, 2)
+		deals := makeBatcherDeals(3)
+		for _, deal := range deals {
+			require.NoError(t, b.add(deal))
+		}
+
+		require.Eventually(t, func() bool {
+			fp.lk.Lock()
+			defer fp.lk.Unlock()
+			return len(fp.batches) == 1
+		}, time.Second, 5*time.Millisecond)
+
+		// a late callback for the first batch, which filled up and was
+		// published, must not flush the second
+		b.flushWindow(0)
+		b.lk.Lock()
+		require.Len(t, b.pending, 1)
+		b.lk.Unlock()
+		b.stop(ctx)
+
+		require.Len(t, fp.batches, 2)
+		require.Equal(t, deals[:2], fp.batches[0])
+		require.Equal(t, deals[2:], fp.batches[1])
+	})
+
+	t.Run("flushes pending deals on stop", func(t *testing.T) {
+		fp := &fakePublisher{mcid: mcid}
+		b := newPublishBatcher(fp.publish, fp.send, time.Hour, 10)
+		deals := makeBatcherDeals(2)
+		for _, deal := range deals {
+			require.NoError(t, b.add(deal))
+		}
+		b.stop(ctx)
+
+		require.Len(t, fp.batches, 1)
+		require.Equal(t, deals, fp.batches[0])
+		require.Len(t, fp.events, 2)
+
+		require.EqualError(t, b.add(makeBatcherDeals(1)[0]), "publish batcher stopped")
+	})
+
+	t.Run("fails every deal in the batch when publishing fails", func(t *testing.T) {
+		fp := &fakePublisher{err: errors.New("could not post to chain")}
+		b := newPublishBatcher(fp.publish, fp.send, time.Hour, 2)
+		deals := makeBatcherDeals(2)
+		for _, deal := range deals {
+			require.NoError(t, b.add(deal))
+		}
+		b.stop(ctx)
+
+		require.Len(t, fp.events, 2)
+		for _, evt := range fp.events {
+			require.Equal(t, storagemarket.ProviderEventNodeErrored, evt.name)
+			require.Len(t, evt.args, 1)
+			require.EqualError(t, evt.args[0].(error), "publishing deal: could not post to chain")
+		}
+	})
+}
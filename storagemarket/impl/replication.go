@@ -0,0 +1,280 @@
+package storageimpl
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/shared"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/clientstates"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/clientutils"
+)
+
+// ReplicateData computes the commP of the data once, then proposes deals for
+// it to the first params.Replicas candidate providers in parallel. Whenever a
+// deal fails, a deal is proposed to the next candidate in its place. Progress
+// is tracked in memory, and is not resumed when the client restarts. ctx only
+// bounds computing the commP and selecting providers: the deals are proposed
+// until the client stops
+func (c *Client) ReplicateData(ctx context.Context, params storagemarket.ReplicationParams) (storagemarket.ReplicationID, error) {
+	if params.Data == nil {
+		return 0, xerrors.New("no data to replicate")
+	}
+	if params.Replicas == 0 {
+		return 0, xerrors.New("number of replicas must be positive")
+	}
+	if len(params.Providers) == 0 && params.SelectOffers == nil {
+		return 0, xerrors.New("no providers or offer selector given")
+	}
+
+	commP, pieceSize, err := clientutils.CommP(ctx, c.pio, params.ProofType, params.Data, c.commPProgress(params.Data.Root, ""))
+	if err != nil {
+		return 0, xerrors.Errorf("computing commP failed: %w", err)
+	}
+
+	candidates := params.Providers
+	if len(candidates) == 0 {
+		candidates, err = params.SelectOffers(ctx, pieceSize.Padded(), params.EndEpoch-params.StartEpoch)
+		if err != nil {
+			return 0, xerrors.Errorf("selecting providers: %w", err)
+		}
+	}
+
+	// every deal reuses the commP computed above
+	data := *params.Data
+	data.PieceCid = &commP
+	data.PieceSize = pieceSize
+
+	c.replicationsLk.Lock()
+	id := c.nextReplicationID
+	c.nextReplicationID++
+	r := &replication{
+		c:          c,
+		ctx:        c.replicationCtx,
+		params:     params,
+		data:       &data,
+		candidates: candidates,
+		status: storagemarket.ReplicationStatus{
+			ID:        id,
+			PieceCid:  commP,
+			PieceSize: pieceSize,
+			Replicas:  params.Replicas,
+		},
+	}
+	c.replications[id] = r
+	c.replicationsLk.Unlock()
+
+	r.start()
+	return id, nil
+}
+
+// GetReplicationStatus returns the progress of a replication. Only the most
+// recent finished replications are kept, see MaxFinishedReplications
+func (c *Client) GetReplicationStatus(ctx context.Context, id storagemarket.ReplicationID) (storagemarket.ReplicationStatus, error) {
+	c.replicationsLk.Lock()
+	r, ok := c.replications[id]
+	c.replicationsLk.Unlock()
+	if !ok {
+		return storagemarket.ReplicationStatus{}, xerrors.Errorf("no replication with ID %d", id)
+	}
+	return r.currentStatus(), nil
+}
+
+// CheapestOffers returns an OfferSelector that picks providers with
+// FindStorageOffers, cheapest first. The piece size and duration of the
+// criteria are set by the selector
+func CheapestOffers(client storagemarket.StorageClient, criteria storagemarket.AskCriteria) storagemarket.OfferSelector {
+	return func(ctx context.Context, pieceSize abi.PaddedPieceSize, duration abi.ChainEpoch) ([]storagemarket.StorageProviderInfo, error) {
+		criteria.PieceSize = pieceSize
+		criteria.Duration = duration
+		offers, err := client.FindStorageOffers(ctx, criteria, 0)
+		if err != nil {
+			return nil, err
+		}
+		providers := make([]storagemarket.StorageProviderInfo, 0, len(offers))
+		for _, offer := range offers {
+			providers = append(providers, offer.Provider)
+		}
+		return providers, nil
+	}
+}
+
+// replication tracks the deals proposed for a single call to ReplicateData
+type replication struct {
+	c          *Client
+	ctx        context.Context
+	params     storagemarket.ReplicationParams
+	data       *storagemarket.DataRef
+	candidates []storagemarket.StorageProviderInfo
+
+	lk            sync.Mutex
+	nextCandidate int
+	status        storagemarket.ReplicationStatus
+	unsubscribe   shared.Unsubscribe
+}
+
+func (r *replication) start() {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	r.unsubscribe = r.c.SubscribeToEvents(r.onDealEvent)
+	for i := uint(0); i < r.params.Replicas; i++ {
+		r.proposeNext()
+	}
+	r.checkComplete()
+}
+
+func (r *replication) currentStatus() storagemarket.ReplicationStatus {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	status := r.status
+	status.Deals = append([]storagemarket.Replica(nil), r.status.Deals...)
+	return status
+}
+
+// proposeNext starts proposing a deal to the next candidate provider, if
+// there is one left. It must be called with the lock held
+func (r *replication) proposeNext() {
+	if r.status.Complete || r.nextCandidate >= len(r.candidates) {
+		return
+	}
+
+	info := r.candidates[r.nextCandidate]
+	r.nextCandidate++
+	r.status.Deals = append(r.status.Deals, storagemarket.Replica{
+		Provider: info,
+		State:    storagemarket.StorageDealUnknown,
+	})
+	go r.propose(len(r.status.Deals)-1, info)
+}
+
+func (r *replication) propose(index int, info storagemarket.StorageProviderInfo) {
+	proposalCid, err := r.proposeTo(info)
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	if err != nil {
+		r.update(index, storagemarket.StorageDealError, err.Error())
+		return
+	}
+	r.status.Deals[index].ProposalCid = proposalCid
+
+	// events for the deal sent before its proposal cid was recorded were
+	// ignored, so catch up with the current state of the deal
+	var deal storagemarket.ClientDeal
+	if err := r.c.statemachines.Get(proposalCid).Get(&deal); err != nil {
+		log.Errorf("getting deal %s for replication %d: %s", proposalCid, r.status.ID, err)
+		return
+	}
+	r.update(index, deal.State, deal.Message)
+}
+
+func (r *replication) proposeTo(info storagemarket.StorageProviderInfo) (cid.Cid, error) {
+	if uint64(r.data.PieceSize.Padded()) > info.SectorSize {
+		return cid.Undef, fmt.Errorf("cannot propose a deal whose piece size (%d) is greater than sector size (%d)", r.data.PieceSize.Padded(), info.SectorSize)
+	}
+
+	ask, err := r.c.GetAsk(r.ctx, info)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("getting provider ask: %w", err)
+	}
+	duration := r.params.EndEpoch - r.params.StartEpoch
+	askPrice := storagemarket.AskPrice(*ask.Ask, r.data.PieceSize.Padded(), duration)
+	if !r.params.MaxPrice.Nil() && askPrice.GreaterThan(r.params.MaxPrice) {
+		return cid.Undef, xerrors.Errorf("ask price %s is more than maximum price %s", askPrice, r.params.MaxPrice)
+	}
+
+	price := storagemarket.DealPricePerEpoch(*ask.Ask, r.data.PieceSize.Padded(), duration)
+	providerCollateral, clientCollateral, err := r.c.dealCollateral(*ask.Ask, r.data.PieceSize.Padded(), duration, r.params.Collateral)
+	if err != nil {
+		return cid.Undef, err
+	}
+	result, err := r.c.proposeDeal(r.ctx, r.params.Client, &info, r.data, *r.data.PieceCid, r.data.PieceSize,
+		r.params.StartEpoch, r.params.EndEpoch, price, providerCollateral, clientCollateral)
+	if err != nil {
+		if result == nil {
+			return cid.Undef, xerrors.Errorf("proposing deal: %w", err)
+		}
+		log.Warnf("deal %s proposed, but recording provider for retrieval failed: %s", result.ProposalCid, err)
+	}
+	return result.ProposalCid, nil
+}
+
+func (r *replication) onDealEvent(event storagemarket.ClientEvent, deal storagemarket.ClientDeal) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	for i, replica := range r.status.Deals {
+		if replica.ProposalCid.Defined() && replica.ProposalCid.Equals(deal.ProposalCid) {
+			r.update(i, deal.State, deal.Message)
+			return
+		}
+	}
+}
+
+// update records the state of a deal, replacing the deal if it failed. It
+// must be called with the lock held
+func (r *replication) update(index int, state storagemarket.StorageDealStatus, message string) {
+	replica := &r.status.Deals[index]
+	if containsState(replica.State, clientstates.ClientFinalityStates) {
+		return
+	}
+	replica.State = state
+	if message != "" {
+		replica.Message = message
+	}
+
+	switch state {
+	case storagemarket.StorageDealActive:
+		r.status.Active++
+	case storagemarket.StorageDealError:
+		r.status.Failed++
+		r.proposeNext()
+	}
+	r.checkComplete()
+}
+
+// checkComplete marks the replication complete once enough deals are active,
+// or once every deal is finished and there are no candidates left. It must be
+// called with the lock held
+func (r *replication) checkComplete() {
+	if r.status.Complete {
+		return
+	}
+
+	if r.status.Active < r.params.Replicas {
+		if r.nextCandidate < len(r.candidates) {
+			return
+		}
+		for _, replica := range r.status.Deals {
+			if !containsState(replica.State, clientstates.ClientFinalityStates) {
+				return
+			}
+		}
+		r.status.Message = fmt.Sprintf("%d of %d replicas active: no candidate providers left", r.status.Active, r.params.Replicas)
+	}
+
+	r.status.Complete = true
+	// the subscriber cannot be removed while an event is being published to it
+	go r.unsubscribe()
+	r.c.replicationFinished(r.status.ID)
+}
+
+// replicationFinished records that a replication is complete, forgetting the
+// oldest finished replications once there are too many
+func (c *Client) replicationFinished(id storagemarket.ReplicationID) {
+	c.replicationsLk.Lock()
+	defer c.replicationsLk.Unlock()
+
+	c.finishedReplications = append(c.finishedReplications, id)
+	for len(c.finishedReplications) > c.maxFinishedReplications {
+		delete(c.replications, c.finishedReplications[0])
+		c.finishedReplications = c.finishedReplications[1:]
+	}
+}
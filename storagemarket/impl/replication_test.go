@@ -0,0 +1,42 @@
+package storageimpl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hannahhoward/go-pubsub"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+)
+
+func TestReplicationFinished(t *testing.T) {
+	ctx := context.Background()
+	c := &Client{
+		pubSub:                  pubsub.New(clientDispatcher),
+		replications:            make(map[storagemarket.ReplicationID]*replication),
+		maxFinishedReplications: 2,
+	}
+
+	// with no candidates left, each replication finishes as soon as it starts
+	for id := storagemarket.ReplicationID(0); id < 3; id++ {
+		r := &replication{
+			c:      c,
+			params: storagemarket.ReplicationParams{Replicas: 1},
+			status: storagemarket.ReplicationStatus{ID: id, Replicas: 1},
+		}
+		c.replications[id] = r
+		r.start()
+
+		status, err := c.GetReplicationStatus(ctx, id)
+		require.NoError(t, err)
+		require.True(t, status.Complete)
+	}
+
+	_, err := c.GetReplicationStatus(ctx, 0)
+	require.EqualError(t, err, "no replication with ID 0")
+	for id := storagemarket.ReplicationID(1); id < 3; id++ {
+		_, err := c.GetReplicationStatus(ctx, id)
+		require.NoError(t, err)
+	}
+}
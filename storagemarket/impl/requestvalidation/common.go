@@ -54,6 +54,7 @@ func ValidatePush(
 // - voucher has correct type
 // - voucher references an active deal
 // - referenced deal matches the receiver (miner)
+// - referenced deal asked the provider to pull its data
 // - referenced deal matches the given base CID
 // - referenced deal is in an acceptable state
 func ValidatePull(
@@ -76,10 +77,13 @@ func ValidatePull(
 	if deal.Miner != receiver {
 		return xerrors.Errorf("Deal Peer %s, Data Transfer Peer %s: %w", deal.Miner.String(), receiver.String(), ErrWrongPeer)
 	}
+	if deal.DataRef.TransferType != storagemarket.TTProviderPull {
+		return xerrors.Errorf("Deal Transfer Type %s: %w", deal.DataRef.TransferType, ErrWrongTransferType)
+	}
 	if !deal.DataRef.Root.Equals(baseCid) {
 		return xerrors.Errorf("Deal Payload CID %s, Data Transfer CID %s: %w", deal.Proposal.PieceCID.String(), baseCid.String(), ErrWrongPiece)
 	}
-	for _, state := range DataTransferStates {
+	for _, state := range PullDataTransferStates {
 		if deal.State == state {
 			return nil
 		}
@@ -70,7 +70,8 @@ func newClientDeal(minerID peer.ID, state storagemarket.StorageDealStatus) (stor
 		ClientDealProposal: newProposal,
 		ProposalCid:        proposalNd.Cid(),
 		DataRef: &storagemarket.DataRef{
-			Root: blockGenerator.Next().Cid(),
+			TransferType: storagemarket.TTProviderPull,
+			Root:         blockGenerator.Next().Cid(),
 		},
 		Miner:       minerID,
 		MinerWorker: minerAddr,
@@ -245,6 +246,20 @@ func AssertValidatesPulls(t *testing.T, validator datatransfer.RequestValidator,
 			t.Fatal("Pull should fail if piece ref is incorrect")
 		}
 	})
+	t.Run("ValidatePull fails wrong transfer type", func(t *testing.T) {
+		clientDeal, err := newClientDeal(receiver, storagemarket.StorageDealWaitingForDataRequest)
+		if err != nil {
+			t.Fatal("error creating client deal")
+		}
+		clientDeal.DataRef.TransferType = storagemarket.TTGraphsync
+		if err := state.Begin(clientDeal.ProposalCid, &clientDeal); err != nil {
+			t.Fatal("deal tracking failed")
+		}
+		payloadCid := clientDeal.DataRef.Root
+		if !xerrors.Is(validator.ValidatePull(receiver, &rv.StorageDataTransferVoucher{clientDeal.ProposalCid}, payloadCid, nil), rv.ErrWrongTransferType) {
+			t.Fatal("Pull should fail if the client pushes the data for the deal")
+		}
+	})
 	t.Run("ValidatePull fails wrong deal state", func(t *testing.T) {
 		clientDeal, err := newClientDeal(receiver, storagemarket.StorageDealActive)
 		if err != nil {
@@ -259,7 +274,7 @@ func AssertValidatesPulls(t *testing.T, validator datatransfer.RequestValidator,
 		}
 	})
 	t.Run("ValidatePull succeeds", func(t *testing.T) {
-		clientDeal, err := newClientDeal(receiver, storagemarket.StorageDealValidating)
+		clientDeal, err := newClientDeal(receiver, storagemarket.StorageDealWaitingForDataRequest)
 		if err != nil {
 			t.Fatal("error creating client deal")
 		}
@@ -32,12 +32,21 @@ var (
 	// the one specified in the deal
 	ErrWrongPiece = errors.New("base CID for deal does not match CID for piece")
 
+	// ErrWrongTransferType means the deal for this pull request did not ask the
+	// provider to pull its data
+	ErrWrongTransferType = errors.New("deal data is not transferred by a provider pull")
+
 	// ErrInacceptableDealState means the deal for this transfer is not in a deal state
 	// where transfer can be performed
 	ErrInacceptableDealState = errors.New("deal is not a in a state where deals are accepted")
 
 	// DataTransferStates are the states in which it would make sense to actually start a data transfer
 	DataTransferStates = []storagemarket.StorageDealStatus{storagemarket.StorageDealValidating, storagemarket.StorageDealUnknown}
+
+	// PullDataTransferStates are the client deal states in which a provider may
+	// pull the deal data. The provider can open the channel before the client
+	// has processed its response, so the client may still be waiting for it
+	PullDataTransferStates = []storagemarket.StorageDealStatus{storagemarket.StorageDealWaitingForDataRequest, storagemarket.StorageDealTransferring}
 )
 
 // StorageDataTransferVoucher is the voucher type for data transfers
@@ -8,6 +8,7 @@ import (
 	"github.com/filecoin-project/go-address"
 	cborutil "github.com/filecoin-project/go-cbor-util"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/ipfs/go-datastore"
 	logging "github.com/ipfs/go-log/v2"
 	"golang.org/x/xerrors"
@@ -25,21 +26,43 @@ const defaultMinPieceSize abi.PaddedPieceSize = 256
 // It would be nice to default this to the miner's sector size
 const defaultMaxPieceSize abi.PaddedPieceSize = 1 << 20
 
+// DefaultRenewalBuffer is how many epochs before the ask expires that it is
+// re-issued with a new expiry
+const DefaultRenewalBuffer abi.ChainEpoch = 100
+
 type StoredAsk struct {
-	askLk sync.RWMutex
-	ask   *storagemarket.SignedStorageAsk
-	ds    datastore.Batching
-	dsKey datastore.Key
-	spn   storagemarket.StorageProviderNode
-	actor address.Address
+	askLk         sync.Mutex
+	ask           *storagemarket.SignedStorageAsk
+	ds            datastore.Batching
+	dsKey         datastore.Key
+	spn           storagemarket.StorageProviderNode
+	actor         address.Address
+	renewalBuffer abi.ChainEpoch
 }
 
-func NewStoredAsk(ds datastore.Batching, dsKey datastore.Key, spn storagemarket.StorageProviderNode, actor address.Address) (*StoredAsk, error) {
+// StoredAskOption configures a StoredAsk
+type StoredAskOption func(*StoredAsk)
+
+// RenewalBuffer sets how many epochs before the ask expires that it is
+// re-issued. The buffer is capped at half the ask's duration, so that a short
+// lived ask isn't re-issued every time it is read
+func RenewalBuffer(epochs abi.ChainEpoch) StoredAskOption {
+	return func(s *StoredAsk) {
+		s.renewalBuffer = epochs
+	}
+}
+
+func NewStoredAsk(ds datastore.Batching, dsKey datastore.Key, spn storagemarket.StorageProviderNode, actor address.Address, options ...StoredAskOption) (*StoredAsk, error) {
 
 	s := &StoredAsk{
-		ds:    ds,
-		spn:   spn,
-		actor: actor,
+		ds:            ds,
+		spn:           spn,
+		actor:         actor,
+		renewalBuffer: DefaultRenewalBuffer,
+	}
+
+	for _, option := range options {
+		option(s)
 	}
 
 	if err := s.tryLoadAsk(); err != nil {
@@ -78,12 +101,65 @@ func (s *StoredAsk) AddAsk(price abi.TokenAmount, duration abi.ChainEpoch, optio
 		SeqNo:        seqno,
 		MinPieceSize: defaultMinPieceSize,
 		MaxPieceSize: defaultMaxPieceSize,
+		// by default any provider collateral is accepted
+		MinProviderCollateral: big.Zero(),
+		MaxProviderCollateral: big.Zero(),
 	}
 
 	for _, option := range options {
 		option(ask)
 	}
 
+	return s.signAndSaveAsk(ctx, ask)
+}
+
+// GetAsk returns the current ask for the given miner. The chain height is
+// checked each time the ask is read, and an ask that is about to expire is
+// first re-issued with the same terms
+func (s *StoredAsk) GetAsk(addr address.Address) *storagemarket.SignedStorageAsk {
+	s.askLk.Lock()
+	defer s.askLk.Unlock()
+	if s.actor != addr {
+		return nil
+	}
+	if s.ask == nil {
+		return nil
+	}
+	if err := s.renewIfExpiring(); err != nil {
+		log.Errorf("failed to renew storage ask: %s", err)
+	}
+	ask := *s.ask
+	return &ask
+}
+
+// renewIfExpiring re-issues the ask with the next sequence number and a new
+// expiry when the chain is within the renewal buffer of its expiry. The new
+// ask lasts as long as the one it replaces
+func (s *StoredAsk) renewIfExpiring() error {
+	ctx := context.TODO()
+
+	_, height, err := s.spn.GetChainHead(ctx)
+	if err != nil {
+		return err
+	}
+
+	duration := s.ask.Ask.Expiry - s.ask.Ask.Timestamp
+	buffer := s.renewalBuffer
+	if buffer > duration/2 {
+		buffer = duration / 2
+	}
+	if height < s.ask.Ask.Expiry-buffer {
+		return nil
+	}
+
+	ask := *s.ask.Ask
+	ask.Timestamp = height
+	ask.Expiry = height + duration
+	ask.SeqNo++
+	return s.signAndSaveAsk(ctx, &ask)
+}
+
+func (s *StoredAsk) signAndSaveAsk(ctx context.Context, ask *storagemarket.StorageAsk) error {
 	tok, _, err := s.spn.GetChainHead(ctx)
 	if err != nil {
 		return err
@@ -98,20 +174,6 @@ func (s *StoredAsk) AddAsk(price abi.TokenAmount, duration abi.ChainEpoch, optio
 		Ask:       ask,
 		Signature: sig,
 	})
-
-}
-
-func (s *StoredAsk) GetAsk(addr address.Address) *storagemarket.SignedStorageAsk {
-	s.askLk.RLock()
-	defer s.askLk.RUnlock()
-	if s.actor != addr {
-		return nil
-	}
-	if s.ask == nil {
-		return nil
-	}
-	ask := *s.ask
-	return &ask
 }
 
 func (s *StoredAsk) tryLoadAsk() error {
@@ -54,6 +54,41 @@ func TestStoredAsk(t *testing.T) {
 		require.Equal(t, ask.Ask.Price, testPrice)
 		require.Equal(t, ask.Ask.Expiry-ask.Ask.Timestamp, testDuration)
 	})
+	t.Run("renewing an ask before it expires", func(t *testing.T) {
+		err := storedAsk.AddAsk(testPrice, testDuration, storagemarket.MinPieceSize(1024))
+		require.NoError(t, err)
+		ask := storedAsk.GetAsk(actor)
+
+		// not yet within the renewal buffer of the expiry
+		spn.SMState.Epoch = ask.Ask.Expiry - storedask.DefaultRenewalBuffer - 1
+		require.Equal(t, ask, storedAsk.GetAsk(actor))
+
+		spn.SMState.Epoch = ask.Ask.Expiry - storedask.DefaultRenewalBuffer
+		renewed := storedAsk.GetAsk(actor)
+		require.Equal(t, ask.Ask.SeqNo+1, renewed.Ask.SeqNo)
+		require.Equal(t, spn.SMState.Epoch, renewed.Ask.Timestamp)
+		require.Equal(t, spn.SMState.Epoch+testDuration, renewed.Ask.Expiry)
+		require.Equal(t, testPrice, renewed.Ask.Price)
+		require.Equal(t, abi.PaddedPieceSize(1024), renewed.Ask.MinPieceSize)
+
+		// the renewed ask is stored
+		storedAsk2, err := storedask.NewStoredAsk(ds, datastore.NewKey("latest-ask"), spn, actor)
+		require.NoError(t, err)
+		require.Equal(t, renewed, storedAsk2.GetAsk(actor))
+	})
+	t.Run("renewal buffer is capped at half the ask duration", func(t *testing.T) {
+		storedAsk, err := storedask.NewStoredAsk(ds, datastore.NewKey("latest-ask"), spn, actor, storedask.RenewalBuffer(1000))
+		require.NoError(t, err)
+		err = storedAsk.AddAsk(testPrice, testDuration)
+		require.NoError(t, err)
+		ask := storedAsk.GetAsk(actor)
+
+		spn.SMState.Epoch = ask.Ask.Expiry - testDuration/2 - 1
+		require.Equal(t, ask.Ask.SeqNo, storedAsk.GetAsk(actor).Ask.SeqNo)
+
+		spn.SMState.Epoch = ask.Ask.Expiry - testDuration/2
+		require.Equal(t, ask.Ask.SeqNo+1, storedAsk.GetAsk(actor).Ask.SeqNo)
+	})
 	t.Run("node errors", func(t *testing.T) {
 		spnStateIDErr := &testnodes.FakeProviderNode{
 			FakeCommonNode: testnodes.FakeCommonNode{
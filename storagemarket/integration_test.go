@@ -4,20 +4,28 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/filecoin-project/go-address"
 	datatransfer "github.com/filecoin-project/go-data-transfer"
 	graphsync "github.com/filecoin-project/go-data-transfer/impl/graphsync"
+	"github.com/filecoin-project/go-statestore"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/filecoin-project/specs-actors/actors/builtin/market"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
+	"github.com/ipld/go-car"
 	"github.com/ipld/go-ipld-prime"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
 	"github.com/libp2p/go-libp2p-core/peer"
@@ -149,6 +157,59 @@ func TestMakeDeal(t *testing.T) {
 	shared_testutil.AssertDealState(t, storagemarket.StorageDealCompleted, pd.State)
 }
 
+func TestMakeDealProviderPull(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, ctx)
+	h.Client.Run(ctx)
+
+	providerDealChan := make(chan storagemarket.MinerDeal)
+	_ = h.Provider.SubscribeToEvents(func(event storagemarket.ProviderEvent, deal storagemarket.MinerDeal) {
+		providerDealChan <- deal
+	})
+	clientDealChan := make(chan storagemarket.ClientDeal)
+	_ = h.Client.SubscribeToEvents(func(event storagemarket.ClientEvent, deal storagemarket.ClientDeal) {
+		clientDealChan <- deal
+	})
+
+	result := h.ProposeStorageDeal(t, &storagemarket.DataRef{TransferType: storagemarket.TTProviderPull, Root: h.PayloadCid})
+	proposalCid := result.ProposalCid
+
+	ctx, canc := context.WithTimeout(ctx, time.Second)
+	defer canc()
+	var providerSeenDeal storagemarket.MinerDeal
+	var clientSeenDeal storagemarket.ClientDeal
+	var providerstates []storagemarket.StorageDealStatus
+	for providerSeenDeal.State != storagemarket.StorageDealCompleted ||
+		clientSeenDeal.State != storagemarket.StorageDealActive {
+		select {
+		case clientSeenDeal = <-clientDealChan:
+		case providerSeenDeal = <-providerDealChan:
+			providerstates = append(providerstates, providerSeenDeal.State)
+		case <-ctx.Done():
+			t.Fatalf("deal incomplete, client deal state: %s (%d), provider deal state: %s (%d)",
+				storagemarket.DealStates[clientSeenDeal.State],
+				clientSeenDeal.State,
+				storagemarket.DealStates[providerSeenDeal.State],
+				providerSeenDeal.State,
+			)
+		}
+	}
+
+	// the provider pulls the data over a channel it opened itself
+	assert.Subset(t, providerstates, []storagemarket.StorageDealStatus{
+		storagemarket.StorageDealWaitingForData,
+		storagemarket.StorageDealTransferring,
+		storagemarket.StorageDealVerifyData,
+	})
+	assert.Empty(t, providerSeenDeal.Message)
+	assert.Equal(t, proposalCid, providerSeenDeal.ProposalCid)
+	assert.Equal(t, h.TestData.Host1.ID(), providerSeenDeal.Client)
+
+	has, err := h.TestData.Bs2.Has(h.PayloadCid)
+	require.NoError(t, err)
+	assert.True(t, has)
+}
+
 func TestMakeDealOffline(t *testing.T) {
 	ctx := context.Background()
 	h := newHarness(t, ctx)
@@ -176,7 +237,7 @@ func TestMakeDealOffline(t *testing.T) {
 
 	cd, err := h.Client.GetLocalDeal(ctx, proposalCid)
 	assert.NoError(t, err)
-	shared_testutil.AssertDealState(t, storagemarket.StorageDealValidating, cd.State)
+	shared_testutil.AssertDealState(t, storagemarket.StorageDealCheckForAcceptance, cd.State)
 
 	providerDeals, err := h.Provider.ListLocalDeals()
 	assert.NoError(t, err)
@@ -204,6 +265,548 @@ func TestMakeDealOffline(t *testing.T) {
 	shared_testutil.AssertDealState(t, storagemarket.StorageDealCompleted, pd.State)
 }
 
+func TestMakeDealOfflineFromFile(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, ctx, storageimpl.EnableUniversalRetrieval())
+	h.Client.Run(ctx)
+
+	carBuf := new(bytes.Buffer)
+	err := cario.NewCarIO().WriteCar(ctx, h.TestData.Bs1, h.PayloadCid, shared.AllSelector(), carBuf)
+	require.NoError(t, err)
+	carData := carBuf.Bytes()
+
+	commP, size, err := pieceio.GeneratePieceCommitment(abi.RegisteredProof_StackedDRG2KiBPoSt, bytes.NewReader(carData), uint64(len(carData)))
+	require.NoError(t, err)
+
+	writeFile := func(data []byte) filestore.Path {
+		f, err := h.FileStore.CreateTemp()
+		require.NoError(t, err)
+		_, err = f.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		return f.Path()
+	}
+
+	providerDeal := func(proposalCid cid.Cid) storagemarket.MinerDeal {
+		providerDeals, err := h.Provider.ListLocalDeals()
+		require.NoError(t, err)
+		for _, pd := range providerDeals {
+			if pd.ProposalCid.Equals(proposalCid) {
+				return pd
+			}
+		}
+		t.Fatalf("provider has no deal %s", proposalCid)
+		return storagemarket.MinerDeal{}
+	}
+
+	proposeDeal := func(pieceSize abi.UnpaddedPieceSize, price abi.TokenAmount) cid.Cid {
+		result, err := h.Client.ProposeStorageDeal(ctx, h.ProviderAddr, &h.ProviderInfo, &storagemarket.DataRef{
+			TransferType: storagemarket.TTManual,
+			Root:         h.PayloadCid,
+			PieceCid:     &commP,
+			PieceSize:    pieceSize,
+		}, h.Epoch+100, h.Epoch+20100, price, big.NewInt(0), abi.RegisteredProof_StackedDRG2KiBPoSt)
+		require.NoError(t, err)
+		time.Sleep(time.Millisecond * 100)
+		shared_testutil.AssertDealState(t, storagemarket.StorageDealWaitingForData, providerDeal(result.ProposalCid).State)
+		return result.ProposalCid
+	}
+
+	// a file that is not imported stays with the caller
+	requireFileKept := func(path filestore.Path) {
+		f, err := h.FileStore.Open(path)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+	}
+
+	t.Run("rejects a file that does not match the deal piece size", func(t *testing.T) {
+		proposalCid := proposeDeal(2*size, big.NewInt(1))
+		path := writeFile(carData)
+		err := h.Provider.ImportDataForDealFromFile(ctx, proposalCid, path)
+		require.EqualError(t, err, fmt.Sprintf("CAR file of %d bytes pads to piece size %d, but the deal data ref has piece size %d", len(carData), size, 2*size))
+		requireFileKept(path)
+	})
+
+	proposalCid := proposeDeal(size, big.NewInt(1))
+
+	t.Run("rejects a file without the payload as its root", func(t *testing.T) {
+		otherRoot := shared_testutil.GenerateCids(1)[0]
+		headerBuf := new(bytes.Buffer)
+		require.NoError(t, car.WriteHeader(&car.CarHeader{Roots: []cid.Cid{otherRoot}, Version: 1}, headerBuf))
+		path := writeFile(headerBuf.Bytes())
+		err := h.Provider.ImportDataForDealFromFile(ctx, proposalCid, path)
+		require.EqualError(t, err, fmt.Sprintf("CAR file roots [%s] do not match deal payload root %s", otherRoot, h.PayloadCid))
+		requireFileKept(path)
+	})
+
+	path := writeFile(carData)
+	err = h.Provider.ImportDataForDealFromFile(ctx, proposalCid, path)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 100)
+
+	cd, err := h.Client.GetLocalDeal(ctx, proposalCid)
+	require.NoError(t, err)
+	shared_testutil.AssertDealState(t, storagemarket.StorageDealActive, cd.State)
+
+	shared_testutil.AssertDealState(t, storagemarket.StorageDealCompleted, providerDeal(proposalCid).State)
+
+	// the provider is done with the file once the deal is handed off
+	_, err = h.FileStore.Open(path)
+	require.Error(t, err)
+
+	// every block in the file can be found in the piece
+	carReader, err := car.NewCarReader(bytes.NewReader(carData))
+	require.NoError(t, err)
+	for {
+		blk, err := carReader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		cidInfo, err := h.PieceStore.GetCIDInfo(blk.Cid())
+		require.NoError(t, err)
+		require.Len(t, cidInfo.PieceBlockLocations, 1)
+		require.Equal(t, commP, cidInfo.PieceBlockLocations[0].PieceCID)
+	}
+
+	t.Run("a deal that fails deletes the file it imported", func(t *testing.T) {
+		h.ProviderNode.OnDealCompleteError = errors.New("sealing unavailable")
+		defer func() {
+			h.ProviderNode.OnDealCompleteError = nil
+		}()
+
+		proposalCid := proposeDeal(size, big.NewInt(2))
+		path := writeFile(carData)
+		require.NoError(t, h.Provider.ImportDataForDealFromFile(ctx, proposalCid, path))
+
+		require.Eventually(t, func() bool {
+			return providerDeal(proposalCid).State == storagemarket.StorageDealError
+		}, time.Second, 10*time.Millisecond)
+		_, err := h.FileStore.Open(path)
+		require.Error(t, err)
+	})
+}
+
+func TestMakeDealHTTP(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, ctx)
+	h.Client.Run(ctx)
+
+	carBuf := new(bytes.Buffer)
+	err := cario.NewCarIO().WriteCar(ctx, h.TestData.Bs1, h.PayloadCid, shared.AllSelector(), carBuf)
+	require.NoError(t, err)
+	carData := carBuf.Bytes()
+
+	commP, size, err := pieceio.GeneratePieceCommitment(abi.RegisteredProof_StackedDRG2KiBPoSt, bytes.NewReader(carData), uint64(len(carData)))
+	require.NoError(t, err)
+
+	// the client hosts the CAR file itself, behind authorization
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.ServeContent(w, r, "payload.car", time.Time{}, bytes.NewReader(carData))
+	}))
+	defer server.Close()
+
+	dataRef := &storagemarket.DataRef{
+		TransferType: storagemarket.TTHTTP,
+		Root:         h.PayloadCid,
+		PieceCid:     &commP,
+		PieceSize:    size,
+		URL:          server.URL + "/payload.car",
+		Headers:      []storagemarket.HTTPHeader{{Name: "Authorization", Value: "Bearer secret"}},
+	}
+
+	result := h.ProposeStorageDeal(t, dataRef)
+	proposalCid := result.ProposalCid
+
+	time.Sleep(time.Millisecond * 200)
+
+	cd, err := h.Client.GetLocalDeal(ctx, proposalCid)
+	assert.NoError(t, err)
+	shared_testutil.AssertDealState(t, storagemarket.StorageDealActive, cd.State)
+
+	providerDeals, err := h.Provider.ListLocalDeals()
+	assert.NoError(t, err)
+
+	pd := providerDeals[0]
+	assert.True(t, pd.ProposalCid.Equals(proposalCid))
+	assert.Empty(t, pd.Message)
+	shared_testutil.AssertDealState(t, storagemarket.StorageDealCompleted, pd.State)
+
+	usage, err := h.Provider.StagingUsage()
+	require.NoError(t, err)
+	require.Zero(t, usage.Reserved)
+}
+
+func TestMakeDealHTTPDownloadFails(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, ctx)
+	h.Client.Run(ctx)
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	commP := shared_testutil.GenerateCids(1)[0]
+	result := h.ProposeStorageDeal(t, &storagemarket.DataRef{
+		TransferType: storagemarket.TTHTTP,
+		Root:         h.PayloadCid,
+		PieceCid:     &commP,
+		PieceSize:    1016,
+		URL:          server.URL + "/payload.car",
+	})
+
+	require.Eventually(t, func() bool {
+		pd, err := h.Provider.ListLocalDeals()
+		require.NoError(t, err)
+		return len(pd) == 1 && pd[0].State == storagemarket.StorageDealError
+	}, time.Second, 10*time.Millisecond)
+
+	pd, err := h.Provider.ListLocalDeals()
+	require.NoError(t, err)
+	require.Equal(t, "error transferring data: downloading data: unexpected response status 404 Not Found", pd[0].Message)
+
+	_, err = h.FileStore.Open(filestore.Path("deal-" + result.ProposalCid.String() + ".car"))
+	require.Error(t, err)
+}
+
+func TestProviderCancelDealDuringDownload(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, ctx)
+	h.Client.Run(ctx)
+
+	// the server sends part of the file, then hangs until the request is
+	// cancelled
+	requested := make(chan struct{})
+	cancelled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1016")
+		_, _ = w.Write(make([]byte, 100))
+		w.(http.Flusher).Flush()
+		close(requested)
+		<-r.Context().Done()
+		close(cancelled)
+	}))
+	defer server.Close()
+
+	commP := shared_testutil.GenerateCids(1)[0]
+	result := h.ProposeStorageDeal(t, &storagemarket.DataRef{
+		TransferType: storagemarket.TTHTTP,
+		Root:         h.PayloadCid,
+		PieceCid:     &commP,
+		PieceSize:    1016,
+		URL:          server.URL + "/payload.car",
+	})
+
+	select {
+	case <-requested:
+	case <-time.After(time.Second):
+		t.Fatal("provider did not start the download")
+	}
+
+	require.NoError(t, h.Provider.CancelDeal(ctx, result.ProposalCid, "taking too long"))
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("download was not cancelled")
+	}
+
+	require.Eventually(t, func() bool {
+		pd, err := h.Provider.ListLocalDeals()
+		require.NoError(t, err)
+		return len(pd) == 1 && pd[0].State == storagemarket.StorageDealError
+	}, time.Second, 10*time.Millisecond)
+
+	path := filestore.Path("deal-" + result.ProposalCid.String() + ".car")
+	require.Eventually(t, func() bool {
+		_, err := h.FileStore.Open(path)
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+
+	usage, err := h.Provider.StagingUsage()
+	require.NoError(t, err)
+	require.Zero(t, usage.Reserved)
+}
+
+func TestMakeDealWithOperatorApproval(t *testing.T) {
+	ctx := context.Background()
+
+	waitForPendingDeal := func(t *testing.T, h *harness, proposalCid cid.Cid) {
+		require.Eventually(t, func() bool {
+			pending, err := h.Provider.ListPendingDeals()
+			require.NoError(t, err)
+			return len(pending) == 1 && pending[0].ProposalCid.Equals(proposalCid)
+		}, time.Second, 10*time.Millisecond)
+	}
+
+	t.Run("operator approves deal", func(t *testing.T) {
+		h := newHarness(t, ctx, storageimpl.ManualDealApproval(10, time.Hour))
+		h.Client.Run(ctx)
+
+		result := h.ProposeStorageDeal(t, &storagemarket.DataRef{TransferType: storagemarket.TTGraphsync, Root: h.PayloadCid})
+		waitForPendingDeal(t, h, result.ProposalCid)
+
+		pd, err := h.Provider.ListLocalDeals()
+		require.NoError(t, err)
+		shared_testutil.AssertDealState(t, storagemarket.StorageDealAcceptWait, pd[0].State)
+
+		require.NoError(t, h.Provider.ApproveDeal(result.ProposalCid))
+		require.Error(t, h.Provider.ApproveDeal(result.ProposalCid))
+
+		require.Eventually(t, func() bool {
+			cd, err := h.Client.GetLocalDeal(ctx, result.ProposalCid)
+			require.NoError(t, err)
+			return cd.State == storagemarket.StorageDealActive
+		}, time.Second, 10*time.Millisecond)
+
+		pending, err := h.Provider.ListPendingDeals()
+		require.NoError(t, err)
+		require.Empty(t, pending)
+	})
+
+	t.Run("operator rejects deal", func(t *testing.T) {
+		h := newHarness(t, ctx, storageimpl.ManualDealApproval(10, time.Hour))
+		h.Client.Run(ctx)
+
+		result := h.ProposeStorageDeal(t, &storagemarket.DataRef{TransferType: storagemarket.TTGraphsync, Root: h.PayloadCid})
+		waitForPendingDeal(t, h, result.ProposalCid)
+
+		require.NoError(t, h.Provider.RejectDeal(result.ProposalCid, "not today"))
+
+		require.Eventually(t, func() bool {
+			pd, err := h.Provider.ListLocalDeals()
+			require.NoError(t, err)
+			return pd[0].State == storagemarket.StorageDealError
+		}, time.Second, 10*time.Millisecond)
+
+		pd, err := h.Provider.ListLocalDeals()
+		require.NoError(t, err)
+		require.Equal(t, "deal rejected: not today", pd[0].Message)
+	})
+
+	t.Run("pending deal is rejected when time runs out", func(t *testing.T) {
+		// with the default acceptance buffer of 100 epochs the deal, starting
+		// at epoch 200, has to be approved before epoch 0
+		h := newHarness(t, ctx, storageimpl.ManualDealApproval(100, 10*time.Millisecond))
+		h.Client.Run(ctx)
+
+		result := h.ProposeStorageDeal(t, &storagemarket.DataRef{TransferType: storagemarket.TTGraphsync, Root: h.PayloadCid})
+
+		require.Eventually(t, func() bool {
+			pd, err := h.Provider.ListLocalDeals()
+			require.NoError(t, err)
+			return len(pd) == 1 && pd[0].State == storagemarket.StorageDealError
+		}, time.Second, 10*time.Millisecond)
+
+		pd, err := h.Provider.ListLocalDeals()
+		require.NoError(t, err)
+		require.Equal(t, result.ProposalCid, pd[0].ProposalCid)
+		require.Equal(t, "deal rejected: operator did not approve deal before epoch 0", pd[0].Message)
+	})
+}
+
+func TestMakeDealRejectedByDealFilter(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, ctx, storageimpl.DealFilters(
+		storageimpl.DenyClientAddresses(address.TestAddress),
+		storageimpl.AcceptTransferTypes(storagemarket.TTManual),
+	))
+	h.Client.Run(ctx)
+
+	result := h.ProposeStorageDeal(t, &storagemarket.DataRef{TransferType: storagemarket.TTGraphsync, Root: h.PayloadCid})
+
+	require.Eventually(t, func() bool {
+		cd, err := h.Client.GetLocalDeal(ctx, result.ProposalCid)
+		require.NoError(t, err)
+		return cd.State == storagemarket.StorageDealError
+	}, time.Second, 10*time.Millisecond)
+
+	cd, err := h.Client.GetLocalDeal(ctx, result.ProposalCid)
+	require.NoError(t, err)
+	require.Contains(t, cd.Message, "deal rejected: transfer type: transfer type graphsync is not accepted")
+}
+
+func TestProviderCancelDeal(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, ctx)
+	h.Client.Run(ctx)
+
+	carBuf := new(bytes.Buffer)
+	err := cario.NewCarIO().WriteCar(ctx, h.TestData.Bs1, h.PayloadCid, shared.AllSelector(), carBuf)
+	require.NoError(t, err)
+	commP, size, err := pieceio.GeneratePieceCommitment(abi.RegisteredProof_StackedDRG2KiBPoSt, carBuf, uint64(carBuf.Len()))
+	require.NoError(t, err)
+
+	result := h.ProposeStorageDeal(t, &storagemarket.DataRef{
+		TransferType: storagemarket.TTManual,
+		Root:         h.PayloadCid,
+		PieceCid:     &commP,
+		PieceSize:    size,
+	})
+
+	require.Eventually(t, func() bool {
+		pd, err := h.Provider.ListLocalDeals()
+		require.NoError(t, err)
+		return len(pd) == 1 && pd[0].State == storagemarket.StorageDealWaitingForData
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, h.Provider.CancelDeal(ctx, result.ProposalCid, "out of space"))
+
+	require.Eventually(t, func() bool {
+		cd, err := h.Client.GetLocalDeal(ctx, result.ProposalCid)
+		require.NoError(t, err)
+		return cd.State == storagemarket.StorageDealError
+	}, time.Second, 10*time.Millisecond)
+
+	pd, err := h.Provider.ListLocalDeals()
+	require.NoError(t, err)
+	shared_testutil.AssertDealState(t, storagemarket.StorageDealError, pd[0].State)
+	require.Equal(t, "deal cancelled by provider: out of space", pd[0].Message)
+
+	usage, err := h.Provider.StagingUsage()
+	require.NoError(t, err)
+	require.Zero(t, usage.Reserved)
+
+	err = h.Provider.CancelDeal(ctx, result.ProposalCid, "out of space")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "cannot cancel deal "+result.ProposalCid.String()+" in state StorageDealError")
+}
+
+func TestClientCancelDeal(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, ctx)
+	h.Client.Run(ctx)
+
+	carBuf := new(bytes.Buffer)
+	err := cario.NewCarIO().WriteCar(ctx, h.TestData.Bs1, h.PayloadCid, shared.AllSelector(), carBuf)
+	require.NoError(t, err)
+	commP, size, err := pieceio.GeneratePieceCommitment(abi.RegisteredProof_StackedDRG2KiBPoSt, carBuf, uint64(carBuf.Len()))
+	require.NoError(t, err)
+
+	result := h.ProposeStorageDeal(t, &storagemarket.DataRef{
+		TransferType: storagemarket.TTManual,
+		Root:         h.PayloadCid,
+		PieceCid:     &commP,
+		PieceSize:    size,
+	})
+
+	require.Eventually(t, func() bool {
+		cd, err := h.Client.GetLocalDeal(ctx, result.ProposalCid)
+		require.NoError(t, err)
+		return cd.State == storagemarket.StorageDealCheckForAcceptance
+	}, time.Second, 10*time.Millisecond)
+
+	var eventsLk sync.Mutex
+	var events []storagemarket.ClientEvent
+	_ = h.Client.SubscribeToEvents(func(event storagemarket.ClientEvent, deal storagemarket.ClientDeal) {
+		eventsLk.Lock()
+		defer eventsLk.Unlock()
+		events = append(events, event)
+	})
+
+	require.NoError(t, h.Client.CancelDeal(ctx, result.ProposalCid))
+
+	require.Eventually(t, func() bool {
+		pd, err := h.Provider.ListLocalDeals()
+		require.NoError(t, err)
+		return pd[0].State == storagemarket.StorageDealError
+	}, time.Second, 10*time.Millisecond)
+
+	pd, err := h.Provider.ListLocalDeals()
+	require.NoError(t, err)
+	require.Equal(t, "deal cancelled by client", pd[0].Message)
+
+	require.Eventually(t, func() bool {
+		cd, err := h.Client.GetLocalDeal(ctx, result.ProposalCid)
+		require.NoError(t, err)
+		return cd.State == storagemarket.StorageDealError
+	}, time.Second, 10*time.Millisecond)
+
+	cd, err := h.Client.GetLocalDeal(ctx, result.ProposalCid)
+	require.NoError(t, err)
+	require.Equal(t, "deal cancelled by client", cd.Message)
+
+	eventsLk.Lock()
+	require.Contains(t, events, storagemarket.ClientEventCancelled)
+	eventsLk.Unlock()
+
+	err = h.Client.CancelDeal(ctx, result.ProposalCid)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "cannot cancel deal "+result.ProposalCid.String()+" in state StorageDealError")
+}
+
+func TestProviderIgnoresOldClientCancellation(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, ctx)
+	h.Client.Run(ctx)
+
+	carBuf := new(bytes.Buffer)
+	err := cario.NewCarIO().WriteCar(ctx, h.TestData.Bs1, h.PayloadCid, shared.AllSelector(), carBuf)
+	require.NoError(t, err)
+	commP, size, err := pieceio.GeneratePieceCommitment(abi.RegisteredProof_StackedDRG2KiBPoSt, carBuf, uint64(carBuf.Len()))
+	require.NoError(t, err)
+
+	result := h.ProposeStorageDeal(t, &storagemarket.DataRef{
+		TransferType: storagemarket.TTManual,
+		Root:         h.PayloadCid,
+		PieceCid:     &commP,
+		PieceSize:    size,
+	})
+
+	require.Eventually(t, func() bool {
+		pd, err := h.Provider.ListLocalDeals()
+		require.NoError(t, err)
+		return len(pd) == 1 && pd[0].State == storagemarket.StorageDealWaitingForData
+	}, time.Second, 10*time.Millisecond)
+
+	// a cancellation signed long before the current epoch is sent again
+	_, epoch, err := h.ClientNode.GetChainHead(ctx)
+	require.NoError(t, err)
+	s, err := network.NewFromLibp2pHost(h.TestData.Host1).NewDealCancellationStream(h.ProviderInfo.PeerID)
+	require.NoError(t, err)
+	err = s.WriteDealCancellation(network.DealCancellation{
+		Proposal:  result.ProposalCid,
+		Epoch:     epoch - 100,
+		Signature: *shared_testutil.MakeTestSignature(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	time.Sleep(100 * time.Millisecond)
+	pd, err := h.Provider.ListLocalDeals()
+	require.NoError(t, err)
+	shared_testutil.AssertDealState(t, storagemarket.StorageDealWaitingForData, pd[0].State)
+}
+
+func TestCommPProgress(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, ctx)
+	h.Client.Run(ctx)
+
+	var progress []storagemarket.CommPProgress
+	_ = h.Client.SubscribeToCommPProgress(func(p storagemarket.CommPProgress) {
+		progress = append(progress, p)
+	})
+
+	result := h.ProposeStorageDeal(t, &storagemarket.DataRef{TransferType: storagemarket.TTGraphsync, Root: h.PayloadCid})
+	require.True(t, result.ProposalCid.Defined())
+
+	// progress is reported before ProposeStorageDeal returns
+	require.NotEmpty(t, progress)
+	last := progress[len(progress)-1]
+	require.Equal(t, h.PayloadCid, last.PayloadCid)
+	require.Equal(t, h.ProviderInfo.PeerID, last.Provider)
+	require.NotZero(t, last.Total)
+	require.Equal(t, last.Total, last.Processed)
+	for i := 1; i < len(progress); i++ {
+		require.True(t, progress[i].Processed > progress[i-1].Processed)
+	}
+}
+
 func TestMakeDealNonBlocking(t *testing.T) {
 	ctx := context.Background()
 	h := newHarness(t, ctx)
@@ -217,6 +820,10 @@ func TestMakeDealNonBlocking(t *testing.T) {
 	err := h.Provider.Start(ctx)
 	assert.NoError(t, err)
 
+	// require provider collateral, so the provider has to add funds for the deal
+	err = h.Provider.AddAsk(big.NewInt(0), 50_000, storagemarket.MinProviderCollateral(abi.NewTokenAmount(1<<30)))
+	assert.NoError(t, err)
+
 	result := h.ProposeStorageDeal(t, &storagemarket.DataRef{TransferType: storagemarket.TTGraphsync, Root: h.PayloadCid})
 
 	time.Sleep(time.Millisecond * 500)
@@ -234,6 +841,286 @@ func TestMakeDealNonBlocking(t *testing.T) {
 	shared_testutil.AssertDealState(t, storagemarket.StorageDealProviderFunding, pd.State)
 }
 
+func TestProposeDealCollateral(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("client proposes the piece size by default", func(t *testing.T) {
+		h := newHarness(t, ctx)
+		h.Client.Run(ctx)
+
+		result := h.ProposeStorageDeal(t, &storagemarket.DataRef{TransferType: storagemarket.TTGraphsync, Root: h.PayloadCid})
+
+		cd, err := h.Client.GetLocalDeal(ctx, result.ProposalCid)
+		require.NoError(t, err)
+		require.Equal(t, abi.NewTokenAmount(int64(cd.Proposal.PieceSize.Unpadded())), cd.Proposal.ProviderCollateral)
+		require.Equal(t, big.Zero(), cd.Proposal.ClientCollateral)
+	})
+
+	h := newHarness(t, ctx)
+	h.Client.Run(ctx)
+
+	// require between 1 and 2 FIL of provider collateral per GiB
+	err := h.Provider.AddAsk(big.NewInt(0), 50_000,
+		storagemarket.MinProviderCollateral(abi.NewTokenAmount(1<<30)),
+		storagemarket.MaxProviderCollateral(abi.NewTokenAmount(1<<31)),
+	)
+	require.NoError(t, err)
+
+	t.Run("client raises the default collateral to the ask minimum", func(t *testing.T) {
+		result := h.ProposeStorageDeal(t, &storagemarket.DataRef{TransferType: storagemarket.TTGraphsync, Root: h.PayloadCid})
+
+		cd, err := h.Client.GetLocalDeal(ctx, result.ProposalCid)
+		require.NoError(t, err)
+		require.Equal(t, abi.NewTokenAmount(int64(cd.Proposal.PieceSize)), cd.Proposal.ProviderCollateral)
+		require.Equal(t, big.Zero(), cd.Proposal.ClientCollateral)
+	})
+
+	t.Run("client proposes a given collateral without fetching the ask", func(t *testing.T) {
+		ask, err := h.Client.GetAsk(ctx, h.ProviderInfo)
+		require.NoError(t, err)
+
+		// the ask has expired for the client, so it cannot be fetched; the
+		// collateral is outside its bounds, which is for the provider to check
+		clientState := testnodes.NewStorageMarketState()
+		clientState.Epoch = ask.Ask.Expiry
+		h.ClientNode.SMState = clientState
+
+		result, err := h.Client.ProposeStorageDeal(
+			h.Ctx,
+			h.ProviderAddr,
+			&h.ProviderInfo,
+			&storagemarket.DataRef{TransferType: storagemarket.TTGraphsync, Root: h.PayloadCid},
+			h.Epoch+100,
+			h.Epoch+20100,
+			big.NewInt(1),
+			abi.NewTokenAmount(1<<40),
+			abi.RegisteredProof_StackedDRG2KiBPoSt,
+		)
+		require.NoError(t, err)
+
+		cd, err := h.Client.GetLocalDeal(ctx, result.ProposalCid)
+		require.NoError(t, err)
+		require.Equal(t, abi.NewTokenAmount(1<<40), cd.Proposal.ProviderCollateral)
+		require.Equal(t, big.Zero(), cd.Proposal.ClientCollateral)
+	})
+}
+
+func TestGetAskRefusesExpiredAsk(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, ctx)
+
+	ask, err := h.Client.GetAsk(ctx, h.ProviderInfo)
+	require.NoError(t, err)
+
+	// the client sees a later chain head than the provider, which has not
+	// renewed its ask yet
+	clientState := testnodes.NewStorageMarketState()
+	clientState.Epoch = ask.Ask.Expiry
+	h.ClientNode.SMState = clientState
+
+	_, err = h.Client.GetAsk(ctx, h.ProviderInfo)
+	require.EqualError(t, err, fmt.Sprintf("ask expired at epoch %d", ask.Ask.Expiry))
+}
+
+func TestFindStorageOffers(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, ctx)
+
+	// 1 FIL per GiB / Epoch, so a deal costs 1 FIL per byte per epoch
+	err := h.Provider.AddAsk(abi.NewTokenAmount(1<<30), 50_000)
+	require.NoError(t, err)
+
+	unreachable := storagemarket.StorageProviderInfo{
+		Address:    address.TestAddress,
+		SectorSize: h.ProviderInfo.SectorSize,
+		PeerID:     peer.ID("unreachable provider"),
+	}
+	h.ClientNode.SMState.Providers = []*storagemarket.StorageProviderInfo{&h.ProviderInfo, &unreachable}
+
+	criteria := func(modify func(*storagemarket.AskCriteria)) storagemarket.AskCriteria {
+		c := storagemarket.AskCriteria{PieceSize: 1024, Duration: 1000}
+		if modify != nil {
+			modify(&c)
+		}
+		return c
+	}
+
+	t.Run("finds offers from responsive providers", func(t *testing.T) {
+		offers, err := h.Client.FindStorageOffers(ctx, criteria(nil), 0)
+		require.NoError(t, err)
+		require.Len(t, offers, 1)
+		require.Equal(t, h.ProviderInfo, offers[0].Provider)
+		require.Equal(t, h.ProviderAddr, offers[0].Ask.Ask.Miner)
+		require.Equal(t, abi.NewTokenAmount(1024), offers[0].PricePerEpoch)
+		require.Equal(t, abi.NewTokenAmount(1024*1000), offers[0].TotalPrice)
+	})
+
+	tests := map[string]func(*storagemarket.AskCriteria){
+		"price above maximum": func(c *storagemarket.AskCriteria) {
+			c.MaxPrice = abi.NewTokenAmount(1<<30 - 1)
+		},
+		"different sector size": func(c *storagemarket.AskCriteria) {
+			c.SectorSize = 1 << 30
+		},
+		"piece too small": func(c *storagemarket.AskCriteria) {
+			c.PieceSize = 128
+		},
+		"piece too large": func(c *storagemarket.AskCriteria) {
+			c.PieceSize = 1 << 21
+		},
+		"ask expires too soon": func(c *storagemarket.AskCriteria) {
+			c.MinAskExpiry = 50_001
+		},
+	}
+	for name, modify := range tests {
+		t.Run("skips providers when "+name, func(t *testing.T) {
+			offers, err := h.Client.FindStorageOffers(ctx, criteria(modify), 0)
+			require.NoError(t, err)
+			require.Empty(t, offers)
+		})
+	}
+
+	t.Run("accepts price at maximum", func(t *testing.T) {
+		offers, err := h.Client.FindStorageOffers(ctx, criteria(func(c *storagemarket.AskCriteria) {
+			c.MaxPrice = abi.NewTokenAmount(1 << 30)
+		}), 1)
+		require.NoError(t, err)
+		require.Len(t, offers, 1)
+	})
+}
+
+func TestFindStorageOffersTieredPricing(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, ctx)
+
+	// 1 FIL per GiB / Epoch, halved for pieces of at least 2KiB, and quartered
+	// for deals of at least 2000 epochs
+	err := h.Provider.AddAsk(abi.NewTokenAmount(1<<30), 50_000,
+		storagemarket.PieceSizePrice(2048, abi.NewTokenAmount(1<<29)),
+		storagemarket.DurationPrice(2000, abi.NewTokenAmount(1<<28)))
+	require.NoError(t, err)
+	h.ClientNode.SMState.Providers = []*storagemarket.StorageProviderInfo{&h.ProviderInfo}
+
+	tests := map[string]struct {
+		criteria      storagemarket.AskCriteria
+		pricePerEpoch abi.TokenAmount
+	}{
+		"no tier": {
+			criteria:      storagemarket.AskCriteria{PieceSize: 1024, Duration: 1000},
+			pricePerEpoch: abi.NewTokenAmount(1024),
+		},
+		"piece size tier": {
+			criteria:      storagemarket.AskCriteria{PieceSize: 2048, Duration: 1000},
+			pricePerEpoch: abi.NewTokenAmount(1024),
+		},
+		"duration tier": {
+			criteria:      storagemarket.AskCriteria{PieceSize: 1024, Duration: 2000},
+			pricePerEpoch: abi.NewTokenAmount(256),
+		},
+		"cheapest of both tiers": {
+			criteria:      storagemarket.AskCriteria{PieceSize: 2048, Duration: 2000},
+			pricePerEpoch: abi.NewTokenAmount(512),
+		},
+		"tier price below maximum": {
+			criteria:      storagemarket.AskCriteria{PieceSize: 2048, Duration: 1000, MaxPrice: abi.NewTokenAmount(1 << 29)},
+			pricePerEpoch: abi.NewTokenAmount(1024),
+		},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			offers, err := h.Client.FindStorageOffers(ctx, data.criteria, 0)
+			require.NoError(t, err)
+			require.Len(t, offers, 1)
+			require.Len(t, offers[0].Ask.Ask.PriceTiers, 2)
+			require.Equal(t, data.pricePerEpoch, offers[0].PricePerEpoch)
+			require.Equal(t, big.Mul(data.pricePerEpoch, abi.NewTokenAmount(int64(data.criteria.Duration))), offers[0].TotalPrice)
+		})
+	}
+}
+
+func TestReplicateData(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, ctx)
+	h.Client.Run(ctx)
+
+	carBuf := new(bytes.Buffer)
+	err := cario.NewCarIO().WriteCar(ctx, h.TestData.Bs1, h.PayloadCid, shared.AllSelector(), carBuf)
+	require.NoError(t, err)
+	commP, size, err := pieceio.GeneratePieceCommitment(abi.RegisteredProof_StackedDRG2KiBPoSt, carBuf, uint64(carBuf.Len()))
+	require.NoError(t, err)
+
+	tooSmall := h.ProviderInfo
+	tooSmall.SectorSize = 128
+
+	// each replication starts one epoch later, so that deals with the same
+	// provider have distinct proposals
+	startEpoch := h.Epoch + 100
+	replicate := func(t *testing.T, replicas uint, providers ...storagemarket.StorageProviderInfo) storagemarket.ReplicationStatus {
+		startEpoch++
+		id, err := h.Client.ReplicateData(ctx, storagemarket.ReplicationParams{
+			Client:     address.TestAddress,
+			Data:       &storagemarket.DataRef{TransferType: storagemarket.TTGraphsync, Root: h.PayloadCid},
+			Replicas:   replicas,
+			Providers:  providers,
+			StartEpoch: startEpoch,
+			EndEpoch:   startEpoch + 20000,
+			ProofType:  abi.RegisteredProof_StackedDRG2KiBPoSt,
+		})
+		require.NoError(t, err)
+
+		var status storagemarket.ReplicationStatus
+		require.Eventually(t, func() bool {
+			status, err = h.Client.GetReplicationStatus(ctx, id)
+			require.NoError(t, err)
+			return status.Complete
+		}, time.Second, 10*time.Millisecond)
+
+		require.Equal(t, commP, status.PieceCid)
+		require.Equal(t, size, status.PieceSize)
+		return status
+	}
+
+	t.Run("replaces providers whose deals fail", func(t *testing.T) {
+		status := replicate(t, 1, tooSmall, h.ProviderInfo)
+		require.Equal(t, uint(1), status.Active)
+		require.Equal(t, uint(1), status.Failed)
+		require.Empty(t, status.Message)
+		require.Len(t, status.Deals, 2)
+
+		require.Equal(t, storagemarket.StorageDealError, status.Deals[0].State)
+		require.False(t, status.Deals[0].ProposalCid.Defined())
+		require.Contains(t, status.Deals[0].Message, "greater than sector size")
+
+		require.Equal(t, storagemarket.StorageDealActive, status.Deals[1].State)
+		cd, err := h.Client.GetLocalDeal(ctx, status.Deals[1].ProposalCid)
+		require.NoError(t, err)
+		require.Equal(t, commP, cd.Proposal.PieceCID)
+	})
+
+	t.Run("completes short of target when candidates run out", func(t *testing.T) {
+		status := replicate(t, 2, h.ProviderInfo, tooSmall)
+		require.Equal(t, uint(1), status.Active)
+		require.Equal(t, uint(1), status.Failed)
+		require.Equal(t, "1 of 2 replicas active: no candidate providers left", status.Message)
+	})
+
+	t.Run("requires candidate providers", func(t *testing.T) {
+		_, err := h.Client.ReplicateData(ctx, storagemarket.ReplicationParams{
+			Data:     &storagemarket.DataRef{TransferType: storagemarket.TTGraphsync, Root: h.PayloadCid},
+			Replicas: 1,
+		})
+		require.EqualError(t, err, "no providers or offer selector given")
+	})
+
+	t.Run("selects candidates from storage offers", func(t *testing.T) {
+		h.ClientNode.SMState.Providers = []*storagemarket.StorageProviderInfo{&h.ProviderInfo}
+		selector := storageimpl.CheapestOffers(h.Client, storagemarket.AskCriteria{})
+		providers, err := selector(ctx, size.Padded(), 20000)
+		require.NoError(t, err)
+		require.Equal(t, []storagemarket.StorageProviderInfo{h.ProviderInfo}, providers)
+	})
+}
+
 type harness struct {
 	Ctx          context.Context
 	Epoch        abi.ChainEpoch
@@ -244,10 +1131,12 @@ type harness struct {
 	Provider     storagemarket.StorageProvider
 	ProviderNode *testnodes.FakeProviderNode
 	ProviderInfo storagemarket.StorageProviderInfo
+	FileStore    filestore.FileStore
+	PieceStore   piecestore.PieceStore
 	TestData     *shared_testutil.Libp2pTestData
 }
 
-func newHarness(t *testing.T, ctx context.Context) *harness {
+func newHarness(t *testing.T, ctx context.Context, options ...storageimpl.StorageProviderOption) *harness {
 	epoch := abi.ChainEpoch(100)
 	td := shared_testutil.NewLibp2pTestData(ctx, t)
 	fpath := filepath.Join("storagemarket", "fixtures", "payload.txt")
@@ -281,8 +1170,10 @@ func newHarness(t *testing.T, ctx context.Context) *harness {
 	assert.NoError(t, err)
 
 	// create provider and client
+	// the client only sends data to providers that pull it for its own deals
 	dt1 := graphsync.NewGraphSyncDataTransfer(td.Host1, td.GraphSync1, td.DTStoredCounter1)
-	require.NoError(t, dt1.RegisterVoucherType(&requestvalidation.StorageDataTransferVoucher{}, &fakeDTValidator{}))
+	clientValidator := requestvalidation.NewUnifiedRequestValidator(nil, statestore.New(td.Ds1))
+	require.NoError(t, dt1.RegisterVoucherType(&requestvalidation.StorageDataTransferVoucher{}, clientValidator))
 
 	client, err := storageimpl.NewClient(
 		network.NewFromLibp2pHost(td.Host1),
@@ -291,6 +1182,7 @@ func newHarness(t *testing.T, ctx context.Context) *harness {
 		discovery.NewLocal(td.Ds1),
 		td.Ds1,
 		&clientNode,
+		storageimpl.DealPollingInterval(10*time.Millisecond),
 	)
 	require.NoError(t, err)
 
@@ -310,6 +1202,7 @@ func newHarness(t *testing.T, ctx context.Context) *harness {
 		providerAddr,
 		abi.RegisteredProof_StackedDRG2KiBPoSt,
 		storedAsk,
+		options...,
 	)
 	assert.NoError(t, err)
 
@@ -339,6 +1232,8 @@ func newHarness(t *testing.T, ctx context.Context) *harness {
 		Provider:     provider,
 		ProviderNode: providerNode,
 		ProviderInfo: providerInfo,
+		FileStore:    fs,
+		PieceStore:   ps,
 		TestData:     td,
 	}
 }
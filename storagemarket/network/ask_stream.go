@@ -47,6 +47,10 @@ func (as *askStream) WriteAskResponse(qr AskResponse) error {
 	return cborutil.WriteCborRPC(as.rw, &qr)
 }
 
+func (as *askStream) Reset() error {
+	return as.rw.Reset()
+}
+
 func (as *askStream) Close() error {
 	return as.rw.Close()
 }
@@ -0,0 +1,39 @@
+package network
+
+import (
+	"bufio"
+
+	cborutil "github.com/filecoin-project/go-cbor-util"
+	"github.com/libp2p/go-libp2p-core/mux"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+type dealCancellationStream struct {
+	p        peer.ID
+	rw       mux.MuxedStream
+	buffered *bufio.Reader
+}
+
+var _ DealCancellationStream = (*dealCancellationStream)(nil)
+
+func (d *dealCancellationStream) ReadDealCancellation() (DealCancellation, error) {
+	var c DealCancellation
+
+	if err := c.UnmarshalCBOR(d.buffered); err != nil {
+		log.Warn(err)
+		return DealCancellationUndefined, err
+	}
+	return c, nil
+}
+
+func (d *dealCancellationStream) WriteDealCancellation(c DealCancellation) error {
+	return cborutil.WriteCborRPC(d.rw, &c)
+}
+
+func (d *dealCancellationStream) Close() error {
+	return d.rw.Close()
+}
+
+func (d *dealCancellationStream) RemotePeer() peer.ID {
+	return d.p
+}
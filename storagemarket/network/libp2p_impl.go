@@ -46,10 +46,32 @@ func (impl *libp2pStorageMarketNetwork) NewDealStream(id peer.ID) (StorageDealSt
 	return &dealStream{p: id, rw: s, buffered: buffered, host: impl.host}, nil
 }
 
+func (impl *libp2pStorageMarketNetwork) NewDealStatusStream(id peer.ID) (DealStatusStream, error) {
+	s, err := impl.host.NewStream(context.Background(), id, storagemarket.DealStatusProtocolID)
+	if err != nil {
+		log.Warn(err)
+		return nil, err
+	}
+	buffered := bufio.NewReaderSize(s, 16)
+	return &dealStatusStream{p: id, rw: s, buffered: buffered}, nil
+}
+
+func (impl *libp2pStorageMarketNetwork) NewDealCancellationStream(id peer.ID) (DealCancellationStream, error) {
+	s, err := impl.host.NewStream(context.Background(), id, storagemarket.DealCancellationProtocolID)
+	if err != nil {
+		log.Warn(err)
+		return nil, err
+	}
+	buffered := bufio.NewReaderSize(s, 16)
+	return &dealCancellationStream{p: id, rw: s, buffered: buffered}, nil
+}
+
 func (impl *libp2pStorageMarketNetwork) SetDelegate(r StorageReceiver) error {
 	impl.receiver = r
 	impl.host.SetStreamHandler(storagemarket.DealProtocolID, impl.handleNewDealStream)
 	impl.host.SetStreamHandler(storagemarket.AskProtocolID, impl.handleNewAskStream)
+	impl.host.SetStreamHandler(storagemarket.DealStatusProtocolID, impl.handleNewDealStatusStream)
+	impl.host.SetStreamHandler(storagemarket.DealCancellationProtocolID, impl.handleNewDealCancellationStream)
 	return nil
 }
 
@@ -57,6 +79,8 @@ func (impl *libp2pStorageMarketNetwork) StopHandlingRequests() error {
 	impl.receiver = nil
 	impl.host.RemoveStreamHandler(storagemarket.DealProtocolID)
 	impl.host.RemoveStreamHandler(storagemarket.AskProtocolID)
+	impl.host.RemoveStreamHandler(storagemarket.DealStatusProtocolID)
+	impl.host.RemoveStreamHandler(storagemarket.DealCancellationProtocolID)
 	return nil
 }
 
@@ -84,6 +108,30 @@ func (impl *libp2pStorageMarketNetwork) handleNewDealStream(s network.Stream) {
 	impl.receiver.HandleDealStream(ds)
 }
 
+func (impl *libp2pStorageMarketNetwork) handleNewDealStatusStream(s network.Stream) {
+	if impl.receiver == nil {
+		log.Warn("no receiver set")
+		s.Reset() // nolint: errcheck,gosec
+		return
+	}
+	remotePID := s.Conn().RemotePeer()
+	buffered := bufio.NewReaderSize(s, 16)
+	qs := &dealStatusStream{remotePID, s, buffered}
+	impl.receiver.HandleDealStatusStream(qs)
+}
+
+func (impl *libp2pStorageMarketNetwork) handleNewDealCancellationStream(s network.Stream) {
+	if impl.receiver == nil {
+		log.Warn("no receiver set")
+		s.Reset() // nolint: errcheck,gosec
+		return
+	}
+	remotePID := s.Conn().RemotePeer()
+	buffered := bufio.NewReaderSize(s, 16)
+	cs := &dealCancellationStream{remotePID, s, buffered}
+	impl.receiver.HandleDealCancellationStream(cs)
+}
+
 func (impl *libp2pStorageMarketNetwork) ID() peer.ID {
 	return impl.host.ID()
 }
@@ -17,6 +17,8 @@ type testReceiver struct {
 	t                 *testing.T
 	dealStreamHandler func(network.StorageDealStream)
 	askStreamHandler  func(network.StorageAskStream)
+	dealStatusHandler func(network.DealStatusStream)
+	cancelHandler     func(network.DealCancellationStream)
 }
 
 func (tr *testReceiver) HandleDealStream(s network.StorageDealStream) {
@@ -32,6 +34,20 @@ func (tr *testReceiver) HandleAskStream(s network.StorageAskStream) {
 	}
 }
 
+func (tr *testReceiver) HandleDealStatusStream(s network.DealStatusStream) {
+	defer s.Close()
+	if tr.dealStatusHandler != nil {
+		tr.dealStatusHandler(s)
+	}
+}
+
+func (tr *testReceiver) HandleDealCancellationStream(s network.DealCancellationStream) {
+	defer s.Close()
+	if tr.cancelHandler != nil {
+		tr.cancelHandler(s)
+	}
+}
+
 func TestAskStreamSendReceiveAskRequest(t *testing.T) {
 	ctx := context.Background()
 	td := shared_testutil.NewLibp2pTestData(ctx, t)
@@ -81,6 +97,82 @@ func TestAskStreamSendReceiveAskResponse(t *testing.T) {
 
 }
 
+func TestAskStreamReset(t *testing.T) {
+	ctx := context.Background()
+	td := shared_testutil.NewLibp2pTestData(ctx, t)
+	fromNetwork := network.NewFromLibp2pHost(td.Host1)
+	toNetwork := network.NewFromLibp2pHost(td.Host2)
+	toHost := td.Host2.ID()
+
+	tr := &testReceiver{t: t}
+	require.NoError(t, fromNetwork.SetDelegate(tr))
+
+	// host2 reads the request but never answers
+	unblock := make(chan struct{})
+	defer close(unblock)
+	tr2 := &testReceiver{t: t, askStreamHandler: func(s network.StorageAskStream) {
+		_, _ = s.ReadAskRequest()
+		<-unblock
+	}}
+	require.NoError(t, toNetwork.SetDelegate(tr2))
+
+	as, err := fromNetwork.NewAskStream(toHost)
+	require.NoError(t, err)
+	defer as.Close()
+	require.NoError(t, as.WriteAskRequest(shared_testutil.MakeTestStorageAskRequest()))
+
+	readErr := make(chan error, 1)
+	go func() {
+		_, err := as.ReadAskResponse()
+		readErr <- err
+	}()
+	require.NoError(t, as.Reset())
+	select {
+	case err := <-readErr:
+		require.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("read was not interrupted by the reset")
+	}
+}
+
+func TestDealStatusStreamReset(t *testing.T) {
+	ctx := context.Background()
+	td := shared_testutil.NewLibp2pTestData(ctx, t)
+	fromNetwork := network.NewFromLibp2pHost(td.Host1)
+	toNetwork := network.NewFromLibp2pHost(td.Host2)
+	toHost := td.Host2.ID()
+
+	tr := &testReceiver{t: t}
+	require.NoError(t, fromNetwork.SetDelegate(tr))
+
+	// host2 reads the request but never answers
+	unblock := make(chan struct{})
+	defer close(unblock)
+	tr2 := &testReceiver{t: t, dealStatusHandler: func(s network.DealStatusStream) {
+		_, _ = s.ReadDealStatusRequest()
+		<-unblock
+	}}
+	require.NoError(t, toNetwork.SetDelegate(tr2))
+
+	ds, err := fromNetwork.NewDealStatusStream(toHost)
+	require.NoError(t, err)
+	defer ds.Close()
+	require.NoError(t, ds.WriteDealStatusRequest(shared_testutil.MakeTestDealStatusRequest()))
+
+	readErr := make(chan error, 1)
+	go func() {
+		_, err := ds.ReadDealStatusResponse()
+		readErr <- err
+	}()
+	require.NoError(t, ds.Reset())
+	select {
+	case err := <-readErr:
+		require.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("read was not interrupted by the reset")
+	}
+}
+
 func TestAskStreamSendReceiveMultipleSuccessful(t *testing.T) {
 	// send query, read in handler, send response back, read response
 	ctxBg := context.Background()
@@ -217,6 +309,84 @@ func TestDealStreamSendReceiveMultipleSuccessful(t *testing.T) {
 
 }
 
+func TestDealStatusStreamSendReceiveMultipleSuccessful(t *testing.T) {
+	// send status request, read in handler, send response back,
+	// read response
+
+	bgCtx := context.Background()
+	td := shared_testutil.NewLibp2pTestData(bgCtx, t)
+	fromNetwork := network.NewFromLibp2pHost(td.Host1)
+	toNetwork := network.NewFromLibp2pHost(td.Host2)
+	toPeer := td.Host2.ID()
+
+	// set up stream handler, channels, and response
+	q := shared_testutil.MakeTestDealStatusRequest()
+	qr := shared_testutil.MakeTestDealStatusResponse()
+	qchan := make(chan network.DealStatusRequest, 1)
+
+	tr2 := &testReceiver{t: t, dealStatusHandler: func(s network.DealStatusStream) {
+		readq, err := s.ReadDealStatusRequest()
+		require.NoError(t, err)
+		qchan <- readq
+
+		require.NoError(t, s.WriteDealStatusResponse(qr))
+	}}
+	require.NoError(t, toNetwork.SetDelegate(tr2))
+
+	// start sending status request
+	qs, err := fromNetwork.NewDealStatusStream(toPeer)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(bgCtx, 10*time.Second)
+	defer cancel()
+
+	require.NoError(t, qs.WriteDealStatusRequest(q))
+
+	// read response and verify it's the one we told toNetwork to send
+	responseReceived, err := qs.ReadDealStatusResponse()
+	require.NoError(t, err)
+	assert.Equal(t, qr, responseReceived)
+
+	select {
+	case <-ctx.Done():
+		t.Errorf("failed to receive messages")
+	case readq := <-qchan:
+		assert.Equal(t, q, readq)
+	}
+}
+
+func TestDealCancellationStreamSendReceive(t *testing.T) {
+	bgCtx := context.Background()
+	td := shared_testutil.NewLibp2pTestData(bgCtx, t)
+	fromNetwork := network.NewFromLibp2pHost(td.Host1)
+	toNetwork := network.NewFromLibp2pHost(td.Host2)
+	toPeer := td.Host2.ID()
+
+	c := shared_testutil.MakeTestDealCancellation()
+	cchan := make(chan network.DealCancellation, 1)
+
+	tr2 := &testReceiver{t: t, cancelHandler: func(s network.DealCancellationStream) {
+		readc, err := s.ReadDealCancellation()
+		require.NoError(t, err)
+		cchan <- readc
+	}}
+	require.NoError(t, toNetwork.SetDelegate(tr2))
+
+	cs, err := fromNetwork.NewDealCancellationStream(toPeer)
+	require.NoError(t, err)
+	require.NoError(t, cs.WriteDealCancellation(c))
+
+	ctx, cancel := context.WithTimeout(bgCtx, 10*time.Second)
+	defer cancel()
+
+	select {
+	case <-ctx.Done():
+		t.Errorf("failed to receive messages")
+	case readc := <-cchan:
+		assert.Equal(t, c, readc)
+	}
+}
+
 func TestLibp2pStorageMarketNetwork_StopHandlingRequests(t *testing.T) {
 	bgCtx := context.Background()
 	td := shared_testutil.NewLibp2pTestData(bgCtx, t)
@@ -11,6 +11,9 @@ type StorageAskStream interface {
 	WriteAskRequest(AskRequest) error
 	ReadAskResponse() (AskResponse, error)
 	WriteAskResponse(AskResponse) error
+	// Reset closes both ends of the stream, failing any read or write
+	// that is still waiting
+	Reset() error
 	Close() error
 }
 
@@ -27,17 +30,44 @@ type StorageDealStream interface {
 	Close() error
 }
 
+// DealStatusStream is a stream for reading and writing requests
+// and responses on the deal status protocol
+type DealStatusStream interface {
+	ReadDealStatusRequest() (DealStatusRequest, error)
+	WriteDealStatusRequest(DealStatusRequest) error
+	ReadDealStatusResponse() (DealStatusResponse, error)
+	WriteDealStatusResponse(DealStatusResponse) error
+	RemotePeer() peer.ID
+	// Reset closes both ends of the stream, failing any read or write
+	// that is still waiting
+	Reset() error
+	Close() error
+}
+
+// DealCancellationStream is a stream for reading and writing cancellations
+// on the deal cancellation protocol
+type DealCancellationStream interface {
+	ReadDealCancellation() (DealCancellation, error)
+	WriteDealCancellation(DealCancellation) error
+	RemotePeer() peer.ID
+	Close() error
+}
+
 // StorageReceiver implements functions for receiving
 // incoming data on storage protocols
 type StorageReceiver interface {
 	HandleAskStream(StorageAskStream)
 	HandleDealStream(StorageDealStream)
+	HandleDealStatusStream(DealStatusStream)
+	HandleDealCancellationStream(DealCancellationStream)
 }
 
 // StorageMarketNetwork is a network abstraction for the storage market
 type StorageMarketNetwork interface {
 	NewAskStream(peer.ID) (StorageAskStream, error)
 	NewDealStream(peer.ID) (StorageDealStream, error)
+	NewDealStatusStream(peer.ID) (DealStatusStream, error)
+	NewDealCancellationStream(peer.ID) (DealCancellationStream, error)
 	SetDelegate(StorageReceiver) error
 	StopHandlingRequests() error
 	ID() peer.ID
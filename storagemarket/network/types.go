@@ -2,6 +2,8 @@ package network
 
 import (
 	"github.com/filecoin-project/go-address"
+	cborutil "github.com/filecoin-project/go-cbor-util"
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/builtin/market"
 	"github.com/filecoin-project/specs-actors/actors/crypto"
 	"github.com/ipfs/go-cid"
@@ -9,7 +11,7 @@ import (
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 )
 
-//go:generate cbor-gen-for AskRequest AskResponse Proposal Response SignedResponse
+//go:generate cbor-gen-for AskRequest AskResponse Proposal Response SignedResponse DealStatusRequest DealStatusResponse DealCancellation dealMessagePayload
 
 // Proposal is the data sent over the network from client to provider when proposing
 // a deal
@@ -56,3 +58,61 @@ type AskResponse struct {
 }
 
 var AskResponseUndefined = AskResponse{}
+
+// DealStatusRequest is sent by a client to query the provider for the state
+// of a deal, signed by the client address of the deal over its SigningBytes.
+// Epoch is the chain epoch the client signed the request at
+type DealStatusRequest struct {
+	Proposal  cid.Cid
+	Epoch     abi.ChainEpoch
+	Signature crypto.Signature
+}
+
+var DealStatusRequestUndefined = DealStatusRequest{}
+
+// DealStatusResponse is the provider's signed response to a deal status request
+type DealStatusResponse struct {
+	DealState storagemarket.ProviderDealState
+	Signature crypto.Signature
+}
+
+var DealStatusResponseUndefined = DealStatusResponse{}
+
+// DealCancellation is sent by a client to tell the provider it has cancelled
+// a deal, signed by the client address of the deal over its SigningBytes.
+// Epoch is the chain epoch the client signed the cancellation at
+type DealCancellation struct {
+	Proposal  cid.Cid
+	Epoch     abi.ChainEpoch
+	Signature crypto.Signature
+}
+
+var DealCancellationUndefined = DealCancellation{}
+
+// dealMessagePayload is what a client signs for a message about one of its
+// deals. The tag is the protocol the message is sent on, so that a signature
+// for one kind of message is not valid for another, and the epoch lets the
+// provider turn away old messages that are sent again
+type dealMessagePayload struct {
+	Tag      string
+	Proposal cid.Cid
+	Epoch    abi.ChainEpoch
+}
+
+// SigningBytes returns the bytes the client signs for a deal status request
+func (r *DealStatusRequest) SigningBytes() ([]byte, error) {
+	return cborutil.Dump(&dealMessagePayload{
+		Tag:      storagemarket.DealStatusProtocolID,
+		Proposal: r.Proposal,
+		Epoch:    r.Epoch,
+	})
+}
+
+// SigningBytes returns the bytes the client signs for a deal cancellation
+func (c *DealCancellation) SigningBytes() ([]byte, error) {
+	return cborutil.Dump(&dealMessagePayload{
+		Tag:      storagemarket.DealCancellationProtocolID,
+		Proposal: c.Proposal,
+		Epoch:    c.Epoch,
+	})
+}
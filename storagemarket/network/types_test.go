@@ -0,0 +1,32 @@
+package network_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/shared_testutil"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/network"
+)
+
+func TestSigningBytes(t *testing.T) {
+	proposal := shared_testutil.GenerateCids(1)[0]
+
+	request := network.DealStatusRequest{Proposal: proposal, Epoch: 10}
+	requestBytes, err := request.SigningBytes()
+	require.NoError(t, err)
+
+	cancellation := network.DealCancellation{Proposal: proposal, Epoch: 10}
+	cancellationBytes, err := cancellation.SigningBytes()
+	require.NoError(t, err)
+
+	// a signed status request cannot be sent as a cancellation
+	require.NotEqual(t, requestBytes, cancellationBytes)
+	require.NotContains(t, [][]byte{requestBytes, cancellationBytes}, proposal.Bytes())
+
+	// nor sent again at a later epoch
+	request.Epoch = 11
+	laterBytes, err := request.SigningBytes()
+	require.NoError(t, err)
+	require.NotEqual(t, requestBytes, laterBytes)
+}
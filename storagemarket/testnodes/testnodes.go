@@ -163,6 +163,7 @@ type FakeClientNode struct {
 	ValidatePublishedError  error
 	DealCommittedSyncError  error
 	DealCommittedAsyncError error
+	SignBytesError          error
 }
 
 // ListClientDeals just returns the deals in the storage market state
@@ -207,6 +208,14 @@ func (n *FakeClientNode) ValidateAskSignature(ctx context.Context, ask *storagem
 	return n.ValidationError == nil, n.ValidationError
 }
 
+// SignBytes simulates signing data by returning a test signature
+func (n *FakeClientNode) SignBytes(ctx context.Context, signer address.Address, b []byte) (*crypto.Signature, error) {
+	if n.SignBytesError == nil {
+		return shared_testutil.MakeTestSignature(), nil
+	}
+	return nil, n.SignBytesError
+}
+
 var _ storagemarket.StorageClientNode = (*FakeClientNode)(nil)
 
 // FakeProviderNode implements functions specific to the StorageProviderNode
@@ -225,15 +234,17 @@ type FakeProviderNode struct {
 	SignBytesError                      error
 }
 
-// PublishDeals simulates publishing a deal by adding it to the storage market state
-func (n *FakeProviderNode) PublishDeals(ctx context.Context, deal storagemarket.MinerDeal) (cid.Cid, error) {
+// PublishDeals simulates publishing deals by adding them to the storage market state
+func (n *FakeProviderNode) PublishDeals(ctx context.Context, deals []storagemarket.MinerDeal) (cid.Cid, error) {
 	if n.PublishDealsError == nil {
-		sd := storagemarket.StorageDeal{
-			DealProposal: deal.Proposal,
-			DealState:    market.DealState{},
-		}
+		for _, deal := range deals {
+			sd := storagemarket.StorageDeal{
+				DealProposal: deal.Proposal,
+				DealState:    market.DealState{},
+			}
 
-		n.SMState.AddDeal(sd)
+			n.SMState.AddDeal(sd)
+		}
 
 		return shared_testutil.GenerateCids(1)[0], nil
 	}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/filecoin-project/specs-actors/actors/builtin/market"
 	"github.com/filecoin-project/specs-actors/actors/crypto"
 	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
@@ -17,10 +18,21 @@ import (
 	"github.com/filecoin-project/go-fil-markets/shared"
 )
 
-//go:generate cbor-gen-for ClientDeal MinerDeal Balance SignedStorageAsk StorageAsk StorageDeal DataRef
+//go:generate cbor-gen-for ClientDeal minerDealTuple Balance SignedStorageAsk PriceTier StorageDeal HTTPHeader ProviderDealState dataRefTuple storageAskTuple
 
 const DealProtocolID = "/fil/storage/mk/1.0.1"
 const AskProtocolID = "/fil/storage/ask/1.0.1"
+const DealStatusProtocolID = "/fil/storage/status/1.0.1"
+
+// DealCancellationProtocolID is the protocol a client uses to cancel a deal.
+// Cancellations are not sent on the deal protocol: by the time a client
+// cancels, the deal stream is often closed already (offline deals, deals
+// restored after a restart, deals polled over the status protocol), and the
+// deal protocol reads exactly one proposal per stream, so a new message on it
+// would break providers that do not know it. A provider without this
+// protocol is not notified, and fails the deal once the client's stream or
+// transfer goes away, as it did before cancellation existed
+const DealCancellationProtocolID = "/fil/storage/cancel/1.0.1"
 
 type Balance struct {
 	Locked    abi.TokenAmount
@@ -57,6 +69,7 @@ const (
 	StorageDealPublishing            // Waiting for deal to appear on chain
 	StorageDealError                 // deal failed with an unexpected error
 	StorageDealCompleted             // on provider side, indicates deal is active and info for retrieval is recorded
+	StorageDealCheckForAcceptance    // Client is polling the provider until the deal is accepted or rejected
 )
 
 // DealStates maps StorageDealStatus codes to string names
@@ -85,6 +98,7 @@ var DealStates = map[StorageDealStatus]string{
 	StorageDealPublishing:            "StorageDealPublishing",
 	StorageDealError:                 "StorageDealError",
 	StorageDealCompleted:             "StorageDealCompleted",
+	StorageDealCheckForAcceptance:    "StorageDealCheckForAcceptance",
 }
 
 func init() {
@@ -109,6 +123,27 @@ type StorageAsk struct {
 	Timestamp    abi.ChainEpoch
 	Expiry       abi.ChainEpoch
 	SeqNo        uint64
+
+	// MinProviderCollateral is the least collateral per GiB of padded piece
+	// size the provider locks for a deal. Proposals with less are rejected
+	MinProviderCollateral abi.TokenAmount
+	// MaxProviderCollateral is the most collateral per GiB of padded piece
+	// size the provider locks for a deal. Proposals with more are rejected,
+	// and zero means there is no upper bound
+	MaxProviderCollateral abi.TokenAmount
+	// PriceTiers optionally discount Price for large pieces or long deals.
+	// Peers that predate the tiers and collateral bounds can only read asks
+	// that leave them unset
+	PriceTiers []PriceTier
+}
+
+// PriceTier is a price for deals with at least the given piece size and
+// duration
+type PriceTier struct {
+	MinPieceSize abi.PaddedPieceSize
+	MinDuration  abi.ChainEpoch
+	// Price per GiB / Epoch
+	Price abi.TokenAmount
 }
 
 // StorageAskOption allows custom configuration of a storage ask
@@ -126,8 +161,82 @@ func MaxPieceSize(maxPieceSize abi.PaddedPieceSize) StorageAskOption {
 	}
 }
 
+func MinProviderCollateral(minProviderCollateral abi.TokenAmount) StorageAskOption {
+	return func(sa *StorageAsk) {
+		sa.MinProviderCollateral = minProviderCollateral
+	}
+}
+
+func MaxProviderCollateral(maxProviderCollateral abi.TokenAmount) StorageAskOption {
+	return func(sa *StorageAsk) {
+		sa.MaxProviderCollateral = maxProviderCollateral
+	}
+}
+
+// TieredPrice adds a price tier to the ask
+func TieredPrice(tier PriceTier) StorageAskOption {
+	return func(sa *StorageAsk) {
+		sa.PriceTiers = append(sa.PriceTiers, tier)
+	}
+}
+
+// PieceSizePrice adds a price tier for pieces of at least the given size
+func PieceSizePrice(minPieceSize abi.PaddedPieceSize, price abi.TokenAmount) StorageAskOption {
+	return TieredPrice(PriceTier{MinPieceSize: minPieceSize, Price: price})
+}
+
+// DurationPrice adds a price tier for deals lasting at least the given number
+// of epochs
+func DurationPrice(minDuration abi.ChainEpoch, price abi.TokenAmount) StorageAskOption {
+	return TieredPrice(PriceTier{MinDuration: minDuration, Price: price})
+}
+
 var StorageAskUndefined = StorageAsk{}
 
+// CollateralPolicy decides the collateral a client puts in a deal proposal,
+// based on the provider's ask
+type CollateralPolicy interface {
+	// ProviderCollateral returns the collateral the provider is asked to lock for the deal
+	ProviderCollateral(ask StorageAsk, pieceSize abi.PaddedPieceSize, duration abi.ChainEpoch) (abi.TokenAmount, error)
+
+	// ClientCollateral returns the collateral the client locks for the deal
+	ClientCollateral(ask StorageAsk, pieceSize abi.PaddedPieceSize, duration abi.ChainEpoch) (abi.TokenAmount, error)
+}
+
+// ProviderCollateralBounds returns the range of provider collateral the ask
+// accepts for a piece of the given size. The returned max is nil if there is
+// no upper bound
+func ProviderCollateralBounds(ask StorageAsk, pieceSize abi.PaddedPieceSize) (min abi.TokenAmount, max abi.TokenAmount) {
+	min = big.Zero()
+	if !ask.MinProviderCollateral.Nil() {
+		min = big.Div(big.Mul(ask.MinProviderCollateral, abi.NewTokenAmount(int64(pieceSize))), abi.NewTokenAmount(1<<30))
+	}
+	if !ask.MaxProviderCollateral.Nil() && !ask.MaxProviderCollateral.IsZero() {
+		max = big.Div(big.Mul(ask.MaxProviderCollateral, abi.NewTokenAmount(int64(pieceSize))), abi.NewTokenAmount(1<<30))
+	}
+	return min, max
+}
+
+// AskPrice returns the price per GiB / Epoch the ask charges for a deal with
+// the given piece size and duration: the lowest of the ask price and the
+// prices of the tiers the deal falls in
+func AskPrice(ask StorageAsk, pieceSize abi.PaddedPieceSize, duration abi.ChainEpoch) abi.TokenAmount {
+	price := ask.Price
+	for _, tier := range ask.PriceTiers {
+		if pieceSize >= tier.MinPieceSize && duration >= tier.MinDuration && tier.Price.LessThan(price) {
+			price = tier.Price
+		}
+	}
+	return price
+}
+
+// DealPricePerEpoch returns the lowest price per epoch the ask accepts for a
+// deal with the given piece size and duration
+func DealPricePerEpoch(ask StorageAsk, pieceSize abi.PaddedPieceSize, duration abi.ChainEpoch) abi.TokenAmount {
+	price := AskPrice(ask, pieceSize, duration)
+	return big.Div(big.Mul(price, abi.NewTokenAmount(int64(pieceSize))), abi.NewTokenAmount(1<<30))
+}
+
 type MinerDeal struct {
 	market.ClientDealProposal
 	ProposalCid      cid.Cid
@@ -144,6 +253,27 @@ type MinerDeal struct {
 	Ref *DataRef
 
 	DealID abi.DealID
+
+	// PublishIndex is the position of the deal in the PublishStorageDeals
+	// message that published it
+	PublishIndex uint64
+
+	// PendingApproval is set while a deal waits in StorageDealAcceptWait for
+	// the operator to approve or reject it, and Approved once the operator
+	// has approved it
+	PendingApproval bool
+	Approved        bool
+}
+
+// ProviderDealState is a summary of the provider's view of a deal, sent to
+// clients that query the status of a deal
+type ProviderDealState struct {
+	State       StorageDealStatus
+	Message     string
+	Proposal    *market.DealProposal
+	ProposalCid *cid.Cid
+	PublishCid  *cid.Cid
+	DealID      abi.DealID
 }
 
 type ProviderEvent uint64
@@ -236,39 +366,67 @@ const (
 
 	// ProviderEventFailed indicates a deal has failed and should no longer be processed
 	ProviderEventFailed
+
+	// ProviderEventRestart is used to resume the deal after a state machine shutdown
+	ProviderEventRestart
+
+	// ProviderEventAwaitingApproval happens when a deal is parked until the operator approves or rejects it
+	ProviderEventAwaitingApproval
+
+	// ProviderEventDealApproved happens when the operator approves a deal awaiting approval
+	ProviderEventDealApproved
+
+	// ProviderEventRestartAwaitingApproval is used to resume a deal in the approval queue after a state machine shutdown
+	ProviderEventRestartAwaitingApproval
+
+	// ProviderEventDealCancelled happens when the provider cancels a deal before it is published
+	ProviderEventDealCancelled
+
+	// ProviderEventClientCancelled happens when the client cancels a deal before it is published
+	ProviderEventClientCancelled
+
+	// ProviderEventApprovalRejected happens when a deal awaiting approval is rejected by the operator, or because it was not approved in time
+	ProviderEventApprovalRejected
 )
 
 // ProviderEvents maps provider event codes to string names
 var ProviderEvents = map[ProviderEvent]string{
-	ProviderEventOpen:                   "ProviderEventOpen",
-	ProviderEventNodeErrored:            "ProviderEventNodeErrored",
-	ProviderEventDealRejected:           "ProviderEventDealRejected",
-	ProviderEventDealAccepted:           "ProviderEventDealAccepted",
-	ProviderEventDealDeciding:           "ProviderEventDealDeciding",
-	ProviderEventInsufficientFunds:      "ProviderEventInsufficientFunds",
-	ProviderEventFundingInitiated:       "ProviderEventFundingInitiated",
-	ProviderEventFunded:                 "ProviderEventFunded",
-	ProviderEventDataTransferFailed:     "ProviderEventDataTransferFailed",
-	ProviderEventDataRequested:          "ProviderEventDataRequested",
-	ProviderEventDataTransferInitiated:  "ProviderEventDataTransferInitiated",
-	ProviderEventDataTransferCompleted:  "ProviderEventDataTransferCompleted",
-	ProviderEventManualDataReceived:     "ProviderEventManualDataReceived",
-	ProviderEventGeneratePieceCIDFailed: "ProviderEventGeneratePieceCIDFailed",
-	ProviderEventVerifiedData:           "ProviderEventVerifiedData",
-	ProviderEventSendResponseFailed:     "ProviderEventSendResponseFailed",
-	ProviderEventDealPublishInitiated:   "ProviderEventDealPublishInitiated",
-	ProviderEventDealPublished:          "ProviderEventDealPublished",
-	ProviderEventDealPublishError:       "ProviderEventDealPublishError",
-	ProviderEventFileStoreErrored:       "ProviderEventFileStoreErrored",
-	ProviderEventDealHandoffFailed:      "ProviderEventDealHandoffFailed",
-	ProviderEventDealHandedOff:          "ProviderEventDealHandedOff",
-	ProviderEventDealActivationFailed:   "ProviderEventDealActivationFailed",
-	ProviderEventUnableToLocatePiece:    "ProviderEventUnableToLocatePiece",
-	ProviderEventDealActivated:          "ProviderEventDealActivated",
-	ProviderEventPieceStoreErrored:      "ProviderEventPieceStoreErrored",
-	ProviderEventReadMetadataErrored:    "ProviderEventReadMetadataErrored",
-	ProviderEventDealCompleted:          "ProviderEventDealCompleted",
-	ProviderEventFailed:                 "ProviderEventFailed",
+	ProviderEventOpen:                    "ProviderEventOpen",
+	ProviderEventNodeErrored:             "ProviderEventNodeErrored",
+	ProviderEventDealRejected:            "ProviderEventDealRejected",
+	ProviderEventDealAccepted:            "ProviderEventDealAccepted",
+	ProviderEventDealDeciding:            "ProviderEventDealDeciding",
+	ProviderEventInsufficientFunds:       "ProviderEventInsufficientFunds",
+	ProviderEventFundingInitiated:        "ProviderEventFundingInitiated",
+	ProviderEventFunded:                  "ProviderEventFunded",
+	ProviderEventDataTransferFailed:      "ProviderEventDataTransferFailed",
+	ProviderEventDataRequested:           "ProviderEventDataRequested",
+	ProviderEventDataTransferInitiated:   "ProviderEventDataTransferInitiated",
+	ProviderEventDataTransferCompleted:   "ProviderEventDataTransferCompleted",
+	ProviderEventManualDataReceived:      "ProviderEventManualDataReceived",
+	ProviderEventGeneratePieceCIDFailed:  "ProviderEventGeneratePieceCIDFailed",
+	ProviderEventVerifiedData:            "ProviderEventVerifiedData",
+	ProviderEventSendResponseFailed:      "ProviderEventSendResponseFailed",
+	ProviderEventDealPublishInitiated:    "ProviderEventDealPublishInitiated",
+	ProviderEventDealPublished:           "ProviderEventDealPublished",
+	ProviderEventDealPublishError:        "ProviderEventDealPublishError",
+	ProviderEventFileStoreErrored:        "ProviderEventFileStoreErrored",
+	ProviderEventDealHandoffFailed:       "ProviderEventDealHandoffFailed",
+	ProviderEventDealHandedOff:           "ProviderEventDealHandedOff",
+	ProviderEventDealActivationFailed:    "ProviderEventDealActivationFailed",
+	ProviderEventUnableToLocatePiece:     "ProviderEventUnableToLocatePiece",
+	ProviderEventDealActivated:           "ProviderEventDealActivated",
+	ProviderEventPieceStoreErrored:       "ProviderEventPieceStoreErrored",
+	ProviderEventReadMetadataErrored:     "ProviderEventReadMetadataErrored",
+	ProviderEventDealCompleted:           "ProviderEventDealCompleted",
+	ProviderEventFailed:                  "ProviderEventFailed",
+	ProviderEventRestart:                 "ProviderEventRestart",
+	ProviderEventAwaitingApproval:        "ProviderEventAwaitingApproval",
+	ProviderEventDealApproved:            "ProviderEventDealApproved",
+	ProviderEventRestartAwaitingApproval: "ProviderEventRestartAwaitingApproval",
+	ProviderEventDealCancelled:           "ProviderEventDealCancelled",
+	ProviderEventClientCancelled:         "ProviderEventClientCancelled",
+	ProviderEventApprovalRejected:        "ProviderEventApprovalRejected",
 }
 
 type ClientDeal struct {
@@ -350,6 +508,24 @@ const (
 
 	// ClientEventFailed happens when a deal terminates in failure
 	ClientEventFailed
+
+	// ClientEventRestart is used to resume the deal after a state machine shutdown
+	ClientEventRestart
+
+	// ClientEventStreamLost happens when a client restarts while the deal still
+	// depends on a stream to the provider that cannot be recovered
+	ClientEventStreamLost
+
+	// ClientEventCheckForAcceptance happens when a client stops listening on the deal
+	// stream and starts polling the provider for the deal state instead
+	ClientEventCheckForAcceptance
+
+	// ClientEventWaitForDealState happens when a client polled the provider for the
+	// deal state and the deal is not yet accepted or rejected
+	ClientEventWaitForDealState
+
+	// ClientEventCancelled happens when a client cancels a deal before the provider accepts it
+	ClientEventCancelled
 )
 
 // ClientEvents maps client event codes to string names
@@ -375,6 +551,11 @@ var ClientEvents = map[ClientEvent]string{
 	ClientEventDealActivationFailed:       "ClientEventDealActivationFailed",
 	ClientEventDealActivated:              "ClientEventDealActivated",
 	ClientEventFailed:                     "ClientEventFailed",
+	ClientEventRestart:                    "ClientEventRestart",
+	ClientEventStreamLost:                 "ClientEventStreamLost",
+	ClientEventCheckForAcceptance:         "ClientEventCheckForAcceptance",
+	ClientEventWaitForDealState:           "ClientEventWaitForDealState",
+	ClientEventCancelled:                  "ClientEventCancelled",
 }
 
 // StorageDeal is a local combination of a proposal and a current deal state
@@ -392,6 +573,20 @@ type MessagePublishedCallback func(mcid cid.Cid, err error)
 type ProviderSubscriber func(event ProviderEvent, deal MinerDeal)
 type ClientSubscriber func(event ClientEvent, deal ClientDeal)
 
+// CommPProgress reports how much of the CAR for a payload a client has
+// processed while computing its commP, before any deal for it is proposed
+type CommPProgress struct {
+	PayloadCid cid.Cid
+	// Provider is the peer the deal will be proposed to, if there is only one
+	Provider  peer.ID
+	Processed uint64
+	Total     uint64
+}
+
+// CommPProgressSubscriber is called as a client computes commPs. It is called
+// on the goroutine computing the commP, so it should return quickly
+type CommPProgressSubscriber func(progress CommPProgress)
+
 // StorageProvider is the interface provided for storage providers
 type StorageProvider interface {
 	Start(ctx context.Context) error
@@ -409,14 +604,41 @@ type StorageProvider interface {
 	// ListLocalDeals lists deals processed by this storage provider
 	ListLocalDeals() ([]MinerDeal, error)
 
+	// ListPendingDeals lists deals waiting for the operator to approve or reject them
+	ListPendingDeals() ([]MinerDeal, error)
+
+	// ApproveDeal accepts a deal waiting for operator approval
+	ApproveDeal(proposalCid cid.Cid) error
+
+	// RejectDeal rejects a deal waiting for operator approval
+	RejectDeal(proposalCid cid.Cid, reason string) error
+
+	// CancelDeal aborts a deal that has not yet been published
+	CancelDeal(ctx context.Context, proposalCid cid.Cid, reason string) error
+
 	// AddStorageCollateral adds storage collateral
 	AddStorageCollateral(ctx context.Context, amount abi.TokenAmount) error
 
 	// GetStorageCollateral returns the current collateral balance
 	GetStorageCollateral(ctx context.Context) (Balance, error)
 
+	// StagingUsage reports the space reserved for, used by, and still available
+	// to deal data staged by this storage provider
+	StagingUsage() (filestore.Usage, error)
+
+	// ImportDataForDeal imports the data for an offline deal. The piece
+	// commitment is always computed over the imported data, never taken from
+	// the commP cache, as the data itself must be checked against the deal
 	ImportDataForDeal(ctx context.Context, propCid cid.Cid, data io.Reader) error
 
+	// ImportDataForDealFromFile imports the data for an offline deal from a
+	// CAR file in the provider's filestore, without copying it. Once the
+	// import succeeds the deal owns the file, and deletes it when the piece is
+	// handed off for sealing or the deal fails. If the import fails the file
+	// is left with the caller. Like ImportDataForDeal, it does not use the
+	// commP cache
+	ImportDataForDealFromFile(ctx context.Context, propCid cid.Cid, path filestore.Path) error
+
 	SubscribeToEvents(subscriber ProviderSubscriber) shared.Unsubscribe
 }
 
@@ -444,8 +666,9 @@ type StorageProviderNode interface {
 
 	GetChainHead(ctx context.Context) (shared.TipSetToken, abi.ChainEpoch, error)
 
-	// Publishes deal on chain, returns the message cid, but does not wait for message to appear
-	PublishDeals(ctx context.Context, deal MinerDeal) (cid.Cid, error)
+	// Publishes deals on chain in a single message, returns the message cid, but does not wait for message to appear.
+	// The deal IDs in the message return value are in the same order as the given deals
+	PublishDeals(ctx context.Context, deals []MinerDeal) (cid.Cid, error)
 
 	// ListProviderDeals lists all deals associated with a storage provider
 	ListProviderDeals(ctx context.Context, addr address.Address, tok shared.TipSetToken) ([]StorageDeal, error)
@@ -495,6 +718,9 @@ type StorageClientNode interface {
 	OnDealSectorCommitted(ctx context.Context, provider address.Address, dealID abi.DealID, cb DealSectorCommittedCallback) error
 
 	ValidateAskSignature(ctx context.Context, ask *SignedStorageAsk, tok shared.TipSetToken) (bool, error)
+
+	// Signs bytes
+	SignBytes(ctx context.Context, signer address.Address, b []byte) (*crypto.Signature, error)
 }
 
 type StorageClientProofs interface {
@@ -515,17 +741,131 @@ type ProposeStorageDealResult struct {
 	ProposalCid cid.Cid
 }
 
+// AskCriteria describes the deal a client wants to make when it searches for
+// storage offers
+type AskCriteria struct {
+	// PieceSize and Duration describe the deal. Providers whose ask does not
+	// accept a piece of this size are skipped, and offers are ranked by the
+	// total price of the deal
+	PieceSize abi.PaddedPieceSize
+	Duration  abi.ChainEpoch
+
+	// MaxPrice is the highest price per GiB / Epoch the client will pay. A nil
+	// MaxPrice means there is no limit
+	MaxPrice abi.TokenAmount
+
+	// SectorSize selects only providers with the given sector size, if non-zero
+	SectorSize uint64
+
+	// MinAskExpiry skips asks that expire before the given epoch, if non-zero.
+	// Asks that have already expired are always skipped
+	MinAskExpiry abi.ChainEpoch
+}
+
+// StorageOffer is a provider's ask that satisfies some AskCriteria
+type StorageOffer struct {
+	Provider StorageProviderInfo
+	Ask      *SignedStorageAsk
+
+	// PricePerEpoch is the lowest price per epoch the ask accepts for the
+	// criteria's piece size
+	PricePerEpoch abi.TokenAmount
+	// TotalPrice is the price of the deal over the criteria's duration
+	TotalPrice abi.TokenAmount
+}
+
 const (
 	TTGraphsync = "graphsync"
 	TTManual    = "manual"
+
+	// TTProviderPull transfers the data over graphsync like TTGraphsync, but
+	// the provider opens the channel and pulls the data from the client, so
+	// that clients which can't dial out to the provider can still make deals
+	TTProviderPull = "providerpull"
+
+	// TTHTTP has the provider download the deal data as a CAR file from the
+	// URL in the DataRef
+	TTHTTP = "http"
 )
 
 type DataRef struct {
 	TransferType string
 	Root         cid.Cid
 
-	PieceCid  *cid.Cid              // Optional for non-manual transfer, will be recomputed from the data if not given
-	PieceSize abi.UnpaddedPieceSize // Optional for non-manual transfer, will be recomputed from the data if not given
+	PieceCid  *cid.Cid              // Optional for graphsync transfers, will be recomputed from the data if not given
+	PieceSize abi.UnpaddedPieceSize // Optional for graphsync transfers, will be recomputed from the data if not given
+
+	URL     string       // Required for TTHTTP transfers, the location of the CAR file holding the data
+	Headers []HTTPHeader // Optional for TTHTTP transfers, sent with each request for the CAR file, e.g. for authorization
+}
+
+// HTTPHeader is a header sent with requests for the deal data of a TTHTTP transfer
+type HTTPHeader struct {
+	Name  string
+	Value string
+}
+
+// OfferSelector chooses the providers to store a piece of the given size
+// with, for the given number of epochs, in order of preference
+type OfferSelector func(ctx context.Context, pieceSize abi.PaddedPieceSize, duration abi.ChainEpoch) ([]StorageProviderInfo, error)
+
+// ReplicationParams describes the deals a client makes to store copies of the
+// same data with several providers
+type ReplicationParams struct {
+	// Client is the address the deals are proposed from
+	Client address.Address
+	Data   *DataRef
+
+	// Replicas is the number of providers that should store the data
+	Replicas uint
+
+	// Providers are the candidate providers, in order of preference. If no
+	// providers are given, the candidates are chosen with SelectOffers
+	Providers    []StorageProviderInfo
+	SelectOffers OfferSelector
+
+	// MaxPrice is the highest price per GiB / Epoch the client will pay. Each
+	// deal is proposed at the price of the provider's ask, and providers whose
+	// ask is above MaxPrice are skipped. A nil MaxPrice means there is no limit
+	MaxPrice abi.TokenAmount
+
+	StartEpoch abi.ChainEpoch
+	EndEpoch   abi.ChainEpoch
+	// Collateral is the provider collateral proposed in each deal. A zero
+	// Collateral leaves the choice to the client's collateral policy
+	Collateral abi.TokenAmount
+	ProofType  abi.RegisteredProof
+}
+
+// ReplicationID identifies a replication started by a storage client
+type ReplicationID uint64
+
+// Replica is a deal proposed to a single provider as part of a replication
+type Replica struct {
+	Provider StorageProviderInfo
+	// ProposalCid is undefined if the deal could not be proposed
+	ProposalCid cid.Cid
+	State       StorageDealStatus
+	Message     string
+}
+
+// ReplicationStatus is the progress of a replication
+type ReplicationStatus struct {
+	ID        ReplicationID
+	PieceCid  cid.Cid
+	PieceSize abi.UnpaddedPieceSize
+	Replicas  uint
+
+	// Deals lists every deal proposed for the replication, including those
+	// that failed and were replaced by a deal with the next candidate provider
+	Deals  []Replica
+	Active uint
+	Failed uint
+
+	// Complete is set once the target number of deals are active, or once no
+	// candidate providers are left to replace the deals that failed
+	Complete bool
+	Message  string
 }
 
 // The interface provided by the module to the outside world for storage clients.
@@ -549,12 +889,27 @@ type StorageClient interface {
 	// GetAsk returns the current ask for a storage provider
 	GetAsk(ctx context.Context, info StorageProviderInfo) (*SignedStorageAsk, error)
 
-	//// FindStorageOffers lists providers and queries them to find offers that satisfy some criteria based on price, duration, etc.
-	//FindStorageOffers(criteria AskCriteria, limit uint) []*StorageOffer
+	// GetProviderDealState queries the provider of a deal for the provider's view of the deal
+	GetProviderDealState(ctx context.Context, proposalCid cid.Cid) (*ProviderDealState, error)
 
-	// ProposeStorageDeal initiates deal negotiation with a Storage Provider
+	// CancelDeal cancels a deal the provider has not yet accepted
+	CancelDeal(ctx context.Context, proposalCid cid.Cid) error
+
+	// FindStorageOffers lists providers and queries them to find offers that satisfy some criteria based on price, duration, etc.
+	FindStorageOffers(ctx context.Context, criteria AskCriteria, limit uint) ([]*StorageOffer, error)
+
+	// ProposeStorageDeal initiates deal negotiation with a Storage Provider.
+	// A zero collateral has the client's collateral policy decide the
+	// provider collateral from the provider's ask
 	ProposeStorageDeal(ctx context.Context, addr address.Address, info *StorageProviderInfo, data *DataRef, startEpoch abi.ChainEpoch, endEpoch abi.ChainEpoch, price abi.TokenAmount, collateral abi.TokenAmount, rt abi.RegisteredProof) (*ProposeStorageDealResult, error)
 
+	// ReplicateData proposes deals to store the same data with several providers
+	ReplicateData(ctx context.Context, params ReplicationParams) (ReplicationID, error)
+
+	// GetReplicationStatus returns the progress of a replication. The status
+	// of a finished replication is only kept for a while after it finishes
+	GetReplicationStatus(ctx context.Context, id ReplicationID) (ReplicationStatus, error)
+
 	// GetPaymentEscrow returns the current funds available for deal payment
 	GetPaymentEscrow(ctx context.Context, addr address.Address) (Balance, error)
 
@@ -562,4 +917,8 @@ type StorageClient interface {
 	AddPaymentEscrow(ctx context.Context, addr address.Address, amount abi.TokenAmount) error
 
 	SubscribeToEvents(subscriber ClientSubscriber) shared.Unsubscribe
+
+	// SubscribeToCommPProgress listens for progress computing the commP of
+	// data for deals that are being proposed
+	SubscribeToCommPProgress(subscriber CommPProgressSubscriber) shared.Unsubscribe
 }
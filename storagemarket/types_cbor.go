@@ -0,0 +1,59 @@
+package storagemarket
+
+import (
+	"io"
+
+	"github.com/filecoin-project/go-fil-markets/shared"
+)
+
+// dataRefTuple is the cbor-gen encoding of DataRef, whose URL and Headers
+// were added after its original four fields
+type dataRefTuple DataRef
+
+var dataRefCodec = shared.NewTupleCodec(4, &dataRefTuple{})
+
+// MarshalCBOR encodes a DataRef, leaving out the URL and Headers when unset
+func (t *DataRef) MarshalCBOR(w io.Writer) error {
+	return dataRefCodec.Marshal(w, (*dataRefTuple)(t))
+}
+
+// UnmarshalCBOR decodes a DataRef, with or without the URL and Headers
+func (t *DataRef) UnmarshalCBOR(r io.Reader) error {
+	return dataRefCodec.Unmarshal(r, (*dataRefTuple)(t))
+}
+
+// storageAskTuple is the cbor-gen encoding of StorageAsk, whose collateral
+// bounds and price tiers were added after its original seven fields
+type storageAskTuple StorageAsk
+
+var storageAskCodec = shared.NewTupleCodec(7, &storageAskTuple{})
+
+// MarshalCBOR encodes a StorageAsk, leaving out the collateral bounds and
+// price tiers when unset
+func (t *StorageAsk) MarshalCBOR(w io.Writer) error {
+	return storageAskCodec.Marshal(w, (*storageAskTuple)(t))
+}
+
+// UnmarshalCBOR decodes a StorageAsk, with or without the collateral bounds
+// and price tiers
+func (t *StorageAsk) UnmarshalCBOR(r io.Reader) error {
+	return storageAskCodec.Unmarshal(r, (*storageAskTuple)(t))
+}
+
+// minerDealTuple is the cbor-gen encoding of MinerDeal, whose PublishIndex
+// and approval flags were added after its original thirteen fields
+type minerDealTuple MinerDeal
+
+var minerDealCodec = shared.NewTupleCodec(13, &minerDealTuple{})
+
+// MarshalCBOR encodes a MinerDeal, leaving out the PublishIndex and approval
+// flags when unset
+func (t *MinerDeal) MarshalCBOR(w io.Writer) error {
+	return minerDealCodec.Marshal(w, (*minerDealTuple)(t))
+}
+
+// UnmarshalCBOR decodes a MinerDeal, including ones stored before the
+// PublishIndex and approval flags were added
+func (t *MinerDeal) UnmarshalCBOR(r io.Reader) error {
+	return minerDealCodec.Unmarshal(r, (*minerDealTuple)(t))
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/filecoin-project/go-fil-markets/filestore"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
 	"github.com/filecoin-project/specs-actors/actors/crypto"
 	"github.com/libp2p/go-libp2p-core/peer"
 	cbg "github.com/whyrusleeping/cbor-gen"
@@ -295,12 +296,12 @@ func (t *ClientDeal) UnmarshalCBOR(r io.Reader) error {
 	return nil
 }
 
-func (t *MinerDeal) MarshalCBOR(w io.Writer) error {
+func (t *minerDealTuple) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
 		return err
 	}
-	if _, err := w.Write([]byte{141}); err != nil {
+	if _, err := w.Write([]byte{144}); err != nil {
 		return err
 	}
 
@@ -421,10 +422,25 @@ func (t *MinerDeal) MarshalCBOR(w io.Writer) error {
 		return err
 	}
 
+	// t.PublishIndex (uint64) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.PublishIndex))); err != nil {
+		return err
+	}
+
+	// t.PendingApproval (bool) (bool)
+	if err := cbg.WriteBool(w, t.PendingApproval); err != nil {
+		return err
+	}
+
+	// t.Approved (bool) (bool)
+	if err := cbg.WriteBool(w, t.Approved); err != nil {
+		return err
+	}
 	return nil
 }
 
-func (t *MinerDeal) UnmarshalCBOR(r io.Reader) error {
+func (t *minerDealTuple) UnmarshalCBOR(r io.Reader) error {
 	br := cbg.GetPeeker(r)
 
 	maj, extra, err := cbg.CborReadHeader(br)
@@ -435,7 +451,7 @@ func (t *MinerDeal) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input should be of type array")
 	}
 
-	if extra != 13 {
+	if extra != 16 {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
@@ -624,6 +640,54 @@ func (t *MinerDeal) UnmarshalCBOR(r io.Reader) error {
 		t.DealID = abi.DealID(extra)
 
 	}
+	// t.PublishIndex (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.PublishIndex = uint64(extra)
+
+	}
+	// t.PendingApproval (bool) (bool)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajOther {
+		return fmt.Errorf("booleans must be major type 7")
+	}
+	switch extra {
+	case 20:
+		t.PendingApproval = false
+	case 21:
+		t.PendingApproval = true
+	default:
+		return fmt.Errorf("booleans are either major type 7, value 20 or 21 (got %d)", extra)
+	}
+	// t.Approved (bool) (bool)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajOther {
+		return fmt.Errorf("booleans must be major type 7")
+	}
+	switch extra {
+	case 20:
+		t.Approved = false
+	case 21:
+		t.Approved = true
+	default:
+		return fmt.Errorf("booleans are either major type 7, value 20 or 21 (got %d)", extra)
+	}
 	return nil
 }
 
@@ -765,17 +829,12 @@ func (t *SignedStorageAsk) UnmarshalCBOR(r io.Reader) error {
 	return nil
 }
 
-func (t *StorageAsk) MarshalCBOR(w io.Writer) error {
+func (t *PriceTier) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
 		return err
 	}
-	if _, err := w.Write([]byte{135}); err != nil {
-		return err
-	}
-
-	// t.Price (big.Int) (struct)
-	if err := t.Price.MarshalCBOR(w); err != nil {
+	if _, err := w.Write([]byte{131}); err != nil {
 		return err
 	}
 
@@ -785,49 +844,25 @@ func (t *StorageAsk) MarshalCBOR(w io.Writer) error {
 		return err
 	}
 
-	// t.MaxPieceSize (abi.PaddedPieceSize) (uint64)
-
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.MaxPieceSize))); err != nil {
-		return err
-	}
-
-	// t.Miner (address.Address) (struct)
-	if err := t.Miner.MarshalCBOR(w); err != nil {
-		return err
-	}
-
-	// t.Timestamp (abi.ChainEpoch) (int64)
-	if t.Timestamp >= 0 {
-		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Timestamp))); err != nil {
-			return err
-		}
-	} else {
-		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajNegativeInt, uint64(-t.Timestamp)-1)); err != nil {
-			return err
-		}
-	}
-
-	// t.Expiry (abi.ChainEpoch) (int64)
-	if t.Expiry >= 0 {
-		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Expiry))); err != nil {
+	// t.MinDuration (abi.ChainEpoch) (int64)
+	if t.MinDuration >= 0 {
+		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.MinDuration))); err != nil {
 			return err
 		}
 	} else {
-		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajNegativeInt, uint64(-t.Expiry)-1)); err != nil {
+		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajNegativeInt, uint64(-t.MinDuration)-1)); err != nil {
 			return err
 		}
 	}
 
-	// t.SeqNo (uint64) (uint64)
-
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.SeqNo))); err != nil {
+	// t.Price (big.Int) (struct)
+	if err := t.Price.MarshalCBOR(w); err != nil {
 		return err
 	}
-
 	return nil
 }
 
-func (t *StorageAsk) UnmarshalCBOR(r io.Reader) error {
+func (t *PriceTier) UnmarshalCBOR(r io.Reader) error {
 	br := cbg.GetPeeker(r)
 
 	maj, extra, err := cbg.CborReadHeader(br)
@@ -838,19 +873,10 @@ func (t *StorageAsk) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input should be of type array")
 	}
 
-	if extra != 7 {
+	if extra != 3 {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
-	// t.Price (big.Int) (struct)
-
-	{
-
-		if err := t.Price.UnmarshalCBOR(br); err != nil {
-			return xerrors.Errorf("unmarshaling t.Price: %w", err)
-		}
-
-	}
 	// t.MinPieceSize (abi.PaddedPieceSize) (uint64)
 
 	{
@@ -865,55 +891,7 @@ func (t *StorageAsk) UnmarshalCBOR(r io.Reader) error {
 		t.MinPieceSize = abi.PaddedPieceSize(extra)
 
 	}
-	// t.MaxPieceSize (abi.PaddedPieceSize) (uint64)
-
-	{
-
-		maj, extra, err = cbg.CborReadHeader(br)
-		if err != nil {
-			return err
-		}
-		if maj != cbg.MajUnsignedInt {
-			return fmt.Errorf("wrong type for uint64 field")
-		}
-		t.MaxPieceSize = abi.PaddedPieceSize(extra)
-
-	}
-	// t.Miner (address.Address) (struct)
-
-	{
-
-		if err := t.Miner.UnmarshalCBOR(br); err != nil {
-			return xerrors.Errorf("unmarshaling t.Miner: %w", err)
-		}
-
-	}
-	// t.Timestamp (abi.ChainEpoch) (int64)
-	{
-		maj, extra, err := cbg.CborReadHeader(br)
-		var extraI int64
-		if err != nil {
-			return err
-		}
-		switch maj {
-		case cbg.MajUnsignedInt:
-			extraI = int64(extra)
-			if extraI < 0 {
-				return fmt.Errorf("int64 positive overflow")
-			}
-		case cbg.MajNegativeInt:
-			extraI = int64(extra)
-			if extraI < 0 {
-				return fmt.Errorf("int64 negative oveflow")
-			}
-			extraI = -1 - extraI
-		default:
-			return fmt.Errorf("wrong type for int64 field: %d", maj)
-		}
-
-		t.Timestamp = abi.ChainEpoch(extraI)
-	}
-	// t.Expiry (abi.ChainEpoch) (int64)
+	// t.MinDuration (abi.ChainEpoch) (int64)
 	{
 		maj, extra, err := cbg.CborReadHeader(br)
 		var extraI int64
@@ -936,20 +914,15 @@ func (t *StorageAsk) UnmarshalCBOR(r io.Reader) error {
 			return fmt.Errorf("wrong type for int64 field: %d", maj)
 		}
 
-		t.Expiry = abi.ChainEpoch(extraI)
+		t.MinDuration = abi.ChainEpoch(extraI)
 	}
-	// t.SeqNo (uint64) (uint64)
+	// t.Price (big.Int) (struct)
 
 	{
 
-		maj, extra, err = cbg.CborReadHeader(br)
-		if err != nil {
-			return err
-		}
-		if maj != cbg.MajUnsignedInt {
-			return fmt.Errorf("wrong type for uint64 field")
+		if err := t.Price.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Price: %w", err)
 		}
-		t.SeqNo = uint64(extra)
 
 	}
 	return nil
@@ -1012,55 +985,42 @@ func (t *StorageDeal) UnmarshalCBOR(r io.Reader) error {
 	return nil
 }
 
-func (t *DataRef) MarshalCBOR(w io.Writer) error {
+func (t *HTTPHeader) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
 		return err
 	}
-	if _, err := w.Write([]byte{132}); err != nil {
+	if _, err := w.Write([]byte{130}); err != nil {
 		return err
 	}
 
-	// t.TransferType (string) (string)
-	if len(t.TransferType) > cbg.MaxLength {
-		return xerrors.Errorf("Value in field t.TransferType was too long")
+	// t.Name (string) (string)
+	if len(t.Name) > cbg.MaxLength {
+		return xerrors.Errorf("Value in field t.Name was too long")
 	}
 
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.TransferType)))); err != nil {
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.Name)))); err != nil {
 		return err
 	}
-	if _, err := w.Write([]byte(t.TransferType)); err != nil {
+	if _, err := w.Write([]byte(t.Name)); err != nil {
 		return err
 	}
 
-	// t.Root (cid.Cid) (struct)
-
-	if err := cbg.WriteCid(w, t.Root); err != nil {
-		return xerrors.Errorf("failed to write cid field t.Root: %w", err)
+	// t.Value (string) (string)
+	if len(t.Value) > cbg.MaxLength {
+		return xerrors.Errorf("Value in field t.Value was too long")
 	}
 
-	// t.PieceCid (cid.Cid) (struct)
-
-	if t.PieceCid == nil {
-		if _, err := w.Write(cbg.CborNull); err != nil {
-			return err
-		}
-	} else {
-		if err := cbg.WriteCid(w, *t.PieceCid); err != nil {
-			return xerrors.Errorf("failed to write cid field t.PieceCid: %w", err)
-		}
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.Value)))); err != nil {
+		return err
 	}
-
-	// t.PieceSize (abi.UnpaddedPieceSize) (uint64)
-
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.PieceSize))); err != nil {
+	if _, err := w.Write([]byte(t.Value)); err != nil {
 		return err
 	}
-
 	return nil
 }
 
-func (t *DataRef) UnmarshalCBOR(r io.Reader) error {
+func (t *HTTPHeader) UnmarshalCBOR(r io.Reader) error {
 	br := cbg.GetPeeker(r)
 
 	maj, extra, err := cbg.CborReadHeader(br)
@@ -1071,11 +1031,11 @@ func (t *DataRef) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input should be of type array")
 	}
 
-	if extra != 4 {
+	if extra != 2 {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
-	// t.TransferType (string) (string)
+	// t.Name (string) (string)
 
 	{
 		sval, err := cbg.ReadString(br)
@@ -1083,57 +1043,657 @@ func (t *DataRef) UnmarshalCBOR(r io.Reader) error {
 			return err
 		}
 
-		t.TransferType = string(sval)
+		t.Name = string(sval)
 	}
-	// t.Root (cid.Cid) (struct)
+	// t.Value (string) (string)
 
 	{
-
-		c, err := cbg.ReadCid(br)
+		sval, err := cbg.ReadString(br)
 		if err != nil {
-			return xerrors.Errorf("failed to read cid field t.Root: %w", err)
+			return err
 		}
 
-		t.Root = c
+		t.Value = string(sval)
+	}
+	return nil
+}
 
+func (t *ProviderDealState) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{134}); err != nil {
+		return err
 	}
-	// t.PieceCid (cid.Cid) (struct)
 
-	{
+	// t.State (uint64) (uint64)
 
-		pb, err := br.PeekByte()
-		if err != nil {
-			return err
-		}
-		if pb == cbg.CborNull[0] {
-			var nbuf [1]byte
-			if _, err := br.Read(nbuf[:]); err != nil {
-				return err
-			}
-		} else {
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.State))); err != nil {
+		return err
+	}
 
-			c, err := cbg.ReadCid(br)
-			if err != nil {
-				return xerrors.Errorf("failed to read cid field t.PieceCid: %w", err)
-			}
+	// t.Message (string) (string)
+	if len(t.Message) > cbg.MaxLength {
+		return xerrors.Errorf("Value in field t.Message was too long")
+	}
 
-			t.PieceCid = &c
-		}
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.Message)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.Message)); err != nil {
+		return err
+	}
 
+	// t.Proposal (market.DealProposal) (struct)
+	if err := t.Proposal.MarshalCBOR(w); err != nil {
+		return err
 	}
-	// t.PieceSize (abi.UnpaddedPieceSize) (uint64)
 
-	{
+	// t.ProposalCid (cid.Cid) (struct)
 
-		maj, extra, err = cbg.CborReadHeader(br)
-		if err != nil {
+	if t.ProposalCid == nil {
+		if _, err := w.Write(cbg.CborNull); err != nil {
 			return err
 		}
-		if maj != cbg.MajUnsignedInt {
-			return fmt.Errorf("wrong type for uint64 field")
+	} else {
+		if err := cbg.WriteCid(w, *t.ProposalCid); err != nil {
+			return xerrors.Errorf("failed to write cid field t.ProposalCid: %w", err)
 		}
-		t.PieceSize = abi.UnpaddedPieceSize(extra)
+	}
 
+	// t.PublishCid (cid.Cid) (struct)
+
+	if t.PublishCid == nil {
+		if _, err := w.Write(cbg.CborNull); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteCid(w, *t.PublishCid); err != nil {
+			return xerrors.Errorf("failed to write cid field t.PublishCid: %w", err)
+		}
 	}
+
+	// t.DealID (abi.DealID) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.DealID))); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *ProviderDealState) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 6 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.State (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.State = uint64(extra)
+
+	}
+	// t.Message (string) (string)
+
+	{
+		sval, err := cbg.ReadString(br)
+		if err != nil {
+			return err
+		}
+
+		t.Message = string(sval)
+	}
+	// t.Proposal (market.DealProposal) (struct)
+
+	{
+
+		pb, err := br.PeekByte()
+		if err != nil {
+			return err
+		}
+		if pb == cbg.CborNull[0] {
+			var nbuf [1]byte
+			if _, err := br.Read(nbuf[:]); err != nil {
+				return err
+			}
+		} else {
+			t.Proposal = new(market.DealProposal)
+			if err := t.Proposal.UnmarshalCBOR(br); err != nil {
+				return xerrors.Errorf("unmarshaling t.Proposal pointer: %w", err)
+			}
+		}
+
+	}
+	// t.ProposalCid (cid.Cid) (struct)
+
+	{
+
+		pb, err := br.PeekByte()
+		if err != nil {
+			return err
+		}
+		if pb == cbg.CborNull[0] {
+			var nbuf [1]byte
+			if _, err := br.Read(nbuf[:]); err != nil {
+				return err
+			}
+		} else {
+
+			c, err := cbg.ReadCid(br)
+			if err != nil {
+				return xerrors.Errorf("failed to read cid field t.ProposalCid: %w", err)
+			}
+
+			t.ProposalCid = &c
+		}
+
+	}
+	// t.PublishCid (cid.Cid) (struct)
+
+	{
+
+		pb, err := br.PeekByte()
+		if err != nil {
+			return err
+		}
+		if pb == cbg.CborNull[0] {
+			var nbuf [1]byte
+			if _, err := br.Read(nbuf[:]); err != nil {
+				return err
+			}
+		} else {
+
+			c, err := cbg.ReadCid(br)
+			if err != nil {
+				return xerrors.Errorf("failed to read cid field t.PublishCid: %w", err)
+			}
+
+			t.PublishCid = &c
+		}
+
+	}
+	// t.DealID (abi.DealID) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.DealID = abi.DealID(extra)
+
+	}
+	return nil
+}
+
+func (t *dataRefTuple) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{134}); err != nil {
+		return err
+	}
+
+	// t.TransferType (string) (string)
+	if len(t.TransferType) > cbg.MaxLength {
+		return xerrors.Errorf("Value in field t.TransferType was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.TransferType)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.TransferType)); err != nil {
+		return err
+	}
+
+	// t.Root (cid.Cid) (struct)
+
+	if err := cbg.WriteCid(w, t.Root); err != nil {
+		return xerrors.Errorf("failed to write cid field t.Root: %w", err)
+	}
+
+	// t.PieceCid (cid.Cid) (struct)
+
+	if t.PieceCid == nil {
+		if _, err := w.Write(cbg.CborNull); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteCid(w, *t.PieceCid); err != nil {
+			return xerrors.Errorf("failed to write cid field t.PieceCid: %w", err)
+		}
+	}
+
+	// t.PieceSize (abi.UnpaddedPieceSize) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.PieceSize))); err != nil {
+		return err
+	}
+
+	// t.URL (string) (string)
+	if len(t.URL) > cbg.MaxLength {
+		return xerrors.Errorf("Value in field t.URL was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.URL)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.URL)); err != nil {
+		return err
+	}
+
+	// t.Headers ([]storagemarket.HTTPHeader) (slice)
+	if len(t.Headers) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.Headers was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajArray, uint64(len(t.Headers)))); err != nil {
+		return err
+	}
+	for _, v := range t.Headers {
+		if err := v.MarshalCBOR(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *dataRefTuple) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 6 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.TransferType (string) (string)
+
+	{
+		sval, err := cbg.ReadString(br)
+		if err != nil {
+			return err
+		}
+
+		t.TransferType = string(sval)
+	}
+	// t.Root (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.Root: %w", err)
+		}
+
+		t.Root = c
+
+	}
+	// t.PieceCid (cid.Cid) (struct)
+
+	{
+
+		pb, err := br.PeekByte()
+		if err != nil {
+			return err
+		}
+		if pb == cbg.CborNull[0] {
+			var nbuf [1]byte
+			if _, err := br.Read(nbuf[:]); err != nil {
+				return err
+			}
+		} else {
+
+			c, err := cbg.ReadCid(br)
+			if err != nil {
+				return xerrors.Errorf("failed to read cid field t.PieceCid: %w", err)
+			}
+
+			t.PieceCid = &c
+		}
+
+	}
+	// t.PieceSize (abi.UnpaddedPieceSize) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.PieceSize = abi.UnpaddedPieceSize(extra)
+
+	}
+	// t.URL (string) (string)
+
+	{
+		sval, err := cbg.ReadString(br)
+		if err != nil {
+			return err
+		}
+
+		t.URL = string(sval)
+	}
+	// t.Headers ([]storagemarket.HTTPHeader) (slice)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.Headers: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajArray {
+		return fmt.Errorf("expected cbor array")
+	}
+
+	if extra > 0 {
+		t.Headers = make([]HTTPHeader, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+
+		var v HTTPHeader
+		if err := v.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+		t.Headers[i] = v
+	}
+
+	return nil
+}
+
+func (t *storageAskTuple) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{138}); err != nil {
+		return err
+	}
+
+	// t.Price (big.Int) (struct)
+	if err := t.Price.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.MinPieceSize (abi.PaddedPieceSize) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.MinPieceSize))); err != nil {
+		return err
+	}
+
+	// t.MaxPieceSize (abi.PaddedPieceSize) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.MaxPieceSize))); err != nil {
+		return err
+	}
+
+	// t.Miner (address.Address) (struct)
+	if err := t.Miner.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Timestamp (abi.ChainEpoch) (int64)
+	if t.Timestamp >= 0 {
+		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Timestamp))); err != nil {
+			return err
+		}
+	} else {
+		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajNegativeInt, uint64(-t.Timestamp)-1)); err != nil {
+			return err
+		}
+	}
+
+	// t.Expiry (abi.ChainEpoch) (int64)
+	if t.Expiry >= 0 {
+		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Expiry))); err != nil {
+			return err
+		}
+	} else {
+		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajNegativeInt, uint64(-t.Expiry)-1)); err != nil {
+			return err
+		}
+	}
+
+	// t.SeqNo (uint64) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.SeqNo))); err != nil {
+		return err
+	}
+
+	// t.MinProviderCollateral (big.Int) (struct)
+	if err := t.MinProviderCollateral.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.MaxProviderCollateral (big.Int) (struct)
+	if err := t.MaxProviderCollateral.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.PriceTiers ([]storagemarket.PriceTier) (slice)
+	if len(t.PriceTiers) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.PriceTiers was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajArray, uint64(len(t.PriceTiers)))); err != nil {
+		return err
+	}
+	for _, v := range t.PriceTiers {
+		if err := v.MarshalCBOR(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *storageAskTuple) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 10 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Price (big.Int) (struct)
+
+	{
+
+		if err := t.Price.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Price: %w", err)
+		}
+
+	}
+	// t.MinPieceSize (abi.PaddedPieceSize) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.MinPieceSize = abi.PaddedPieceSize(extra)
+
+	}
+	// t.MaxPieceSize (abi.PaddedPieceSize) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.MaxPieceSize = abi.PaddedPieceSize(extra)
+
+	}
+	// t.Miner (address.Address) (struct)
+
+	{
+
+		if err := t.Miner.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Miner: %w", err)
+		}
+
+	}
+	// t.Timestamp (abi.ChainEpoch) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeader(br)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.Timestamp = abi.ChainEpoch(extraI)
+	}
+	// t.Expiry (abi.ChainEpoch) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeader(br)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.Expiry = abi.ChainEpoch(extraI)
+	}
+	// t.SeqNo (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.SeqNo = uint64(extra)
+
+	}
+	// t.MinProviderCollateral (big.Int) (struct)
+
+	{
+
+		if err := t.MinProviderCollateral.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.MinProviderCollateral: %w", err)
+		}
+
+	}
+	// t.MaxProviderCollateral (big.Int) (struct)
+
+	{
+
+		if err := t.MaxProviderCollateral.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.MaxProviderCollateral: %w", err)
+		}
+
+	}
+	// t.PriceTiers ([]storagemarket.PriceTier) (slice)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.PriceTiers: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajArray {
+		return fmt.Errorf("expected cbor array")
+	}
+
+	if extra > 0 {
+		t.PriceTiers = make([]PriceTier, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+
+		var v PriceTier
+		if err := v.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+		t.PriceTiers[i] = v
+	}
+
 	return nil
 }
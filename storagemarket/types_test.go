@@ -0,0 +1,215 @@
+package storagemarket_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+	"github.com/filecoin-project/specs-actors/actors/crypto"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/filestore"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+)
+
+func TestDataRefMarshalUnmarshal(t *testing.T) {
+	root, err := cid.Decode("bafkreicicneu2e36cyy3xiyb2wwkw3t3w6vhjtqrqxkfmvs66uoxg5txwi")
+	require.NoError(t, err)
+	pieceCid, err := cid.Decode("bafkreibuenncyubohem5h4ak6xnlxb6llcxpivtlcbrr6ks5xfevb277xu")
+	require.NoError(t, err)
+	ref := storagemarket.DataRef{
+		TransferType: storagemarket.TTGraphsync,
+		Root:         root,
+		PieceCid:     &pieceCid,
+		PieceSize:    1016,
+	}
+
+	// ref encoded before URL and Headers were added
+	original, err := hex.DecodeString("8469677261706873796e63d82a582500015512204813494d137e1631bba301d5acab6e7bb7aa74ce1185d456565ef51d737677b2d82a5825000155122034235a2c502e3919d3f00af5dabb87cb58aef4566b10631f2a5db94950ebffbd1903f8")
+	require.NoError(t, err)
+
+	t.Run("reads the original encoding", func(t *testing.T) {
+		var unmarshalled storagemarket.DataRef
+		err := unmarshalled.UnmarshalCBOR(bytes.NewReader(original))
+		require.NoError(t, err)
+		require.Equal(t, ref, unmarshalled)
+	})
+
+	t.Run("without a URL, matches the original encoding", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		err := ref.MarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, original, buf.Bytes())
+	})
+
+	t.Run("with a URL and headers", func(t *testing.T) {
+		httpRef := ref
+		httpRef.TransferType = storagemarket.TTHTTP
+		httpRef.URL = "https://example.com/data.car"
+		httpRef.Headers = []storagemarket.HTTPHeader{{Name: "Authorization", Value: "Bearer token"}}
+		buf := new(bytes.Buffer)
+		err := httpRef.MarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, byte(0x86), buf.Bytes()[0])
+
+		var unmarshalled storagemarket.DataRef
+		err = unmarshalled.UnmarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, httpRef, unmarshalled)
+	})
+
+	t.Run("wrong number of fields", func(t *testing.T) {
+		var unmarshalled storagemarket.DataRef
+		err := unmarshalled.UnmarshalCBOR(bytes.NewReader([]byte{0x83}))
+		require.EqualError(t, err, "cbor input had wrong number of fields")
+	})
+}
+
+func TestStorageAskMarshalUnmarshal(t *testing.T) {
+	ask := storagemarket.StorageAsk{
+		Price:        abi.NewTokenAmount(100),
+		MinPieceSize: 256,
+		MaxPieceSize: 1 << 20,
+		Miner:        address.TestAddress,
+		Timestamp:    10,
+		Expiry:       20,
+		SeqNo:        3,
+	}
+
+	// ask encoded before the collateral bounds and price tiers were added
+	original, err := hex.DecodeString("874200641901001a0010000055024716b023b7fe84b6e7dcda303c3d754b1a8ff2fc0a1403")
+	require.NoError(t, err)
+
+	t.Run("reads the original encoding", func(t *testing.T) {
+		var unmarshalled storagemarket.StorageAsk
+		err := unmarshalled.UnmarshalCBOR(bytes.NewReader(original))
+		require.NoError(t, err)
+		expected := ask
+		expected.MinProviderCollateral = abi.NewTokenAmount(0)
+		expected.MaxProviderCollateral = abi.NewTokenAmount(0)
+		require.Equal(t, expected, unmarshalled)
+	})
+
+	t.Run("without collateral bounds or tiers, matches the original encoding", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		err := ask.MarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, original, buf.Bytes())
+	})
+
+	t.Run("with collateral bounds", func(t *testing.T) {
+		collateralAsk := ask
+		collateralAsk.MinProviderCollateral = abi.NewTokenAmount(5)
+		collateralAsk.MaxProviderCollateral = abi.NewTokenAmount(10)
+		buf := new(bytes.Buffer)
+		err := collateralAsk.MarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, byte(0x89), buf.Bytes()[0])
+
+		var unmarshalled storagemarket.StorageAsk
+		err = unmarshalled.UnmarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, collateralAsk, unmarshalled)
+	})
+
+	t.Run("with price tiers", func(t *testing.T) {
+		tieredAsk := ask
+		tieredAsk.MinProviderCollateral = abi.NewTokenAmount(0)
+		tieredAsk.MaxProviderCollateral = abi.NewTokenAmount(0)
+		tieredAsk.PriceTiers = []storagemarket.PriceTier{{MinPieceSize: 1 << 10, MinDuration: 1000, Price: abi.NewTokenAmount(50)}}
+		buf := new(bytes.Buffer)
+		err := tieredAsk.MarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, byte(0x8a), buf.Bytes()[0])
+
+		var unmarshalled storagemarket.StorageAsk
+		err = unmarshalled.UnmarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, tieredAsk, unmarshalled)
+	})
+
+	t.Run("wrong number of fields", func(t *testing.T) {
+		var unmarshalled storagemarket.StorageAsk
+		err := unmarshalled.UnmarshalCBOR(bytes.NewReader([]byte{0x86}))
+		require.EqualError(t, err, "cbor input had wrong number of fields")
+	})
+}
+
+func TestMinerDealMarshalUnmarshal(t *testing.T) {
+	deal := makeTestMinerDeal(t)
+
+	// deal stored before PublishIndex and the approval flags were added
+	original, err := hex.DecodeString("8d828ad82a5825000155122034235a2c502e3919d3f00af5dabb87cb58aef4566b10631f2a5db94950ebffbd190400f455024716b023b7fe84b6e7dcda303c3d754b1a8ff2fc5502c0d06605cef612c0e217c6364c5d056c480634e30a144200014200024200034a027369676e6174757265d82a582500015512204813494d137e1631bba301d5acab6e7bb7aa74ce1185d456565ef51d737677b2f6d82a582500015512204813494d137e1631bba301d5acab6e7bb7aa74ce1185d456565ef51d737677b2656d696e657266636c69656e7406657069656365686d65746164617461f4676d6573736167658469677261706873796e63d82a582500015512204813494d137e1631bba301d5acab6e7bb7aa74ce1185d456565ef51d737677b2f60005")
+	require.NoError(t, err)
+
+	t.Run("reads the original encoding", func(t *testing.T) {
+		var unmarshalled storagemarket.MinerDeal
+		err := unmarshalled.UnmarshalCBOR(bytes.NewReader(original))
+		require.NoError(t, err)
+		require.Equal(t, deal, unmarshalled)
+	})
+
+	t.Run("without the new fields, matches the original encoding", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		err := deal.MarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, original, buf.Bytes())
+	})
+
+	t.Run("with the new fields", func(t *testing.T) {
+		approved := deal
+		approved.PublishIndex = 2
+		approved.Approved = true
+		buf := new(bytes.Buffer)
+		err := approved.MarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, byte(0x90), buf.Bytes()[0])
+
+		var unmarshalled storagemarket.MinerDeal
+		err = unmarshalled.UnmarshalCBOR(buf)
+		require.NoError(t, err)
+		require.Equal(t, approved, unmarshalled)
+	})
+}
+
+func makeTestMinerDeal(t *testing.T) storagemarket.MinerDeal {
+	root, err := cid.Decode("bafkreicicneu2e36cyy3xiyb2wwkw3t3w6vhjtqrqxkfmvs66uoxg5txwi")
+	require.NoError(t, err)
+	pieceCid, err := cid.Decode("bafkreibuenncyubohem5h4ak6xnlxb6llcxpivtlcbrr6ks5xfevb277xu")
+	require.NoError(t, err)
+	publishCid := root
+	return storagemarket.MinerDeal{
+		ClientDealProposal: market.ClientDealProposal{
+			Proposal: market.DealProposal{
+				PieceCID:             pieceCid,
+				PieceSize:            1024,
+				Client:               address.TestAddress,
+				Provider:             address.TestAddress2,
+				StartEpoch:           10,
+				EndEpoch:             20,
+				StoragePricePerEpoch: abi.NewTokenAmount(1),
+				ProviderCollateral:   abi.NewTokenAmount(2),
+				ClientCollateral:     abi.NewTokenAmount(3),
+			},
+			ClientSignature: crypto.Signature{Type: crypto.SigTypeBLS, Data: []byte("signature")},
+		},
+		ProposalCid:  root,
+		PublishCid:   &publishCid,
+		Miner:        peer.ID("miner"),
+		Client:       peer.ID("client"),
+		State:        storagemarket.StorageDealActive,
+		PiecePath:    filestore.Path("piece"),
+		MetadataPath: filestore.Path("metadata"),
+		Message:      "message",
+		Ref: &storagemarket.DataRef{
+			TransferType: storagemarket.TTGraphsync,
+			Root:         root,
+		},
+		DealID: 5,
+	}
+}